@@ -0,0 +1,456 @@
+// Package app is the rollout command line, separate from main so it can be run end to end against a fake
+// environment and cluster.
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+	// Embedded so --timezone works in minimal images without a zoneinfo database
+	_ "time/tzdata"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// version is set at build time with -ldflags "-X github.com/tim-codez/devops-skills-assessment/cmd/app.version=..."
+var version = "dev"
+
+// Switch this to "nginx" if you have already ran "make deploy", that way you can see real resources get restarted
+// otherwise there will be no pods to restart with the name "database", not as cool of a demonstration.
+const defaultPodFilter = "database"
+
+// globalOptions are the flags shared by every subcommand.
+type globalOptions struct {
+	filter            string
+	selector          string
+	namespaces        []string
+	excludeNamespaces []string
+	exclude           []string
+	kubeconfig        string
+	kubeContext       string
+	logLevel          string
+	logFormat         string
+	timeZone          string
+	historyDir        string
+	configSource      string
+
+	env    Env
+	logger *logrus.Logger
+	// location is the --timezone one, nil when not set
+	location *time.Location
+}
+
+// Run runs the command line with args, excluding the program name, in env and returns the process exit code.
+func Run(args []string, env Env) int {
+	root := newRootCommand(env)
+	root.SetArgs(args)
+	if err := root.Execute(); err != nil {
+		fmt.Fprintln(env.Stderr, "Error:", err)
+		return exitCode(err)
+	}
+	return 0
+}
+
+func newRootCommand(env Env) *cobra.Command {
+	g := &globalOptions{env: env, logger: logrus.New()}
+	g.logger.SetOutput(env.Stderr)
+	g.logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	root := &cobra.Command{
+		Use:           "rollout",
+		Short:         "Gracefully restart Kubernetes workloads matching a filter",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return g.setup()
+		},
+	}
+	root.SetOut(env.Stdout)
+	root.SetErr(env.Stderr)
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&g.filter, "filter", defaultPodFilter, "Only handle workloads whose name contains this, set it to \"\" to select by --selector alone")
+	flags.StringSliceVar(&g.exclude, "exclude", nil, "Skip workloads whose name matches this glob (e.g. nginx-canary or *-canary) even though they match --filter, repeatable")
+	flags.StringVarP(&g.selector, "selector", "l", "", "Only handle workloads matching this label selector, e.g. app.kubernetes.io/part-of=payments, combined with --filter")
+	flags.StringSliceVarP(&g.namespaces, "namespace", "n", nil, "Only handle workloads in namespaces matching this glob (e.g. team-*), repeatable, defaults to every namespace except kube-system")
+	flags.StringSliceVar(&g.excludeNamespaces, "exclude-namespace", nil, "Skip namespaces matching this glob, repeatable, kube-system is always skipped unless named by --namespace")
+	flags.StringVar(&g.kubeconfig, "kubeconfig", "", "Path to the kubeconfig, defaults to the files listed in $KUBECONFIG merged like kubectl does, or ~/.kube/config")
+	flags.StringVar(&g.kubeContext, "context", "", "Kubeconfig context to use, defaults to the current context")
+	flags.StringVar(&g.configSource, "config-source", string(ConfigSourceAuto), "Where to load the cluster connection from: kubeconfig, in-cluster (the pod's service account) or auto (the kubeconfig, or in-cluster when running in a pod without one)")
+	flags.StringVar(&g.logLevel, "log-level", "info", "Log level: debug, info, warn or error")
+	flags.StringVar(&g.logFormat, "log-format", "text", "Log format: text, or json for one JSON object per line")
+	flags.StringVar(&g.timeZone, "timezone", "", "Time zone to render log and report timestamps in, e.g. Europe/Berlin or Local, the JSON report keeps the UTC start time next to it and restartedAt annotations stay UTC")
+	flags.StringVar(&g.historyDir, "history-dir", defaultHistoryDir(), "Directory the report of every restart run is kept in, empty disables it")
+
+	root.AddCommand(
+		newRestartCommand(g),
+		newStatusCommand(g),
+		newPlanCommand(g),
+		newValidatePlanCommand(g),
+		newVerifyCommand(g),
+		newComplianceCommand(g),
+		newHistoryCommand(g),
+		newUndoCommand(g),
+		newOperatorCommand(g),
+		newAuditWebhookCommand(g),
+		newReloadCommand(g),
+		newCampaignCommand(g),
+		&cobra.Command{
+			Use:   "version",
+			Short: "Print the version",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				fmt.Fprintln(cmd.OutOrStdout(), version)
+			},
+		},
+	)
+	return root
+}
+
+// clientOptions returns the rollout options every subcommand derives from the global flags.
+func (g *globalOptions) clientOptions() []rollout.Option {
+	return []rollout.Option{
+		rollout.WithNamespaces(g.namespaces...),
+		rollout.WithExcludedNamespaces(g.excludeNamespaces...),
+		rollout.WithExcludedNames(g.exclude...),
+		rollout.WithClock(g.env.Clock),
+		rollout.WithLabelSelector(g.selector),
+		rollout.WithTimeZone(g.location),
+	}
+}
+
+// formatTime renders t as RFC3339 in the --timezone time zone, as it is when that isn't set.
+func (g *globalOptions) formatTime(t time.Time) string {
+	return formatTimeIn(t, g.location)
+}
+
+// formatTimeIn renders t as RFC3339 in loc, as it is when loc is nil.
+func formatTimeIn(t time.Time, loc *time.Location) string {
+	if loc != nil {
+		t = t.In(loc)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// zonedFormatter renders the timestamps of log entries in location.
+type zonedFormatter struct {
+	logrus.Formatter
+	location *time.Location
+}
+
+func (f *zonedFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	entry.Time = entry.Time.In(f.location)
+	return f.Formatter.Format(entry)
+}
+
+// rolloutLogger adapts log to the logger of the rollout client, so the client logs through the CLI's
+// logrus logger with its level, format and time zone.
+func rolloutLogger(log logrus.FieldLogger) rollout.Logger {
+	return logrusLogger{FieldLogger: log}
+}
+
+// logrusLogger is a rollout.Logger logging through a logrus logger, which has the logging methods.
+type logrusLogger struct {
+	logrus.FieldLogger
+}
+
+func (l logrusLogger) WithField(key string, value any) rollout.Logger {
+	return logrusLogger{FieldLogger: l.FieldLogger.WithField(key, value)}
+}
+
+func (l logrusLogger) WithFields(fields rollout.Fields) rollout.Logger {
+	return logrusLogger{FieldLogger: l.FieldLogger.WithFields(logrus.Fields(fields))}
+}
+
+func (l logrusLogger) WithError(err error) rollout.Logger {
+	return logrusLogger{FieldLogger: l.FieldLogger.WithError(err)}
+}
+
+// setup validates the global flags and configures logging with them, before every command. Commands setting
+// global flags themselves, e.g. from a campaign, call it once they have.
+func (g *globalOptions) setup() error {
+	level, err := logrus.ParseLevel(g.logLevel)
+	if err != nil {
+		return fmt.Errorf("invalid --log-level: %w", err)
+	}
+	g.logger.SetLevel(level)
+	switch g.logFormat {
+	case "text":
+	case "json":
+		g.logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid --log-format %q, expected text or json", g.logFormat)
+	}
+	if g.timeZone != "" {
+		if g.location, err = time.LoadLocation(g.timeZone); err != nil {
+			return fmt.Errorf("invalid --timezone: %w", err)
+		}
+		g.logger.SetFormatter(&zonedFormatter{Formatter: g.logger.Formatter, location: g.location})
+	}
+
+	if _, err := labels.Parse(g.selector); err != nil {
+		return fmt.Errorf("invalid --selector: %w", err)
+	}
+	switch ConfigSource(g.configSource) {
+	case ConfigSourceAuto, ConfigSourceKubeconfig, ConfigSourceInCluster:
+	default:
+		return fmt.Errorf("invalid --config-source %q, expected %s, %s or %s", g.configSource, ConfigSourceAuto, ConfigSourceKubeconfig, ConfigSourceInCluster)
+	}
+	return nil
+}
+
+// connect connects to the cluster of kubeContext, the --context one or the current context when empty.
+func (g *globalOptions) connect(kubeContext string) (*Cluster, error) {
+	if kubeContext == "" {
+		kubeContext = g.kubeContext
+	}
+	return g.env.Connect(ConnectOptions{
+		Kubeconfig: g.kubeconfig,
+		Context:    kubeContext,
+		Source:     ConfigSource(g.configSource),
+	})
+}
+
+// connectAll connects to each of targets, kubeconfig contexts or kubeconfig files (connected to through their
+// current context), to every context of the kubeconfig with allContexts, otherwise to the --context one.
+func (g *globalOptions) connectAll(targets []string, allContexts bool) ([]*Cluster, error) {
+	if len(targets) > 0 {
+		return g.connectTargets(targets)
+	}
+	if !allContexts {
+		cluster, err := g.connect("")
+		if err != nil {
+			return nil, err
+		}
+		return []*Cluster{cluster}, nil
+	}
+
+	if ConfigSource(g.configSource) == ConfigSourceInCluster || g.env.Contexts == nil {
+		return nil, fmt.Errorf("--all-contexts needs a kubeconfig")
+	}
+	kubeContexts, err := g.env.Contexts(ConnectOptions{Kubeconfig: g.kubeconfig, Source: ConfigSource(g.configSource)})
+	if err != nil {
+		return nil, err
+	}
+	if len(kubeContexts) == 0 {
+		return nil, fmt.Errorf("the kubeconfig has no contexts")
+	}
+
+	clusters := make([]*Cluster, 0, len(kubeContexts))
+	for _, kubeContext := range kubeContexts {
+		cluster, err := g.connect(kubeContext)
+		if err != nil {
+			return nil, fmt.Errorf("context %s: %w", kubeContext, err)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// connectTargets connects to each of targets, see connectAll. A target is a kubeconfig file when it names one,
+// clusters of several files are told apart by their path when their current contexts share a name.
+func (g *globalOptions) connectTargets(targets []string) ([]*Cluster, error) {
+	clusters := make([]*Cluster, 0, len(targets))
+	names := map[string]bool{}
+	for _, target := range targets {
+		opts := ConnectOptions{Kubeconfig: g.kubeconfig, Context: target, Source: ConfigSource(g.configSource)}
+		isFile := false
+		if info, err := g.env.FS.Stat(target); err == nil && !info.IsDir() {
+			opts.Kubeconfig, opts.Context, isFile = target, "", true
+		}
+
+		cluster, err := g.env.Connect(opts)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", target, err)
+		}
+		if isFile && names[cluster.Name] {
+			cluster.Name = target
+		}
+		if names[cluster.Name] {
+			return nil, fmt.Errorf("cluster %s is given more than once", cluster.Name)
+		}
+		names[cluster.Name] = true
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// loadingRules returns where the kubeconfig is loaded from, like kubectl: the explicit path if set, then
+// every file listed in $KUBECONFIG (separated by the OS path list separator, ':' or ';' on Windows) merged
+// in order, then the recommended .kube/config in the home directory (see homeDir). Unlike clientcmd's
+// defaults, everything is resolved with getenv and fsys rather than from the process environment.
+func loadingRules(getenv func(string) string, fsys FS, explicit string) *clientcmd.ClientConfigLoadingRules {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = explicit
+
+	home := homeDir(runtime.GOOS, getenv, fsys)
+	recommended := filepath.Join(home, clientcmd.RecommendedHomeDir, clientcmd.RecommendedFileName)
+	rules.MigrationRules = nil
+	if home != "" {
+		// The file kubectl used before .kube/config is still moved there, as kubectl does
+		rules.MigrationRules = map[string]string{recommended: filepath.Join(home, clientcmd.RecommendedHomeDir, ".kubeconfig")}
+	}
+
+	switch envKubeConfig := getenv(clientcmd.RecommendedConfigPathEnvVar); {
+	case envKubeConfig != "":
+		rules.Precedence = filepath.SplitList(envKubeConfig)
+		rules.WarnIfAllMissing = true
+	case home == "":
+		// Without a home directory, e.g. in a container without HOME, the recommended file would be
+		// looked up relative to the working directory
+		rules.Precedence = nil
+	default:
+		rules.Precedence = []string{recommended}
+		rules.WarnIfAllMissing = false
+	}
+	return rules
+}
+
+// homeDir returns the home directory on goos the same way client-go's homedir.HomeDir does, resolved with
+// getenv and fsys. That is HOME, except on Windows, where the first of HOME, HOMEDRIVE and HOMEPATH, and
+// USERPROFILE holding a .kube/config is picked, else the first of HOME, USERPROFILE, and HOMEDRIVE and
+// HOMEPATH that is a writable directory, exists or at least is set, in that order. It is empty when none is
+// set.
+func homeDir(goos string, getenv func(string) string, fsys FS) string {
+	if goos != "windows" {
+		return getenv("HOME")
+	}
+
+	home, userProfile := getenv("HOME"), getenv("USERPROFILE")
+	var homeDriveHomePath string
+	if drive, path := getenv("HOMEDRIVE"), getenv("HOMEPATH"); drive != "" && path != "" {
+		homeDriveHomePath = drive + path
+	}
+
+	for _, dir := range []string{home, homeDriveHomePath, userProfile} {
+		if dir == "" {
+			continue
+		}
+		if _, err := fsys.Stat(filepath.Join(dir, clientcmd.RecommendedHomeDir, clientcmd.RecommendedFileName)); err == nil {
+			return dir
+		}
+	}
+
+	var firstSet, firstExisting string
+	// USERPROFILE is preferred over HOMEDRIVE and HOMEPATH here, as other tools writing credentials do
+	for _, dir := range []string{home, userProfile, homeDriveHomePath} {
+		if dir == "" {
+			continue
+		}
+		if firstSet == "" {
+			firstSet = dir
+		}
+		info, err := fsys.Stat(dir)
+		if err != nil {
+			continue
+		}
+		if firstExisting == "" {
+			firstExisting = dir
+		}
+		if info.IsDir() && info.Mode().Perm()&0o200 != 0 {
+			return dir
+		}
+	}
+	if firstExisting != "" {
+		return firstExisting
+	}
+	return firstSet
+}
+
+// inClusterName names the cluster in reports when connected through the pod's service account.
+const inClusterName = "in-cluster"
+
+// loadConfig builds the rest config for opts and names the cluster it points at.
+func loadConfig(getenv func(string) string, fsys FS, opts ConnectOptions) (*rest.Config, string, error) {
+	rules := loadingRules(getenv, fsys, opts.Kubeconfig)
+	if configSource(getenv, fsys, opts, rules) == ConfigSourceInCluster {
+		if opts.Context != "" {
+			return nil, "", fmt.Errorf("kubeconfig context %s can't be used with in-cluster config", opts.Context)
+		}
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load in-cluster config: %w", err)
+		}
+		return config, inClusterName, nil
+	}
+
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: opts.Context})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build kubernetes config: %w", err)
+	}
+	return config, clusterName(clientConfig, opts.Context, config), nil
+}
+
+// configSource resolves the source of opts, picking the pod's service account for ConfigSourceAuto when
+// running in a cluster, as told by getenv, without any of the kubeconfig files rules load existing in fsys.
+func configSource(getenv func(string) string, fsys FS, opts ConnectOptions, rules *clientcmd.ClientConfigLoadingRules) ConfigSource {
+	if opts.Source != ConfigSourceAuto {
+		return opts.Source
+	}
+	// An explicit kubeconfig or context always means the kubeconfig, even when it turns out to be missing
+	if opts.Kubeconfig != "" || opts.Context != "" || getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return ConfigSourceKubeconfig
+	}
+	exists := func(path string) bool {
+		_, err := fsys.Stat(path)
+		return path != "" && err == nil
+	}
+	if slices.ContainsFunc(rules.GetLoadingPrecedence(), exists) {
+		return ConfigSourceKubeconfig
+	}
+	return ConfigSourceInCluster
+}
+
+// clusterName names the cluster a config points at for reports, the kubeconfig context name when it can be
+// resolved, falling back to the API server address.
+func clusterName(clientConfig clientcmd.ClientConfig, kubeContext string, config *rest.Config) string {
+	if kubeContext != "" {
+		return kubeContext
+	}
+
+	if raw, err := clientConfig.RawConfig(); err == nil && raw.CurrentContext != "" {
+		return raw.CurrentContext
+	}
+	return config.Host
+}
+
+// currentUser returns the local username, used as the default restart initiator.
+func currentUser(env Env) string {
+	if env.Username == nil {
+		return ""
+	}
+	return env.Username()
+}
+
+// parseOrder validates the value of an --order flag.
+func parseOrder(value string) (rollout.Order, error) {
+	switch order := rollout.Order(value); order {
+	case rollout.OrderRisk, rollout.OrderName:
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown order %q, expected %s or %s", value, rollout.OrderRisk, rollout.OrderName)
+}
+
+// namespaceOrderUsage is the help of the --namespace-order flags.
+const namespaceOrderUsage = "Go through namespaces one after the other, oldest or newest (by creation time) first, or priority (highest " +
+	rollout.NamespacePriorityLabel + " label first), instead of ordering the workloads of every namespace together"
+
+// parseNamespaceOrder validates the value of a --namespace-order flag, empty leaving namespaces unordered.
+func parseNamespaceOrder(value string) (rollout.NamespaceOrder, error) {
+	switch order := rollout.NamespaceOrder(value); order {
+	case "", rollout.NamespaceOrderOldest, rollout.NamespaceOrderNewest, rollout.NamespaceOrderPriority:
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown namespace order %q, expected %s, %s or %s", value,
+		rollout.NamespaceOrderOldest, rollout.NamespaceOrderNewest, rollout.NamespaceOrderPriority)
+}
@@ -0,0 +1,296 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// writeKubeconfig writes a kubeconfig with a context for each of contexts to path.
+func writeKubeconfig(t *testing.T, path string, contexts ...string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("apiVersion: v1\nkind: Config\nclusters:\n- name: test\n  cluster:\n    server: https://127.0.0.1:6443\nusers:\n- name: test\n  user: {}\ncontexts:\n")
+	for _, name := range contexts {
+		b.WriteString("- name: " + name + "\n  context:\n    cluster: test\n    user: test\n")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadingRules(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	writeKubeconfig(t, filepath.Join(home, ".kube", "config"), "home")
+	writeKubeconfig(t, filepath.Join(dir, "dev"), "dev", "shared")
+	writeKubeconfig(t, filepath.Join(dir, "prod"), "prod", "shared")
+	kubeconfigs := filepath.Join(dir, "dev") + string(filepath.ListSeparator) + filepath.Join(dir, "prod")
+
+	tests := []struct {
+		name           string
+		vars           map[string]string
+		wantPrecedence []string
+		wantContexts   []string
+	}{
+		{
+			name: "no home directory",
+			vars: map[string]string{},
+		},
+		{
+			name:           "home",
+			vars:           map[string]string{"HOME": home},
+			wantPrecedence: []string{filepath.Join(home, ".kube", "config")},
+			wantContexts:   []string{"home"},
+		},
+		{
+			name:           "kubeconfig list merged over home",
+			vars:           map[string]string{"HOME": home, "KUBECONFIG": kubeconfigs},
+			wantPrecedence: []string{filepath.Join(dir, "dev"), filepath.Join(dir, "prod")},
+			wantContexts:   []string{"dev", "prod", "shared"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.vars[key] }
+
+			rules := loadingRules(getenv, osFS{}, "")
+			if !slices.Equal(rules.Precedence, tt.wantPrecedence) {
+				t.Errorf("got precedence %v, want %v", rules.Precedence, tt.wantPrecedence)
+			}
+			for dst, src := range rules.MigrationRules {
+				if home := homeDir(runtime.GOOS, getenv, osFS{}); !strings.HasPrefix(dst, home) || !strings.HasPrefix(src, home) {
+					t.Errorf("got migration of %s to %s outside of the home directory %q", src, dst, home)
+				}
+			}
+			if tt.wantContexts == nil {
+				return
+			}
+			contexts, err := listContexts(getenv, osFS{}, ConnectOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(contexts, tt.wantContexts) {
+				t.Errorf("got contexts %v, want %v", contexts, tt.wantContexts)
+			}
+		})
+	}
+}
+
+func TestHomeDir(t *testing.T) {
+	const (
+		home    = `C:\home`
+		profile = `C:\Users\alice`
+		drive   = `D:`
+		path    = `\alice`
+	)
+	writable := &fstest.MapFile{Mode: fs.ModeDir | 0o755}
+	readOnly := &fstest.MapFile{Mode: fs.ModeDir | 0o555}
+	kubeconfig := func(dir string) string { return filepath.Join(dir, ".kube", "config") }
+	windows := map[string]string{"HOME": home, "USERPROFILE": profile, "HOMEDRIVE": drive, "HOMEPATH": path}
+
+	tests := []struct {
+		name  string
+		goos  string
+		vars  map[string]string
+		files fstest.MapFS
+		want  string
+	}{
+		{
+			name: "home",
+			goos: "linux",
+			vars: map[string]string{"HOME": "/home/alice", "USERPROFILE": profile},
+			want: "/home/alice",
+		},
+		{
+			name: "windows variables ignored on linux",
+			goos: "linux",
+			vars: map[string]string{"USERPROFILE": profile, "HOMEDRIVE": drive, "HOMEPATH": path},
+		},
+		{
+			name:  "home with a kubeconfig",
+			goos:  "windows",
+			vars:  windows,
+			files: fstest.MapFS{kubeconfig(home): {}, kubeconfig(profile): {}},
+			want:  home,
+		},
+		{
+			name:  "home drive and path with a kubeconfig before user profile",
+			goos:  "windows",
+			vars:  windows,
+			files: fstest.MapFS{kubeconfig(drive + path): {}, kubeconfig(profile): {}},
+			want:  drive + path,
+		},
+		{
+			name:  "user profile with a kubeconfig",
+			goos:  "windows",
+			vars:  windows,
+			files: fstest.MapFS{home: writable, kubeconfig(profile): {}},
+			want:  profile,
+		},
+		{
+			name:  "user profile writable before home drive and path",
+			goos:  "windows",
+			vars:  windows,
+			files: fstest.MapFS{drive + path: writable, profile: writable},
+			want:  profile,
+		},
+		{
+			name:  "first writable",
+			goos:  "windows",
+			vars:  windows,
+			files: fstest.MapFS{home: readOnly, profile: readOnly, drive + path: writable},
+			want:  drive + path,
+		},
+		{
+			name:  "first existing",
+			goos:  "windows",
+			vars:  windows,
+			files: fstest.MapFS{profile: readOnly, drive + path: readOnly},
+			want:  profile,
+		},
+		{
+			name: "first set",
+			goos: "windows",
+			vars: map[string]string{"HOMEDRIVE": drive, "HOMEPATH": path, "USERPROFILE": profile},
+			want: profile,
+		},
+		{
+			name: "home drive without home path",
+			goos: "windows",
+			vars: map[string]string{"HOMEDRIVE": drive},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.files == nil {
+				tt.files = fstest.MapFS{}
+			}
+			getenv := func(key string) string { return tt.vars[key] }
+			if got := homeDir(tt.goos, getenv, memFS{tt.files}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigSource(t *testing.T) {
+	inCluster := map[string]string{"HOME": "home", "KUBERNETES_SERVICE_HOST": "10.0.0.1"}
+	kubeconfig := fstest.MapFS{"home/.kube/config": {}}
+
+	tests := []struct {
+		name  string
+		vars  map[string]string
+		files fstest.MapFS
+		opts  ConnectOptions
+		want  ConfigSource
+	}{
+		{
+			name: "outside a cluster",
+			vars: map[string]string{"HOME": "home"},
+			opts: ConnectOptions{Source: ConfigSourceAuto},
+			want: ConfigSourceKubeconfig,
+		},
+		{
+			name: "in a cluster without a kubeconfig",
+			vars: inCluster,
+			opts: ConnectOptions{Source: ConfigSourceAuto},
+			want: ConfigSourceInCluster,
+		},
+		{
+			name:  "in a cluster with a kubeconfig",
+			vars:  inCluster,
+			files: kubeconfig,
+			opts:  ConnectOptions{Source: ConfigSourceAuto},
+			want:  ConfigSourceKubeconfig,
+		},
+		{
+			name:  "in a cluster with a kubeconfig listed in KUBECONFIG",
+			vars:  map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "KUBECONFIG": "missing" + string(filepath.ListSeparator) + "home/.kube/config"},
+			files: kubeconfig,
+			opts:  ConnectOptions{Source: ConfigSourceAuto},
+			want:  ConfigSourceKubeconfig,
+		},
+		{
+			name: "in a cluster with a context",
+			vars: inCluster,
+			opts: ConnectOptions{Source: ConfigSourceAuto, Context: "prod"},
+			want: ConfigSourceKubeconfig,
+		},
+		{
+			name: "in-cluster forced",
+			vars: map[string]string{"HOME": "home"},
+			opts: ConnectOptions{Source: ConfigSourceInCluster},
+			want: ConfigSourceInCluster,
+		},
+		{
+			name: "kubeconfig forced",
+			vars: inCluster,
+			opts: ConnectOptions{Source: ConfigSourceKubeconfig},
+			want: ConfigSourceKubeconfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.files == nil {
+				tt.files = fstest.MapFS{}
+			}
+			getenv := func(key string) string { return tt.vars[key] }
+			fsys := memFS{tt.files}
+			rules := loadingRules(getenv, fsys, tt.opts.Kubeconfig)
+			if got := configSource(getenv, fsys, tt.opts, rules); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitiatorDefault(t *testing.T) {
+	env := newTestEnv()
+	restart, _, err := newRootCommand(env.Env).Find([]string{"restart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restart.Flags().Lookup("initiator").DefValue; got != "alice" {
+		t.Errorf("got --initiator defaulting to %q, want the user running the command", got)
+	}
+
+	env.Username = nil
+	restart, _, err = newRootCommand(env.Env).Find([]string{"restart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restart.Flags().Lookup("initiator").DefValue; got != "" {
+		t.Errorf("got --initiator defaulting to %q without a user, want none", got)
+	}
+}
+
+func TestRolloutLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &out
+	logger.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+
+	var log rollout.Logger = rolloutLogger(logger.WithField("component", "rollout"))
+	log.WithFields(rollout.Fields{"name": "web"}).WithError(errors.New("boom")).Warnf("Failed to restart %s", "web")
+	log.Debug("not logged at info level")
+
+	want := "level=warning msg=\"Failed to restart web\" component=rollout error=boom name=web\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// auditWebhookOptions are the flags of the audit-webhook subcommand.
+type auditWebhookOptions struct {
+	addr          string
+	certFile      string
+	keyFile       string
+	fieldManagers []string
+}
+
+// newAuditWebhookCommand returns the "audit-webhook" subcommand, serving an admission webhook that records
+// restarts made outside this tool in the run history.
+func newAuditWebhookCommand(g *globalOptions) *cobra.Command {
+	o := &auditWebhookOptions{}
+	cmd := &cobra.Command{
+		Use:   "audit-webhook",
+		Short: "Serve an admission webhook recording restarts made by anyone in the run history",
+		Long: "Serve an admission webhook on /audit until interrupted (see manifests/audit-webhook). Every update\n" +
+			"changing the restartedAt pod template annotation of a Deployment, StatefulSet or DaemonSet, e.g. by\n" +
+			"kubectl rollout restart or another tool, is kept in --history-dir as a run of its own with the user\n" +
+			"who made it, next to the runs of this tool, so history and undo cover every restart. Requests are\n" +
+			"always allowed, the webhook only observes them.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuditWebhook(g, o)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&o.addr, "addr", ":8443", "Address to serve the webhook at")
+	flags.StringVar(&o.certFile, "tls-cert-file", "", "TLS certificate to serve the webhook with, the API server only calls webhooks over HTTPS")
+	flags.StringVar(&o.keyFile, "tls-key-file", "", "Private key of --tls-cert-file")
+	flags.StringSliceVar(&o.fieldManagers, "own-field-manager", []string{rollout.DefaultFieldManager}, "Field managers whose restarts aren't recorded, as the runs making them already are, repeatable")
+	return cmd
+}
+
+func runAuditWebhook(g *globalOptions, o *auditWebhookOptions) error {
+	if g.historyDir == "" {
+		return fmt.Errorf("--history-dir is needed to record restarts in")
+	}
+	if o.certFile == "" || o.keyFile == "" {
+		return fmt.Errorf("--tls-cert-file and --tls-key-file are needed to serve the webhook")
+	}
+	componentLogger := g.logger.WithField("component", "audit-webhook")
+
+	listener, err := net.Listen("tcp", o.addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/audit", auditHandler(g.env, g.historyDir, o.fieldManagers, componentLogger))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := g.env.Context()
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	componentLogger.WithField("addr", listener.Addr().String()).Info("Serving the audit webhook on /audit")
+	if err := server.ServeTLS(listener, o.certFile, o.keyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("audit webhook failed: %w", err)
+	}
+	return nil
+}
+
+// auditHandler answers AdmissionReviews, keeping the restarts they make in dir, see rollout.ExternalRestart.
+// Every request is allowed, a restart that can't be recorded is logged rather than blocking the change.
+func auditHandler(env Env, dir string, ownFieldManagers []string, log logrus.FieldLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := &admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+			http.Error(w, "expected an AdmissionReview request", http.StatusBadRequest)
+			return
+		}
+		req := review.Request
+		requestLogger := log.WithFields(logrus.Fields{
+			"kind":      req.Kind.Kind,
+			"namespace": req.Namespace,
+			"name":      req.Name,
+			"user":      req.UserInfo.Username,
+		})
+
+		report, err := rollout.ExternalRestart(req, env.Clock.Now(), ownFieldManagers)
+		switch {
+		case err != nil:
+			requestLogger.WithError(err).Warn("Failed to read the admission request")
+		case report != nil:
+			if path, err := saveRun(env.FS, dir, report); err != nil {
+				requestLogger.WithError(err).Error("Failed to record restart")
+			} else {
+				requestLogger.WithField("path", path).Info("Recorded restart")
+			}
+		}
+
+		review.Request = nil
+		review.Response = &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(review)
+	})
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAuditHandler(t *testing.T) {
+	template := func(restartedAt string) []byte {
+		data := `{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":"` + restartedAt + `"}}}}}`
+		return []byte(data)
+	}
+	review := func(fieldManager string) *admissionv1.AdmissionReview {
+		return &admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       "7d3f",
+				Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+				Namespace: "default",
+				Name:      "web",
+				Operation: admissionv1.Update,
+				UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+				Object:    runtime.RawExtension{Raw: template("2024-05-06T07:08:09Z")},
+				OldObject: runtime.RawExtension{Raw: template("2024-01-01T00:00:00Z")},
+				Options:   runtime.RawExtension{Raw: []byte(`{"fieldManager":"` + fieldManager + `"}`)},
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		fieldManager string
+		wantRecorded bool
+	}{
+		{name: "kubectl restart", fieldManager: "kubectl-rollout", wantRecorded: true},
+		{name: "own restart", fieldManager: rollout.DefaultFieldManager},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv()
+			server := httptest.NewServer(auditHandler(env.Env, "history", []string{rollout.DefaultFieldManager}, logrus.New()))
+			defer server.Close()
+
+			body, err := json.Marshal(review(tt.fieldManager))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := &admissionv1.AdmissionReview{}
+			if err := json.Unmarshal(data, got); err != nil {
+				t.Fatalf("got response %q: %v", data, err)
+			}
+			if got.Response == nil || !got.Response.Allowed || got.Response.UID != "7d3f" {
+				t.Errorf("got response %s, want request 7d3f allowed", data)
+			}
+
+			report, err := lastRun(env.FS, "history")
+			if !tt.wantRecorded {
+				if err == nil {
+					t.Errorf("got run %s recorded, want none", report.RunID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got %v, want the restart recorded", err)
+			}
+			if report.Initiator != "alice" || len(report.Results) != 1 || report.Results[0].Name != "web" {
+				t.Errorf("got run by %q restarting %+v, want web restarted by alice", report.Initiator, report.Results)
+			}
+		})
+	}
+}
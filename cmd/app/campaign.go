@@ -0,0 +1,387 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	"sigs.k8s.io/yaml"
+)
+
+// campaign is a named, reusable restart procedure, e.g. the restart run after every OpenSSL CVE. Flags maps
+// restart flag names (without the leading dashes) to their values, so a campaign can codify anything the
+// command line can express: filters, strategy, timeouts, reports and notifications.
+//
+// An example campaigns file:
+//
+//	openssl-cve:
+//	  description: Cycle every database after an OpenSSL patch
+//	  flags:
+//	    reason: OpenSSL CVE patch rollout
+//	    timeout: 2h
+//	    owner-webhooks: owners.json
+//
+// A campaign too large for one maintenance window is spread over several runs with PerRun, each run
+// restarting the next share of the workloads it hasn't restarted yet, e.g. 20% per nightly run:
+//
+//	fleet-refresh:
+//	  description: Restart the whole fleet over a week of nights
+//	  perRun: 20%
+//	  flags:
+//	    filter: ""
+//	    wait: 10m
+//
+// A campaign that isn't urgent is deferred to off-peak hours with OffPeak, its runs wait for one of the
+// windows unless run with --urgent:
+//
+//	cache-refresh:
+//	  offPeak: ["Mon-Fri 22:00-06:00", "Sat,Sun 00:00-24:00"]
+type campaign struct {
+	Description string            `json:"description"`
+	Flags       map[string]string `json:"flags"`
+	// PerRun spreads the campaign over runs restarting this share of its workloads each, a percentage or a
+	// number of workloads, its progress is kept in the campaign state directory
+	PerRun string `json:"perRun,omitempty"`
+	// CompleteAt is the share of its workloads a spread campaign is complete at, 100% when empty
+	CompleteAt string `json:"completeAt,omitempty"`
+	// OffPeak are the windows the campaign's runs start in, as given to --off-peak
+	OffPeak []string `json:"offPeak,omitempty"`
+}
+
+// loadCampaign reads the campaign called name from the YAML campaigns file at path.
+func loadCampaign(fsys FS, path, name string) (campaign, error) {
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return campaign{}, fmt.Errorf("failed to read campaigns: %w", err)
+	}
+
+	campaigns := map[string]campaign{}
+	if err := yaml.UnmarshalStrict(data, &campaigns); err != nil {
+		return campaign{}, fmt.Errorf("failed to parse campaigns: %w", err)
+	}
+
+	c, ok := campaigns[name]
+	if !ok {
+		return campaign{}, fmt.Errorf("campaign %q not found in %s", name, path)
+	}
+	return c, nil
+}
+
+// applyCampaign sets every flag of the campaign that was not given explicitly on the command line, so a
+// campaign can still be adjusted for a single run.
+func applyCampaign(fs *pflag.FlagSet, c campaign) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *pflag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for name, value := range c.Flags {
+		if name == "campaign" || name == "campaigns" {
+			return fmt.Errorf("campaigns can't set --%s", name)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("campaign flag %s: %w", name, err)
+		}
+	}
+
+	if len(c.OffPeak) > 0 {
+		if _, ok := c.Flags["off-peak"]; ok {
+			return fmt.Errorf("campaigns set their off-peak windows with offPeak, not the off-peak flag")
+		}
+		if !explicit["off-peak"] {
+			for _, window := range c.OffPeak {
+				if err := fs.Set("off-peak", window); err != nil {
+					return fmt.Errorf("campaign offPeak: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// defaultCampaignStateDir is where the progress of spread campaigns is kept unless --campaign-state-dir says
+// otherwise.
+func defaultCampaignStateDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "rollout", "campaigns")
+}
+
+// campaignState is the progress of a spread campaign across its runs, kept as JSON in the campaign state
+// directory so every run carries on where the previous one stopped.
+type campaignState struct {
+	Name    string
+	Started time.Time
+	// Completed is when the campaign met its completion criteria, nil while it is in progress
+	Completed *time.Time `json:",omitempty"`
+	// Total is how many workloads the campaign matched at its last run, Pending how many of them it has yet
+	// to restart
+	Total   int
+	Pending int
+	// Restarted are the workloads the campaign has restarted, as kind/namespace/name
+	Restarted []string
+	Runs      []campaignRun
+}
+
+// campaignRun is a run of a spread campaign.
+type campaignRun struct {
+	RunID     string
+	StartTime time.Time
+	Restarted int
+	Failed    int
+}
+
+// campaignKey identifies a workload in a campaign's state.
+func campaignKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// campaignStatePath is where the state of the campaign called name is kept in dir.
+func campaignStatePath(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+// loadCampaignState reads the state of the campaign called name from dir, a campaign without one hasn't
+// run yet.
+func loadCampaignState(fsys FS, dir, name string) (*campaignState, error) {
+	data, err := fsys.ReadFile(campaignStatePath(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &campaignState{Name: name}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read campaign state: %w", err)
+	}
+
+	state := &campaignState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse campaign state of %s: %w", name, err)
+	}
+	return state, nil
+}
+
+// saveCampaignState writes state to dir.
+func saveCampaignState(fsys FS, dir string, state *campaignState) error {
+	if err := fsys.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create campaign state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsys.WriteFile(campaignStatePath(dir, state.Name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write campaign state: %w", err)
+	}
+	return nil
+}
+
+// parseShare returns how many of total workloads value stands for, a percentage (e.g. 20%), rounded up, or
+// a number of workloads.
+func parseShare(value string, total int) (int, error) {
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return 0, fmt.Errorf("invalid percentage %q, expected more than 0%% and at most 100%%", value)
+		}
+		return int(math.Ceil(float64(total) * p / 100)), nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid share %q, expected a percentage or a number of workloads", value)
+	}
+	return n, nil
+}
+
+// validateSpread checks the shares of spread campaign c.
+func validateSpread(c campaign) error {
+	if _, err := parseShare(c.PerRun, 100); err != nil {
+		return fmt.Errorf("invalid campaign perRun: %w", err)
+	}
+	if _, err := parseShare(c.completeAt(), 100); err != nil {
+		return fmt.Errorf("invalid campaign completeAt: %w", err)
+	}
+	return nil
+}
+
+// completeAt returns the share of its workloads the campaign is complete at.
+func (c campaign) completeAt() string {
+	if c.CompleteAt == "" {
+		return "100%"
+	}
+	return c.CompleteAt
+}
+
+// complete reports whether the campaign has met its completion criteria by state.
+func (c campaign) complete(state *campaignState) bool {
+	want, _ := parseShare(c.completeAt(), state.Total)
+	return state.Total-state.Pending >= min(want, state.Total)
+}
+
+// nextCampaignPlan updates state from the workloads the campaign matches now and returns the plan of its
+// next run: the next share of them, in run order, it hasn't restarted yet, nil once it is complete.
+func nextCampaignPlan(c campaign, state *campaignState, matched *rollout.RestartPlan, now time.Time) *rollout.RestartPlan {
+	if state.Started.IsZero() {
+		state.Started = now
+	}
+
+	var pending []rollout.PlannedRestart
+	for _, w := range matched.Workloads {
+		if !slices.Contains(state.Restarted, campaignKey(w.Kind, w.Namespace, w.Name)) {
+			pending = append(pending, w)
+		}
+	}
+	state.Total, state.Pending = len(matched.Workloads), len(pending)
+
+	if c.complete(state) {
+		if state.Completed == nil {
+			state.Completed = &now
+		}
+		return nil
+	}
+	perRun, _ := parseShare(c.PerRun, state.Total)
+	return &rollout.RestartPlan{Filter: matched.Filter, Workloads: pending[:min(max(perRun, 1), len(pending))]}
+}
+
+// recordCampaignRun adds the outcome of a run of the campaign to state. Workloads that failed stay pending,
+// the next run retries them first.
+func recordCampaignRun(c campaign, state *campaignState, report *rollout.Report, now time.Time) {
+	for _, r := range report.Results {
+		if r.Action != rollout.ActionRestarted {
+			continue
+		}
+		if key := campaignKey(r.Kind, r.Namespace, r.Name); !slices.Contains(state.Restarted, key) {
+			state.Restarted = append(state.Restarted, key)
+			state.Pending--
+		}
+	}
+	state.Runs = append(state.Runs, campaignRun{
+		RunID:     report.RunID,
+		StartTime: report.StartTime,
+		Restarted: report.Restarted,
+		Failed:    report.Failed,
+	})
+	if state.Completed == nil && c.complete(state) {
+		state.Completed = &now
+	}
+}
+
+// campaignPlan loads the state of spread campaign c and returns the plan of its next run, see
+// nextCampaignPlan, from the workloads opts match.
+func campaignPlan(ctx context.Context, g *globalOptions, cluster *Cluster, c campaign, name, dir string, opts []rollout.Option) (*campaignState, *rollout.RestartPlan, error) {
+	state, err := loadCampaignState(g.env.FS, dir, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	matched, err := rollout.Plan(ctx, cluster.Clientset, g.filter, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list the workloads of the campaign: %w", err)
+	}
+	return state, nextCampaignPlan(c, state, matched, g.env.Clock.Now()), nil
+}
+
+// logCampaignProgress logs how far along state is.
+func logCampaignProgress(log logrus.FieldLogger, state *campaignState) {
+	log.WithFields(logrus.Fields{
+		"campaign":  state.Name,
+		"restarted": state.Total - state.Pending,
+		"total":     state.Total,
+		"runs":      len(state.Runs),
+	}).Info("Campaign progress")
+}
+
+// newCampaignCommand returns the "campaign" subcommand, for following spread campaigns.
+func newCampaignCommand(g *globalOptions) *cobra.Command {
+	var stateDir string
+	cmd := &cobra.Command{
+		Use:   "campaign",
+		Short: "Follow campaigns spread over several runs",
+	}
+	cmd.PersistentFlags().StringVar(&stateDir, "campaign-state-dir", defaultCampaignStateDir(), "Directory the progress of campaigns spread over several runs is kept in")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status [campaign]",
+		Short: "Show the progress of spread campaigns, or the runs of one",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				return showCampaign(g.env, stateDir, args[0], g.location)
+			}
+			return listCampaigns(g.env, stateDir, g.location)
+		},
+	})
+	return cmd
+}
+
+func listCampaigns(env Env, dir string, loc *time.Location) error {
+	paths, err := env.FS.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	slices.Sort(paths)
+
+	tw := tabwriter.NewWriter(env.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CAMPAIGN\tSTARTED\tRUNS\tRESTARTED\tTOTAL\tPROGRESS\tSTATUS")
+	for _, path := range paths {
+		state, err := loadCampaignState(env.FS, dir, strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\n", state.Name, formatTimeIn(state.Started, loc), len(state.Runs),
+			state.Total-state.Pending, state.Total, campaignProgress(state), campaignStatus(state, loc))
+	}
+	return tw.Flush()
+}
+
+func showCampaign(env Env, dir, name string, loc *time.Location) error {
+	if _, err := env.FS.Stat(campaignStatePath(dir, name)); err != nil {
+		return fmt.Errorf("campaign %q has not run yet", name)
+	}
+	state, err := loadCampaignState(env.FS, dir, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.Stdout, "Campaign %s started %s, %d of %d workloads restarted (%s), %s\n\n", state.Name,
+		formatTimeIn(state.Started, loc), state.Total-state.Pending, state.Total, campaignProgress(state), campaignStatus(state, loc))
+	tw := tabwriter.NewWriter(env.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN\tSTARTED\tRESTARTED\tFAILED")
+	for _, run := range state.Runs {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", run.RunID, formatTimeIn(run.StartTime, loc), run.Restarted, run.Failed)
+	}
+	return tw.Flush()
+}
+
+// campaignProgress returns the share of its workloads the campaign has restarted.
+func campaignProgress(state *campaignState) string {
+	if state.Total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(state.Total-state.Pending)/float64(state.Total)*100)
+}
+
+// campaignStatus returns whether the campaign is complete.
+func campaignStatus(state *campaignState, loc *time.Location) string {
+	if state.Completed != nil {
+		return "complete since " + formatTimeIn(*state.Completed, loc)
+	}
+	return "in progress"
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCampaignSetsGlobalFlags(t *testing.T) {
+	labels := map[string]string{"app": "web"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		flags    string
+		args     []string
+		wantCode int
+		// wantStderr and notStderr are what the command's log output contains and doesn't
+		wantStderr string
+		notStderr  string
+	}{
+		{
+			name:       "log format",
+			flags:      "log-format: json",
+			wantStderr: `"msg":"Running campaign"`,
+		},
+		{
+			name:      "log level",
+			flags:     "log-level: warn",
+			notStderr: "Running campaign",
+		},
+		{
+			name:       "the command line wins",
+			flags:      "log-level: warn",
+			args:       []string{"--log-level", "info"},
+			wantStderr: "Running campaign",
+		},
+		{
+			name:       "invalid selector",
+			flags:      `selector: "app in (web"`,
+			wantCode:   1,
+			wantStderr: "invalid --selector",
+		},
+		{
+			name:       "invalid time zone",
+			flags:      "timezone: Mars/Olympus",
+			wantCode:   1,
+			wantStderr: "invalid --timezone",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, deployment)
+			env.fs.WriteFile("campaigns.yaml", []byte("nightly:\n  description: Nightly restart\n  flags:\n    "+tt.flags+"\n"), 0o644)
+
+			args := append([]string{"restart", "--campaign", "nightly", "--filter", "web", "--dry-run", "--server-check=false", "--access-check=false", "--history-dir", ""}, tt.args...)
+			env.run(t, tt.wantCode, args...)
+			if tt.wantStderr != "" && !strings.Contains(env.stderr.String(), tt.wantStderr) {
+				t.Errorf("got stderr\n%s\nwant it to contain %q", env.stderr, tt.wantStderr)
+			}
+			if tt.notStderr != "" && strings.Contains(env.stderr.String(), tt.notStderr) {
+				t.Errorf("got stderr\n%s\nwant it not to contain %q", env.stderr, tt.notStderr)
+			}
+		})
+	}
+}
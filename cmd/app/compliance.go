@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// complianceOptions are the flags of the compliance subcommand.
+type complianceOptions struct {
+	maxAge string
+	format string
+	output string
+}
+
+// newComplianceCommand returns the read-only "compliance" subcommand, reporting matched workloads whose pods
+// are older than a maximum age as CSV or JSON.
+func newComplianceCommand(g *globalOptions) *cobra.Command {
+	o := &complianceOptions{}
+	cmd := &cobra.Command{
+		Use:   "compliance",
+		Short: "Report matched workloads whose pods are older than a maximum age",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCompliance(g, o)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&o.maxAge, "max-age", "30d", "Maximum allowed pod age, as a Go duration or a number of days (e.g. 30d)")
+	flags.StringVar(&o.format, "format", "json", "Report format, json or csv")
+	flags.StringVar(&o.output, "output", "", "File to write the report to, defaults to stdout")
+	return cmd
+}
+
+func runCompliance(g *globalOptions, o *complianceOptions) error {
+	componentLogger := g.logger.WithField("component", "compliance")
+
+	age, err := parseAge(o.maxAge)
+	if err != nil {
+		return fmt.Errorf("invalid --max-age: %w", err)
+	}
+	if o.format != "json" && o.format != "csv" {
+		return fmt.Errorf("unsupported report format %q, expected json or csv", o.format)
+	}
+
+	cluster, err := g.connect("")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := g.env.Context()
+	defer stop()
+
+	report, err := rollout.NewRolloutClient(cluster.Clientset, g.filter, rolloutLogger(componentLogger), g.clientOptions()...).Compliance(ctx, age)
+	if err != nil {
+		return fmt.Errorf("compliance check failed: %w", err)
+	}
+
+	return writeOutput(g.env, o.output, func(w io.Writer) error {
+		if o.format == "csv" {
+			return report.WriteCSV(w)
+		}
+		return report.WriteJSON(w)
+	})
+}
+
+// parseAge extends time.ParseDuration with a day suffix, since compliance thresholds are usually in days.
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of days %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
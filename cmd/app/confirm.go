@@ -0,0 +1,52 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// errNotConfirmed is returned when the restart is declined at the confirmation prompt.
+var errNotConfirmed = errors.New("restart not confirmed, nothing was restarted")
+
+// confirmRestart prints the workloads of plans, the plan of each of clusters, as a table on env's stderr and
+// asks on its stdin whether to restart them, reporting whether the answer was yes. The table goes to stderr
+// so it doesn't mix with a summary printed on stdout.
+func confirmRestart(env Env, clusters []*Cluster, plans []*rollout.RestartPlan) (bool, error) {
+	total := 0
+	tw := tabwriter.NewWriter(env.Stderr, 0, 0, 2, ' ', 0)
+	header := "NAMESPACE\tKIND\tNAME\tREPLICAS"
+	if len(clusters) > 1 {
+		header = "CLUSTER\t" + header
+	}
+	fmt.Fprintln(tw, header)
+	for i, plan := range plans {
+		for _, w := range plan.Workloads {
+			if len(clusters) > 1 {
+				fmt.Fprintf(tw, "%s\t", clusters[i].Name)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", w.Namespace, w.Kind, w.Name, w.Replicas)
+			total++
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(env.Stderr, "\nRestart these %d workload(s)? [y/N] ", total)
+	answer, err := bufio.NewReader(env.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		// No answer, e.g. stdin was closed, is a no
+		fmt.Fprintln(env.Stderr)
+		return false, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
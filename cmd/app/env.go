@@ -0,0 +1,220 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/clock"
+)
+
+// Env is everything the CLI reads from or writes to outside its arguments. OSEnv is the real process
+// environment, tests substitute a fixed clock, an in-memory filesystem, fake environment variables and a
+// fake cluster to run commands end to end without a real cluster.
+type Env struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Interactive reports whether Stdin is a terminal someone can answer prompts on, never when nil
+	Interactive func() bool
+
+	// Getenv looks up an environment variable, returning "" when it is unset
+	Getenv func(key string) string
+	// Username returns the name of the user running the command, "" when unknown or when nil
+	Username func() string
+	// Clock is what run start times, durations, report names and rollout waits are measured on
+	Clock clock.WithTicker
+	// FS is where campaigns, tiers, webhooks, checkpoints, reports and the run history are read from and
+	// written to
+	FS FS
+	// Connect connects to the cluster described by opts
+	Connect func(opts ConnectOptions) (*Cluster, error)
+	// Contexts lists the contexts of the kubeconfig opts loads, sorted by name
+	Contexts func(opts ConnectOptions) ([]string, error)
+	// Context returns the context a command runs in and a function releasing it, cancelled with a
+	// signalError cause when the process is interrupted
+	Context func() (context.Context, context.CancelFunc)
+}
+
+// Cluster is a connection to a Kubernetes cluster.
+type Cluster struct {
+	// Name identifies the cluster in reports, usually the kubeconfig context name
+	Name      string
+	Config    *rest.Config
+	Clientset kubernetes.Interface
+	// Dynamic is only needed by the volume snapshot, Argo Rollouts and operator handlers
+	Dynamic dynamic.Interface
+	// Usage counts the API requests made through the clients, nil when they aren't counted
+	Usage *rollout.UsageTracker
+}
+
+// ConnectOptions says which cluster to connect to and how.
+type ConnectOptions struct {
+	// Kubeconfig is the path to the kubeconfig, empty for the files listed in $KUBECONFIG or the default location
+	Kubeconfig string
+	// Context is the kubeconfig context, empty for the current one
+	Context string
+	Source  ConfigSource
+}
+
+// ConfigSource is where the cluster connection is configured from.
+type ConfigSource string
+
+const (
+	// ConfigSourceAuto uses the kubeconfig, falling back to the pod's service account when running inside a
+	// cluster without a kubeconfig, e.g. as a CronJob
+	ConfigSourceAuto ConfigSource = "auto"
+	// ConfigSourceKubeconfig always uses the kubeconfig
+	ConfigSourceKubeconfig ConfigSource = "kubeconfig"
+	// ConfigSourceInCluster always uses the pod's service account
+	ConfigSourceInCluster ConfigSource = "in-cluster"
+)
+
+// FS is the subset of filesystem access the CLI needs.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// AppendFile appends data to the file name, creating it with perm if it doesn't exist
+	AppendFile(name string, data []byte, perm fs.FileMode) error
+	Remove(name string) error
+	MkdirAll(path string, perm fs.FileMode) error
+	Stat(name string) (fs.FileInfo, error)
+	Glob(pattern string) ([]string, error)
+}
+
+// OSEnv returns the environment of the running process.
+func OSEnv() Env {
+	return Env{
+		Stdin:       os.Stdin,
+		Stdout:      os.Stdout,
+		Stderr:      os.Stderr,
+		Interactive: stdinIsTerminal,
+		Getenv:      os.Getenv,
+		Username:    osUsername,
+		Clock:       clock.RealClock{},
+		FS:          osFS{},
+		Connect: func(opts ConnectOptions) (*Cluster, error) {
+			return connect(os.Getenv, osFS{}, opts)
+		},
+		Contexts: func(opts ConnectOptions) ([]string, error) {
+			return listContexts(os.Getenv, osFS{}, opts)
+		},
+		Context: signalContext,
+	}
+}
+
+// osUsername returns the name of the user the process runs as, from $USER when it can't be looked up.
+func osUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// stdinIsTerminal reports whether the process's standard input is a terminal.
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// osFS is the FS of the operating system.
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (osFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+// connect builds the clients for the cluster described by opts, resolving the kubeconfig with getenv and
+// fsys.
+func connect(getenv func(string) string, fsys FS, opts ConnectOptions) (*Cluster, error) {
+	config, name, err := loadConfig(getenv, fsys, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every client created from the config counts its requests
+	usage := rollout.NewUsageTracker()
+	config.Wrap(usage.WrapTransport)
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	return &Cluster{
+		Name:      name,
+		Config:    config,
+		Clientset: clientset,
+		Dynamic:   dynamicClient,
+		Usage:     usage,
+	}, nil
+}
+
+// listContexts returns the names of the contexts of the kubeconfig opts loads, resolved with getenv and fsys.
+func listContexts(getenv func(string) string, fsys FS, opts ConnectOptions) ([]string, error) {
+	config, err := loadingRules(getenv, fsys, opts.Kubeconfig).Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return slices.Sorted(maps.Keys(config.Contexts)), nil
+}
+
+// writeOutput writes what write produces to the file at path, or to stdout when path is empty.
+func writeOutput(env Env, path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(env.Stdout)
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	return env.FS.WriteFile(path, buf.Bytes(), 0o644)
+}
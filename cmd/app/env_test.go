@@ -0,0 +1,90 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// memFS is an in-memory FS, its paths relative and slash separated.
+type memFS struct {
+	fstest.MapFS
+}
+
+func (m memFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.MapFS[name] = &fstest.MapFile{Data: data, Mode: perm}
+	return nil
+}
+
+func (m memFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	if f, ok := m.MapFS[name]; ok {
+		f.Data = append(f.Data, data...)
+		return nil
+	}
+	return m.WriteFile(name, data, perm)
+}
+
+func (m memFS) Remove(name string) error {
+	if _, ok := m.MapFS[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.MapFS, name)
+	return nil
+}
+
+func (m memFS) MkdirAll(string, fs.FileMode) error {
+	return nil
+}
+
+// testEnv is an Env of a fake cluster holding objects, with the output of the commands run in it.
+type testEnv struct {
+	Env
+	fs     memFS
+	vars   map[string]string
+	stdout *bytes.Buffer
+	stderr *bytes.Buffer
+}
+
+func newTestEnv(objects ...runtime.Object) *testEnv {
+	e := &testEnv{
+		fs:     memFS{fstest.MapFS{}},
+		vars:   map[string]string{},
+		stdout: &bytes.Buffer{},
+		stderr: &bytes.Buffer{},
+	}
+	clientset := fake.NewSimpleClientset(objects...)
+	e.Env = Env{
+		Stdin:    strings.NewReader(""),
+		Stdout:   e.stdout,
+		Stderr:   e.stderr,
+		Getenv:   func(key string) string { return e.vars[key] },
+		Username: func() string { return "alice" },
+		Clock:    clocktesting.NewFakeClock(time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)),
+		FS:       e.fs,
+		Connect: func(ConnectOptions) (*Cluster, error) {
+			return &Cluster{Name: "test", Clientset: clientset}, nil
+		},
+		Context: func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		},
+	}
+	return e
+}
+
+// run runs the command line args in e, failing t unless it exits with wantCode.
+func (e *testEnv) run(t *testing.T, wantCode int, args ...string) {
+	t.Helper()
+	e.stdout.Reset()
+	e.stderr.Reset()
+	if code := Run(args, e.Env); code != wantCode {
+		t.Fatalf("%s exited with %d, want %d, stderr:\n%s", strings.Join(args, " "), code, wantCode, e.stderr)
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// fleet is an inventory of clusters, labelled so that a run can target a slice of the fleet by label, e.g.
+// every EU staging cluster, rather than by listing their contexts.
+//
+// An example fleet file:
+//
+//	clusters:
+//	- name: eu-staging-1
+//	  context: gke_acme_europe-west1_staging-1
+//	  labels: {region: eu, tier: staging}
+//	- name: us-prod-1
+//	  kubeconfig: kubeconfigs/us-prod-1.yaml
+//	  labels: {region: us, tier: prod}
+type fleet struct {
+	Clusters []fleetCluster `json:"clusters"`
+}
+
+// fleetCluster is a cluster of a fleet.
+type fleetCluster struct {
+	// Name identifies the cluster in reports and logs
+	Name string `json:"name"`
+	// Kubeconfig is the kubeconfig the cluster is connected to through, relative to the fleet file, the
+	// --kubeconfig one when empty
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Context is the kubeconfig context of the cluster, the current one when empty
+	Context string            `json:"context,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// loadFleet reads the YAML fleet file at path.
+func loadFleet(fsys FS, path string) (fleet, error) {
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return fleet{}, fmt.Errorf("failed to read fleet: %w", err)
+	}
+
+	var f fleet
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return fleet{}, fmt.Errorf("failed to parse fleet: %w", err)
+	}
+	names := map[string]bool{}
+	for i, c := range f.Clusters {
+		if c.Name == "" {
+			return fleet{}, fmt.Errorf("fleet cluster %d has no name", i+1)
+		}
+		if names[c.Name] {
+			return fleet{}, fmt.Errorf("fleet cluster %s is listed more than once", c.Name)
+		}
+		names[c.Name] = true
+		if c.Kubeconfig != "" && !filepath.IsAbs(c.Kubeconfig) {
+			f.Clusters[i].Kubeconfig = filepath.Join(filepath.Dir(path), c.Kubeconfig)
+		}
+	}
+	return f, nil
+}
+
+// selectClusters returns the clusters of f whose labels match selector.
+func (f fleet) selectClusters(selector labels.Selector) []fleetCluster {
+	var selected []fleetCluster
+	for _, c := range f.Clusters {
+		if selector.Matches(labels.Set(c.Labels)) {
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
+// connectFleet connects to the clusters of the fleet file at path matching selector, naming them after their
+// fleet name.
+func (g *globalOptions) connectFleet(path string, selector labels.Selector) ([]*Cluster, error) {
+	f, err := loadFleet(g.env.FS, path)
+	if err != nil {
+		return nil, err
+	}
+	selected := f.selectClusters(selector)
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no cluster of fleet %s matches %q", path, selector)
+	}
+
+	clusters := make([]*Cluster, 0, len(selected))
+	for _, c := range selected {
+		opts := ConnectOptions{Kubeconfig: g.kubeconfig, Context: c.Context, Source: ConfigSource(g.configSource)}
+		if c.Kubeconfig != "" {
+			opts.Kubeconfig = c.Kubeconfig
+		}
+		cluster, err := g.env.Connect(opts)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", c.Name, err)
+		}
+		cluster.Name = c.Name
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
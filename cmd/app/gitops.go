@@ -0,0 +1,90 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// defaultReportGitPath lays out committed reports by day and cluster.
+const defaultReportGitPath = "rollouts/{{.Date}}/{{.Cluster}}-{{.Time}}.csv"
+
+// reportPathData is available to the -report-git-path template.
+type reportPathData struct {
+	Date     string
+	Time     string
+	Cluster  string
+	Campaign string
+}
+
+// commitReport writes the run results as CSV into the git checkout at repo, at the path rendered from
+// pathTemplate, and commits it, pushing the commit when push is set. It shells out to git so the
+// checkout's own credentials and hooks apply. The path of the committed report is returned.
+func commitReport(ctx context.Context, env Env, repo, pathTemplate string, push bool, data reportPathData, results []rollout.ResourceResult) (string, error) {
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(pathTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid report path template: %w", err)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("invalid report path template: %w", err)
+	}
+
+	relPath := filepath.Clean(rendered.String())
+	if filepath.IsAbs(relPath) || strings.HasPrefix(relPath, "..") {
+		return "", fmt.Errorf("report path %s is outside the repository", relPath)
+	}
+
+	path := filepath.Join(repo, relPath)
+	if err := env.FS.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := writeReport(env, path, results); err != nil {
+		return "", err
+	}
+
+	message := fmt.Sprintf("Rollout restart report for %s on %s", data.Cluster, data.Date)
+	if data.Campaign != "" {
+		message = fmt.Sprintf("Rollout restart report for %s on %s (campaign %s)", data.Cluster, data.Date, data.Campaign)
+	}
+	if err := git(ctx, repo, "add", "--", relPath); err != nil {
+		return "", err
+	}
+	if err := git(ctx, repo, "commit", "-m", message, "--", relPath); err != nil {
+		return "", err
+	}
+	if push {
+		if err := git(ctx, repo, "push"); err != nil {
+			return "", err
+		}
+	}
+	return relPath, nil
+}
+
+// git runs a git subcommand in repo, returning its output in the error when it fails.
+func git(ctx context.Context, repo string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", repo}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// newReportPathData fills the report path template fields for a run started at start. Separators in the
+// cluster name, common in cloud provider context names, are replaced so it stays a single path element.
+func newReportPathData(start time.Time, cluster, campaign string) reportPathData {
+	return reportPathData{
+		Date:     start.Format("2006-01-02"),
+		Time:     start.Format("150405"),
+		Cluster:  strings.NewReplacer("/", "_", ":", "_").Replace(cluster),
+		Campaign: campaign,
+	}
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// runFileTime names history files by start time, so they list in the order the runs happened.
+const runFileTime = "20060102T150405Z"
+
+// defaultHistoryDir is where each restart run's report is kept unless --history-dir says otherwise.
+func defaultHistoryDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "rollout", "history")
+}
+
+// saveRun stores the report of a run in the history directory and returns the path it was written to.
+func saveRun(fsys FS, dir string, report *rollout.Report) (string, error) {
+	if err := fsys.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, report.StartTime.UTC().Format(runFileTime)+"-"+report.RunID+".json")
+	if err := fsys.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write run history: %w", err)
+	}
+	return path, nil
+}
+
+// loadRun reads a run's report, ref is either a run ID (or a unique prefix of one) in the history
+// directory, or the path to a report file.
+func loadRun(fsys FS, dir, ref string) (*rollout.Report, error) {
+	path := ref
+	if _, err := fsys.Stat(ref); err != nil {
+		matches, err := fsys.Glob(filepath.Join(dir, "*-"+ref+"*.json"))
+		if err != nil {
+			return nil, err
+		}
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("no run %q in %s", ref, dir)
+		case 1:
+			path = matches[0]
+		default:
+			return nil, fmt.Errorf("run %q is ambiguous, it matches %d runs", ref, len(matches))
+		}
+	}
+
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run: %w", err)
+	}
+	report, err := rollout.ParseReport(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse run %s: %w", path, err)
+	}
+	return report, nil
+}
+
+// newHistoryCommand returns the "history" subcommand, for looking back at the runs kept in --history-dir.
+func newHistoryCommand(g *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Inspect previous restart runs",
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List previous restart runs, oldest first",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return listRuns(g.env, g.historyDir, g.location)
+			},
+		},
+		&cobra.Command{
+			Use:   "diff <run1> <run2>",
+			Short: "Compare which workloads were restarted or failed in two runs",
+			Long: "Compare which workloads were restarted or failed in two runs, e.g. to confirm workloads that failed\n" +
+				"before a fix are restarted after it. Runs are given by run ID, a unique prefix of one, or a report file.",
+			Args: cobra.ExactArgs(2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return diffRuns(g.env, g.historyDir, args[0], args[1], g.location)
+			},
+		},
+	)
+	return cmd
+}
+
+func listRuns(env Env, dir string, loc *time.Location) error {
+	paths, err := env.FS.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	tw := tabwriter.NewWriter(env.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN\tSTARTED\tDURATION\tRESTARTED\tFAILED\tSKIPPED\tCANCELLED\tINITIATOR")
+	for _, path := range paths {
+		report, err := loadRun(env.FS, dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%t\t%s\n", report.RunID, formatTimeIn(report.StartTime, loc),
+			report.Duration.Round(time.Second), report.Restarted, report.Failed, report.Skipped, report.Cancelled, orDash(report.Initiator))
+	}
+	return tw.Flush()
+}
+
+func diffRuns(env Env, dir, ref1, ref2 string, loc *time.Location) error {
+	before, err := loadRun(env.FS, dir, ref1)
+	if err != nil {
+		return err
+	}
+	after, err := loadRun(env.FS, dir, ref2)
+	if err != nil {
+		return err
+	}
+
+	diffs := rollout.DiffResults(before.Results, after.Results)
+	fixed, stillFailing, newlyFailing := 0, 0, 0
+	tw := tabwriter.NewWriter(env.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(env.Stdout, "Comparing run %s (%s) with run %s (%s)\n\n", before.RunID, formatTimeIn(before.StartTime, loc), after.RunID, formatTimeIn(after.StartTime, loc))
+	fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tBEFORE\tAFTER\tERROR")
+	for _, d := range diffs {
+		switch {
+		case d.Fixed():
+			fixed++
+		case d.After == rollout.ActionFailed && d.Before == rollout.ActionFailed:
+			stillFailing++
+		case d.After == rollout.ActionFailed:
+			newlyFailing++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Kind, d.Namespace, d.Name, orDash(d.Before), orDash(d.After), d.Error)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.Stdout, "\n%d fixed, %d still failing, %d newly failing\n", fixed, stillFailing, newlyFailing)
+	return nil
+}
+
+// orDash shows a workload missing from a run as a dash.
+func orDash(action string) string {
+	if action == "" {
+		return "-"
+	}
+	return action
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Incident sources --incident-check can query.
+const (
+	incidentSourcePagerDuty  = "pagerduty"
+	incidentSourceStatuspage = "statuspage"
+)
+
+// pagerDutyIncidentsURL and statuspageAPIURL are the APIs active incidents are queried from.
+const (
+	pagerDutyIncidentsURL = "https://api.pagerduty.com/incidents"
+	statuspageAPIURL      = "https://api.statuspage.io/v1"
+)
+
+// sev1Priorities are the PagerDuty incident priorities taken as Sev1, an incident without a priority is
+// one when its urgency is high.
+var sev1Priorities = []string{"P1", "SEV1", "SEV-1"}
+
+// incident is an active Sev1 incident.
+type incident struct {
+	Source  string
+	Title   string
+	Service string
+	URL     string
+}
+
+// incidentChecker looks up active Sev1 incidents in PagerDuty and Statuspage.
+type incidentChecker struct {
+	client *http.Client
+	// pagerDutyToken is the REST API key PagerDuty is queried with
+	pagerDutyToken string
+	// statuspageKey is the API key Statuspage page statuspagePage is queried with
+	statuspageKey  string
+	statuspagePage string
+}
+
+// newIncidentChecker configures the checker for the Statuspage page from the PAGERDUTY_TOKEN and
+// STATUSPAGE_API_KEY environment variables, kept out of the flags so they don't end up in the process list.
+func newIncidentChecker(env Env, statuspagePage string) *incidentChecker {
+	return &incidentChecker{
+		client:         &http.Client{Timeout: 10 * time.Second},
+		pagerDutyToken: env.Getenv("PAGERDUTY_TOKEN"),
+		statuspageKey:  env.Getenv("STATUSPAGE_API_KEY"),
+		statuspagePage: statuspagePage,
+	}
+}
+
+// checkIncidents refuses a run while any of sources reports an active Sev1 incident affecting the services
+// it targets, or when they can't be checked, unless override is set, in which case they are only logged.
+// The targeted services are the ones matching the services globs, or the ones whose name contains filter
+// when there are none. An incident not tied to a service affects every one.
+func checkIncidents(ctx context.Context, log logrus.FieldLogger, checker *incidentChecker, sources, services []string, filter string, override bool) error {
+	var affecting []incident
+	for _, source := range sources {
+		incidents, err := checker.active(ctx, source)
+		if err != nil {
+			if override {
+				log.WithError(err).Warnf("Failed to check %s for active incidents, restarting anyway as overridden", source)
+				continue
+			}
+			return fmt.Errorf("failed to check %s for active incidents, pass --override-incident to restart anyway: %w", source, err)
+		}
+		for _, i := range incidents {
+			if i.Service == "" || targetsService(services, filter, i.Service) {
+				affecting = append(affecting, i)
+			}
+		}
+	}
+	if len(affecting) == 0 {
+		return nil
+	}
+
+	for _, i := range affecting {
+		log.WithFields(logrus.Fields{
+			"source":  i.Source,
+			"service": i.Service,
+			"url":     i.URL,
+		}).Warn("Active Sev1 incident: " + i.Title)
+	}
+	if override {
+		log.WithField("incidents", len(affecting)).Warn("Restarting despite active Sev1 incidents, as overridden")
+		return nil
+	}
+	return fmt.Errorf("%d active Sev1 incident(s) affect the targeted services, restarting could compound them, pass --override-incident to restart anyway", len(affecting))
+}
+
+// targetsService reports whether the run targets service, by services globs or else by filter.
+func targetsService(services []string, filter, service string) bool {
+	if len(services) > 0 {
+		return slices.ContainsFunc(services, func(pattern string) bool {
+			ok, _ := path.Match(pattern, service)
+			return ok
+		})
+	}
+	return strings.Contains(strings.ToLower(service), strings.ToLower(filter))
+}
+
+// active returns the active Sev1 incidents of source.
+func (c *incidentChecker) active(ctx context.Context, source string) ([]incident, error) {
+	switch source {
+	case incidentSourcePagerDuty:
+		return c.pagerDuty(ctx)
+	case incidentSourceStatuspage:
+		return c.statuspage(ctx)
+	}
+	return nil, fmt.Errorf("unknown incident source %q, expected %s or %s", source, incidentSourcePagerDuty, incidentSourceStatuspage)
+}
+
+// pagerDuty returns the triggered and acknowledged high urgency PagerDuty incidents with a Sev1 priority,
+// or no priority at all.
+func (c *incidentChecker) pagerDuty(ctx context.Context) ([]incident, error) {
+	if c.pagerDutyToken == "" {
+		return nil, fmt.Errorf("PAGERDUTY_TOKEN is not set")
+	}
+
+	var incidents []incident
+	for offset := 0; ; {
+		query := url.Values{
+			"statuses[]":  {"triggered", "acknowledged"},
+			"urgencies[]": {"high"},
+			"limit":       {"100"},
+			"offset":      {strconv.Itoa(offset)},
+		}
+		var page struct {
+			Incidents []struct {
+				Title    string `json:"title"`
+				HTMLURL  string `json:"html_url"`
+				Priority *struct {
+					Summary string `json:"summary"`
+				} `json:"priority"`
+				Service struct {
+					Summary string `json:"summary"`
+				} `json:"service"`
+			} `json:"incidents"`
+			More bool `json:"more"`
+		}
+		header := http.Header{
+			"Authorization": {"Token token=" + c.pagerDutyToken},
+			"Accept":        {"application/vnd.pagerduty+json;version=2"},
+		}
+		if err := c.getJSON(ctx, pagerDutyIncidentsURL+"?"+query.Encode(), header, &page); err != nil {
+			return nil, err
+		}
+
+		for _, i := range page.Incidents {
+			if i.Priority != nil && !slices.Contains(sev1Priorities, strings.ToUpper(i.Priority.Summary)) {
+				continue
+			}
+			incidents = append(incidents, incident{Source: incidentSourcePagerDuty, Title: i.Title, Service: i.Service.Summary, URL: i.HTMLURL})
+		}
+		if !page.More || len(page.Incidents) == 0 {
+			return incidents, nil
+		}
+		offset += len(page.Incidents)
+	}
+}
+
+// statuspage returns the unresolved incidents of the Statuspage page with a critical impact, one for each
+// component they affect.
+func (c *incidentChecker) statuspage(ctx context.Context) ([]incident, error) {
+	if c.statuspageKey == "" {
+		return nil, fmt.Errorf("STATUSPAGE_API_KEY is not set")
+	}
+	if c.statuspagePage == "" {
+		return nil, fmt.Errorf("--statuspage-page is needed to check Statuspage")
+	}
+
+	var unresolved []struct {
+		Name       string `json:"name"`
+		Impact     string `json:"impact"`
+		Shortlink  string `json:"shortlink"`
+		Components []struct {
+			Name string `json:"name"`
+		} `json:"components"`
+	}
+	endpoint := fmt.Sprintf("%s/pages/%s/incidents/unresolved", statuspageAPIURL, url.PathEscape(c.statuspagePage))
+	if err := c.getJSON(ctx, endpoint, http.Header{"Authorization": {"OAuth " + c.statuspageKey}}, &unresolved); err != nil {
+		return nil, err
+	}
+
+	var incidents []incident
+	for _, i := range unresolved {
+		if i.Impact != "critical" {
+			continue
+		}
+		if len(i.Components) == 0 {
+			incidents = append(incidents, incident{Source: incidentSourceStatuspage, Title: i.Name, URL: i.Shortlink})
+		}
+		for _, component := range i.Components {
+			incidents = append(incidents, incident{Source: incidentSourceStatuspage, Title: i.Name, Service: component.Name, URL: i.Shortlink})
+		}
+	}
+	return incidents, nil
+}
+
+// getJSON gets endpoint with header and decodes the JSON response into v.
+func (c *incidentChecker) getJSON(ctx context.Context, endpoint string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
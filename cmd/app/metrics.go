@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// runMetrics are the Prometheus metrics of restart runs, fed by the run's callbacks.
+type runMetrics struct {
+	registry   *prometheus.Registry
+	restarted  *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	duration   prometheus.Histogram
+	peakMemory prometheus.Gauge
+}
+
+func newRunMetrics() *runMetrics {
+	m := &runMetrics{
+		registry: prometheus.NewRegistry(),
+		restarted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "workloads_restarted_total",
+			Help: "Workloads restarted, by kind and namespace.",
+		}, []string{"kind", "namespace"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "restart_errors_total",
+			Help: "Workloads that failed to restart, by kind and namespace.",
+		}, []string{"kind", "namespace"}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "rollout_duration_seconds",
+			Help: "Duration of restart runs.",
+			// Runs take anywhere from seconds for a single workload to hours for a whole fleet
+			Buckets: prometheus.ExponentialBuckets(1, 4, 9),
+		}),
+		peakMemory: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "run_peak_memory_bytes",
+			Help: "Peak memory in use during the last run.",
+		}),
+	}
+	m.registry.MustRegister(m.restarted, m.errors, m.duration, m.peakMemory)
+	return m
+}
+
+// callbacks returns the run callbacks counting restarts and failures.
+func (m *runMetrics) callbacks() rollout.Callbacks {
+	return rollout.Callbacks{
+		OnRestarted: func(r rollout.ResourceResult) {
+			m.restarted.WithLabelValues(r.Kind, r.Namespace).Inc()
+		},
+		OnFailed: func(r rollout.ResourceResult) {
+			m.errors.WithLabelValues(r.Kind, r.Namespace).Inc()
+		},
+	}
+}
+
+// trackUsage exports the API requests counted by usage, and the bytes sent and received with them, summed
+// over the clusters they count.
+func (m *runMetrics) trackUsage(usage ...*rollout.UsageTracker) {
+	sum := func(count func(*rollout.UsageTracker) int64) func() float64 {
+		return func() float64 {
+			var total int64
+			for _, u := range usage {
+				total += count(u)
+			}
+			return float64(total)
+		}
+	}
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "api_requests_total",
+			Help: "Requests made to the Kubernetes API.",
+		}, sum((*rollout.UsageTracker).Requests)),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "api_request_bytes_total",
+			Help: "Bytes of request bodies sent to the Kubernetes API.",
+		}, sum((*rollout.UsageTracker).BytesSent)),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "api_response_bytes_total",
+			Help: "Bytes of response bodies received from the Kubernetes API.",
+		}, sum((*rollout.UsageTracker).BytesReceived)),
+	)
+}
+
+// observeRun records the duration of a finished run and its peak memory, when usage was tracked.
+func (m *runMetrics) observeRun(d time.Duration, usage *rollout.Usage) {
+	m.duration.Observe(d.Seconds())
+	if usage != nil {
+		m.peakMemory.Set(float64(usage.PeakMemoryBytes))
+	}
+}
+
+// serve exposes the metrics on /metrics at addr until the returned function is called. The listener is
+// opened before returning, so a taken port fails the run up front.
+func (m *runMetrics) serve(log logrus.FieldLogger, addr string) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithError(err).Error("Metrics server failed")
+		}
+	}()
+	log.WithField("addr", listener.Addr().String()).Info("Serving metrics on /metrics")
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
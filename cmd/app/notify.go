@@ -0,0 +1,140 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// slackPostMessageURL is the Slack Web API method posting a message to a channel.
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// annotationNotifier sends each workload's restart to the targets in its rollout.NotifyAnnotation, a comma
+// separated list of Slack channels ("#payments" or "slack:payments"), email addresses ("oncall@example.com"
+// or "mailto:oncall@example.com") and webhook URLs.
+type annotationNotifier struct {
+	client *http.Client
+	// slackToken is the bot token Slack messages are posted with
+	slackToken string
+	// smtpAddr is the host:port of the mail server emails are sent through, from smtpFrom
+	smtpAddr string
+	smtpFrom string
+	smtpAuth smtp.Auth
+}
+
+// newAnnotationNotifier configures the notifier from the flags and the SLACK_TOKEN, SMTP_USERNAME and
+// SMTP_PASSWORD environment variables, kept out of the flags so they don't end up in the process list.
+func newAnnotationNotifier(env Env, smtpAddr, smtpFrom string) *annotationNotifier {
+	n := &annotationNotifier{
+		client:     &http.Client{Timeout: 10 * time.Second},
+		slackToken: env.Getenv("SLACK_TOKEN"),
+		smtpAddr:   smtpAddr,
+		smtpFrom:   smtpFrom,
+	}
+	if username := env.Getenv("SMTP_USERNAME"); username != "" && smtpAddr != "" {
+		host, _, _ := strings.Cut(smtpAddr, ":")
+		n.smtpAuth = smtp.PlainAuth("", username, env.Getenv("SMTP_PASSWORD"), host)
+	}
+	return n
+}
+
+// notify sends a notification for every restarted or failed workload with a notify annotation. A target
+// that can't be reached is logged and doesn't stop the others.
+func (n *annotationNotifier) notify(ctx context.Context, log logrus.FieldLogger, results []rollout.ResourceResult) {
+	for _, r := range results {
+		if r.Notify == "" || r.Action == rollout.ActionSkipped {
+			continue
+		}
+
+		text := fmt.Sprintf("Rollout restart of %s %s/%s: %s", r.Kind, r.Namespace, r.Name, r.Action)
+		if r.Error != "" {
+			text += " (" + r.Error + ")"
+		}
+		for _, target := range strings.Split(r.Notify, ",") {
+			target = strings.TrimSpace(target)
+			if target == "" {
+				continue
+			}
+
+			targetLog := log.WithFields(logrus.Fields{"target": target, "namespace": r.Namespace, "name": r.Name})
+			if err := n.send(ctx, target, text); err != nil {
+				targetLog.WithError(err).Error("Failed to send restart notification")
+				continue
+			}
+			targetLog.Debug("Sent restart notification")
+		}
+	}
+}
+
+// send delivers text to a single target, picking the channel from its form.
+func (n *annotationNotifier) send(ctx context.Context, target, text string) error {
+	switch {
+	case strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "http://"):
+		return postWebhook(ctx, n.client, target, text)
+	case strings.HasPrefix(target, "#"):
+		return n.postSlack(ctx, target, text)
+	case strings.HasPrefix(target, "slack:"):
+		return n.postSlack(ctx, strings.TrimPrefix(target, "slack:"), text)
+	case strings.HasPrefix(target, "mailto:"):
+		return n.sendEmail(strings.TrimPrefix(target, "mailto:"), text)
+	case strings.Contains(target, "@"):
+		return n.sendEmail(target, text)
+	}
+	return fmt.Errorf("unknown notification target %q, expected a Slack channel, email address or webhook URL", target)
+}
+
+// postSlack posts text to a Slack channel with chat.postMessage.
+func (n *annotationNotifier) postSlack(ctx context.Context, channel, text string) error {
+	if n.slackToken == "" {
+		return fmt.Errorf("SLACK_TOKEN is not set")
+	}
+
+	body, err := json.Marshal(map[string]string{"channel": channel, "text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slackPostMessageURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+n.slackToken)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Slack reports API errors in the body of a 200 response
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("slack returned %s: %w", resp.Status, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack returned %s", result.Error)
+	}
+	return nil
+}
+
+// sendEmail mails text to address through the configured mail server.
+func (n *annotationNotifier) sendEmail(address, text string) error {
+	if n.smtpAddr == "" || n.smtpFrom == "" {
+		return fmt.Errorf("--notify-smtp-addr and --notify-smtp-from are needed to send email")
+	}
+
+	subject, _, _ := strings.Cut(text, " (")
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", n.smtpFrom, address, subject, text)
+	return smtp.SendMail(n.smtpAddr, n.smtpAuth, n.smtpFrom, []string{address}, []byte(message))
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/operator"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// operatorOptions are the flags of the operator subcommand.
+type operatorOptions struct {
+	leaderElect             bool
+	leaderElectionNamespace string
+	healthProbeAddr         string
+	metricsAddr             string
+	freezeConfigMap         string
+}
+
+// newOperatorCommand returns the "operator" subcommand, running restarts declared by RolloutRestart
+// resources until interrupted.
+func newOperatorCommand(g *globalOptions) *cobra.Command {
+	o := &operatorOptions{}
+	cmd := &cobra.Command{
+		Use:   "operator",
+		Short: "Run as an operator, restarting the workloads declared by RolloutRestart resources",
+		Long: "Run as an operator until interrupted, reconciling RolloutRestart resources (rollout.tim-codez.io/v1alpha1,\n" +
+			"see manifests/operator): each one is restarted once whenever its spec changes, or on its cron schedule,\n" +
+			"and the outcome of its last run is recorded in its status. The workloads a RolloutRestart restarts are\n" +
+			"selected by its own spec, --filter, --selector and the namespace flags don't apply.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runOperator(g, o)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&o.leaderElect, "leader-elect", false, "Elect a leader among the operator's replicas so only one of them restarts at a time")
+	flags.StringVar(&o.leaderElectionNamespace, "leader-election-namespace", "", "Namespace of the leader election Lease, defaults to the operator pod's namespace")
+	flags.StringVar(&o.healthProbeAddr, "health-probe-addr", ":8081", "Serve the /healthz and /readyz probes at this address, empty disables them")
+	flags.StringVar(&o.metricsAddr, "metrics-addr", ":8080", "Serve the operator's Prometheus metrics on /metrics at this address, empty disables them")
+	flags.StringVar(&o.freezeConfigMap, "freeze-configmap", defaultFreezeConfigMap, "Namespace/name of the ConfigMap that freezes restarts cluster-wide while it exists, empty disables the check")
+	return cmd
+}
+
+func runOperator(g *globalOptions, o *operatorOptions) error {
+	componentLogger := g.logger.WithField("component", "operator")
+
+	var opts []rollout.Option
+	if o.freezeConfigMap != "" {
+		namespace, name, ok := strings.Cut(o.freezeConfigMap, "/")
+		if !ok || namespace == "" || name == "" {
+			return fmt.Errorf("invalid --freeze-configmap %q, expected namespace/name", o.freezeConfigMap)
+		}
+		opts = append(opts, rollout.WithFreezeConfigMap(namespace, name))
+	}
+
+	cluster, err := g.connect("")
+	if err != nil {
+		return err
+	}
+	opts = append(opts, rollout.WithClusterName(cluster.Name))
+
+	ctx, stop := g.env.Context()
+	defer stop()
+
+	componentLogger.WithField("cluster", cluster.Name).Info("Starting operator")
+	reconciler := &operator.Reconciler{
+		Clientset: cluster.Clientset,
+		Log:       rolloutLogger(componentLogger),
+		Clock:     g.env.Clock,
+		Options:   opts,
+	}
+	err = operator.Run(ctx, cluster.Config, reconciler, g.logger, operator.Options{
+		LeaderElection:          o.leaderElect,
+		LeaderElectionNamespace: o.leaderElectionNamespace,
+		HealthProbeAddress:      o.healthProbeAddr,
+		MetricsAddress:          o.metricsAddr,
+	})
+	if err != nil {
+		return fmt.Errorf("operator failed: %w", err)
+	}
+	return nil
+}
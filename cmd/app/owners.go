@@ -0,0 +1,89 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// notifyOwners posts a summary of each owner's restarted and failed workloads to that owner's webhook.
+// The file at path is a JSON object mapping owner names (as resolved from the workload owner keys) to
+// Slack compatible incoming webhook URLs, owners without an entry are not notified.
+func notifyOwners(ctx context.Context, log logrus.FieldLogger, fsys FS, path string, results []rollout.ResourceResult) error {
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read owner webhooks: %w", err)
+	}
+
+	webhooks := map[string]string{}
+	if err := json.Unmarshal(data, &webhooks); err != nil {
+		return fmt.Errorf("failed to parse owner webhooks: %w", err)
+	}
+
+	byOwner := map[string][]string{}
+	for _, r := range results {
+		if r.Owner == "" || r.Action == rollout.ActionSkipped {
+			continue
+		}
+		line := fmt.Sprintf("%s %s/%s: %s", r.Kind, r.Namespace, r.Name, r.Action)
+		if r.Error != "" {
+			line += " (" + r.Error + ")"
+		}
+		byOwner[r.Owner] = append(byOwner[r.Owner], line)
+	}
+
+	owners := make([]string, 0, len(byOwner))
+	for owner := range byOwner {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, owner := range owners {
+		url, ok := webhooks[owner]
+		if !ok {
+			continue
+		}
+
+		text := fmt.Sprintf("Rollout restart touched %d workload(s) owned by %s:\n%s", len(byOwner[owner]), owner, strings.Join(byOwner[owner], "\n"))
+		if err := postWebhook(ctx, client, url, text); err != nil {
+			log.WithError(err).WithField("owner", owner).Error("Failed to notify owner")
+			continue
+		}
+		log.WithField("owner", owner).Info("Notified owner")
+	}
+	return nil
+}
+
+// postWebhook sends text as a Slack style {"text": ...} payload, which generic webhook receivers can also consume.
+func postWebhook(ctx context.Context, client *http.Client, url, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
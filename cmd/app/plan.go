@@ -0,0 +1,151 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	"sigs.k8s.io/yaml"
+)
+
+// planOptions are the flags of the plan subcommand.
+type planOptions struct {
+	team           string
+	ownerKeys      string
+	order          string
+	namespaceOrder string
+	output         string
+	related        bool
+}
+
+// newPlanCommand returns the read-only "plan" subcommand, listing the workloads a restart would cycle and
+// how their new pods will differ from the running ones.
+func newPlanCommand(g *globalOptions) *cobra.Command {
+	o := &planOptions{}
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "List the workloads a restart would cycle",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPlan(g, o)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&o.team, "team", "", "Only plan workloads owned by this team, as resolved from --owner-keys")
+	flags.StringVar(&o.ownerKeys, "owner-keys", strings.Join(rollout.DefaultOwnerKeys, ","), "Comma separated workload annotation/label keys the service owner is resolved from")
+	flags.StringVar(&o.order, "order", string(rollout.OrderRisk), "Order to list matched workloads in, risk (lowest risk first) or name (namespace, kind and name)")
+	flags.StringVar(&o.namespaceOrder, "namespace-order", "", namespaceOrderUsage)
+	flags.BoolVar(&o.related, "related", false, "List the HorizontalPodAutoscalers, PodDisruptionBudgets, Services and NetworkPolicies related to each workload")
+	flags.StringVarP(&o.output, "output", "o", "", "Print the plan as json or yaml rather than a table, versioned by its schemaVersion, for automation and restart --plan-file")
+	return cmd
+}
+
+// newValidatePlanCommand returns the "validate-plan" subcommand, checking a plan file against the plan
+// schema before it is handed to restart --plan-file or built on by other tools.
+func newValidatePlanCommand(g *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate-plan <file>",
+		Short: "Check a plan file against the plan schema",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plan, err := loadPlan(g.env.FS, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(g.env.Stdout, "%s is a valid %s plan of %d workload(s)\n", args[0], plan.SchemaVersion, len(plan.Workloads))
+			return nil
+		},
+	}
+}
+
+func runPlan(g *globalOptions, o *planOptions) error {
+	if o.output != "" && o.output != "json" && o.output != "yaml" {
+		return fmt.Errorf("unsupported output %q, expected json or yaml", o.output)
+	}
+	order, err := parseOrder(o.order)
+	if err != nil {
+		return fmt.Errorf("invalid --order: %w", err)
+	}
+	namespaceOrder, err := parseNamespaceOrder(o.namespaceOrder)
+	if err != nil {
+		return fmt.Errorf("invalid --namespace-order: %w", err)
+	}
+	cluster, err := g.connect("")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := g.env.Context()
+	defer stop()
+
+	opts := append(g.clientOptions(),
+		rollout.WithTeam(o.team),
+		rollout.WithOwnerKeys(strings.Split(o.ownerKeys, ",")...),
+		rollout.WithOrder(order),
+		rollout.WithNamespaceOrder(namespaceOrder),
+	)
+	if o.related {
+		opts = append(opts, rollout.WithRelatedObjects())
+	}
+	plan, err := rollout.Plan(ctx, cluster.Clientset, g.filter, opts...)
+	if err != nil {
+		return fmt.Errorf("planning failed: %w", err)
+	}
+	if o.output != "" {
+		return writePlan(g.env.Stdout, o.output, plan)
+	}
+
+	tw := tabwriter.NewWriter(g.env.Stdout, 0, 0, 2, ' ', 0)
+	if o.related {
+		fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tOWNER\tRELATED\tNOTES")
+	} else {
+		fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tOWNER\tNOTES")
+	}
+	for _, w := range plan.Workloads {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t", w.Kind, w.Namespace, w.Name, w.Owner)
+		if o.related {
+			related := make([]string, len(w.Related))
+			for i, r := range w.Related {
+				related[i] = r.String()
+			}
+			fmt.Fprintf(tw, "%s\t", strings.Join(related, ", "))
+		}
+		fmt.Fprintln(tw, strings.Join(w.Notes, "; "))
+	}
+	return tw.Flush()
+}
+
+// writePlan prints plan to w as format, json or yaml.
+func writePlan(w io.Writer, format string, plan *rollout.RestartPlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if format == "yaml" {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return err
+		}
+	} else {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// loadPlan reads and validates the plan file at path, see rollout.ParsePlan.
+func loadPlan(fsys FS, path string) (*rollout.RestartPlan, error) {
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	plan, err := rollout.ParsePlan(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plan, nil
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidatePlan(t *testing.T) {
+	golden, err := os.ReadFile("../rollout/testdata/plan.v1.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		plan     string
+		wantCode int
+		want     string
+	}{
+		{name: "golden plan", plan: string(golden), want: "plan.json is a valid v1 plan of 2 workload(s)"},
+		{name: "yaml plan", plan: "schemaVersion: v1\nWorkloads:\n- Kind: job\n  Namespace: batch\n  Name: migrate\n", want: "plan.json is a valid v1 plan of 1 workload(s)"},
+		{name: "other version", plan: `{"schemaVersion":"v2","Workloads":[]}`, wantCode: 1, want: `unsupported schema version "v2"`},
+		{name: "invalid workload", plan: `{"schemaVersion":"v1","Workloads":[{"Kind":"pod","Namespace":"default"}]}`, wantCode: 1, want: `unknown kind "pod"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv()
+			env.fs.WriteFile("plan.json", []byte(tt.plan), 0o644)
+
+			env.run(t, tt.wantCode, "validate-plan", "plan.json")
+			if got := env.stdout.String() + env.stderr.String(); !strings.Contains(got, tt.want) {
+				t.Errorf("got output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanRoundTrip(t *testing.T) {
+	labels := map[string]string{"app": "web"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+		},
+	}
+
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			env := newTestEnv(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, deployment)
+
+			env.run(t, 0, "plan", "--filter", "web", "--output", format)
+			env.fs.WriteFile("plan."+format, env.stdout.Bytes(), 0o644)
+
+			env.run(t, 0, "validate-plan", "plan."+format)
+			if want := "plan." + format + " is a valid v1 plan of 1 workload(s)"; !strings.Contains(env.stdout.String(), want) {
+				t.Errorf("got %q, want %q", env.stdout, want)
+			}
+		})
+	}
+}
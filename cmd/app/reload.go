@@ -0,0 +1,89 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// reloadOptions are the flags of the reload subcommand.
+type reloadOptions struct {
+	dryRun          string
+	wait            time.Duration
+	quietPeriod     time.Duration
+	freezeConfigMap string
+}
+
+// newReloadCommand returns the "reload" subcommand, restarting workloads whenever a ConfigMap or Secret
+// they use changes.
+func newReloadCommand(g *globalOptions) *cobra.Command {
+	o := &reloadOptions{}
+	cmd := &cobra.Command{
+		Use:   "reload",
+		Short: "Watch ConfigMaps and Secrets and restart the workloads using one whenever it changes",
+		Long: "Watch every ConfigMap and Secret until interrupted and, whenever the data of one changes, restart the\n" +
+			"workloads matching --filter and --selector that mount it or read it into their environment, so they pick\n" +
+			"up the new configuration. Changes to the objects a workload uses within --quiet-period of each other are\n" +
+			"coalesced into a single restart, whose reason annotation lists them all. The workloads of a namespace due\n" +
+			"at the same time are restarted as a run of their own and kept in --history-dir.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReload(g, o)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&o.dryRun, "dry-run", "none", "Only show what would be restarted: none, client or server")
+	flags.Lookup("dry-run").NoOptDefVal = "client"
+	flags.DurationVar(&o.wait, "wait", 0, "Wait up to this long for each restarted workload to finish rolling out, 0 doesn't wait")
+	flags.DurationVar(&o.quietPeriod, "quiet-period", 0, "Restart a workload only once the ConfigMaps and Secrets it uses haven't changed for this long, 0 restarts it on every change")
+	flags.StringVar(&o.freezeConfigMap, "freeze-configmap", defaultFreezeConfigMap, "Namespace/name of the ConfigMap that freezes restarts cluster-wide while it exists, empty disables the check")
+	return cmd
+}
+
+func runReload(g *globalOptions, o *reloadOptions) error {
+	componentLogger := g.logger.WithField("component", "reload")
+
+	dryRun, err := parseDryRun(o.dryRun)
+	if err != nil {
+		return fmt.Errorf("invalid --dry-run: %w", err)
+	}
+
+	cluster, err := g.connect("")
+	if err != nil {
+		return err
+	}
+
+	opts := append(g.clientOptions(),
+		rollout.WithClusterName(cluster.Name),
+		rollout.WithDryRun(dryRun),
+		rollout.WithWaitForRollout(o.wait),
+		rollout.WithReloadQuietPeriod(o.quietPeriod),
+		rollout.WithUsageTracker(cluster.Usage),
+	)
+	if o.freezeConfigMap != "" {
+		namespace, name, ok := strings.Cut(o.freezeConfigMap, "/")
+		if !ok || namespace == "" || name == "" {
+			return fmt.Errorf("invalid --freeze-configmap %q, expected namespace/name", o.freezeConfigMap)
+		}
+		opts = append(opts, rollout.WithFreezeConfigMap(namespace, name))
+	}
+
+	ctx, stop := g.env.Context()
+	defer stop()
+
+	return rollout.NewRolloutClient(cluster.Clientset, g.filter, rolloutLogger(componentLogger), opts...).WatchConfig(ctx, func(report *rollout.Report) {
+		logUsage(componentLogger, report.Usage)
+		if g.historyDir == "" {
+			return
+		}
+		if path, err := saveRun(g.env.FS, g.historyDir, report); err != nil {
+			componentLogger.WithError(err).Error("Failed to save run history")
+		} else {
+			componentLogger.WithField("path", path).Debug("Saved run history")
+		}
+	})
+}
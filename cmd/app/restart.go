@@ -0,0 +1,976 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// defaultFreezeConfigMap is where incident tooling freezes restarts cluster-wide, see --freeze-configmap.
+const defaultFreezeConfigMap = "kube-system/rollout-freeze"
+
+// restartOptions are the flags of the restart subcommand.
+type restartOptions struct {
+	campaignName     string
+	campaignsFile    string
+	campaignStateDir string
+	// campaign is the one named by --campaign, loaded and applied before the run
+	campaign         *campaign
+	checkpoint       string
+	signingKey       string
+	initiator        string
+	reason           string
+	reportFormat     string
+	reportFile       string
+	output           string
+	allContexts      bool
+	clusters         []string
+	fleet            string
+	clusterSelector  string
+	supportBundle    string
+	reportGitRepo    string
+	reportGitPath    string
+	reportGitPush    bool
+	ownerKeys        string
+	team             string
+	ownerWebhooks    string
+	cpuPrice         float64
+	memoryPrice      float64
+	surgeWindow      time.Duration
+	timeout          time.Duration
+	strategy         string
+	pods             string
+	cordonedNodes    bool
+	containers       string
+	containerCommand string
+	policyCommand    string
+	maxRisk          int
+	allowLocalData   bool
+	respectPDB       bool
+	backupTimeout    time.Duration
+	snapshotVolumes  bool
+	snapshotClass    string
+	operatorHandlers bool
+	autoIntegrations bool
+	argoRollouts     bool
+	cronJobs         bool
+	recreateJobs     bool
+	diffPods         time.Duration
+	requestTimeout   time.Duration
+	tiers            string
+	tierGateTimeout  time.Duration
+	order            string
+	namespaceOrder   string
+	dryRun           string
+	yes              bool
+	retryFailed      string
+	planFile         string
+	wait             time.Duration
+	metricsAddr      string
+	concurrency      int
+	kubectlParity    bool
+	stampAnnotations map[string]string
+	stampLabels      map[string]string
+	fieldManager     string
+	serverSide       bool
+	forceSSA         bool
+	failOnError      bool
+	maxErrors        int
+	canary           bool
+	canaryPercent    int
+	canaryTimeout    time.Duration
+	notifyAnnotated  bool
+	notifySMTPAddr   string
+	notifySMTPFrom   string
+	notifyWebhooks   []string
+	adaptiveBatches  int
+	schedule         string
+	offPeak          []string
+	urgent           bool
+	freezeConfigMap  string
+	freezeWait       time.Duration
+	interval         time.Duration
+	jitter           time.Duration
+	accessCheck      bool
+	serverCheck      bool
+	incidentSources  []string
+	incidentServices []string
+	statuspagePage   string
+	overrideIncident bool
+}
+
+// newRestartCommand returns the "restart" subcommand, gracefully restarting every workload matching the filter.
+func newRestartCommand(g *globalOptions) *cobra.Command {
+	o := &restartOptions{}
+	cmd := &cobra.Command{
+		Use:   "restart",
+		Short: "Restart every workload matching the filter",
+		Args:  cobra.NoArgs,
+		// The campaign may set global flags too, e.g. --log-level or --selector, so it is applied before they
+		// are validated
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if o.campaignName != "" {
+				c, err := loadCampaign(g.env.FS, o.campaignsFile, o.campaignName)
+				if err != nil {
+					return err
+				}
+				if err := applyCampaign(cmd.Flags(), c); err != nil {
+					return err
+				}
+				o.campaign = &c
+			}
+			return g.setup()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRestart(g, o)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&o.campaignName, "campaign", "", "Run the named campaign from --campaigns, its flags apply unless given explicitly")
+	flags.StringVar(&o.campaignsFile, "campaigns", "campaigns.yaml", "YAML file of named campaigns")
+	flags.StringVar(&o.campaignStateDir, "campaign-state-dir", defaultCampaignStateDir(), "Directory the progress of campaigns spread over several runs is kept in (see campaign status)")
+	flags.StringVar(&o.checkpoint, "checkpoint", "", "Path to a checkpoint file, a run interrupted with Ctrl+C resumes from it when re-run with the same path")
+	flags.StringVar(&o.signingKey, "signing-key", "", "Path to a PEM encoded ed25519 private key used to sign the provenance annotation on restarted workloads")
+	flags.StringVar(&o.initiator, "initiator", currentUser(g.env), "Initiator recorded in the signed provenance annotation")
+	flags.StringVar(&o.reason, "reason", "", "Reason recorded in the signed provenance annotation")
+	flags.StringVar(&o.reportFormat, "report-format", "", "Write a per-resource report of the run, currently only csv is supported")
+	flags.StringVar(&o.reportFile, "report-file", "", "File to write the report to, defaults to stdout")
+	flags.BoolVar(&o.allContexts, "all-contexts", false, "Restart in every context of the kubeconfig, one cluster after the other, with a summary per cluster")
+	flags.StringVar(&o.fleet, "fleet", "", "YAML fleet inventory of clusters with their kubeconfig, context and labels, restart in each of its clusters (or the ones matching --cluster-selector) at once, as with --clusters")
+	flags.StringVar(&o.clusterSelector, "cluster-selector", "", "Label selector picking the clusters of the --fleet inventory to restart in, e.g. region=eu,tier=staging")
+	flags.StringSliceVar(&o.clusters, "clusters", nil, "Restart in each of these kubeconfig contexts or kubeconfig files at once, e.g. prod-eu,prod-us, a cluster failing doesn't stop the others and --output and --report-format merge their results")
+	flags.StringVar(&o.supportBundle, "support-bundle", "", "When the run has failures, write a support bundle (.tar.gz) of the report and the manifests, events and logs of the failed workloads to this path, suffixed with the cluster name with --all-contexts or --clusters")
+	flags.StringVarP(&o.output, "output", "o", "", "Print the summary of the run to stdout as json or yaml, with the result of every workload, for automation")
+	flags.StringVar(&o.reportGitRepo, "report-git-repo", "", "Git checkout to commit the CSV report of the run into, leaving an audit trail next to the infrastructure code")
+	flags.StringVar(&o.reportGitPath, "report-git-path", defaultReportGitPath, "Path of the committed report within --report-git-repo, a template with .Date, .Time, .Cluster and .Campaign")
+	flags.BoolVar(&o.reportGitPush, "report-git-push", false, "Push the report commit to the checkout's upstream")
+	flags.StringVar(&o.ownerKeys, "owner-keys", strings.Join(rollout.DefaultOwnerKeys, ","), "Comma separated workload annotation/label keys the service owner is resolved from")
+	flags.StringVar(&o.team, "team", "", "Only restart workloads owned by this team, as resolved from --owner-keys, combine with --owner-webhooks to notify it")
+	flags.StringVar(&o.ownerWebhooks, "owner-webhooks", "", "JSON file mapping owners to webhook URLs, each owner is notified about restarts of their workloads")
+	flags.BoolVar(&o.notifyAnnotated, "notify-annotated", false, "Notify the targets in each restarted workload's "+rollout.NotifyAnnotation+" annotation: Slack channels (#channel, posted with $SLACK_TOKEN), email addresses or webhook URLs")
+	flags.StringVar(&o.notifySMTPAddr, "notify-smtp-addr", "", "Mail server (host:port) --notify-annotated sends email through, authenticating with $SMTP_USERNAME and $SMTP_PASSWORD when set")
+	flags.StringVar(&o.notifySMTPFrom, "notify-smtp-from", "", "Sender address of --notify-annotated emails")
+	flags.StringSliceVar(&o.notifyWebhooks, "notify-webhook", nil, "Post the summary of the run (counts, errors, duration) to this Slack incoming webhook or generic HTTP webhook once it finishes, repeatable")
+	flags.Float64Var(&o.cpuPrice, "cpu-hour-price", 0, "Price of one CPU core hour, used to estimate the cost of the run")
+	flags.Float64Var(&o.memoryPrice, "memory-gib-hour-price", 0, "Price of one GiB of memory per hour, used to estimate the cost of the run")
+	flags.DurationVar(&o.surgeWindow, "surge-window", 5*time.Minute, "How long each restarted pod is assumed to overlap with its replacement when estimating cost")
+	flags.StringVar(&o.schedule, "schedule", "", "Keep running and restart on this cron schedule, e.g. \"0 3 * * *\" (CRON_TZ=<zone> prefix for a time zone other than the local one), a run still going at the next scheduled time skips it")
+	flags.StringArrayVar(&o.offPeak, "off-peak", nil, "Only start runs in this off-peak window, given as [DAYS ]HH:MM-HH:MM in the --timezone zone, e.g. \"22:00-06:00\" or \"Sat,Sun 00:00-24:00\", a run due outside every window waits for the next one and isn't stopped when its window closes, repeatable")
+	flags.BoolVar(&o.urgent, "urgent", false, "Start the run at once, outside the --off-peak windows, e.g. to roll out an urgent patch with a campaign that is otherwise deferred to off-peak hours")
+	flags.DurationVar(&o.timeout, "timeout", 0, "Cancel the run once it has taken this long, 0 disables the timeout")
+	flags.StringVar(&o.strategy, "strategy", "", "How to replace the pods of matched workloads: rollout (patch the pod template like kubectl rollout restart), evict (evict the pods) or scale (scale to 0 and back, with downtime), defaults to evict with --pods, --cordoned-nodes or --containers and rollout otherwise")
+	flags.StringVar(&o.pods, "pods", "", "Comma separated pods to cycle by eviction instead of rolling the whole workload, StatefulSet pods may also be given as ordinals or ranges, e.g. 0-2")
+	flags.BoolVar(&o.cordonedNodes, "cordoned-nodes", false, "Only cycle, by eviction, pods of matched workloads running on cordoned nodes")
+	flags.StringVar(&o.containers, "containers", "", "Comma separated containers to restart in place, by exec'ing --container-restart-command, instead of rolling the whole workload")
+	flags.StringVar(&o.containerCommand, "container-restart-command", "kill 1", "Shell command exec'd into each container named by --containers to make it exit")
+	flags.StringVar(&o.policyCommand, "policy-command", "", "Shell command consulted for every matched workload, it receives the workload as JSON on stdin and prints {\"allow\": bool, \"strategy\": \"rollout|evict|scale\", \"reason\": string}")
+	flags.IntVar(&o.maxRisk, "max-risk", 0, "Skip matched workloads with a restart risk score (0-100) at or above this, e.g. 50 to hold back single replica workloads without budget slack, 0 restarts everything")
+	flags.BoolVar(&o.respectPDB, "respect-pdb", false, "Skip workloads whose rollout would take down more pods at once than their PodDisruptionBudget allows, instead of only warning")
+	flags.BoolVar(&o.allowLocalData, "allow-local-data", false, "Also restart workloads whose pods keep data in hostPath, in-memory or large emptyDir volumes, which is lost on restart")
+	flags.DurationVar(&o.backupTimeout, "backup-timeout", rollout.DefaultBackupTimeout, "How long to wait for the pre-restart backup of an annotated workload before failing its restart")
+	flags.BoolVar(&o.snapshotVolumes, "snapshot-volumes", false, "Take CSI VolumeSnapshots of StatefulSet volumes before restarting them")
+	flags.StringVar(&o.snapshotClass, "snapshot-class", "", "VolumeSnapshotClass for --snapshot-volumes, defaults to the cluster default")
+	flags.BoolVar(&o.operatorHandlers, "operator-handlers", false, "Restart workloads managed by supported database operators (Strimzi, Crunchy PGO) through the operator")
+	flags.BoolVar(&o.cronJobs, "cronjobs", false, "Restart CronJobs too, stamping their job template so the next Job they start picks up the change")
+	flags.BoolVar(&o.recreateJobs, "recreate-jobs", false, "Restart running Jobs too by deleting and creating them again, Jobs started by a CronJob are left to it")
+	flags.BoolVar(&o.argoRollouts, "argo-rollouts", false, "Restart Argo Rollouts (argoproj.io/v1alpha1 Rollout) too, through their spec.restartAt as kubectl argo rollouts restart does")
+	flags.BoolVar(&o.autoIntegrations, "auto-integrations", false, "Detect which optional integrations each cluster has installed (volume snapshots, Argo Rollouts, database operators) and enable them, as --snapshot-volumes, --argo-rollouts and --operator-handlers do, listing them at debug level")
+	flags.DurationVar(&o.diffPods, "diff-pods", 0, "Wait up to this long for the first new pod of each restarted workload and report how its spec differs from the replaced pods, 0 disables")
+	flags.DurationVar(&o.requestTimeout, "request-timeout", rollout.DefaultRequestTimeout, "Timeout for each individual API call, 0 disables it")
+	flags.StringVar(&o.tiers, "tiers", "", "YAML file of ordered namespace tiers (e.g. dev, staging, prod), each tier only starts once the previous one has rolled out")
+	flags.DurationVar(&o.tierGateTimeout, "tier-gate-timeout", rollout.DefaultTierGateTimeout, "How long to wait for a tier to roll out before stopping the run")
+	flags.StringVar(&o.order, "order", string(rollout.OrderRisk), "Order to restart matched workloads in, risk (lowest risk first) or name (namespace, kind and name)")
+	flags.StringVar(&o.namespaceOrder, "namespace-order", "", namespaceOrderUsage)
+	flags.StringVar(&o.dryRun, "dry-run", "none", "Only show what would be restarted: none, client (nothing is sent to the API server) or server (changes are validated by the API server without being persisted)")
+	flags.Lookup("dry-run").NoOptDefVal = "client"
+	flags.BoolVarP(&o.yes, "yes", "y", false, "Restart without asking for confirmation, which is only asked for when stdin is a terminal")
+	flags.DurationVar(&o.interval, "interval", 0, "Space restarts out by this long, e.g. 30s to restart one workload every 30s, 0 restarts them back to back")
+	flags.DurationVar(&o.jitter, "jitter", 0, "Vary the --interval between restarts by a random amount of up to this long either way, e.g. 10s for 30s ±10s")
+	flags.DurationVar(&o.wait, "wait", 0, "Wait up to this long for each restarted workload to finish rolling out, recording it as failed if it doesn't, 0 doesn't wait")
+	flags.StringVar(&o.fieldManager, "field-manager", rollout.DefaultFieldManager, "Field manager restarts are sent as, shown in the managedFields of restarted workloads")
+	flags.BoolVar(&o.serverSide, "server-side", false, "Set the restart annotation with server-side apply, failing on workloads where another field manager (e.g. a GitOps controller) owns it")
+	flags.BoolVar(&o.forceSSA, "force-ssa", false, "Take over ownership of the restart annotation on server-side apply conflicts, implies --server-side")
+	flags.StringToStringVar(&o.stampAnnotations, "stamp-annotation", nil, "Also set these key=value annotations on the pod template of every restarted workload, e.g. ones admission policies require to allow changes during a freeze, repeatable")
+	flags.StringToStringVar(&o.stampLabels, "stamp-label", nil, "Also set these key=value labels on the pod template of every restarted workload, repeatable")
+	flags.BoolVar(&o.kubectlParity, "kubectl-parity", false, "Send exactly the patch kubectl rollout restart does, as its kubectl-rollout field manager, can't be combined with --signing-key")
+	flags.IntVar(&o.concurrency, "concurrency", 1, "Number of namespaces to process in parallel, workloads within a namespace are still restarted one at a time")
+	flags.IntVar(&o.adaptiveBatches, "adaptive-batches", 0, "With --wait, restart workloads in batches that grow while they roll out quickly and shrink on failures or slowdowns, up to this size, 0 restarts one at a time")
+	flags.StringVar(&o.metricsAddr, "metrics-addr", "", "Serve Prometheus metrics on /metrics at this address (e.g. :9090) while the run lasts")
+	flags.BoolVar(&o.canary, "canary", false, "Restart one matching workload per namespace first, and only restart the rest once it has rolled out, aborting the run otherwise")
+	flags.IntVar(&o.canaryPercent, "canary-percent", 0, "Percentage of each namespace's matching workloads restarted as canaries, 0 restarts a single one, implies --canary")
+	flags.DurationVar(&o.canaryTimeout, "canary-timeout", rollout.DefaultCanaryTimeout, "How long the canaries have to roll out before the run is aborted")
+	flags.StringVar(&o.freezeConfigMap, "freeze-configmap", defaultFreezeConfigMap, "Namespace/name of the ConfigMap that freezes restarts cluster-wide while it exists, e.g. during an incident, with optional reason and until (RFC3339) keys, empty disables the check")
+	flags.DurationVar(&o.freezeWait, "freeze-wait", 0, "Wait up to this long for a cluster-wide freeze to be lifted instead of refusing to run right away")
+	flags.BoolVar(&o.serverCheck, "server-check", true, "Check the cluster runs a supported Kubernetes version serving the APIs and features the run uses before restarting anything")
+	flags.BoolVar(&o.accessCheck, "access-check", true, "Check the permissions the run needs (list and patch workloads, list namespaces) before restarting anything, failing right away with the missing ones")
+	flags.StringSliceVar(&o.incidentSources, "incident-check", nil, "Refuse to run while pagerduty (queried with $PAGERDUTY_TOKEN) or statuspage (with $STATUSPAGE_API_KEY) reports an active Sev1 incident affecting the targeted services, repeatable")
+	flags.StringSliceVar(&o.incidentServices, "incident-services", nil, "Globs of the PagerDuty services or Statuspage components the run targets, defaults to the ones whose name contains --filter")
+	flags.StringVar(&o.statuspagePage, "statuspage-page", "", "ID of the Statuspage page --incident-check=statuspage queries")
+	flags.BoolVar(&o.overrideIncident, "override-incident", false, "Run even though --incident-check found active Sev1 incidents, or couldn't check for them")
+	flags.IntVar(&o.maxErrors, "max-errors", 0, "Abort the run, with a summary of what was done, once this many workloads have failed to restart, 0 never aborts")
+	flags.BoolVar(&o.failOnError, "fail-on-error", true, "Exit non-zero when any workload failed to restart or the run had errors, set it to false to only report them")
+	flags.StringVar(&o.retryFailed, "retry-failed", "", "Only restart the workloads that failed in this previous run, given by run ID or report file (see history list)")
+	flags.StringVar(&o.planFile, "plan-file", "", "Only restart the workloads listed in this plan file (see plan --output), on top of --filter and the other restrictions, workloads created since are left alone")
+	return cmd
+}
+
+func runRestart(g *globalOptions, o *restartOptions) error {
+	logger := g.logger
+
+	// spread is the campaign when it is spread over several runs
+	var spread *campaign
+	if c := o.campaign; c != nil {
+		if c.PerRun != "" {
+			if err := validateSpread(*c); err != nil {
+				return err
+			}
+			if o.retryFailed != "" || o.planFile != "" {
+				return fmt.Errorf("--retry-failed and --plan-file can't be combined with a campaign spread over several runs")
+			}
+			if o.campaignStateDir == "" {
+				return fmt.Errorf("campaign %s is spread over several runs, it needs a --campaign-state-dir", o.campaignName)
+			}
+			spread = c
+		}
+		logger.WithFields(logrus.Fields{
+			"campaign":    o.campaignName,
+			"description": c.Description,
+		}).Info("Running campaign")
+	}
+
+	var freezeNamespace, freezeName string
+	if o.freezeConfigMap != "" {
+		var ok bool
+		if freezeNamespace, freezeName, ok = strings.Cut(o.freezeConfigMap, "/"); !ok || freezeNamespace == "" || freezeName == "" {
+			return fmt.Errorf("invalid --freeze-configmap %q, expected namespace/name", o.freezeConfigMap)
+		}
+	}
+	if o.maxErrors < 0 {
+		return fmt.Errorf("invalid --max-errors %d, expected 0 or more", o.maxErrors)
+	}
+	if o.canaryPercent < 0 || o.canaryPercent > 100 {
+		return fmt.Errorf("invalid --canary-percent %d, expected 0 to 100", o.canaryPercent)
+	}
+	for _, source := range o.incidentSources {
+		if source != incidentSourcePagerDuty && source != incidentSourceStatuspage {
+			return fmt.Errorf("invalid --incident-check %q, expected %s or %s", source, incidentSourcePagerDuty, incidentSourceStatuspage)
+		}
+	}
+	if o.reportFormat != "" && o.reportFormat != "csv" {
+		return fmt.Errorf("unsupported report format %q, expected csv", o.reportFormat)
+	}
+	if o.output != "" && o.output != "json" && o.output != "yaml" {
+		return fmt.Errorf("unsupported output %q, expected json or yaml", o.output)
+	}
+	if o.retryFailed != "" && o.planFile != "" {
+		return fmt.Errorf("--retry-failed and --plan-file both pick the workloads to restart, give one of them")
+	}
+	if o.output != "" && o.reportFormat != "" && o.reportFile == "" {
+		return fmt.Errorf("--output and --report-format both write to stdout, give the report a --report-file")
+	}
+	order, err := parseOrder(o.order)
+	if err != nil {
+		return fmt.Errorf("invalid --order: %w", err)
+	}
+	namespaceOrder, err := parseNamespaceOrder(o.namespaceOrder)
+	if err != nil {
+		return fmt.Errorf("invalid --namespace-order: %w", err)
+	}
+	dryRun, err := parseDryRun(o.dryRun)
+	if err != nil {
+		return fmt.Errorf("invalid --dry-run: %w", err)
+	}
+	if o.adaptiveBatches > 0 && o.wait <= 0 {
+		return fmt.Errorf("--adaptive-batches needs --wait, batches are sized by how long they take to roll out")
+	}
+	if o.interval < 0 || o.jitter < 0 {
+		return fmt.Errorf("--interval and --jitter can't be negative")
+	}
+	if o.jitter > 0 && o.interval == 0 {
+		return fmt.Errorf("--jitter needs --interval, it varies the interval between restarts")
+	}
+	if o.adaptiveBatches > 0 && o.interval > 0 {
+		return fmt.Errorf("--adaptive-batches and --interval can't be combined, batches restart their workloads at once")
+	}
+	if o.adaptiveBatches > 0 && o.concurrency > 1 {
+		return fmt.Errorf("--adaptive-batches and --concurrency can't be combined")
+	}
+	var schedule cron.Schedule
+	if o.schedule != "" {
+		if schedule, err = cron.ParseStandard(o.schedule); err != nil {
+			return fmt.Errorf("invalid --schedule: %w", err)
+		}
+	}
+	offPeak, err := parseOffPeakWindows(o.offPeak)
+	if err != nil {
+		return fmt.Errorf("invalid --off-peak: %w", err)
+	}
+	if o.urgent {
+		offPeak = nil
+	}
+	if o.allContexts && g.kubeContext != "" {
+		return fmt.Errorf("--all-contexts and --context can't be combined")
+	}
+	if len(o.clusters) > 0 && (o.allContexts || g.kubeContext != "") {
+		return fmt.Errorf("--clusters can't be combined with --all-contexts or --context")
+	}
+	if o.fleet != "" && (len(o.clusters) > 0 || o.allContexts || g.kubeContext != "") {
+		return fmt.Errorf("--fleet can't be combined with --clusters, --all-contexts or --context")
+	}
+	if o.clusterSelector != "" && o.fleet == "" {
+		return fmt.Errorf("--cluster-selector needs --fleet, the inventory the clusters are selected from")
+	}
+	clusterSelector, err := labels.Parse(o.clusterSelector)
+	if err != nil {
+		return fmt.Errorf("invalid --cluster-selector: %w", err)
+	}
+	if multiCluster := o.allContexts || len(o.clusters) > 0 || o.fleet != ""; multiCluster && o.checkpoint != "" {
+		return fmt.Errorf("--checkpoint can't be combined with --all-contexts, --clusters or --fleet, the checkpoint doesn't tell clusters apart")
+	} else if multiCluster && spread != nil {
+		return fmt.Errorf("a campaign spread over several runs can't be combined with --all-contexts, --clusters or --fleet")
+	}
+	if o.pods != "" && o.cordonedNodes {
+		return fmt.Errorf("--pods and --cordoned-nodes can't be combined")
+	}
+	var strategy rollout.RestartStrategy
+	if o.strategy != "" {
+		if strategy, err = rollout.ParseRestartStrategy(o.strategy); err != nil {
+			return fmt.Errorf("invalid --strategy: %w", err)
+		}
+		if strategy != rollout.EvictStrategy && (o.pods != "" || o.cordonedNodes || o.containers != "") {
+			return fmt.Errorf("--pods, --cordoned-nodes and --containers cycle pods, they need the %s strategy", rollout.StrategyEvict)
+		}
+	}
+	if o.kubectlParity && o.signingKey != "" {
+		return fmt.Errorf("--kubectl-parity and --signing-key can't be combined, kubectl doesn't sign restarts")
+	}
+	if o.kubectlParity && (len(o.stampAnnotations) > 0 || len(o.stampLabels) > 0) {
+		return fmt.Errorf("--kubectl-parity can't be combined with --stamp-annotation or --stamp-label, kubectl only sets restartedAt")
+	}
+	if err := validateStamp(o.stampAnnotations, o.stampLabels); err != nil {
+		return err
+	}
+	if o.kubectlParity && (o.serverSide || o.forceSSA) {
+		return fmt.Errorf("--kubectl-parity can't be combined with server-side apply, kubectl rollout restart sends a strategic merge patch")
+	}
+
+	componentLogger := logger.WithField("component", "rollout")
+	var clusters []*Cluster
+	if o.fleet != "" {
+		clusters, err = g.connectFleet(o.fleet, clusterSelector)
+	} else {
+		clusters, err = g.connectAll(o.clusters, o.allContexts)
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := append(g.clientOptions(),
+		rollout.WithOwnerKeys(strings.Split(o.ownerKeys, ",")...),
+		rollout.WithRequestTimeout(o.requestTimeout),
+		rollout.WithTeam(o.team),
+		rollout.WithRiskThreshold(o.maxRisk),
+		rollout.WithBackupTimeout(o.backupTimeout),
+		rollout.WithPodSpecDiff(o.diffPods),
+		rollout.WithOrder(order),
+		rollout.WithNamespaceOrder(namespaceOrder),
+		rollout.WithDryRun(dryRun),
+		rollout.WithWaitForRollout(o.wait),
+		rollout.WithInterval(o.interval, o.jitter),
+		rollout.WithConcurrency(o.concurrency),
+		rollout.WithFieldManager(o.fieldManager),
+	)
+	if strategy != nil {
+		opts = append(opts, rollout.WithRestartStrategy(strategy))
+	}
+	if o.pods != "" {
+		opts = append(opts, rollout.WithPods(strings.Split(o.pods, ",")...))
+	}
+	if o.cordonedNodes {
+		opts = append(opts, rollout.WithCordonedNodesOnly())
+	}
+	if o.allowLocalData {
+		opts = append(opts, rollout.WithLocalDataConfirmed())
+	}
+	if o.respectPDB {
+		opts = append(opts, rollout.WithRespectPDB())
+	}
+	if o.policyCommand != "" {
+		opts = append(opts, rollout.WithPolicy(rollout.CommandPolicy{"/bin/sh", "-c", o.policyCommand}))
+	}
+	if o.retryFailed != "" {
+		previous, err := loadRun(g.env.FS, g.historyDir, o.retryFailed)
+		if err != nil {
+			return fmt.Errorf("failed to load the run to retry: %w", err)
+		}
+		plan := rollout.RetryPlan(previous)
+		if len(plan.Workloads) == 0 {
+			componentLogger.WithField("run", previous.RunID).Info("Nothing failed in the run, nothing to retry")
+			return nil
+		}
+		componentLogger.WithFields(logrus.Fields{
+			"run":    previous.RunID,
+			"failed": len(plan.Workloads),
+		}).Info("Retrying the failed workloads of a previous run")
+		opts = append(opts, rollout.WithPlan(plan))
+	}
+	if o.planFile != "" {
+		plan, err := loadPlan(g.env.FS, o.planFile)
+		if err != nil {
+			return err
+		}
+		componentLogger.WithFields(logrus.Fields{
+			"plan":      o.planFile,
+			"workloads": len(plan.Workloads),
+		}).Info("Restarting the workloads of a plan")
+		opts = append(opts, rollout.WithPlan(plan))
+	}
+	if o.tiers != "" {
+		tiers, err := loadTiers(g.env.FS, o.tiers)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, rollout.WithTiers(tiers...), rollout.WithTierGateTimeout(o.tierGateTimeout))
+	}
+	if o.serverSide || o.forceSSA {
+		opts = append(opts, rollout.WithServerSideApply(o.forceSSA))
+	}
+	if o.kubectlParity {
+		opts = append(opts, rollout.WithKubectlParity())
+	}
+	if len(o.stampAnnotations) > 0 || len(o.stampLabels) > 0 {
+		opts = append(opts, rollout.WithTemplateStamp(o.stampAnnotations, o.stampLabels))
+	}
+	if o.canary || o.canaryPercent > 0 {
+		opts = append(opts, rollout.WithCanary(o.canaryPercent, o.canaryTimeout))
+	}
+	if o.maxErrors > 0 {
+		opts = append(opts, rollout.WithMaxErrors(o.maxErrors))
+	}
+	if o.cronJobs {
+		opts = append(opts, rollout.WithCronJobs())
+	}
+	if o.recreateJobs {
+		opts = append(opts, rollout.WithJobRecreation())
+	}
+	if o.failOnError {
+		opts = append(opts, rollout.WithFailOnErrors())
+	}
+	if o.adaptiveBatches > 0 {
+		opts = append(opts, rollout.WithAdaptiveBatches(o.adaptiveBatches))
+	}
+	for _, url := range o.notifyWebhooks {
+		opts = append(opts, rollout.WithNotifiers(rollout.WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}))
+	}
+	if freezeName != "" {
+		opts = append(opts, rollout.WithFreezeConfigMap(freezeNamespace, freezeName), rollout.WithFreezeWait(o.freezeWait))
+	}
+	if o.serverCheck {
+		opts = append(opts, rollout.WithServerCheck())
+	}
+	if o.accessCheck {
+		opts = append(opts, rollout.WithAccessCheck())
+	}
+	if o.checkpoint != "" {
+		opts = append(opts, rollout.WithCheckpoint(g.env.FS, o.checkpoint))
+	}
+	if o.signingKey != "" {
+		data, err := g.env.FS.ReadFile(o.signingKey)
+		if err != nil {
+			return fmt.Errorf("failed to read signing key: %w", err)
+		}
+		key, err := rollout.ParseSigningKey(data)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key: %w", err)
+		}
+		opts = append(opts, rollout.WithProvenanceSigning(key, o.initiator, o.reason))
+	}
+
+	var callbacks rollout.Callbacks
+	var metrics *runMetrics
+	if o.metricsAddr != "" {
+		metrics = newRunMetrics()
+		stopMetrics, err := metrics.serve(componentLogger, o.metricsAddr)
+		if err != nil {
+			return fmt.Errorf("failed to serve metrics: %w", err)
+		}
+		defer stopMetrics()
+		callbacks = metrics.callbacks()
+		var usage []*rollout.UsageTracker
+		for _, cluster := range clusters {
+			if cluster.Usage != nil {
+				usage = append(usage, cluster.Usage)
+			}
+		}
+		if len(usage) > 0 {
+			metrics.trackUsage(usage...)
+		}
+	}
+
+	// integrations are the optional integrations each cluster has, detected with --auto-integrations
+	integrations := map[*Cluster][]rollout.Integration{}
+	if o.autoIntegrations {
+		for _, cluster := range clusters {
+			detected, err := rollout.DetectIntegrations(cluster.Clientset.Discovery())
+			if err != nil {
+				return fmt.Errorf("cluster %s: %w", cluster.Name, err)
+			}
+			names := make([]string, 0, len(detected))
+			for _, integration := range detected {
+				names = append(names, string(integration))
+			}
+			componentLogger.WithFields(logrus.Fields{
+				"cluster":      cluster.Name,
+				"integrations": strings.Join(names, ","),
+			}).Debug("Detected integrations")
+			integrations[cluster] = detected
+		}
+	}
+
+	// clusterOptions are the options of a run against cluster
+	clusterOptions := func(cluster *Cluster) []rollout.Option {
+		clusterOpts := append(slices.Clip(opts),
+			rollout.WithClusterName(cluster.Name),
+			rollout.WithUsageTracker(cluster.Usage),
+		)
+		if o.containers != "" {
+			clusterOpts = append(clusterOpts, rollout.WithContainerRestart(cluster.Config, strings.Split(o.containers, ","), "/bin/sh", "-c", o.containerCommand))
+		}
+		if o.snapshotVolumes || slices.Contains(integrations[cluster], rollout.IntegrationVolumeSnapshots) {
+			clusterOpts = append(clusterOpts, rollout.WithVolumeSnapshots(cluster.Dynamic, o.snapshotClass))
+		}
+		if o.argoRollouts || slices.Contains(integrations[cluster], rollout.IntegrationArgoRollouts) {
+			clusterOpts = append(clusterOpts, rollout.WithArgoRollouts(cluster.Dynamic))
+		}
+		if o.operatorHandlers || slices.ContainsFunc(integrations[cluster], rollout.Integration.IsOperator) {
+			clusterOpts = append(clusterOpts, rollout.WithOperatorHandlers(cluster.Dynamic))
+		}
+		return clusterOpts
+	}
+
+	// previewCluster returns the plan of what a run against cluster would restart
+	previewCluster := func(ctx context.Context, cluster *Cluster) (*rollout.RestartPlan, error) {
+		runOpts := clusterOptions(cluster)
+		if spread != nil {
+			_, plan, err := campaignPlan(ctx, g, cluster, *spread, o.campaignName, o.campaignStateDir, runOpts)
+			if plan == nil {
+				plan = &rollout.RestartPlan{Filter: g.filter}
+			}
+			return plan, err
+		}
+		return rollout.Plan(ctx, cluster.Clientset, g.filter, runOpts...)
+	}
+
+	// restartCluster runs the restart against cluster, logging to log, and returns its report, nil when a
+	// spread campaign had nothing left to restart. A confirmed plan limits the run to its workloads.
+	restartCluster := func(ctx context.Context, cluster *Cluster, log logrus.FieldLogger, confirmed *rollout.RestartPlan) (*rollout.Report, error) {
+		runOpts := clusterOptions(cluster)
+		var state *campaignState
+		if spread != nil {
+			var plan *rollout.RestartPlan
+			var err error
+			state, plan, err = campaignPlan(ctx, g, cluster, *spread, o.campaignName, o.campaignStateDir, runOpts)
+			if err != nil {
+				return nil, err
+			}
+			if plan == nil {
+				log.WithField("campaign", o.campaignName).Info("Campaign is complete, nothing left to restart")
+				logCampaignProgress(log, state)
+				return nil, saveCampaignState(g.env.FS, o.campaignStateDir, state)
+			}
+			log.WithFields(logrus.Fields{
+				"campaign":  o.campaignName,
+				"workloads": len(plan.Workloads),
+				"pending":   state.Pending,
+			}).Info("Restarting the next share of the campaign")
+			runOpts = append(runOpts, rollout.WithPlan(plan))
+		}
+		// Workloads matching since the confirmation weren't confirmed
+		if confirmed != nil {
+			runOpts = append(runOpts, rollout.WithPlan(confirmed))
+		}
+
+		cb := callbacks
+		cb.OnProgress = progressLogger(log, g.location)
+		rc := rollout.NewRolloutClient(cluster.Clientset, g.filter, rolloutLogger(log), runOpts...)
+		start := g.env.Clock.Now()
+		report, err := rc.RunWithCallbacks(ctx, cb)
+		if metrics != nil {
+			metrics.observeRun(g.env.Clock.Since(start), report.Usage)
+		}
+		logUsage(log, report.Usage)
+
+		// Warnings are repeated after the run, so they don't get lost among the progress output
+		for _, warning := range rc.Warnings() {
+			log.WithFields(logrus.Fields{
+				"kind":      warning.Kind,
+				"namespace": warning.Namespace,
+				"name":      warning.Name,
+				"reason":    warning.Reason,
+			}).Warn("Needs attention: " + warning.Message)
+		}
+
+		// A dry run restarts nothing, the campaign hasn't progressed
+		if state != nil && dryRun == rollout.DryRunNone {
+			recordCampaignRun(*spread, state, report, g.env.Clock.Now())
+			if stateErr := saveCampaignState(g.env.FS, o.campaignStateDir, state); stateErr != nil {
+				log.WithError(stateErr).Error("Failed to save campaign progress")
+			}
+			logCampaignProgress(log, state)
+		}
+
+		if g.historyDir != "" {
+			if path, historyErr := saveRun(g.env.FS, g.historyDir, report); historyErr != nil {
+				log.WithError(historyErr).Error("Failed to save run history")
+			} else {
+				log.WithField("path", path).Debug("Saved run history")
+			}
+		}
+
+		if o.supportBundle != "" && (report.Failed > 0 || len(report.Errors) > 0) {
+			path := o.supportBundle
+			if len(clusters) > 1 {
+				path = clusterPath(path, cluster.Name)
+			}
+			// The failures are just as worth escalating when the run was cancelled
+			bundleErr := writeOutput(g.env, path, func(w io.Writer) error {
+				return rc.WriteSupportBundle(context.WithoutCancel(ctx), w, report)
+			})
+			if bundleErr != nil {
+				log.WithError(bundleErr).Error("Failed to write support bundle")
+			} else {
+				log.WithField("path", path).Info("Wrote support bundle")
+			}
+		}
+		return report, err
+	}
+
+	// runOnce is a single run against every cluster, the only one unless it is scheduled
+	runOnce := func(ctx context.Context) error {
+		if o.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeoutCause(ctx, o.timeout, errRunTimeout)
+			defer cancel()
+		}
+
+		// A dry run changes nothing, it can't compound an incident
+		if len(o.incidentSources) > 0 && dryRun == rollout.DryRunNone {
+			checker := newIncidentChecker(g.env, o.statuspagePage)
+			if err := checkIncidents(ctx, componentLogger, checker, o.incidentSources, o.incidentServices, g.filter, o.overrideIncident); err != nil {
+				return err
+			}
+		}
+
+		// Only a person at a terminal can confirm, and a scheduled or dry run has nobody to ask each time
+		var confirmed []*rollout.RestartPlan
+		if !o.yes && schedule == nil && dryRun == rollout.DryRunNone && g.env.Interactive != nil && g.env.Interactive() {
+			plans := make([]*rollout.RestartPlan, len(clusters))
+			matched := 0
+			for i, cluster := range clusters {
+				var err error
+				if plans[i], err = previewCluster(ctx, cluster); err != nil {
+					return fmt.Errorf("failed to list the workloads to restart in %s: %w", cluster.Name, err)
+				}
+				matched += len(plans[i].Workloads)
+			}
+			if matched > 0 {
+				ok, err := confirmRestart(g.env, clusters, plans)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return errNotConfirmed
+				}
+				confirmed = plans
+			}
+		}
+
+		start := g.env.Clock.Now()
+		summaries := make([]clusterSummary, len(clusters))
+		restartNth := func(i int) {
+			summaries[i].name = clusters[i].Name
+			// Clusters not started yet are left alone once the run has been cancelled
+			if ctx.Err() != nil {
+				summaries[i].err = fmt.Errorf("not started: %w", context.Cause(ctx))
+				return
+			}
+			log := componentLogger
+			if len(clusters) > 1 {
+				log = componentLogger.WithField("cluster", clusters[i].Name)
+			}
+			var plan *rollout.RestartPlan
+			if confirmed != nil {
+				plan = confirmed[i]
+			}
+			summaries[i].report, summaries[i].err = restartCluster(ctx, clusters[i], log, plan)
+		}
+		// --clusters and --fleet restart every cluster at once, each failing on its own
+		if len(o.clusters) > 1 || o.fleet != "" {
+			var wg sync.WaitGroup
+			for i := range clusters {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					restartNth(i)
+				}()
+			}
+			wg.Wait()
+		} else {
+			for i := range clusters {
+				restartNth(i)
+			}
+		}
+
+		var reports []*rollout.Report
+		for _, s := range summaries {
+			if s.report != nil {
+				reports = append(reports, s.report)
+			}
+		}
+		var summary *rollout.Report
+		switch {
+		case len(clusters) > 1:
+			logClusterSummaries(componentLogger, summaries)
+			summary = rollout.MergeReports(reports...)
+			// A cluster the run failed in before recording any error of its own still shows up in the errors
+			for _, s := range summaries {
+				if s.err != nil && (s.report == nil || len(s.report.Errors) == 0) {
+					summary.Errors = append(summary.Errors, s.name+": "+s.err.Error())
+				}
+			}
+		case len(reports) == 1:
+			summary = reports[0]
+		}
+		var results []rollout.ResourceResult
+		if summary != nil {
+			results = summary.Results
+		}
+
+		if o.output != "" && summary != nil {
+			if outputErr := writeSummary(g.env.Stdout, o.output, summary); outputErr != nil {
+				componentLogger.WithError(outputErr).Error("Failed to print the run summary")
+			}
+		}
+
+		// The report is written even for failed or cancelled runs, it then holds the partial results
+		if o.reportFormat != "" {
+			if reportErr := writeReport(g.env, o.reportFile, results); reportErr != nil {
+				componentLogger.WithError(reportErr).Error("Failed to write report")
+			}
+		}
+
+		if o.reportGitRepo != "" {
+			data := newReportPathData(start, clustersName(clusters), o.campaignName)
+			path, gitErr := commitReport(context.WithoutCancel(ctx), g.env, o.reportGitRepo, o.reportGitPath, o.reportGitPush, data, results)
+			if gitErr != nil {
+				componentLogger.WithError(gitErr).Error("Failed to commit report")
+			} else {
+				componentLogger.WithField("path", path).Info("Committed report")
+			}
+		}
+
+		estimate := rollout.EstimateCost(results, rollout.CostModel{
+			CPUCoreHourPrice:   o.cpuPrice,
+			MemoryGiBHourPrice: o.memoryPrice,
+			SurgeWindow:        o.surgeWindow,
+		})
+		componentLogger.WithFields(logrus.Fields{
+			"pods_cycled":      estimate.PodsCycled,
+			"pod_hours":        fmt.Sprintf("%.2f", estimate.PodHours),
+			"cpu_core_hours":   fmt.Sprintf("%.2f", estimate.CPUCoreHours),
+			"memory_gib_hours": fmt.Sprintf("%.2f", estimate.MemoryGiBHours),
+			"estimated_cost":   fmt.Sprintf("%.2f", estimate.EstimatedCost),
+		}).Info("Estimated restart churn")
+
+		if o.ownerWebhooks != "" && dryRun == rollout.DryRunNone {
+			// Notifications still go out for a cancelled run, the workloads it did restart are just as disruptive
+			if notifyErr := notifyOwners(context.WithoutCancel(ctx), componentLogger, g.env.FS, o.ownerWebhooks, results); notifyErr != nil {
+				componentLogger.WithError(notifyErr).Error("Failed to notify owners")
+			}
+		}
+		if o.notifyAnnotated && dryRun == rollout.DryRunNone {
+			newAnnotationNotifier(g.env, o.notifySMTPAddr, o.notifySMTPFrom).notify(context.WithoutCancel(ctx), componentLogger, results)
+		}
+
+		return clustersError(summaries, len(clusters))
+	}
+
+	ctx, stop := g.env.Context()
+	defer stop()
+	if schedule != nil {
+		return runScheduled(ctx, componentLogger, g.env.Clock, g.location, o.schedule, schedule, offPeak, runOnce)
+	}
+	if err := waitForOffPeak(ctx, componentLogger, g.env.Clock, g.location, offPeak); err != nil {
+		return err
+	}
+	return runOnce(ctx)
+}
+
+// progressLogger returns a Callbacks.OnProgress logging the progress of a run to log, the ETA in loc.
+func progressLogger(log logrus.FieldLogger, loc *time.Location) func(rollout.Progress) {
+	return func(p rollout.Progress) {
+		fields := logrus.Fields{
+			"completed": p.Completed,
+			"matched":   p.Matched,
+		}
+		if !p.ETA.IsZero() {
+			fields["remaining"] = p.Remaining.Round(time.Second).String()
+			fields["eta"] = formatTimeIn(p.ETA, loc)
+		}
+		log.WithFields(fields).Info("Progress")
+	}
+}
+
+// clusterSummary is the outcome of a run against one cluster, report is nil when nothing was run.
+type clusterSummary struct {
+	name   string
+	report *rollout.Report
+	err    error
+}
+
+// logClusterSummaries logs the outcome of a run in each of several clusters.
+func logClusterSummaries(log logrus.FieldLogger, summaries []clusterSummary) {
+	for _, s := range summaries {
+		entry := log.WithField("cluster", s.name)
+		if s.report != nil {
+			entry = entry.WithFields(logrus.Fields{
+				"restarted": s.report.Restarted,
+				"failed":    s.report.Failed,
+				"skipped":   s.report.Skipped,
+				"cancelled": s.report.Cancelled,
+			})
+		}
+		if s.err != nil {
+			entry.WithError(s.err).Error("Cluster summary")
+			continue
+		}
+		entry.Info("Cluster summary")
+	}
+}
+
+// clustersError returns the error of a run against total clusters, naming the clusters it failed in when
+// there are several.
+func clustersError(summaries []clusterSummary, total int) error {
+	var errs []error
+	for _, s := range summaries {
+		if s.err == nil {
+			continue
+		}
+		if total == 1 {
+			return fmt.Errorf("rollout failed: %w", s.err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", s.name, s.err))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("rollout failed in %d of %d clusters: %w", len(errs), total, errors.Join(errs...))
+}
+
+// clusterPath inserts the name of cluster into path before its extension, e.g. bundle-prod-eu.tar.gz.
+func clusterPath(path, cluster string) string {
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(cluster)
+	for _, ext := range []string{".tar.gz", ".tgz", filepath.Ext(path)} {
+		if base, ok := strings.CutSuffix(path, ext); ok && ext != "" {
+			return base + "-" + name + ext
+		}
+	}
+	return path + "-" + name
+}
+
+// clustersName names the clusters of a run in report paths, the cluster itself when there is only one.
+func clustersName(clusters []*Cluster) string {
+	if len(clusters) == 1 {
+		return clusters[0].Name
+	}
+	return "multi-cluster"
+}
+
+// logUsage logs the client's own footprint during a run, shown with --log-level debug.
+func logUsage(log logrus.FieldLogger, usage *rollout.Usage) {
+	if usage == nil {
+		return
+	}
+	log.WithFields(logrus.Fields{
+		"api_requests":    usage.APIRequests,
+		"bytes_sent":      usage.BytesSent,
+		"bytes_received":  usage.BytesReceived,
+		"peak_memory_mib": fmt.Sprintf("%.1f", float64(usage.PeakMemoryBytes)/(1<<20)),
+	}).Debug("Run footprint")
+}
+
+// writeReport writes the run results as CSV to path, or stdout when path is empty.
+func writeReport(env Env, path string, results []rollout.ResourceResult) error {
+	return writeOutput(env, path, func(w io.Writer) error {
+		return rollout.WriteResultsCSV(w, results)
+	})
+}
+
+// writeSummary prints report to w as format, json or yaml. Each YAML summary is a document of its own, so
+// the summaries of scheduled runs can be told apart.
+func writeSummary(w io.Writer, format string, report *rollout.Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if format == "yaml" {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return err
+		}
+		data = append([]byte("---\n"), data...)
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if format == "json" {
+		_, err = io.WriteString(w, "\n")
+	}
+	return err
+}
+
+// validateStamp checks the keys of the --stamp-annotation and --stamp-label flags and the label values, so a
+// typo fails the run before anything is restarted rather than every patch.
+func validateStamp(annotations, labels map[string]string) error {
+	for key := range annotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid --stamp-annotation key %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	for key, value := range labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid --stamp-label key %q: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid --stamp-label value %q: %s", value, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// parseDryRun validates the value of the --dry-run flag.
+func parseDryRun(value string) (rollout.DryRunMode, error) {
+	switch value {
+	case "none":
+		return rollout.DryRunNone, nil
+	case string(rollout.DryRunClient), string(rollout.DryRunServer):
+		return rollout.DryRunMode(value), nil
+	}
+	return "", fmt.Errorf("unknown dry run mode %q, expected none, client or server", value)
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestRestartSigningKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{"app": "web"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		wantCode int
+		// want is what the command's stdout, or stderr when it fails, contains
+		want string
+	}{
+		{name: "signs the restart", key: "key.pem", want: `"rollout.tim-codez.io/provenance-signature"`},
+		{name: "missing key", key: "missing.pem", wantCode: 1, want: "failed to read signing key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, deployment)
+			env.fs.WriteFile("key.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+
+			env.run(t, tt.wantCode, "restart", "--filter", "web", "--signing-key", tt.key, "--output", "json",
+				"--server-check=false", "--access-check=false", "--history-dir", "")
+			out := env.stdout.String()
+			if tt.wantCode != 0 {
+				out = env.stderr.String()
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("got\n%s\nwant it to contain %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartMaxRisk(t *testing.T) {
+	labels := map[string]string{"app": "web"}
+	selector := &metav1.LabelSelector{MatchLabels: labels}
+	// A single replica whose budget allows no disruption at critical priority, a risk of 75
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](1),
+			Selector: selector,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{PriorityClassName: "system-cluster-critical"},
+			},
+		},
+	}
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: selector},
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "restarted by default", want: `"Restarted": 1`},
+		{name: "held back at or above --max-risk", args: []string{"--max-risk", "75"}, want: `"Skipped": 1`},
+		{name: "restarted below --max-risk", args: []string{"--max-risk", "76"}, want: `"Restarted": 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, deployment, pdb)
+			args := append([]string{"restart", "--filter", "web", "--output", "json", "--server-check=false", "--access-check=false", "--history-dir", ""}, tt.args...)
+			env.run(t, 0, args...)
+			if out := env.stdout.String(); !strings.Contains(out, tt.want) {
+				t.Errorf("got\n%s\nwant it to contain %q", out, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"k8s.io/utils/clock"
+)
+
+// runScheduled calls run at every time of schedule, spec being how it was given, until ctx is done, logging
+// times in loc. Runs never overlap: the times that pass while a run is still going are skipped, with a
+// warning. A failed run is logged and the next one goes ahead as scheduled. A run due outside every one of
+// offPeak waits for the next window, the times that pass meanwhile are covered by the deferred run.
+func runScheduled(ctx context.Context, log logrus.FieldLogger, clk clock.WithTicker, loc *time.Location, spec string, schedule cron.Schedule, offPeak []offPeakWindow, run func(context.Context) error) error {
+	log = log.WithField("schedule", spec)
+	for {
+		now := clk.Now()
+		next := schedule.Next(now)
+		if next.IsZero() {
+			log.Info("The schedule has no further runs")
+			return nil
+		}
+		log.WithField("next_run", formatTimeIn(next, loc)).Info("Waiting for the next scheduled run")
+
+		timer := clk.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C():
+		}
+		if waitForOffPeak(ctx, log, clk, loc, offPeak) != nil {
+			return nil
+		}
+		last := next
+		for t := schedule.Next(next); !t.IsZero() && !t.After(clk.Now()); t = schedule.Next(t) {
+			last = t
+		}
+
+		start := clk.Now()
+		err := run(ctx)
+		// An interrupted run ends the daemon with the run's error, like an unscheduled run
+		if ctx.Err() != nil {
+			return err
+		}
+		runLog := log.WithFields(logrus.Fields{
+			"scheduled_for": formatTimeIn(next, loc),
+			"took":          clk.Since(start).Round(time.Second).String(),
+		})
+		if err != nil {
+			runLog.WithError(err).Error("Scheduled run failed")
+		} else {
+			runLog.Info("Scheduled run completed")
+		}
+
+		skipped := 0
+		for t := schedule.Next(last); !t.IsZero() && !t.After(clk.Now()); t = schedule.Next(t) {
+			skipped++
+		}
+		if skipped > 0 {
+			log.WithField("skipped", skipped).Warn("Skipped scheduled runs, the previous run was still in progress")
+		}
+	}
+}
+
+// offPeakWindow is a recurring window of the day runs may start in, e.g. at night when production traffic is
+// low. A window ending before it starts runs past midnight, it belongs to the day it starts on.
+type offPeakWindow struct {
+	// days the window is open on, every day when nil
+	days map[time.Weekday]bool
+	// start and end are minutes after midnight
+	start, end int
+}
+
+// weekdays are the days of the week by the abbreviations off-peak windows name them with.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseOffPeakWindows parses off-peak windows given as [DAYS ]HH:MM-HH:MM, DAYS being a comma separated list
+// of days or ranges of days, e.g. "22:00-06:00", "Mon-Fri 20:00-23:00" or "Sat,Sun 00:00-24:00".
+func parseOffPeakWindows(specs []string) ([]offPeakWindow, error) {
+	windows := make([]offPeakWindow, 0, len(specs))
+	for _, spec := range specs {
+		w, err := parseOffPeakWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", spec, err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func parseOffPeakWindow(spec string) (offPeakWindow, error) {
+	var w offPeakWindow
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+	case 2:
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return w, err
+		}
+		w.days = days
+		fields = fields[1:]
+	default:
+		return w, fmt.Errorf("expected [DAYS ]HH:MM-HH:MM")
+	}
+
+	from, to, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return w, fmt.Errorf("expected a time range HH:MM-HH:MM")
+	}
+	var err error
+	if w.start, err = parseTimeOfDay(from); err != nil {
+		return w, err
+	}
+	if w.end, err = parseTimeOfDay(to); err != nil {
+		return w, err
+	}
+	if w.start == w.end || w.start == 24*60 {
+		return w, fmt.Errorf("the window is empty")
+	}
+	return w, nil
+}
+
+// parseWeekdays parses a comma separated list of days or ranges of days, e.g. Mon-Fri or Fri-Mon.
+func parseWeekdays(s string) (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	for _, item := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(item, "-")
+		from, ok := weekdays[strings.ToLower(first)]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q, expected one of Mon, Tue, Wed, Thu, Fri, Sat or Sun", first)
+		}
+		to := from
+		if isRange {
+			if to, ok = weekdays[strings.ToLower(last)]; !ok {
+				return nil, fmt.Errorf("unknown day %q, expected one of Mon, Tue, Wed, Thu, Fri, Sat or Sun", last)
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseTimeOfDay parses HH:MM into minutes after midnight, 24:00 being the end of the day.
+func parseTimeOfDay(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	h, hErr := strconv.Atoi(hours)
+	m, mErr := strconv.Atoi(minutes)
+	if !ok || hErr != nil || mErr != nil || h < 0 || m < 0 || m > 59 || h > 24 || h == 24 && m > 0 {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return h*60 + m, nil
+}
+
+// nextOffPeak returns t when one of windows is open at t, in loc (the local time zone when nil), and when
+// the next one opens otherwise.
+func nextOffPeak(windows []offPeakWindow, t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+
+	var next time.Time
+	// A window that opened the day before may still be open, and every window opens within a week
+	for offset := -1; offset <= 7; offset++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+offset, 0, 0, 0, 0, loc)
+		for _, w := range windows {
+			if w.days != nil && !w.days[day.Weekday()] {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), 0, w.start, 0, 0, loc)
+			end := time.Date(day.Year(), day.Month(), day.Day(), 0, w.end, 0, 0, loc)
+			if w.end < w.start {
+				end = end.AddDate(0, 0, 1)
+			}
+			switch {
+			case !t.Before(start) && t.Before(end):
+				return t
+			case start.After(t) && (next.IsZero() || start.Before(next)):
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// waitForOffPeak returns once one of windows is open, at once when there are none. Times are logged in loc.
+func waitForOffPeak(ctx context.Context, log logrus.FieldLogger, clk clock.WithTicker, loc *time.Location, windows []offPeakWindow) error {
+	if len(windows) == 0 {
+		return nil
+	}
+	now := clk.Now()
+	start := nextOffPeak(windows, now, loc)
+	if !start.After(now) {
+		return nil
+	}
+	log.WithField("window_start", formatTimeIn(start, loc)).Info("Waiting for the next off-peak window")
+
+	timer := clk.NewTimer(start.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("stopped waiting for an off-peak window: %w", context.Cause(ctx))
+	case <-timer.C():
+		return nil
+	}
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestParseOffPeakWindows(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{spec: "22:00-06:00"},
+		{spec: "Mon-Fri 20:00-23:30"},
+		{spec: "sat,SUN 00:00-24:00"},
+		{spec: "Fri-Mon,Wed 01:00-02:00"},
+		{spec: "22:00", wantErr: "expected a time range"},
+		{spec: "Mon Tue 22:00-06:00", wantErr: "expected [DAYS ]HH:MM-HH:MM"},
+		{spec: "Monday 22:00-06:00", wantErr: "unknown day"},
+		{spec: "Mon-Funday 22:00-06:00", wantErr: "unknown day"},
+		{spec: "22:60-06:00", wantErr: "invalid time of day"},
+		{spec: "24:30-06:00", wantErr: "invalid time of day"},
+		{spec: "10pm-6am", wantErr: "invalid time of day"},
+		{spec: "06:00-06:00", wantErr: "empty"},
+		{spec: "24:00-06:00", wantErr: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := parseOffPeakWindows([]string{tt.spec})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got %v, want the window parsed", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNextOffPeak(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-05-06 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		windows []string
+		loc     *time.Location
+		t       time.Time
+		want    time.Time
+	}{
+		{name: "in the window", windows: []string{"01:00-05:00"}, t: at(6, 3, 0), want: at(6, 3, 0)},
+		{name: "at the start of the window", windows: []string{"01:00-05:00"}, t: at(6, 1, 0), want: at(6, 1, 0)},
+		{name: "at the end of the window", windows: []string{"01:00-05:00"}, t: at(6, 5, 0), want: at(7, 1, 0)},
+		{name: "before the window", windows: []string{"22:00-06:00"}, t: at(6, 12, 0), want: at(6, 22, 0)},
+		{name: "past midnight", windows: []string{"22:00-06:00"}, t: at(7, 2, 0), want: at(7, 2, 0)},
+		{name: "past midnight into a day without the window", windows: []string{"Fri 22:00-06:00"}, t: at(11, 2, 0), want: at(11, 2, 0)},
+		{name: "on the next day of the window", windows: []string{"Sat,Sun 00:00-24:00"}, t: at(8, 12, 0), want: at(11, 0, 0)},
+		{name: "a week later", windows: []string{"Mon 01:00-02:00"}, t: at(6, 3, 0), want: at(13, 1, 0)},
+		{name: "the earliest of several windows", windows: []string{"Sat 00:00-01:00", "Mon-Fri 20:00-21:00"}, t: at(10, 21, 0), want: at(11, 0, 0)},
+		{name: "in the time zone", windows: []string{"22:00-06:00"}, loc: berlin, t: at(6, 12, 0), want: at(6, 20, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows, err := parseOffPeakWindows(tt.windows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loc := tt.loc
+			if loc == nil {
+				loc = time.UTC
+			}
+			if got := nextOffPeak(windows, tt.t, loc); !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunScheduledWaitsForOffPeak(t *testing.T) {
+	// Due hourly from 01:00, the first run waits for the window opening at 02:30 and covers the run due at 02:00
+	clock := clocktesting.NewFakeClock(time.Date(2024, 5, 6, 0, 30, 0, 0, time.UTC))
+	schedule, err := cron.ParseStandard("CRON_TZ=UTC 0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows, err := parseOffPeakWindows([]string{"02:30-04:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.Out = io.Discard
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var runs []time.Time
+	done := make(chan error)
+	go func() {
+		done <- runScheduled(ctx, log, clock, time.UTC, "0 * * * *", schedule, windows, func(context.Context) error {
+			runs = append(runs, clock.Now())
+			if len(runs) == 2 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []time.Time{time.Date(2024, 5, 6, 2, 30, 0, 0, time.UTC), time.Date(2024, 5, 6, 3, 0, 0, 0, time.UTC)}
+			if !slices.EqualFunc(runs, want, time.Time.Equal) {
+				t.Errorf("got runs at %v, want %v", runs, want)
+			}
+			return
+		default:
+		}
+		if clock.HasWaiters() {
+			clock.Step(time.Minute)
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestApplyCampaignOffPeak(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		c       campaign
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "sets the windows",
+			c:    campaign{OffPeak: []string{"Mon-Fri 22:00-06:00", "Sat,Sun 00:00-24:00"}},
+			want: []string{"Mon-Fri 22:00-06:00", "Sat,Sun 00:00-24:00"},
+		},
+		{
+			name: "the command line wins",
+			args: []string{"--off-peak", "01:00-02:00"},
+			c:    campaign{OffPeak: []string{"22:00-06:00"}},
+			want: []string{"01:00-02:00"},
+		},
+		{
+			name:    "not as a flag too",
+			c:       campaign{OffPeak: []string{"22:00-06:00"}, Flags: map[string]string{"off-peak": "01:00-02:00"}},
+			wantErr: "with offPeak",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var offPeak []string
+			fs := pflag.NewFlagSet("restart", pflag.ContinueOnError)
+			fs.StringArrayVar(&offPeak, "off-peak", nil, "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyCampaign(fs, tt.c)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(offPeak, tt.want) {
+				t.Errorf("got --off-peak %q, want %q", offPeak, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// errRunTimeout is the cancellation cause of a run that exceeded -timeout.
+var errRunTimeout = errors.New("run exceeded its timeout")
+
+// signalError is the cancellation cause of a run interrupted by a signal.
+type signalError struct {
+	signal os.Signal
+}
+
+func (e *signalError) Error() string {
+	return fmt.Sprintf("received %s signal", e.signal)
+}
+
+// signalContext returns a context cancelled on SIGINT/SIGTERM with a signalError cause. Once cancelled the
+// default signal behaviour is restored, so a second Ctrl+C exits immediately instead of waiting for
+// in-flight updates.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			cancel(&signalError{signal: sig})
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+
+	return ctx, func() { cancel(nil) }
+}
+
+// exitCode maps a failed run's error to a process exit code, following shell conventions so scripts can
+// tell a timeout (124, as timeout(1) uses) or a signal (128 + signal number) apart from a plain failure.
+func exitCode(err error) int {
+	var sigErr *signalError
+	switch {
+	case errors.As(err, &sigErr):
+		if sig, ok := sigErr.signal.(syscall.Signal); ok {
+			return 128 + int(sig)
+		}
+		return 130
+	case errors.Is(err, errRunTimeout):
+		return 124
+	default:
+		return 1
+	}
+}
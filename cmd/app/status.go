@@ -0,0 +1,49 @@
+package app
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// newStatusCommand returns the read-only "status" subcommand, showing how far each matched workload's
+// rollout has progressed and when it was last restarted.
+func newStatusCommand(g *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the rollout progress of every workload matching the filter",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatus(g)
+		},
+	}
+}
+
+func runStatus(g *globalOptions) error {
+	componentLogger := g.logger.WithField("component", "status")
+	cluster, err := g.connect("")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := g.env.Context()
+	defer stop()
+
+	statuses, err := rollout.NewRolloutClient(cluster.Clientset, g.filter, rolloutLogger(componentLogger), g.clientOptions()...).Status(ctx)
+	if err != nil {
+		return fmt.Errorf("status check failed: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(g.env.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tREADY\tUP-TO-DATE\tLAST RESTART")
+	for _, s := range statuses {
+		lastRestart := "unknown"
+		if !s.LastRestart.IsZero() {
+			lastRestart = g.formatTime(s.LastRestart)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%d\t%s\n", s.Kind, s.Namespace, s.Name, s.Ready, s.Desired, s.Updated, lastRestart)
+	}
+	return tw.Flush()
+}
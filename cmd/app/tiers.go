@@ -0,0 +1,40 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	"sigs.k8s.io/yaml"
+)
+
+// tiersFile is the YAML file of ordered environment tiers, e.g.
+//
+//	tiers:
+//	  - name: dev
+//	    namespaces: [dev-*]
+//	  - name: staging
+//	    namespaces: [staging-*]
+//	  - name: prod
+//	    namespaces: [prod-*, payments]
+type tiersFile struct {
+	Tiers []rollout.Tier `json:"tiers"`
+}
+
+// loadTiers reads the ordered environment tiers from the YAML file at path.
+func loadTiers(fsys FS, path string) ([]rollout.Tier, error) {
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tiers: %w", err)
+	}
+
+	var f tiersFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse tiers: %w", err)
+	}
+	for i, t := range f.Tiers {
+		if t.Name == "" || len(t.Namespaces) == 0 {
+			return nil, fmt.Errorf("tier %d needs a name and at least one namespace pattern", i+1)
+		}
+	}
+	return f.Tiers, nil
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// undoOptions are the flags of the undo subcommand.
+type undoOptions struct {
+	dryRun      string
+	wait        time.Duration
+	failOnError bool
+}
+
+// newUndoCommand returns the "undo" subcommand, reverting the restarts of a previous run.
+func newUndoCommand(g *globalOptions) *cobra.Command {
+	o := &undoOptions{}
+	cmd := &cobra.Command{
+		Use:   "undo [run]",
+		Short: "Revert the restarts of a previous run, the last one by default",
+		Long: "Revert the restarts of a previous run by setting the restart annotations of every workload it restarted\n" +
+			"back to their previous values, which rolls Deployments back to their previous ReplicaSet like kubectl\n" +
+			"rollout undo. Workloads restarted again since are skipped. The run is given by run ID, a unique prefix\n" +
+			"of one, or a report file, and defaults to the last run in --history-dir.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ref := ""
+			if len(args) > 0 {
+				ref = args[0]
+			}
+			return runUndo(g, o, ref)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&o.dryRun, "dry-run", "none", "Only show what would be reverted: none, client or server")
+	flags.Lookup("dry-run").NoOptDefVal = "client"
+	flags.DurationVar(&o.wait, "wait", 0, "Wait up to this long for each reverted workload to finish rolling back, 0 doesn't wait")
+	flags.BoolVar(&o.failOnError, "fail-on-error", true, "Exit non-zero when any workload failed to be reverted")
+	return cmd
+}
+
+func runUndo(g *globalOptions, o *undoOptions, ref string) error {
+	componentLogger := g.logger.WithField("component", "undo")
+
+	dryRun, err := parseDryRun(o.dryRun)
+	if err != nil {
+		return fmt.Errorf("invalid --dry-run: %w", err)
+	}
+
+	var previous *rollout.Report
+	if ref == "" {
+		previous, err = lastRun(g.env.FS, g.historyDir)
+	} else {
+		previous, err = loadRun(g.env.FS, g.historyDir, ref)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load the run to undo: %w", err)
+	}
+
+	cluster, err := g.connect("")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := g.env.Context()
+	defer stop()
+
+	opts := append(g.clientOptions(),
+		rollout.WithClusterName(cluster.Name),
+		rollout.WithDryRun(dryRun),
+		rollout.WithWaitForRollout(o.wait),
+		rollout.WithUsageTracker(cluster.Usage),
+	)
+	if o.failOnError {
+		opts = append(opts, rollout.WithFailOnErrors())
+	}
+
+	componentLogger.WithFields(logrus.Fields{
+		"run":       previous.RunID,
+		"started":   g.formatTime(previous.StartTime),
+		"restarted": previous.Restarted,
+	}).Info("Undoing run")
+	report, err := rollout.NewRolloutClient(cluster.Clientset, g.filter, rolloutLogger(componentLogger), opts...).Undo(ctx, previous)
+	logUsage(componentLogger, report.Usage)
+
+	if g.historyDir != "" {
+		if path, historyErr := saveRun(g.env.FS, g.historyDir, report); historyErr != nil {
+			componentLogger.WithError(historyErr).Error("Failed to save run history")
+		} else {
+			componentLogger.WithField("path", path).Debug("Saved run history")
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("undo failed: %w", err)
+	}
+	return nil
+}
+
+// lastRun reads the report of the most recent run in the history directory that restarted anything, so
+// dry runs and runs that found nothing to do are passed over.
+func lastRun(fsys FS, dir string) (*rollout.Report, error) {
+	paths, err := fsys.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Run files are named after their start time, so the most recent one sorts last
+	sort.Strings(paths)
+	for _, path := range slices.Backward(paths) {
+		report, err := loadRun(fsys, dir, path)
+		if err != nil {
+			return nil, err
+		}
+		if report.Restarted > 0 {
+			return report, nil
+		}
+	}
+	return nil, fmt.Errorf("no run in %s restarted anything", dir)
+}
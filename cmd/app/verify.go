@@ -0,0 +1,96 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+)
+
+// verifyOptions are the flags of the verify subcommand.
+type verifyOptions struct {
+	since    string
+	contexts string
+}
+
+// newVerifyCommand returns the read-only "verify" subcommand. It checks every matched workload in each given
+// kubeconfig context has been restarted since a point in time, and exits non-zero if any lag behind.
+func newVerifyCommand(g *globalOptions) *cobra.Command {
+	o := &verifyOptions{}
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check every matched workload has been restarted since a point in time",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerify(g, o)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&o.since, "since", "", "Matched workloads must have been restarted after this time (RFC3339 or YYYY-MM-DD)")
+	flags.StringVar(&o.contexts, "contexts", "", "Comma separated kubeconfig contexts to compare, defaults to the --context one or the current context")
+	return cmd
+}
+
+func runVerify(g *globalOptions, o *verifyOptions) error {
+	componentLogger := g.logger.WithField("component", "verify")
+
+	sinceTime, err := parseTime(o.since)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	kubeContexts := []string{""}
+	if o.contexts != "" {
+		kubeContexts = strings.Split(o.contexts, ",")
+	}
+
+	ctx, stop := g.env.Context()
+	defer stop()
+
+	laggards, failedClusters := 0, 0
+	for _, kubeContext := range kubeContexts {
+		clusterLogger := componentLogger
+		if kubeContext != "" {
+			clusterLogger = componentLogger.WithField("context", kubeContext)
+		}
+
+		cluster, err := g.connect(kubeContext)
+		if err != nil {
+			clusterLogger.WithError(err).Error("Failed to connect to cluster")
+			failedClusters++
+			continue
+		}
+
+		result, err := rollout.NewRolloutClient(cluster.Clientset, g.filter, rolloutLogger(clusterLogger), g.clientOptions()...).Verify(ctx, sinceTime)
+		if err != nil {
+			clusterLogger.WithError(err).Error("Verification failed")
+			failedClusters++
+			continue
+		}
+		laggards += len(result.Laggards)
+	}
+
+	if laggards > 0 || failedClusters > 0 {
+		componentLogger.WithFields(logrus.Fields{
+			"laggards":        laggards,
+			"failed_clusters": failedClusters,
+		}).Error("Not every matched workload has been restarted since verification time")
+		return fmt.Errorf("%d workload(s) not restarted since %s, %d cluster(s) failed", laggards, sinceTime.Format(time.RFC3339), failedClusters)
+	}
+	return nil
+}
+
+// parseTime accepts either a full RFC3339 timestamp or a plain date, interpreted as midnight UTC.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("a time is required")
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
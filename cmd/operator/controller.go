@@ -0,0 +1,426 @@
+// Package operator runs restarts declaratively: a controller reconciles RolloutRestart custom resources,
+// restarting the workloads each one selects once or on its schedule, and records the outcome of every run
+// in the resource's status.
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/tim-codez/devops-skills-assessment/cmd/operator/v1alpha1"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Reasons of the conditions.
+const (
+	reasonCompleted       = "Completed"
+	reasonFailed          = "Failed"
+	reasonInvalidSchedule = "InvalidSchedule"
+	reasonInvalidRetry    = "InvalidRetry"
+	reasonRunning         = "Running"
+	reasonIdle            = "Idle"
+)
+
+// finalizer keeps a deleted RolloutRestart around until a run of it in progress has stopped.
+const finalizer = "rollout.tim-codez.io/run"
+
+// deletionPollInterval is how often a run checks whether its RolloutRestart is being deleted.
+const deletionPollInterval = 5 * time.Second
+
+// errDeleted cancels the run of a RolloutRestart that is being deleted.
+var errDeleted = errors.New("RolloutRestart is being deleted")
+
+// Reconciler restarts the workloads selected by RolloutRestart resources. A RolloutRestart without a
+// schedule is run once for every generation of its spec, one with a schedule every time it is due. Setting
+// its retryFailedRun retries the failed workloads of its last run right away, the retry being the run of the
+// new generation.
+type Reconciler struct {
+	// Client reads and updates the RolloutRestarts
+	Client client.Client
+	// Clientset restarts the workloads
+	Clientset kubernetes.Interface
+	Log       rollout.Logger
+	Clock     clock.WithTicker
+	// Options are applied to every run before the ones derived from the RolloutRestart's spec
+	Options []rollout.Option
+}
+
+// SetupWithManager registers the reconciler with mgr. Only spec changes and deletions trigger a
+// reconcile, the status the reconciler writes itself doesn't.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	deleting := predicate.Funcs{UpdateFunc: func(e event.UpdateEvent) bool {
+		return !e.ObjectNew.GetDeletionTimestamp().IsZero()
+	}}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.RolloutRestart{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, deleting))).
+		Complete(r)
+}
+
+// Reconcile runs the RolloutRestart named by req when it is due and records the outcome in its status,
+// requeueing it for its next scheduled run.
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	rr := &v1alpha1.RolloutRestart{}
+	if err := r.Client.Get(ctx, req.NamespacedName, rr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	log := r.Log.WithField("rollout_restart", rr.Name)
+
+	if !rr.DeletionTimestamp.IsZero() {
+		// Reconciles of a RolloutRestart wait for its run, which has noticed the deletion and stopped by now
+		if controllerutil.RemoveFinalizer(rr, finalizer) {
+			log.Debug("Removing the finalizer of the deleted RolloutRestart")
+			return ctrl.Result{}, r.Client.Update(ctx, rr)
+		}
+		return ctrl.Result{}, nil
+	}
+	if controllerutil.AddFinalizer(rr, finalizer) {
+		if err := r.Client.Update(ctx, rr); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	base := rr.DeepCopy()
+	if rr.Spec.Suspend {
+		log.Debug("RolloutRestart is suspended")
+		return ctrl.Result{}, r.patchIdleStatus(ctx, rr, base)
+	}
+
+	now := r.Clock.Now()
+	next, err := r.nextRun(rr)
+	if err != nil {
+		// An invalid schedule won't become valid until the spec changes, which triggers a reconcile
+		log.WithError(err).Error("Invalid RolloutRestart schedule")
+		meta.SetStatusCondition(&rr.Status.Conditions, metav1.Condition{
+			Type:               v1alpha1.ConditionSucceeded,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: rr.Generation,
+			Reason:             reasonInvalidSchedule,
+			Message:            err.Error(),
+		})
+		rr.Status.NextRunTime = nil
+		return ctrl.Result{}, r.patchIdleStatus(ctx, rr, base)
+	}
+
+	// The spec change asking for a retry is handled by it, even when there is nothing to retry
+	retry, err := retryPlan(rr)
+	if err != nil || retry != nil && len(retry.Workloads) == 0 {
+		if err != nil {
+			log.WithError(err).Error("Invalid RolloutRestart retry")
+			meta.SetStatusCondition(&rr.Status.Conditions, metav1.Condition{
+				Type:               v1alpha1.ConditionSucceeded,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: rr.Generation,
+				Reason:             reasonInvalidRetry,
+				Message:            err.Error(),
+			})
+		} else {
+			log.WithField("run", rr.Spec.RetryFailedRun).Info("Nothing failed in the run, nothing to retry")
+		}
+		rr.Status.RetriedRun = rr.Spec.RetryFailedRun
+		rr.Status.ObservedGeneration = rr.Generation
+		if err := r.patchIdleStatus(ctx, rr, base); err != nil {
+			return ctrl.Result{}, err
+		}
+		if next.IsZero() {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{RequeueAfter: max(next.Sub(now), 0)}, nil
+	}
+
+	switch {
+	case retry != nil:
+		// A retry is made right away
+	case next.IsZero() && rr.Status.ObservedGeneration == rr.Generation:
+		// Unscheduled and already run for this spec
+		return ctrl.Result{}, r.patchIdleStatus(ctx, rr, base)
+	case next.After(now):
+		if rr.Status.NextRunTime == nil || !rr.Status.NextRunTime.Time.Equal(next) {
+			rr.Status.NextRunTime = &metav1.Time{Time: next}
+		}
+		if err := r.patchIdleStatus(ctx, rr, base); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+	}
+
+	rr.Status.Phase = v1alpha1.PhaseRunning
+	meta.SetStatusCondition(&rr.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionProgressing,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: rr.Generation,
+		Reason:             reasonRunning,
+		Message:            "Restarting the selected workloads",
+	})
+	if err := r.Client.Status().Patch(ctx, rr, client.MergeFrom(base)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The run can take hours, in which the RolloutRestart's metadata and spec may change. Its outcome is
+	// patched onto whatever the RolloutRestart has become rather than conflicting with it, the run handled
+	// the generation it started with.
+	base = rr.DeepCopy()
+	opts := r.runOptions(rr.Spec)
+	if retry != nil {
+		log.WithFields(rollout.Fields{
+			"run":    rr.Spec.RetryFailedRun,
+			"failed": len(retry.Workloads),
+		}).Info("Retrying the failed workloads of the last run")
+		rr.Status.RetriedRun = rr.Spec.RetryFailedRun
+		opts = append(opts, rollout.WithPlan(retry))
+	} else {
+		log.Info("Running RolloutRestart")
+	}
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go r.watchDeletion(runCtx, cancel, req.NamespacedName)
+	report, runErr := rollout.NewRolloutClient(r.Clientset, rr.Spec.Filter, log, opts...).Run(runCtx)
+	if errors.Is(context.Cause(runCtx), errDeleted) {
+		// The deletion triggers another reconcile, which removes the finalizer
+		log.Info("Stopped the run of the deleted RolloutRestart")
+		return ctrl.Result{}, nil
+	}
+	r.recordRun(rr, report, runErr)
+
+	rr.Status.NextRunTime = nil
+	var result ctrl.Result
+	if rr.Spec.Schedule != "" {
+		// The schedule parsed above, the next run is due after the one just recorded
+		next, _ := r.nextRun(rr)
+		rr.Status.NextRunTime = &metav1.Time{Time: next}
+		result.RequeueAfter = max(next.Sub(r.Clock.Now()), 0)
+	}
+	setIdle(rr)
+
+	// The run has happened, the status is written even when it was cancelled by the manager stopping
+	if err := r.Client.Status().Patch(context.WithoutCancel(ctx), rr, client.MergeFrom(base)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to record the run of RolloutRestart %s: %w", rr.Name, err)
+	}
+	return result, nil
+}
+
+// patchIdleStatus sets the phase and Progressing condition of rr between runs and patches its status when
+// it changed from base's.
+func (r *Reconciler) patchIdleStatus(ctx context.Context, rr, base *v1alpha1.RolloutRestart) error {
+	setIdle(rr)
+	if equality.Semantic.DeepEqual(rr.Status, base.Status) {
+		return nil
+	}
+	return r.Client.Status().Patch(ctx, rr, client.MergeFrom(base))
+}
+
+// setIdle sets the phase and Progressing condition of rr between runs.
+func setIdle(rr *v1alpha1.RolloutRestart) {
+	switch {
+	case rr.Spec.Suspend:
+		rr.Status.Phase = v1alpha1.PhaseSuspended
+	case meta.IsStatusConditionFalse(rr.Status.Conditions, v1alpha1.ConditionSucceeded):
+		rr.Status.Phase = v1alpha1.PhaseFailed
+	case rr.Status.LastRun == nil:
+		rr.Status.Phase = v1alpha1.PhasePending
+	default:
+		rr.Status.Phase = v1alpha1.PhaseSucceeded
+	}
+
+	message := "Waiting for the spec to change"
+	switch {
+	case rr.Spec.Suspend:
+		message = "Suspended"
+	case rr.Status.NextRunTime != nil:
+		message = "Waiting for the next scheduled run"
+	}
+	meta.SetStatusCondition(&rr.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionProgressing,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: rr.Generation,
+		Reason:             reasonIdle,
+		Message:            message,
+	})
+}
+
+// watchDeletion cancels ctx, the context of a run of the RolloutRestart named by key, once the RolloutRestart
+// is being deleted or gone. Its reconciles wait for the run to finish, so the run polls for the deletion.
+func (r *Reconciler) watchDeletion(ctx context.Context, cancel context.CancelCauseFunc, key types.NamespacedName) {
+	ticker := r.Clock.NewTicker(deletionPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		rr := &v1alpha1.RolloutRestart{}
+		err := r.Client.Get(ctx, key, rr)
+		if apierrors.IsNotFound(err) || err == nil && !rr.DeletionTimestamp.IsZero() {
+			cancel(errDeleted)
+			return
+		}
+	}
+}
+
+// nextRun returns when rr is next due, the zero time when it has no schedule. A scheduled RolloutRestart
+// is first due at its schedule's first time after it was created, every run delayed by its jitter.
+func (r *Reconciler) nextRun(rr *v1alpha1.RolloutRestart) (time.Time, error) {
+	if rr.Spec.Schedule == "" {
+		return time.Time{}, nil
+	}
+	schedule, err := parseSchedule(rr.Spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	last := rr.CreationTimestamp.Time
+	if rr.Status.LastRun != nil {
+		last = rr.Status.LastRun.StartTime.Time
+	}
+	next := schedule.Next(last)
+	return next.Add(runJitter(rr, next)), nil
+}
+
+// parseSchedule parses the schedule of spec in its time zone, if it has one.
+func parseSchedule(spec v1alpha1.RolloutRestartSpec) (cron.Schedule, error) {
+	schedule := spec.Schedule
+	if spec.TimeZone != "" {
+		if strings.HasPrefix(schedule, "CRON_TZ=") || strings.HasPrefix(schedule, "TZ=") {
+			return nil, fmt.Errorf("schedule %q has a time zone of its own, remove it or timeZone", spec.Schedule)
+		}
+		if _, err := time.LoadLocation(spec.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid timeZone: %w", err)
+		}
+		schedule = "CRON_TZ=" + spec.TimeZone + " " + schedule
+	}
+
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec.Schedule, err)
+	}
+	return parsed, nil
+}
+
+// runJitter returns how long the run of rr scheduled at scheduled is delayed, up to rr's jitter. It is
+// derived from rr's UID and the scheduled time, so it is the same on every reconcile and differs between
+// RolloutRestarts on the same schedule.
+func runJitter(rr *v1alpha1.RolloutRestart, scheduled time.Time) time.Duration {
+	if rr.Spec.Jitter == nil || rr.Spec.Jitter.Duration <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d", rr.UID, scheduled.Unix())
+	return time.Duration(h.Sum64() % uint64(rr.Spec.Jitter.Duration))
+}
+
+// retryPlan returns the plan of the retry spec.retryFailedRun asks for, nil when it asks for none or the
+// retry has been made. Only the failed workloads of the last run are kept, it is an error to name another.
+func retryPlan(rr *v1alpha1.RolloutRestart) (*rollout.RestartPlan, error) {
+	id := rr.Spec.RetryFailedRun
+	if id == "" || id == rr.Status.RetriedRun {
+		return nil, nil
+	}
+	if rr.Status.LastRun == nil || rr.Status.LastRun.RunID != id {
+		return nil, fmt.Errorf("can't retry run %s, only the failed workloads of the last run are kept", id)
+	}
+
+	plan := &rollout.RestartPlan{SchemaVersion: rollout.PlanSchemaVersion, Filter: rr.Spec.Filter, Workloads: []rollout.PlannedRestart{}}
+	for _, w := range rr.Status.LastRun.FailedWorkloads {
+		plan.Workloads = append(plan.Workloads, rollout.PlannedRestart{Kind: w.Kind, Namespace: w.Namespace, Name: w.Name})
+	}
+	return plan, nil
+}
+
+// runOptions returns the options of a run of spec.
+func (r *Reconciler) runOptions(spec v1alpha1.RolloutRestartSpec) []rollout.Option {
+	opts := append(r.Options[:len(r.Options):len(r.Options)],
+		rollout.WithNamespaces(spec.Namespaces...),
+		rollout.WithExcludedNamespaces(spec.ExcludeNamespaces...),
+		rollout.WithExcludedNames(spec.Exclude...),
+		rollout.WithLabelSelector(spec.Selector),
+		rollout.WithClock(r.Clock),
+	)
+	if spec.Concurrency > 0 {
+		opts = append(opts, rollout.WithConcurrency(spec.Concurrency))
+	}
+	// The API server validates the strategy against the CRD's enum
+	if strategy, err := rollout.ParseRestartStrategy(spec.Strategy); err == nil {
+		opts = append(opts, rollout.WithRestartStrategy(strategy))
+	}
+	return opts
+}
+
+// recordRun records the outcome of a run of rr in its status, with runErr the error the run returned.
+func (r *Reconciler) recordRun(rr *v1alpha1.RolloutRestart, report *rollout.Report, runErr error) {
+	summary := &v1alpha1.RunSummary{
+		RunID:     report.RunID,
+		StartTime: metav1.Time{Time: report.StartTime},
+		Duration:  metav1.Duration{Duration: report.Duration},
+		Restarted: report.Restarted,
+		Failed:    report.Failed,
+		Skipped:   report.Skipped,
+		Cancelled: report.Cancelled,
+		Errors:    report.Errors,
+		Warnings:  len(report.Warnings),
+	}
+	if len(report.ByKind) > 0 {
+		summary.ByKind = make(map[string]v1alpha1.Tally, len(report.ByKind))
+		for kind, t := range report.ByKind {
+			summary.ByKind[kind] = v1alpha1.Tally{Restarted: t.Restarted, Failed: t.Failed, Skipped: t.Skipped}
+		}
+	}
+	for i, result := range report.Results {
+		workload := v1alpha1.WorkloadReference{Kind: result.Kind, Namespace: result.Namespace, Name: result.Name}
+		if result.Action == rollout.ActionFailed {
+			summary.FailedWorkloads = append(summary.FailedWorkloads, workload)
+		}
+		if i >= v1alpha1.MaxRecordedResults {
+			summary.OmittedResults++
+			continue
+		}
+		summary.Results = append(summary.Results, v1alpha1.WorkloadResult{WorkloadReference: workload, Action: result.Action, Error: result.Error})
+	}
+	rr.Status.LastRun = summary
+	rr.Status.ObservedGeneration = rr.Generation
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.ConditionSucceeded,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: rr.Generation,
+		Reason:             reasonCompleted,
+		Message:            fmt.Sprintf("Restarted %d workload(s)", report.Restarted),
+	}
+	degraded := metav1.Condition{
+		Type:               v1alpha1.ConditionDegraded,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: rr.Generation,
+		Reason:             reasonCompleted,
+		Message:            "No workload failed to restart",
+	}
+	switch {
+	case runErr != nil:
+		condition.Status, condition.Reason, condition.Message = metav1.ConditionFalse, reasonFailed, runErr.Error()
+	case report.Failed > 0 || len(report.Errors) > 0:
+		condition.Status, condition.Reason = metav1.ConditionFalse, reasonFailed
+		condition.Message = fmt.Sprintf("%d workload(s) failed to restart and %d run error(s)", report.Failed, len(report.Errors))
+	}
+	if condition.Status == metav1.ConditionFalse {
+		degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, reasonFailed, condition.Message
+	}
+	meta.SetStatusCondition(&rr.Status.Conditions, condition)
+	meta.SetStatusCondition(&rr.Status.Conditions, degraded)
+}
@@ -0,0 +1,579 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tim-codez/devops-skills-assessment/cmd/operator/v1alpha1"
+	"github.com/tim-codez/devops-skills-assessment/cmd/rollout"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestReconciler returns a reconciler of objects, with a fake clock at created.
+func newTestReconciler(t *testing.T, objects ...client.Object) (*Reconciler, *clocktesting.FakeClock) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	clock := clocktesting.NewFakeClock(created)
+	return &Reconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objects...).
+			WithStatusSubresource(&v1alpha1.RolloutRestart{}).
+			Build(),
+		Clientset: k8sfake.NewSimpleClientset(),
+		Log:       rollout.NewSlogLogger(slog.New(slog.DiscardHandler)),
+		Clock:     clock,
+	}, clock
+}
+
+// created is when the RolloutRestarts of the tests were created.
+var created = time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+func TestNextRun(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		spec    v1alpha1.RolloutRestartSpec
+		lastRun time.Time
+		want    time.Time
+		wantErr string
+	}{
+		{name: "unscheduled"},
+		{
+			name: "first run after creation",
+			spec: v1alpha1.RolloutRestartSpec{Schedule: "0 3 * * *"},
+			want: time.Date(2024, 5, 7, 3, 0, 0, 0, time.Local),
+		},
+		{
+			name:    "run after the last one",
+			spec:    v1alpha1.RolloutRestartSpec{Schedule: "0 3 * * *"},
+			lastRun: time.Date(2024, 5, 7, 3, 0, 5, 0, time.Local),
+			want:    time.Date(2024, 5, 8, 3, 0, 0, 0, time.Local),
+		},
+		{
+			name: "in the time zone",
+			spec: v1alpha1.RolloutRestartSpec{Schedule: "0 3 * * *", TimeZone: "Europe/Berlin"},
+			want: time.Date(2024, 5, 7, 3, 0, 0, 0, berlin),
+		},
+		{
+			name: "in the CRON_TZ time zone",
+			spec: v1alpha1.RolloutRestartSpec{Schedule: "CRON_TZ=Europe/Berlin 0 3 * * *"},
+			want: time.Date(2024, 5, 7, 3, 0, 0, 0, berlin),
+		},
+		{
+			name:    "two time zones",
+			spec:    v1alpha1.RolloutRestartSpec{Schedule: "CRON_TZ=Europe/Berlin 0 3 * * *", TimeZone: "Europe/Berlin"},
+			wantErr: "has a time zone of its own",
+		},
+		{
+			name:    "unknown time zone",
+			spec:    v1alpha1.RolloutRestartSpec{Schedule: "0 3 * * *", TimeZone: "Mars/Olympus"},
+			wantErr: "invalid timeZone",
+		},
+		{
+			name:    "invalid schedule",
+			spec:    v1alpha1.RolloutRestartSpec{Schedule: "at 3"},
+			wantErr: "invalid schedule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := &v1alpha1.RolloutRestart{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly", UID: "uid", CreationTimestamp: metav1.Time{Time: created}},
+				Spec:       tt.spec,
+			}
+			if !tt.lastRun.IsZero() {
+				rr.Status.LastRun = &v1alpha1.RunSummary{StartTime: metav1.Time{Time: tt.lastRun}}
+			}
+
+			got, err := (&Reconciler{}).nextRun(rr)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got next run %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextRunJitter(t *testing.T) {
+	scheduled := time.Date(2024, 5, 7, 3, 0, 0, 0, time.UTC)
+	jitter := 10 * time.Minute
+	rolloutRestart := func(uid types.UID) *v1alpha1.RolloutRestart {
+		return &v1alpha1.RolloutRestart{
+			ObjectMeta: metav1.ObjectMeta{UID: uid, CreationTimestamp: metav1.Time{Time: scheduled.Add(-time.Hour)}},
+			Spec:       v1alpha1.RolloutRestartSpec{Schedule: "CRON_TZ=UTC 0 3 * * *", Jitter: &metav1.Duration{Duration: jitter}},
+		}
+	}
+
+	delays := map[time.Duration]bool{}
+	for _, uid := range []types.UID{"a", "b", "c", "d", "e"} {
+		rr := rolloutRestart(uid)
+		first, err := (&Reconciler{}).nextRun(rr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := (&Reconciler{}).nextRun(rr)
+		if !first.Equal(again) {
+			t.Errorf("%s: got next run %s, then %s, want the same on every reconcile", uid, first, again)
+		}
+		delay := first.Sub(scheduled)
+		if delay < 0 || delay >= jitter {
+			t.Errorf("%s: got a delay of %s, want one under %s", uid, delay, jitter)
+		}
+		delays[delay] = true
+	}
+	if len(delays) < 2 {
+		t.Errorf("got the same delay for every RolloutRestart, want them spread")
+	}
+}
+
+func TestReconcileFinalizer(t *testing.T) {
+	key := types.NamespacedName{Name: "nightly"}
+	now := metav1.NewTime(created)
+
+	tests := []struct {
+		name           string
+		rolloutRestart *v1alpha1.RolloutRestart
+		wantFinalizer  bool
+		wantGone       bool
+	}{
+		{
+			name:           "adds the finalizer",
+			rolloutRestart: &v1alpha1.RolloutRestart{ObjectMeta: metav1.ObjectMeta{Name: "nightly"}, Spec: v1alpha1.RolloutRestartSpec{Suspend: true}},
+			wantFinalizer:  true,
+		},
+		{
+			name: "keeps the finalizer",
+			rolloutRestart: &v1alpha1.RolloutRestart{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly", Finalizers: []string{finalizer}},
+				Spec:       v1alpha1.RolloutRestartSpec{Suspend: true},
+			},
+			wantFinalizer: true,
+		},
+		{
+			name: "removes the finalizer once deleted",
+			rolloutRestart: &v1alpha1.RolloutRestart{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly", Finalizers: []string{finalizer}, DeletionTimestamp: &now},
+			},
+			wantGone: true,
+		},
+		{
+			name: "leaves other finalizers of a deleted RolloutRestart",
+			rolloutRestart: &v1alpha1.RolloutRestart{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly", Finalizers: []string{finalizer, "example.com/other"}, DeletionTimestamp: &now},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestReconciler(t, tt.rolloutRestart)
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("reconcile: %v", err)
+			}
+
+			rr := &v1alpha1.RolloutRestart{}
+			err := r.Client.Get(context.Background(), key, rr)
+			if tt.wantGone {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("got %v, want the RolloutRestart gone", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := slices.Contains(rr.Finalizers, finalizer); got != tt.wantFinalizer {
+				t.Errorf("got finalizers %v, want %s: %t", rr.Finalizers, finalizer, tt.wantFinalizer)
+			}
+		})
+	}
+}
+
+func TestWatchDeletionCancelsRun(t *testing.T) {
+	key := types.NamespacedName{Name: "nightly"}
+	rr := &v1alpha1.RolloutRestart{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Finalizers: []string{finalizer}}}
+	r, clock := newTestReconciler(t, rr)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	done := make(chan struct{})
+	go func() {
+		r.watchDeletion(ctx, cancel, key)
+		close(done)
+	}()
+	waitForTicker := func() {
+		for !clock.HasWaiters() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// The run goes on while the RolloutRestart is there
+	waitForTicker()
+	clock.Step(deletionPollInterval)
+	waitForTicker()
+	if ctx.Err() != nil {
+		t.Fatalf("got the run cancelled with %v, want it running", context.Cause(ctx))
+	}
+
+	if err := r.Client.Delete(context.Background(), rr); err != nil {
+		t.Fatal(err)
+	}
+	clock.Step(deletionPollInterval)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the run wasn't cancelled after the deletion")
+	}
+	if !errors.Is(context.Cause(ctx), errDeleted) {
+		t.Errorf("got the run cancelled with %v, want %v", context.Cause(ctx), errDeleted)
+	}
+}
+
+func TestReconcileRetry(t *testing.T) {
+	key := types.NamespacedName{Name: "nightly"}
+	deployment := func(name string) *appsv1.Deployment {
+		labels := map[string]string{"app": name}
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+			},
+		}
+	}
+	lastRun := &v1alpha1.RunSummary{
+		RunID:           "abc",
+		Restarted:       1,
+		Failed:          1,
+		FailedWorkloads: []v1alpha1.WorkloadReference{{Kind: "deployment", Namespace: "default", Name: "web-api"}},
+	}
+
+	tests := []struct {
+		name       string
+		retry      string
+		retriedRun string
+		lastRun    *v1alpha1.RunSummary
+		// wantPatched are the deployments restarted
+		wantPatched []string
+		wantReason  string
+	}{
+		{
+			name:        "retries the failed workloads",
+			retry:       "abc",
+			lastRun:     lastRun,
+			wantPatched: []string{"web-api"},
+			wantReason:  "Completed",
+		},
+		{
+			name:       "retries once",
+			retry:      "abc",
+			retriedRun: "abc",
+			lastRun:    lastRun,
+		},
+		{
+			name:    "nothing failed",
+			retry:   "abc",
+			lastRun: &v1alpha1.RunSummary{RunID: "abc", Restarted: 2},
+		},
+		{
+			name:       "not the last run",
+			retry:      "older",
+			lastRun:    lastRun,
+			wantReason: reasonInvalidRetry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := &v1alpha1.RolloutRestart{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly", Generation: 2, Finalizers: []string{finalizer}},
+				Spec:       v1alpha1.RolloutRestartSpec{Filter: "web", RetryFailedRun: tt.retry},
+				Status:     v1alpha1.RolloutRestartStatus{ObservedGeneration: 1, LastRun: tt.lastRun, RetriedRun: tt.retriedRun},
+			}
+			if tt.retriedRun != "" {
+				rr.Status.ObservedGeneration = rr.Generation
+			}
+			r, _ := newTestReconciler(t, rr)
+			clientset := k8sfake.NewSimpleClientset(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+				deployment("web-api"),
+				deployment("web-ui"),
+			)
+			r.Clientset = clientset
+
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("reconcile: %v", err)
+			}
+
+			var patched []string
+			for _, action := range clientset.Actions() {
+				if patch, ok := action.(k8stesting.PatchAction); ok && patch.GetResource().Resource == "deployments" {
+					patched = append(patched, patch.GetName())
+				}
+			}
+			if !slices.Equal(patched, tt.wantPatched) {
+				t.Errorf("got deployments %v patched, want %v", patched, tt.wantPatched)
+			}
+
+			got := &v1alpha1.RolloutRestart{}
+			if err := r.Client.Get(context.Background(), key, got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status.RetriedRun != tt.retry {
+				t.Errorf("got retried run %q, want %q", got.Status.RetriedRun, tt.retry)
+			}
+			if got.Status.ObservedGeneration != rr.Generation {
+				t.Errorf("got observed generation %d, want the retry to handle generation %d", got.Status.ObservedGeneration, rr.Generation)
+			}
+			condition := meta.FindStatusCondition(got.Status.Conditions, v1alpha1.ConditionSucceeded)
+			switch {
+			case tt.wantReason == "" && condition != nil:
+				t.Errorf("got condition %+v, want none", condition)
+			case tt.wantReason != "" && (condition == nil || condition.Reason != tt.wantReason):
+				t.Errorf("got condition %+v, want reason %s", condition, tt.wantReason)
+			}
+			if len(tt.wantPatched) > 0 && len(got.Status.LastRun.FailedWorkloads) > 0 {
+				t.Errorf("got failed workloads %v recorded, want the retry's own", got.Status.LastRun.FailedWorkloads)
+			}
+		})
+	}
+}
+
+func TestReconcileRecordsRunAfterChange(t *testing.T) {
+	key := types.NamespacedName{Name: "nightly"}
+	labels := map[string]string{"app": "web"}
+	rr := &v1alpha1.RolloutRestart{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Generation: 1, Finalizers: []string{finalizer}},
+		Spec:       v1alpha1.RolloutRestartSpec{Filter: "web"},
+	}
+	r, _ := newTestReconciler(t, rr)
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+			},
+		},
+	)
+	// GitOps tooling labels the RolloutRestart while the run restarts the deployment
+	clientset.PrependReactor("patch", "deployments", func(k8stesting.Action) (bool, runtime.Object, error) {
+		changed := &v1alpha1.RolloutRestart{}
+		if err := r.Client.Get(context.Background(), key, changed); err != nil {
+			return true, nil, err
+		}
+		changed.Labels = map[string]string{"argocd.argoproj.io/instance": "ops"}
+		return false, nil, r.Client.Update(context.Background(), changed)
+	})
+	r.Clientset = clientset
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	got := &v1alpha1.RolloutRestart{}
+	if err := r.Client.Get(context.Background(), key, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Labels["argocd.argoproj.io/instance"] != "ops" {
+		t.Errorf("got labels %v, want the change made during the run kept", got.Labels)
+	}
+	if got.Status.LastRun == nil || got.Status.LastRun.Restarted != 1 {
+		t.Fatalf("got last run %+v, want the run restarting web recorded", got.Status.LastRun)
+	}
+	if got.Status.ObservedGeneration != rr.Generation {
+		t.Errorf("got observed generation %d, want %d", got.Status.ObservedGeneration, rr.Generation)
+	}
+}
+
+func TestReconcileStatus(t *testing.T) {
+	key := types.NamespacedName{Name: "nightly"}
+	deployment := func(name string) *appsv1.Deployment {
+		labels := map[string]string{"app": name}
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		spec v1alpha1.RolloutRestartSpec
+		// fail is the deployment failing to restart
+		fail         string
+		wantPhase    string
+		wantRunning  bool
+		wantDegraded metav1.ConditionStatus
+		wantResults  []v1alpha1.WorkloadResult
+		wantNextRun  bool
+	}{
+		{
+			name:         "succeeded",
+			spec:         v1alpha1.RolloutRestartSpec{Filter: "web"},
+			wantPhase:    v1alpha1.PhaseSucceeded,
+			wantRunning:  true,
+			wantDegraded: metav1.ConditionFalse,
+			wantResults: []v1alpha1.WorkloadResult{
+				{WorkloadReference: v1alpha1.WorkloadReference{Kind: "deployment", Namespace: "default", Name: "web-api"}, Action: rollout.ActionRestarted},
+				{WorkloadReference: v1alpha1.WorkloadReference{Kind: "deployment", Namespace: "default", Name: "web-ui"}, Action: rollout.ActionRestarted},
+			},
+		},
+		{
+			name:         "degraded",
+			spec:         v1alpha1.RolloutRestartSpec{Filter: "web"},
+			fail:         "web-ui",
+			wantPhase:    v1alpha1.PhaseFailed,
+			wantRunning:  true,
+			wantDegraded: metav1.ConditionTrue,
+			wantResults: []v1alpha1.WorkloadResult{
+				{WorkloadReference: v1alpha1.WorkloadReference{Kind: "deployment", Namespace: "default", Name: "web-api"}, Action: rollout.ActionRestarted},
+				{WorkloadReference: v1alpha1.WorkloadReference{Kind: "deployment", Namespace: "default", Name: "web-ui"}, Action: rollout.ActionFailed, Error: "webhook denied the request"},
+			},
+		},
+		{
+			name:      "suspended",
+			spec:      v1alpha1.RolloutRestartSpec{Filter: "web", Suspend: true},
+			wantPhase: v1alpha1.PhaseSuspended,
+		},
+		{
+			name:        "waiting for the first scheduled run",
+			spec:        v1alpha1.RolloutRestartSpec{Filter: "web", Schedule: "0 3 * * *"},
+			wantPhase:   v1alpha1.PhasePending,
+			wantNextRun: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := &v1alpha1.RolloutRestart{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly", Generation: 1, Finalizers: []string{finalizer}, CreationTimestamp: metav1.Time{Time: created}},
+				Spec:       tt.spec,
+			}
+			r, _ := newTestReconciler(t, rr)
+			clientset := k8sfake.NewSimpleClientset(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+				deployment("web-api"),
+				deployment("web-ui"),
+			)
+			// The status seen while the run restarts the deployments
+			var running *v1alpha1.RolloutRestartStatus
+			clientset.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if running == nil {
+					current := &v1alpha1.RolloutRestart{}
+					if err := r.Client.Get(context.Background(), key, current); err != nil {
+						return true, nil, err
+					}
+					running = &current.Status
+				}
+				if action.(k8stesting.PatchAction).GetName() == tt.fail {
+					return true, nil, errors.New("webhook denied the request")
+				}
+				return false, nil, nil
+			})
+			r.Clientset = clientset
+
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("reconcile: %v", err)
+			}
+
+			if tt.wantRunning {
+				if running == nil || running.Phase != v1alpha1.PhaseRunning || !meta.IsStatusConditionTrue(running.Conditions, v1alpha1.ConditionProgressing) {
+					t.Errorf("got status %+v during the run, want it running and progressing", running)
+				}
+			}
+			got := &v1alpha1.RolloutRestart{}
+			if err := r.Client.Get(context.Background(), key, got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status.Phase != tt.wantPhase {
+				t.Errorf("got phase %q, want %q", got.Status.Phase, tt.wantPhase)
+			}
+			if !meta.IsStatusConditionFalse(got.Status.Conditions, v1alpha1.ConditionProgressing) {
+				t.Errorf("got conditions %+v, want it no longer progressing", got.Status.Conditions)
+			}
+			degraded := meta.FindStatusCondition(got.Status.Conditions, v1alpha1.ConditionDegraded)
+			switch {
+			case tt.wantDegraded == "" && degraded != nil:
+				t.Errorf("got condition %+v, want none before a run", degraded)
+			case tt.wantDegraded != "" && (degraded == nil || degraded.Status != tt.wantDegraded):
+				t.Errorf("got condition %+v, want Degraded %s", degraded, tt.wantDegraded)
+			}
+			var results []v1alpha1.WorkloadResult
+			if got.Status.LastRun != nil {
+				results = got.Status.LastRun.Results
+			}
+			if !slices.Equal(results, tt.wantResults) {
+				t.Errorf("got results %+v, want %+v", results, tt.wantResults)
+			}
+			if (got.Status.NextRunTime != nil) != tt.wantNextRun {
+				t.Errorf("got next run %v, want one: %t", got.Status.NextRunTime, tt.wantNextRun)
+			}
+		})
+	}
+}
+
+func TestRecordRunKeepsResults(t *testing.T) {
+	report := &rollout.Report{}
+	for i := range v1alpha1.MaxRecordedResults + 5 {
+		action := rollout.ActionRestarted
+		if i%50 == 0 || i == v1alpha1.MaxRecordedResults+1 {
+			action = rollout.ActionFailed
+		}
+		report.Results = append(report.Results, rollout.ResourceResult{Kind: "deployment", Namespace: "default", Name: fmt.Sprintf("web-%d", i), Action: action})
+	}
+	rr := &v1alpha1.RolloutRestart{}
+
+	(&Reconciler{}).recordRun(rr, report, nil)
+	if got := len(rr.Status.LastRun.Results); got != v1alpha1.MaxRecordedResults {
+		t.Errorf("got %d results kept, want %d", got, v1alpha1.MaxRecordedResults)
+	}
+	if got := rr.Status.LastRun.OmittedResults; got != 5 {
+		t.Errorf("got %d results omitted, want 5", got)
+	}
+	var failed []string
+	for _, w := range rr.Status.LastRun.FailedWorkloads {
+		failed = append(failed, w.Name)
+	}
+	if want := []string{"web-0", "web-50", "web-100", "web-101"}; !slices.Equal(failed, want) {
+		t.Errorf("got failed workloads %v, want every failed one %v", failed, want)
+	}
+}
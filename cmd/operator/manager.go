@@ -0,0 +1,83 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-logr/logr"
+	"github.com/sirupsen/logrus"
+	"github.com/tim-codez/devops-skills-assessment/cmd/operator/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// leaderElectionID names the Lease the replicas of the operator elect their leader with.
+const leaderElectionID = "rollout-operator.rollout.tim-codez.io"
+
+// Options configure how the operator is run.
+type Options struct {
+	// LeaderElection makes only one of several replicas reconcile at a time
+	LeaderElection bool
+	// LeaderElectionNamespace is where the leader election Lease is kept, defaults to the pod's namespace
+	LeaderElectionNamespace string
+	// HealthProbeAddress serves /healthz and /readyz, disabled when empty
+	HealthProbeAddress string
+	// MetricsAddress serves controller-runtime's controller, workqueue and client metrics at /metrics,
+	// disabled when empty
+	MetricsAddress string
+}
+
+// Run runs the operator against the cluster of config until ctx is done, reconciling RolloutRestarts with
+// r. controller-runtime's own logging goes to logger's output, at debug level only when logger is.
+func Run(ctx context.Context, config *rest.Config, r *Reconciler, logger *logrus.Logger, opts Options) error {
+	level := slog.LevelInfo
+	if logger.IsLevelEnabled(logrus.DebugLevel) {
+		level = slog.LevelDebug
+	}
+	ctrl.SetLogger(logr.FromSlogHandler(slog.NewTextHandler(logger.Out, &slog.HandlerOptions{Level: level})))
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return err
+	}
+
+	// controller-runtime serves metrics at its default address unless told "0"
+	metricsAddress := opts.MetricsAddress
+	if metricsAddress == "" {
+		metricsAddress = "0"
+	}
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
+		Scheme:                  scheme,
+		Metrics:                 metricsserver.Options{BindAddress: metricsAddress},
+		HealthProbeBindAddress:  opts.HealthProbeAddress,
+		LeaderElection:          opts.LeaderElection,
+		LeaderElectionID:        leaderElectionID,
+		LeaderElectionNamespace: opts.LeaderElectionNamespace,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the operator's manager: %w", err)
+	}
+
+	if opts.HealthProbeAddress != "" {
+		if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+			return err
+		}
+		if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+			return err
+		}
+	}
+
+	r.Client = mgr.GetClient()
+	if err := r.SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("failed to set up the RolloutRestart controller: %w", err)
+	}
+	return mgr.Start(ctx)
+}
@@ -0,0 +1,9 @@
+package v1alpha1
+
+import "sigs.k8s.io/controller-runtime/pkg/conversion"
+
+var _ conversion.Hub = &RolloutRestart{}
+
+// Hub marks v1alpha1 as the hub the other versions of RolloutRestart convert through, see the package
+// documentation.
+func (*RolloutRestart) Hub() {}
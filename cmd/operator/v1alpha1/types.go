@@ -0,0 +1,251 @@
+// Package v1alpha1 is the rollout.tim-codez.io/v1alpha1 API of the operator, the RolloutRestart custom
+// resource declaring which workloads to restart and when.
+//
+// Fields are added to v1alpha1 as long as they are optional and an unset field keeps the existing
+// behaviour, so resources written by earlier releases keep working unchanged. A change that can't be made
+// that way, e.g. renaming or restructuring a field, goes into a v1beta1 served alongside v1alpha1. v1alpha1
+// stays the storage version and the hub of conversions (see Hub): v1beta1 implements controller-runtime's
+// conversion.Convertible to and from it, and the CRD switches to the Webhook conversion strategy served by
+// the operator. v1beta1 becomes the storage version and hub once a release has migrated the stored resources,
+// and v1alpha1 is deprecated, then no longer served, in the releases after.
+//
+// +kubebuilder:object:generate=true
+// +groupName=rollout.tim-codez.io
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is the group and version of the operator's resources
+	GroupVersion = schema.GroupVersion{Group: "rollout.tim-codez.io", Version: "v1alpha1"}
+
+	// SchemeBuilder registers the operator's resources with a scheme
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the operator's resources to a scheme
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func init() {
+	SchemeBuilder.Register(&RolloutRestart{}, &RolloutRestartList{})
+}
+
+// Strategies a RolloutRestart can restart its workloads with.
+const (
+	// StrategyRollout rolls each workload through its pod template, like kubectl rollout restart
+	StrategyRollout = "rollout"
+	// StrategyEvict evicts each workload's pods, respecting PodDisruptionBudgets
+	StrategyEvict = "evict"
+	// StrategyScale scales each workload to zero and back, taking all of its pods down at once
+	StrategyScale = "scale"
+)
+
+// Condition types of a RolloutRestart.
+const (
+	// ConditionSucceeded is true when the last run restarted every matching workload without errors
+	ConditionSucceeded = "Succeeded"
+	// ConditionProgressing is true while a run is restarting workloads
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded is true when workloads failed to restart in the last run or it had errors, false
+	// once a run has none
+	ConditionDegraded = "Degraded"
+)
+
+// Phases of a RolloutRestart.
+const (
+	// PhasePending is a RolloutRestart that hasn't run yet
+	PhasePending = "Pending"
+	// PhaseRunning is a RolloutRestart restarting its workloads
+	PhaseRunning = "Running"
+	// PhaseSucceeded is a RolloutRestart whose last run restarted every matching workload without errors
+	PhaseSucceeded = "Succeeded"
+	// PhaseFailed is a RolloutRestart whose last run had failures or errors, or that can't run at all
+	PhaseFailed = "Failed"
+	// PhaseSuspended is a RolloutRestart whose runs are suspended
+	PhaseSuspended = "Suspended"
+)
+
+// MaxRecordedResults is how many results of the workloads of a run are kept in its summary, so the status
+// of a RolloutRestart selecting a large part of a cluster stays well under the API server's object size.
+const MaxRecordedResults = 100
+
+// RolloutRestartSpec declares which workloads to restart, the same selection the CLI's global flags make,
+// and when.
+type RolloutRestartSpec struct {
+	// Filter selects workloads whose name contains it, an empty filter selects by Selector alone
+	// +optional
+	Filter string `json:"filter,omitempty"`
+
+	// Selector only selects workloads matching this label selector
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// Namespaces only selects workloads in namespaces matching one of these globs, every namespace except
+	// kube-system when empty
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ExcludeNamespaces skips namespaces matching one of these globs
+	// +optional
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+
+	// Exclude skips workloads whose name matches one of these globs
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Schedule restarts the workloads periodically, in cron format, e.g. "0 3 * * 0", in TimeZone or the time
+	// zone a CRON_TZ= prefix names, otherwise in the operator's local time zone. Without a schedule they are
+	// restarted once every time the spec changes
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// TimeZone is the IANA time zone the schedule is in, e.g. "Europe/Berlin", the schedule can't have a
+	// CRON_TZ= prefix as well
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Jitter delays each scheduled run by up to this long, so RolloutRestarts on the same schedule don't
+	// all start at once. The delay is the same on every reconcile, it should be well under the schedule's
+	// interval
+	// +optional
+	Jitter *metav1.Duration `json:"jitter,omitempty"`
+
+	// Strategy is how the workloads are restarted
+	// +kubebuilder:validation:Enum=rollout;evict;scale
+	// +kubebuilder:default=rollout
+	// +optional
+	Strategy string `json:"strategy,omitempty"`
+
+	// Concurrency is how many namespaces are processed at the same time, the workloads within a namespace
+	// are still restarted one at a time
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// Suspend stops further runs, a run already started finishes
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// RetryFailedRun restarts the workloads that failed in the run with this ID once more, e.g. once the cause
+	// of the failures has been fixed. Only the failed workloads of the last run are kept, so it has to name
+	// the last run. The retry is the run of the spec change setting it, the whole spec isn't run for it
+	// +optional
+	RetryFailedRun string `json:"retryFailedRun,omitempty"`
+}
+
+// RunSummary is the outcome of a run, the summary the CLI logs when a run completes.
+type RunSummary struct {
+	RunID     string           `json:"runID"`
+	StartTime metav1.Time      `json:"startTime"`
+	Duration  metav1.Duration  `json:"duration"`
+	Restarted int              `json:"restarted"`
+	Failed    int              `json:"failed"`
+	Skipped   int              `json:"skipped"`
+	Cancelled bool             `json:"cancelled,omitempty"`
+	Errors    []string         `json:"errors,omitempty"`
+	Warnings  int              `json:"warnings,omitempty"`
+	ByKind    map[string]Tally `json:"byKind,omitempty"`
+
+	// FailedWorkloads are the workloads that failed to restart, see RetryFailedRun
+	// +optional
+	FailedWorkloads []WorkloadReference `json:"failedWorkloads,omitempty"`
+
+	// Results are what the run did to each matching workload, in the order it handled them, up to
+	// MaxRecordedResults of them
+	// +optional
+	Results []WorkloadResult `json:"results,omitempty"`
+
+	// OmittedResults counts the results beyond MaxRecordedResults that aren't kept
+	// +optional
+	OmittedResults int `json:"omittedResults,omitempty"`
+}
+
+// WorkloadResult is what a run did to a workload.
+type WorkloadResult struct {
+	WorkloadReference `json:",inline"`
+
+	// Action is the outcome, restarted, failed, skipped or dry-run
+	Action string `json:"action"`
+
+	// Error is why the workload failed to restart
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// WorkloadReference names a workload.
+type WorkloadReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Tally counts the results of a kind of workload by outcome.
+type Tally struct {
+	Restarted int `json:"restarted"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
+
+// RolloutRestartStatus is the observed state of a RolloutRestart.
+type RolloutRestartStatus struct {
+	// Phase sums up the RolloutRestart's state
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed;Suspended
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the last run was made for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastRun is the outcome of the last run
+	// +optional
+	LastRun *RunSummary `json:"lastRun,omitempty"`
+
+	// NextRunTime is when the next scheduled run is due
+	// +optional
+	NextRunTime *metav1.Time `json:"nextRunTime,omitempty"`
+
+	// RetriedRun is the run ID of the last RetryFailedRun handled, it isn't retried again
+	// +optional
+	RetriedRun string `json:"retriedRun,omitempty"`
+
+	// Conditions of the RolloutRestart, Succeeded, Progressing and Degraded
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// RolloutRestart declares a restart of the workloads it selects, once or on a schedule.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=rr
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Restarted",type=integer,JSONPath=`.status.lastRun.restarted`
+// +kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.lastRun.failed`
+// +kubebuilder:printcolumn:name="Skipped",type=integer,JSONPath=`.status.lastRun.skipped`,priority=1
+// +kubebuilder:printcolumn:name="Last Run",type=date,JSONPath=`.status.lastRun.startTime`
+// +kubebuilder:printcolumn:name="Next Run",type=date,JSONPath=`.status.nextRunTime`
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.conditions[?(@.type=="Succeeded")].message`,priority=1
+type RolloutRestart struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RolloutRestartSpec   `json:"spec,omitempty"`
+	Status RolloutRestartStatus `json:"status,omitempty"`
+}
+
+// RolloutRestartList is a list of RolloutRestarts.
+//
+// +kubebuilder:object:root=true
+type RolloutRestartList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RolloutRestart `json:"items"`
+}
@@ -0,0 +1,247 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ErrMissingPermissions is returned by a run refused by its access check, see WithAccessCheck.
+var ErrMissingPermissions = errors.New("missing permissions")
+
+// namespaceResource is the resource of namespaces, which are cluster scoped, podResource the one of pods.
+var (
+	namespaceResource = schema.GroupResource{Resource: "namespaces"}
+	podResource       = schema.GroupResource{Resource: "pods"}
+)
+
+// workloadResources are the resources of the workload kinds in kindAccessors.
+var workloadResources = []schema.GroupResource{
+	{Group: "apps", Resource: "deployments"},
+	{Group: "apps", Resource: "statefulsets"},
+	{Group: "apps", Resource: "daemonsets"},
+}
+
+// scalableResources are the workload resources ScaleStrategy scales through their scale subresource.
+var scalableResources = []schema.GroupResource{
+	{Group: "apps", Resource: "deployments"},
+	{Group: "apps", Resource: "statefulsets"},
+	argoRolloutResource.GroupResource(),
+}
+
+// cronJobResource and jobResource are the resources of the batch workload kinds, see WithCronJobs and
+// WithJobRecreation.
+var (
+	cronJobResource = schema.GroupResource{Group: "batch", Resource: "cronjobs"}
+	jobResource     = schema.GroupResource{Group: "batch", Resource: "jobs"}
+)
+
+// WithAccessCheck makes a run check with SelfSubjectAccessReviews that it's allowed to do what it needs
+// before touching anything: list the namespaces (get them, when WithNamespaces names them), list every
+// workload kind, CronJobs and Argo Rollouts too when configured, and what restarting them takes with the
+// run's restart strategy. That is patching them to roll them, listing their pods and creating evictions, or
+// execs with WithContainerRestart, to evict them, and patching their scale subresource and listing their
+// pods to scale them, besides getting, deleting and creating Jobs with WithJobRecreation. A strategy a Policy
+// picks for a single workload isn't checked. Missing permissions fail the run right away with
+// ErrMissingPermissions describing all of them, instead of the run failing midway through the namespaces.
+// Workload access is checked in every namespace first, then namespace by namespace for the namespaces the
+// run works on, as it's often only granted per namespace. A client-side dry run only needs to list.
+func WithAccessCheck() Option {
+	return func(rc *rolloutClient) {
+		rc.accessCheck = true
+	}
+}
+
+// access is a permission a run needs, an empty namespace meaning every namespace for namespaced resources.
+type access struct {
+	verb        string
+	resource    schema.GroupResource
+	subresource string
+	namespace   string
+	name        string
+}
+
+// permission describes the verb on the resource, wherever it applies.
+func (a access) permission() string {
+	s := a.verb + " " + a.resource.String()
+	if a.subresource != "" {
+		s += "/" + a.subresource
+	}
+	return s
+}
+
+func (a access) String() string {
+	s := a.permission()
+	switch {
+	case a.name != "":
+		s += "/" + a.name
+	case a.namespace != "":
+		s += " in namespace " + a.namespace
+	case a.resource != namespaceResource:
+		s += " in all namespaces"
+	}
+	return s
+}
+
+// checkAccess returns an ErrMissingPermissions error naming every permission the run needs and lacks.
+func (rc *rolloutClient) checkAccess(ctx context.Context) error {
+	var needed []access
+	if names, ok := literalNames(rc.includeNamespaces); ok {
+		for _, name := range names {
+			needed = append(needed, access{verb: "get", resource: namespaceResource, name: name})
+		}
+	} else {
+		needed = append(needed, access{verb: "list", resource: namespaceResource})
+	}
+	missing, err := rc.missingAccess(ctx, needed)
+	if err != nil {
+		return err
+	}
+	// Without the namespaces, which namespaces workload access is needed in isn't known
+	if len(missing) > 0 {
+		return accessError(missing)
+	}
+
+	resources := workloadResources
+	if rc.cronJobs {
+		resources = append(slices.Clip(resources), cronJobResource)
+	}
+	if rc.argoRollouts != nil {
+		resources = append(slices.Clip(resources), argoRolloutResource.GroupResource())
+	}
+	needed = nil
+	for _, resource := range resources {
+		needed = append(needed, access{verb: "list", resource: resource})
+	}
+	needed = append(needed, rc.strategyAccess(resources)...)
+	// Jobs are restarted by deleting and creating them again, which needs the Job gone in between
+	if rc.recreateJobs {
+		jobVerbs := []string{"list", "get", "delete", "create"}
+		if rc.dryRun == DryRunClient {
+			jobVerbs = jobVerbs[:1]
+		}
+		for _, verb := range jobVerbs {
+			needed = append(needed, access{verb: verb, resource: jobResource})
+		}
+	}
+	clusterWide, err := rc.missingAccess(ctx, needed)
+	if err != nil || len(clusterWide) == 0 {
+		return err
+	}
+
+	selected, err := rc.namespaces(ctx)
+	if err != nil {
+		return err
+	}
+	needed = nil
+	for _, a := range clusterWide {
+		for _, ns := range selected {
+			a.namespace = ns.Name
+			needed = append(needed, a)
+		}
+	}
+	missing, err = rc.missingAccess(ctx, needed)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return accessError(missing)
+	}
+	return nil
+}
+
+// strategyAccess returns the permissions restarting workloads of resources with the run's restart strategy
+// needs besides listing them.
+func (rc *rolloutClient) strategyAccess(resources []schema.GroupResource) []access {
+	var needed []access
+	switch rc.restartStrategy().Name() {
+	case StrategyEvict:
+		// The pods are listed to pick the ones to cycle even in a dry run
+		needed = append(needed, access{verb: "list", resource: podResource})
+		switch {
+		case rc.dryRun == DryRunClient:
+		case rc.containerRestart != nil:
+			// Containers aren't restarted in a server-side dry run either, as exec has no dry run
+			if rc.dryRun == DryRunNone {
+				needed = append(needed, access{verb: "create", resource: podResource, subresource: "exec"})
+			}
+		default:
+			needed = append(needed, access{verb: "create", resource: podResource, subresource: "eviction"})
+		}
+	case StrategyScale:
+		if rc.dryRun == DryRunClient {
+			break
+		}
+		for _, resource := range resources {
+			if slices.Contains(scalableResources, resource) {
+				needed = append(needed, access{verb: "patch", resource: resource, subresource: "scale"})
+			}
+		}
+		// A server-side dry run doesn't wait for the pods to terminate
+		if rc.dryRun == DryRunNone {
+			needed = append(needed, access{verb: "list", resource: podResource})
+		}
+	default:
+		if rc.dryRun == DryRunClient {
+			break
+		}
+		for _, resource := range resources {
+			needed = append(needed, access{verb: "patch", resource: resource})
+		}
+	}
+	return needed
+}
+
+// missingAccess returns which of needed the client isn't allowed.
+func (rc *rolloutClient) missingAccess(ctx context.Context, needed []access) ([]access, error) {
+	var missing []access
+	for _, a := range needed {
+		reviewCtx, cancel := rc.requestContext(ctx)
+		review, err := rc.cs.AuthorizationV1().SelfSubjectAccessReviews().Create(reviewCtx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   a.namespace,
+					Verb:        a.verb,
+					Group:       a.resource.Group,
+					Resource:    a.resource.Resource,
+					Subresource: a.subresource,
+					Name:        a.name,
+				},
+			},
+		}, metav1.CreateOptions{})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to check whether the client may %s: %w", a, err)
+		}
+		if !review.Status.Allowed {
+			missing = append(missing, a)
+		}
+	}
+	return missing, nil
+}
+
+// accessError describes missing, the namespaces a permission is missing in listed together.
+func accessError(missing []access) error {
+	var descriptions []string
+	index := map[string]int{}
+	for _, a := range missing {
+		if a.namespace == "" {
+			descriptions = append(descriptions, a.String())
+			continue
+		}
+		key := a.permission()
+		if i, ok := index[key]; ok {
+			descriptions[i] += ", " + a.namespace
+			continue
+		}
+		index[key] = len(descriptions)
+		descriptions = append(descriptions, key+" in namespaces "+a.namespace)
+	}
+	return fmt.Errorf("%w: %s", ErrMissingPermissions, strings.Join(descriptions, "; "))
+}
@@ -0,0 +1,112 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckAccess(t *testing.T) {
+	listWorkloads := []string{"list deployments.apps", "list statefulsets.apps", "list daemonsets.apps"}
+
+	tests := []struct {
+		name string
+		opts []Option
+		// denied is the permission refused, if any
+		denied string
+		// want are the permissions checked in every namespace, after listing the namespaces
+		want      []string
+		wantError string
+	}{
+		{
+			name: "rollout",
+			want: append(slices.Clone(listWorkloads), "patch deployments.apps", "patch statefulsets.apps", "patch daemonsets.apps"),
+		},
+		{
+			name: "rollout client dry run",
+			opts: []Option{WithDryRun(DryRunClient)},
+			want: listWorkloads,
+		},
+		{
+			name: "evict",
+			opts: []Option{WithRestartStrategy(EvictStrategy)},
+			want: append(slices.Clone(listWorkloads), "list pods", "create pods/eviction"),
+		},
+		{
+			name: "evict picked pods",
+			opts: []Option{WithPods("web-0")},
+			want: append(slices.Clone(listWorkloads), "list pods", "create pods/eviction"),
+		},
+		{
+			name: "evict server dry run",
+			opts: []Option{WithRestartStrategy(EvictStrategy), WithDryRun(DryRunServer)},
+			want: append(slices.Clone(listWorkloads), "list pods", "create pods/eviction"),
+		},
+		{
+			name: "evict client dry run",
+			opts: []Option{WithRestartStrategy(EvictStrategy), WithDryRun(DryRunClient)},
+			want: append(slices.Clone(listWorkloads), "list pods"),
+		},
+		{
+			name: "restart containers",
+			opts: []Option{WithContainerRestart(&rest.Config{}, nil)},
+			want: append(slices.Clone(listWorkloads), "list pods", "create pods/exec"),
+		},
+		{
+			name: "scale",
+			opts: []Option{WithRestartStrategy(ScaleStrategy)},
+			want: append(slices.Clone(listWorkloads), "patch deployments.apps/scale", "patch statefulsets.apps/scale", "list pods"),
+		},
+		{
+			name: "scale server dry run",
+			opts: []Option{WithRestartStrategy(ScaleStrategy), WithDryRun(DryRunServer)},
+			want: append(slices.Clone(listWorkloads), "patch deployments.apps/scale", "patch statefulsets.apps/scale"),
+		},
+		{
+			name:      "missing eviction",
+			opts:      []Option{WithRestartStrategy(EvictStrategy)},
+			denied:    "create pods/eviction",
+			want:      append(slices.Clone(listWorkloads), "list pods", "create pods/eviction", "create pods/eviction"),
+			wantError: "create pods/eviction in namespaces default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+			var checked []string
+			cs.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				attrs := review.Spec.ResourceAttributes
+				a := access{verb: attrs.Verb, resource: schema.GroupResource{Group: attrs.Group, Resource: attrs.Resource}, subresource: attrs.Subresource}
+				if a.resource != namespaceResource {
+					checked = append(checked, a.permission())
+				}
+				review.Status.Allowed = a.permission() != tt.denied
+				return true, review, nil
+			})
+			rc := newEmbeddedClient(cs, "", tt.opts)
+
+			err := rc.checkAccess(context.Background())
+			switch {
+			case tt.wantError == "" && err != nil:
+				t.Fatal(err)
+			case tt.wantError != "" && (!errors.Is(err, ErrMissingPermissions) || !strings.Contains(err.Error(), tt.wantError)):
+				t.Fatalf("got %v, want an error containing %q", err, tt.wantError)
+			}
+			if !slices.Equal(checked, tt.want) {
+				t.Errorf("got checks\n%s\nwant\n%s", strings.Join(checked, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package rollout
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// workloadObject is the set of typed objects the accessor layer supports, extend the union to add a kind.
+type workloadObject interface {
+	*appsv1.Deployment | *appsv1.StatefulSet | *appsv1.DaemonSet | *batchv1.CronJob | *batchv1.Job
+	metav1.Object
+}
+
+// typedClient is the part of a client-go typed client the accessor layer uses, e.g. DeploymentInterface
+// satisfies typedClient[*appsv1.Deployment, *appsv1.DeploymentList].
+type typedClient[T workloadObject, L any] interface {
+	List(ctx context.Context, opts metav1.ListOptions) (L, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (T, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// kindSpec describes where the parts of a typed object the engine needs live. Supporting a new kind
+// only takes a kindSpec for it in accessors, type checked at compile time.
+type kindSpec[T workloadObject, L any] struct {
+	kind     string
+	gvk      schema.GroupVersionKind
+	client   func(namespace string) typedClient[T, L]
+	items    func(list L) []T
+	template func(obj T) *corev1.PodTemplateSpec
+	selector func(obj T) *metav1.LabelSelector
+	replicas func(obj T) int32
+}
+
+// kindAccessor lists one kind of workload, the restart engine and the read-only modes only deal with
+// workloads through it. watch is only used to invalidate a WorkloadCache.
+type kindAccessor struct {
+	kind  string
+	list  func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]workload, error)
+	watch func(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// accessor erases the kind's type parameters, wrapping each listed object in a kind agnostic workload.
+func (s kindSpec[T, L]) accessor() kindAccessor {
+	return kindAccessor{
+		kind: s.kind,
+		list: func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]workload, error) {
+			list, err := s.client(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+
+			objects := s.items(list)
+			workloads := make([]workload, 0, len(objects))
+			for _, obj := range objects {
+				stripManagedFields(obj)
+				workloads = append(workloads, workload{
+					Kind:      s.kind,
+					Namespace: obj.GetNamespace(),
+					Name:      obj.GetName(),
+					Created:   obj.GetCreationTimestamp().Time,
+					Selector:  s.selector(obj),
+					Template:  s.template(obj),
+					Replicas:  s.replicas(obj),
+					object:    obj,
+					gvk:       s.gvk,
+					patch: func(ctx context.Context, pt types.PatchType, patch []byte, opts metav1.PatchOptions, subresources ...string) error {
+						_, err := s.client(obj.GetNamespace()).Patch(ctx, obj.GetName(), pt, patch, opts, subresources...)
+						return err
+					},
+				})
+			}
+			return workloads, nil
+		},
+		watch: func(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+			return s.client(namespace).Watch(ctx, opts)
+		},
+	}
+}
+
+// accessors returns the accessors for every supported workload kind, in processing order, the batch kinds
+// and Argo Rollouts last when configured (see WithCronJobs, WithJobRecreation and WithArgoRollouts).
+func (rc *rolloutClient) accessors() []kindAccessor {
+	accessors := kindAccessors(rc.cs)
+	if rc.cronJobs {
+		accessors = append(accessors, cronJobsAccessor(rc.cs))
+	}
+	if rc.recreateJobs {
+		accessors = append(accessors, jobsAccessor(rc.cs))
+	}
+	if rc.argoRollouts != nil {
+		accessors = append(accessors, argoRolloutsAccessor(rc.argoRollouts))
+	}
+	return accessors
+}
+
+// kindAccessors returns the accessors for every supported workload kind using cs.
+func kindAccessors(cs kubernetes.Interface) []kindAccessor {
+	return []kindAccessor{
+		kindSpec[*appsv1.Deployment, *appsv1.DeploymentList]{
+			kind: "deployment",
+			gvk:  appsv1.SchemeGroupVersion.WithKind("Deployment"),
+			client: func(namespace string) typedClient[*appsv1.Deployment, *appsv1.DeploymentList] {
+				return cs.AppsV1().Deployments(namespace)
+			},
+			items:    func(list *appsv1.DeploymentList) []*appsv1.Deployment { return pointers(list.Items) },
+			template: func(d *appsv1.Deployment) *corev1.PodTemplateSpec { return &d.Spec.Template },
+			selector: func(d *appsv1.Deployment) *metav1.LabelSelector { return d.Spec.Selector },
+			replicas: func(d *appsv1.Deployment) int32 { return replicasOrDefault(d.Spec.Replicas) },
+		}.accessor(),
+		kindSpec[*appsv1.StatefulSet, *appsv1.StatefulSetList]{
+			kind: "statefulset",
+			gvk:  appsv1.SchemeGroupVersion.WithKind("StatefulSet"),
+			client: func(namespace string) typedClient[*appsv1.StatefulSet, *appsv1.StatefulSetList] {
+				return cs.AppsV1().StatefulSets(namespace)
+			},
+			items:    func(list *appsv1.StatefulSetList) []*appsv1.StatefulSet { return pointers(list.Items) },
+			template: func(sts *appsv1.StatefulSet) *corev1.PodTemplateSpec { return &sts.Spec.Template },
+			selector: func(sts *appsv1.StatefulSet) *metav1.LabelSelector { return sts.Spec.Selector },
+			replicas: func(sts *appsv1.StatefulSet) int32 { return replicasOrDefault(sts.Spec.Replicas) },
+		}.accessor(),
+		kindSpec[*appsv1.DaemonSet, *appsv1.DaemonSetList]{
+			kind: "daemonset",
+			gvk:  appsv1.SchemeGroupVersion.WithKind("DaemonSet"),
+			client: func(namespace string) typedClient[*appsv1.DaemonSet, *appsv1.DaemonSetList] {
+				return cs.AppsV1().DaemonSets(namespace)
+			},
+			items:    func(list *appsv1.DaemonSetList) []*appsv1.DaemonSet { return pointers(list.Items) },
+			template: func(ds *appsv1.DaemonSet) *corev1.PodTemplateSpec { return &ds.Spec.Template },
+			selector: func(ds *appsv1.DaemonSet) *metav1.LabelSelector { return ds.Spec.Selector },
+			replicas: func(ds *appsv1.DaemonSet) int32 { return ds.Status.DesiredNumberScheduled },
+		}.accessor(),
+	}
+}
+
+// stripManagedFields drops obj's managedFields once listed, a memory-only trim: the list responses still
+// carry them, as the API has no way to leave them out, not even in the metadata-only form. On clusters with
+// chatty controllers they can be most of an object's size, and nothing the engine does reads them, so they
+// aren't kept around for a whole run. Requests stay small regardless, every write is a patch or, for
+// recreated Jobs, an object built afresh, neither sends a listed object back.
+func stripManagedFields(obj metav1.Object) {
+	obj.SetManagedFields(nil)
+}
+
+// pointers returns pointers to each element of items, so list items can be handled as typed objects.
+func pointers[E any](items []E) []*E {
+	out := make([]*E, len(items))
+	for i := range items {
+		out[i] = &items[i]
+	}
+	return out
+}
+
+// replicasOrDefault dereferences a spec.replicas field, which the API server defaults to 1 when unset.
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
@@ -0,0 +1,191 @@
+package rollout
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+var (
+	testLabels   = map[string]string{"app": "web"}
+	testSelector = &metav1.LabelSelector{MatchLabels: testLabels}
+	testTemplate = corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: testLabels}}
+	testManaged  = []metav1.ManagedFieldsEntry{{Manager: "kubectl"}}
+)
+
+// testMeta returns the metadata of a test object called name in the default namespace.
+func testMeta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: "default", ManagedFields: testManaged}
+}
+
+func TestKindAccessorsList(t *testing.T) {
+	tests := []struct {
+		name     string
+		accessor func(*fake.Clientset) kindAccessor
+		objects  []runtime.Object
+		// want are the names of the listed workloads with their replicas
+		want         map[string]int32
+		wantGVK      schema.GroupVersionKind
+		wantSelector bool
+	}{
+		{
+			name:     "deployments default to one replica",
+			accessor: func(cs *fake.Clientset) kindAccessor { return kindAccessors(cs)[0] },
+			objects: []runtime.Object{
+				&appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](3), Selector: testSelector, Template: testTemplate}},
+				&appsv1.Deployment{ObjectMeta: testMeta("api"), Spec: appsv1.DeploymentSpec{Selector: testSelector, Template: testTemplate}},
+			},
+			want:         map[string]int32{"web": 3, "api": 1},
+			wantGVK:      appsv1.SchemeGroupVersion.WithKind("Deployment"),
+			wantSelector: true,
+		},
+		{
+			name:     "statefulsets",
+			accessor: func(cs *fake.Clientset) kindAccessor { return kindAccessors(cs)[1] },
+			objects: []runtime.Object{
+				&appsv1.StatefulSet{ObjectMeta: testMeta("db"), Spec: appsv1.StatefulSetSpec{Replicas: ptr.To[int32](2), Selector: testSelector, Template: testTemplate}},
+			},
+			want:         map[string]int32{"db": 2},
+			wantGVK:      appsv1.SchemeGroupVersion.WithKind("StatefulSet"),
+			wantSelector: true,
+		},
+		{
+			name:     "daemonsets count their scheduled pods",
+			accessor: func(cs *fake.Clientset) kindAccessor { return kindAccessors(cs)[2] },
+			objects: []runtime.Object{
+				&appsv1.DaemonSet{ObjectMeta: testMeta("agent"), Spec: appsv1.DaemonSetSpec{Selector: testSelector, Template: testTemplate}, Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 5}},
+			},
+			want:         map[string]int32{"agent": 5},
+			wantGVK:      appsv1.SchemeGroupVersion.WithKind("DaemonSet"),
+			wantSelector: true,
+		},
+		{
+			name:     "cronjobs have no pods of their own",
+			accessor: func(cs *fake.Clientset) kindAccessor { return cronJobsAccessor(cs) },
+			objects: []runtime.Object{
+				&batchv1.CronJob{ObjectMeta: testMeta("backup"), Spec: batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: testTemplate}}}},
+			},
+			want:    map[string]int32{"backup": 0},
+			wantGVK: batchv1.SchemeGroupVersion.WithKind("CronJob"),
+		},
+		{
+			name:     "only running jobs not started by a cronjob",
+			accessor: func(cs *fake.Clientset) kindAccessor { return jobsAccessor(cs) },
+			objects: []runtime.Object{
+				&batchv1.Job{ObjectMeta: testMeta("migrate"), Spec: batchv1.JobSpec{Parallelism: ptr.To[int32](2), Selector: testSelector, Template: testTemplate}, Status: batchv1.JobStatus{Active: 2}},
+				&batchv1.Job{ObjectMeta: testMeta("done"), Spec: batchv1.JobSpec{Template: testTemplate}, Status: batchv1.JobStatus{CompletionTime: &metav1.Time{}}},
+				&batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: "backup-1", Namespace: "default", OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "batch/v1", Kind: "CronJob", Name: "backup", Controller: ptr.To(true),
+					}}},
+					Spec:   batchv1.JobSpec{Template: testTemplate},
+					Status: batchv1.JobStatus{Active: 1},
+				},
+			},
+			want:         map[string]int32{"migrate": 2},
+			wantGVK:      batchv1.SchemeGroupVersion.WithKind("Job"),
+			wantSelector: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accessor := tt.accessor(fake.NewSimpleClientset(tt.objects...))
+
+			workloads, err := accessor.list(context.Background(), "default", metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			got := map[string]int32{}
+			for _, w := range workloads {
+				got[w.Name] = w.Replicas
+				if w.Kind != accessor.kind || w.Namespace != "default" {
+					t.Errorf("%s: got kind %q in %q, want %q in default", w.Name, w.Kind, w.Namespace, accessor.kind)
+				}
+				if w.gvk != tt.wantGVK {
+					t.Errorf("%s: got gvk %v, want %v", w.Name, w.gvk, tt.wantGVK)
+				}
+				if w.Template == nil || w.Template.Labels["app"] != "web" {
+					t.Errorf("%s: got template %v, want the object's", w.Name, w.Template)
+				}
+				if (w.Selector != nil) != tt.wantSelector {
+					t.Errorf("%s: got selector %v, want one: %t", w.Name, w.Selector, tt.wantSelector)
+				}
+				if len(w.object.GetManagedFields()) > 0 {
+					t.Errorf("%s: managed fields weren't stripped", w.Name)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got workloads %v, want %v", got, tt.want)
+			}
+			for name, replicas := range tt.want {
+				if r, ok := got[name]; !ok || r != replicas {
+					t.Errorf("got workloads %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestKindAccessorPatch(t *testing.T) {
+	for i, kind := range []string{"deployment", "statefulset", "daemonset"} {
+		t.Run(kind, func(t *testing.T) {
+			cs := fake.NewSimpleClientset(
+				&appsv1.Deployment{ObjectMeta: testMeta("web")},
+				&appsv1.StatefulSet{ObjectMeta: testMeta("web")},
+				&appsv1.DaemonSet{ObjectMeta: testMeta("web")},
+			)
+			workloads, err := kindAccessors(cs)[i].list(context.Background(), "default", metav1.ListOptions{})
+			if err != nil || len(workloads) != 1 {
+				t.Fatalf("list: got %d workloads, %v", len(workloads), err)
+			}
+
+			cs.ClearActions()
+			patch := []byte(`{"metadata":{"labels":{"patched":"true"}}}`)
+			if err := workloads[0].patch(context.Background(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+				t.Fatalf("patch: %v", err)
+			}
+			actions := cs.Actions()
+			if len(actions) != 1 || !actions[0].Matches("patch", kind+"s") {
+				t.Fatalf("got actions %v, want a single patch of %ss", actions, kind)
+			}
+			if got := actions[0].(k8stesting.PatchAction); got.GetName() != "web" || got.GetNamespace() != "default" || string(got.GetPatch()) != string(patch) {
+				t.Errorf("got patch of %s/%s with %s", got.GetNamespace(), got.GetName(), got.GetPatch())
+			}
+		})
+	}
+}
+
+func TestAccessorsOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{name: "built-in kinds", want: []string{"deployment", "statefulset", "daemonset"}},
+		{name: "batch kinds last", opts: []Option{WithJobRecreation(), WithCronJobs()}, want: []string{"deployment", "statefulset", "daemonset", cronJobKind, jobKind}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newEmbeddedClient(fake.NewSimpleClientset(), "", tt.opts)
+			var got []string
+			for _, a := range rc.accessors() {
+				got = append(got, a.kind)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got kinds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// admissionContext holds what admission applies to the new pods of a namespace's workloads.
+type admissionContext struct {
+	namespaceLabels map[string]string
+	limitRanges     []corev1.LimitRange
+	webhooks        []admissionregistrationv1.MutatingWebhookConfiguration
+}
+
+// admissionNotes describes how the pods w creates on restart will differ from its running pods because
+// LimitRange defaults or mutating webhooks have changed since the running pods were admitted, e.g. new
+// default resources or an injected sidecar upgraded since the last deploy.
+func admissionNotes(w workload, pods []corev1.Pod, admission admissionContext) []string {
+	if len(pods) == 0 {
+		return nil
+	}
+
+	var notes []string
+	for _, lr := range admission.limitRanges {
+		for _, item := range lr.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			for _, c := range w.Template.Spec.Containers {
+				notes = append(notes, limitRangeNotes(lr.Name, c, item.DefaultRequest, "request", pods, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Requests })...)
+				notes = append(notes, limitRangeNotes(lr.Name, c, item.Default, "limit", pods, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Limits })...)
+			}
+		}
+	}
+
+	oldest := pods[0].CreationTimestamp.Time
+	for _, pod := range pods {
+		if pod.CreationTimestamp.Time.Before(oldest) {
+			oldest = pod.CreationTimestamp.Time
+		}
+	}
+	for _, config := range admission.webhooks {
+		modified := lastModified(&config)
+		if !modified.After(oldest) {
+			continue
+		}
+		for _, webhook := range config.Webhooks {
+			if mutatesPods(webhook, admission.namespaceLabels, w.Template.Labels) {
+				notes = append(notes, fmt.Sprintf("mutating webhook %s (%s) changed at %s, after the running pods were created", webhook.Name, config.Name, modified.Format(time.RFC3339)))
+			}
+		}
+	}
+	return notes
+}
+
+// limitRangeNotes notes each default of a LimitRange the container doesn't set itself that differs from
+// what the running pods got.
+func limitRangeNotes(limitRange string, c corev1.Container, defaults corev1.ResourceList, what string, pods []corev1.Pod, get func(corev1.ResourceRequirements) corev1.ResourceList) []string {
+	var notes []string
+	for name, value := range defaults {
+		if _, set := get(c.Resources)[name]; set {
+			continue
+		}
+		for _, pod := range pods {
+			i := slices.IndexFunc(pod.Spec.Containers, func(pc corev1.Container) bool { return pc.Name == c.Name })
+			if i < 0 {
+				continue
+			}
+			current, found := get(pod.Spec.Containers[i].Resources)[name]
+			if !found || current.Cmp(value) != 0 {
+				notes = append(notes, fmt.Sprintf("LimitRange %s now defaults the %s %s of container %s to %s (running pod %s has %s)",
+					limitRange, name, what, c.Name, value.String(), pod.Name, quantityOrNone(current, found)))
+				break
+			}
+		}
+	}
+	return notes
+}
+
+func quantityOrNone(q resource.Quantity, found bool) string {
+	if !found {
+		return "none"
+	}
+	return q.String()
+}
+
+// mutatesPods reports whether the webhook intercepts the creation of pods with podLabels in a namespace
+// with namespaceLabels.
+func mutatesPods(webhook admissionregistrationv1.MutatingWebhook, namespaceLabels, podLabels map[string]string) bool {
+	if !selectorMatches(webhook.NamespaceSelector, namespaceLabels) || !selectorMatches(webhook.ObjectSelector, podLabels) {
+		return false
+	}
+
+	for _, rule := range webhook.Rules {
+		creates := slices.Contains(rule.Operations, admissionregistrationv1.Create) || slices.Contains(rule.Operations, admissionregistrationv1.OperationAll)
+		core := slices.Contains(rule.APIGroups, "") || slices.Contains(rule.APIGroups, "*")
+		pods := slices.Contains(rule.Resources, "pods") || slices.Contains(rule.Resources, "*")
+		if creates && core && pods {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorMatches treats a nil selector as matching everything, as admission does.
+func selectorMatches(selector *metav1.LabelSelector, set map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(set))
+}
+
+// lastModified returns when obj was last written, the latest of its creation and managed fields times.
+func lastModified(obj metav1.Object) time.Time {
+	modified := obj.GetCreationTimestamp().Time
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Time != nil && entry.Time.After(modified) {
+			modified = entry.Time.Time
+		}
+	}
+	return modified
+}
+
+// mutatingWebhooks lists the cluster's mutating webhook configurations.
+func (rc *rolloutClient) mutatingWebhooks(ctx context.Context) ([]admissionregistrationv1.MutatingWebhookConfiguration, error) {
+	listCtx, cancel := rc.requestContext(ctx)
+	defer cancel()
+	configs, err := rc.cs.AdmissionregistrationV1().MutatingWebhookConfigurations().List(listCtx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mutating webhooks: %w", err)
+	}
+	return configs.Items, nil
+}
+
+// limitRanges lists the LimitRanges of namespace.
+func (rc *rolloutClient) limitRanges(ctx context.Context, namespace string) ([]corev1.LimitRange, error) {
+	listCtx, cancel := rc.requestContext(ctx)
+	defer cancel()
+	limitRanges, err := rc.cs.CoreV1().LimitRanges(namespace).List(listCtx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list limit ranges: %w", err)
+	}
+	return limitRanges.Items, nil
+}
@@ -0,0 +1,328 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Plan, Apply and the Report they produce are the stable API for embedding restarts in infrastructure
+// tooling, e.g. custom Terraform providers or operators. Unlike NewRolloutClient they take no logger,
+// nothing is logged and progress is reported through Callbacks instead, so embedders don't have to adopt
+// the CLI's logging.
+
+// RestartPlan is the set of workloads a restart would cycle, computed by Plan without changing anything.
+// Written out it follows PlanSchema, see ParsePlan to read it back.
+type RestartPlan struct {
+	SchemaVersion string `json:"schemaVersion"`
+	Filter        string
+	Workloads     []PlannedRestart
+}
+
+// PlannedRestart is a workload a restart plan cycles. Notes describe how its new pods will differ from the
+// running ones, e.g. because a LimitRange or mutating webhook changed since they were created, Related the
+// objects shaping how it behaves while it is cycled (see WithRelatedObjects).
+type PlannedRestart struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Owner     string
+	Replicas  int32
+	Notes     []string
+	Related   []RelatedObject `json:",omitempty"`
+}
+
+// Report is the outcome of a run, returned by Run and Apply. Errors are the run level errors, e.g. a
+// namespace that couldn't be listed, the error of each failed resource is in its result. Written out it
+// follows ReportSchema, see ParseReport to read it back.
+type Report struct {
+	SchemaVersion string `json:"schemaVersion"`
+	RunID         string
+	Cluster       string `json:",omitempty"`
+	// Initiator is the user who made a restart recorded by the audit webhook, see ExternalRestart
+	Initiator string `json:",omitempty"`
+	StartTime time.Time
+	// LocalStartTime is StartTime in TimeZone, the time zone reports are read in, see WithTimeZone
+	LocalStartTime *time.Time `json:",omitempty"`
+	TimeZone       string     `json:",omitempty"`
+	Duration       time.Duration
+	Restarted      int
+	Failed         int
+	Skipped        int
+	Cancelled      bool
+	CancelReason   string
+	Errors         []string
+	Warnings       []Warning
+	Results        []ResourceResult
+	// ByNamespace and ByKind tally the results per namespace and per workload kind, ByCluster per cluster
+	// in a report merging the runs of several clusters (see MergeReports)
+	ByNamespace map[string]Tally `json:",omitempty"`
+	ByKind      map[string]Tally `json:",omitempty"`
+	ByCluster   map[string]Tally `json:",omitempty"`
+	// Usage is the client's own footprint during the run, see WithUsageTracker
+	Usage *Usage `json:",omitempty"`
+}
+
+// Tally counts the results of a group of workloads by outcome.
+type Tally struct {
+	Restarted int
+	Failed    int
+	Skipped   int
+	DryRun    int `json:",omitempty"`
+}
+
+// add counts a result with action.
+func (t *Tally) add(action string) {
+	switch action {
+	case ActionRestarted:
+		t.Restarted++
+	case ActionFailed:
+		t.Failed++
+	case ActionSkipped:
+		t.Skipped++
+	case ActionDryRun:
+		t.DryRun++
+	}
+}
+
+// Plan returns every workload in the cluster whose name contains filter, narrowed further by opts such as
+// WithTeam, without changing anything. The plan can be reviewed before it is passed to Apply, each workload
+// noting how admission (LimitRange defaults, mutating webhooks) will make its new pods differ from the
+// running ones. Workloads are listed in the order a run restarts them (see WithOrder), so plans of the same
+// cluster state are identical.
+func Plan(ctx context.Context, clientset kubernetes.Interface, filter string, opts ...Option) (*RestartPlan, error) {
+	rc := newEmbeddedClient(clientset, filter, opts)
+	plan := &RestartPlan{SchemaVersion: PlanSchemaVersion, Filter: filter, Workloads: []PlannedRestart{}}
+
+	namespaces, err := rc.namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := rc.mutatingWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []workload
+	notes := map[string][]string{}
+	for _, ns := range namespaces {
+		workloads, err := rc.listMatchingWorkloads(ctx, ns.Name)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", ns.Name, err)
+		}
+		if len(workloads) == 0 {
+			continue
+		}
+
+		limitRanges, err := rc.limitRanges(ctx, ns.Name)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", ns.Name, err)
+		}
+		admission := admissionContext{namespaceLabels: ns.Labels, limitRanges: limitRanges, webhooks: webhooks}
+
+		for _, w := range workloads {
+			pods, err := rc.listPods(ctx, w)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s/%s: %w", w.Kind, w.Namespace, w.Name, err)
+			}
+			notes[checkpointKey(w.Kind, w.Namespace, w.Name)] = admissionNotes(w, pods, admission)
+			matched = append(matched, w)
+		}
+	}
+
+	// The plan lists workloads in the order a run restarts them
+	if rc.order != OrderName {
+		rc.assessRisk(ctx, matched)
+	}
+	rc.sortWorkloads(matched, namespaces)
+
+	var related map[string]*namespaceObjects
+	if rc.relatedObjects {
+		var matchedNamespaces []string
+		for _, w := range matched {
+			if !slices.Contains(matchedNamespaces, w.Namespace) {
+				matchedNamespaces = append(matchedNamespaces, w.Namespace)
+			}
+		}
+		if related, err = rc.listRelatedObjects(ctx, matchedNamespaces); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, w := range matched {
+		planned := PlannedRestart{
+			Kind:      w.Kind,
+			Namespace: w.Namespace,
+			Name:      w.Name,
+			Owner:     rc.ownerOf(w.object),
+			Replicas:  w.Replicas,
+			Notes:     notes[checkpointKey(w.Kind, w.Namespace, w.Name)],
+		}
+		if related != nil {
+			planned.Related = related[w.Namespace].relatedTo(w)
+		}
+		plan.Workloads = append(plan.Workloads, planned)
+	}
+	return plan, nil
+}
+
+// Apply restarts exactly the workloads in plan that still exist, reporting progress through cb as it
+// happens. Workloads created since the plan was made are left alone. The report is returned even when
+// the run fails or is cancelled, holding the partial results.
+func Apply(ctx context.Context, clientset kubernetes.Interface, plan *RestartPlan, cb Callbacks, opts ...Option) (*Report, error) {
+	rc := newEmbeddedClient(clientset, plan.Filter, append(opts, WithPlan(plan)))
+	return rc.RunWithCallbacks(ctx, cb)
+}
+
+// WithPlan limits a run to the workloads in plan, on top of the podFilter and any other restrictions.
+// Apply uses it, it also lets the CLI run a plan with its full set of options.
+func WithPlan(plan *RestartPlan) Option {
+	return func(rc *rolloutClient) {
+		rc.planned = make(map[string]bool, len(plan.Workloads))
+		for _, w := range plan.Workloads {
+			rc.planned[checkpointKey(w.Kind, w.Namespace, w.Name)] = true
+		}
+	}
+}
+
+// RetryPlan returns a plan restarting only the workloads that failed in report, e.g. to retry them once the
+// cause has been fixed. It matches every name, so the workloads are retried whatever filter the original
+// run used.
+func RetryPlan(report *Report) *RestartPlan {
+	plan := &RestartPlan{SchemaVersion: PlanSchemaVersion, Workloads: []PlannedRestart{}}
+	for _, r := range report.Results {
+		if r.Action == ActionFailed {
+			plan.Workloads = append(plan.Workloads, PlannedRestart{
+				Kind:      r.Kind,
+				Namespace: r.Namespace,
+				Name:      r.Name,
+				Owner:     r.Owner,
+			})
+		}
+	}
+	return plan
+}
+
+// newEmbeddedClient creates a client for the embedding API, discarding its log output.
+func newEmbeddedClient(clientset kubernetes.Interface, filter string, opts []Option) *rolloutClient {
+	return NewRolloutClient(clientset, filter, discardLogger, opts...)
+}
+
+// Report summarises the last Run, the run so far while it is still in progress.
+func (rc *rolloutClient) Report() *Report {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.metadata == nil {
+		return &Report{SchemaVersion: ReportSchemaVersion}
+	}
+
+	end := rc.metadata.EndTime
+	if end.IsZero() {
+		end = rc.clock.Now()
+	}
+	report := &Report{
+		SchemaVersion: ReportSchemaVersion,
+		RunID:         rc.metadata.RunID,
+		Cluster:       rc.clusterName,
+		StartTime:     rc.metadata.StartTime.UTC(),
+		Duration:      end.Sub(rc.metadata.StartTime),
+		Cancelled:     rc.metadata.Cancelled,
+		CancelReason:  rc.metadata.CancelReason,
+		Errors:        []string{},
+		Warnings:      rc.warnings(),
+		Results:       rc.results(),
+		ByNamespace:   map[string]Tally{},
+		ByKind:        map[string]Tally{},
+		Usage:         rc.metadata.Usage,
+	}
+	report.setTimeZone(rc.timeZone)
+	for _, err := range rc.metadata.Errors {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	for _, r := range report.Results {
+		switch r.Action {
+		case ActionRestarted:
+			report.Restarted++
+		case ActionFailed:
+			report.Failed++
+		case ActionSkipped:
+			report.Skipped++
+		}
+
+		ns, kind := report.ByNamespace[r.Namespace], report.ByKind[r.Kind]
+		ns.add(r.Action)
+		kind.add(r.Action)
+		report.ByNamespace[r.Namespace], report.ByKind[r.Kind] = ns, kind
+	}
+	return report
+}
+
+// MergeReports merges the reports of runs against several clusters into a single one, under a run ID of its
+// own, with their results tallied per cluster in ByCluster. Run level errors are prefixed with the cluster
+// they happened in, the usage is the sum of the runs', their peak memory the highest one.
+func MergeReports(reports ...*Report) *Report {
+	merged := &Report{
+		SchemaVersion: ReportSchemaVersion,
+		RunID:         newRunID(),
+		Errors:        []string{},
+		ByNamespace:   map[string]Tally{},
+		ByKind:        map[string]Tally{},
+		ByCluster:     map[string]Tally{},
+	}
+	var end time.Time
+	for _, report := range reports {
+		if merged.StartTime.IsZero() || report.StartTime.Before(merged.StartTime) {
+			merged.StartTime = report.StartTime
+		}
+		if reportEnd := report.StartTime.Add(report.Duration); reportEnd.After(end) {
+			end = reportEnd
+		}
+		merged.Restarted += report.Restarted
+		merged.Failed += report.Failed
+		merged.Skipped += report.Skipped
+		if report.Cancelled && !merged.Cancelled {
+			merged.Cancelled, merged.CancelReason = true, report.CancelReason
+		}
+		for _, err := range report.Errors {
+			merged.Errors = append(merged.Errors, report.Cluster+": "+err)
+		}
+		merged.Warnings = append(merged.Warnings, report.Warnings...)
+		merged.Results = append(merged.Results, report.Results...)
+
+		for _, r := range report.Results {
+			ns, kind, cluster := merged.ByNamespace[r.Namespace], merged.ByKind[r.Kind], merged.ByCluster[r.Cluster]
+			ns.add(r.Action)
+			kind.add(r.Action)
+			cluster.add(r.Action)
+			merged.ByNamespace[r.Namespace], merged.ByKind[r.Kind], merged.ByCluster[r.Cluster] = ns, kind, cluster
+		}
+
+		if report.Usage != nil {
+			if merged.Usage == nil {
+				merged.Usage = &Usage{}
+			}
+			merged.Usage.APIRequests += report.Usage.APIRequests
+			merged.Usage.BytesSent += report.Usage.BytesSent
+			merged.Usage.BytesReceived += report.Usage.BytesReceived
+			merged.Usage.PeakMemoryBytes = max(merged.Usage.PeakMemoryBytes, report.Usage.PeakMemoryBytes)
+		}
+	}
+	merged.Duration = end.Sub(merged.StartTime)
+	if len(reports) > 0 && reports[0].LocalStartTime != nil {
+		merged.setTimeZone(reports[0].LocalStartTime.Location())
+	}
+	return merged
+}
+
+// setTimeZone sets the local start time of the report in loc, nothing when nil.
+func (r *Report) setTimeZone(loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	local := r.StartTime.In(loc)
+	r.LocalStartTime, r.TimeZone = &local, loc.String()
+}
@@ -0,0 +1,152 @@
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	// argoRolloutGVK and argoRolloutResource are Argo Rollouts' Rollout, its replacement for Deployments
+	argoRolloutGVK      = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+	argoRolloutResource = argoRolloutGVK.GroupVersion().WithResource("rollouts")
+)
+
+// argoRolloutKind is the workload kind of Argo Rollouts.
+const argoRolloutKind = "rollout"
+
+// WithArgoRollouts restarts Argo Rollouts (argoproj.io/v1alpha1 Rollout) alongside the built-in workload
+// kinds, listing and updating them with client. A Rollout is restarted through its spec.restartAt field, the
+// same way kubectl argo rollouts restart does, which has the Argo Rollouts controller replace its pods
+// without a new revision, so canary steps and analysis don't run again. Template stamps and provenance
+// (see WithTemplateStamp and WithProvenanceSigning) are left off Rollouts, as changing their pod template
+// would start a new revision.
+func WithArgoRollouts(client dynamic.Interface) Option {
+	return func(rc *rolloutClient) {
+		rc.argoRollouts = client
+	}
+}
+
+// argoRolloutsAccessor returns the accessor of Argo Rollouts using client. Rollouts are handled as
+// unstructured objects, their pod template and selector are converted to the typed ones.
+func argoRolloutsAccessor(client dynamic.Interface) kindAccessor {
+	return kindAccessor{
+		kind: argoRolloutKind,
+		list: func(ctx context.Context, namespace string, opts metav1.ListOptions) ([]workload, error) {
+			list, err := client.Resource(argoRolloutResource).Namespace(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+
+			workloads := make([]workload, 0, len(list.Items))
+			for i := range list.Items {
+				obj := &list.Items[i]
+				stripManagedFields(obj)
+				w, err := argoRolloutWorkload(client, obj)
+				if err != nil {
+					return nil, fmt.Errorf("rollout %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+				}
+				workloads = append(workloads, w)
+			}
+			return workloads, nil
+		},
+		watch: func(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+			return client.Resource(argoRolloutResource).Namespace(namespace).Watch(ctx, opts)
+		},
+	}
+}
+
+// argoRolloutWorkload wraps the Rollout obj in a workload. A Rollout referencing a Deployment's pod template
+// (spec.workloadRef) has an empty Template.
+func argoRolloutWorkload(client dynamic.Interface, obj *unstructured.Unstructured) (workload, error) {
+	var spec struct {
+		Replicas *int32                 `json:"replicas"`
+		Selector *metav1.LabelSelector  `json:"selector"`
+		Template corev1.PodTemplateSpec `json:"template"`
+	}
+	if content, ok := obj.Object["spec"].(map[string]any); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, &spec); err != nil {
+			return workload{}, fmt.Errorf("failed to read spec: %w", err)
+		}
+	}
+
+	return workload{
+		Kind:      argoRolloutKind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Created:   obj.GetCreationTimestamp().Time,
+		Selector:  spec.Selector,
+		Template:  &spec.Template,
+		Replicas:  replicasOrDefault(spec.Replicas),
+		object:    obj,
+		gvk:       argoRolloutGVK,
+		patch: func(ctx context.Context, pt types.PatchType, patch []byte, opts metav1.PatchOptions, subresources ...string) error {
+			_, err := client.Resource(argoRolloutResource).Namespace(obj.GetNamespace()).Patch(ctx, obj.GetName(), pt, patch, opts, subresources...)
+			return err
+		},
+	}, nil
+}
+
+// restartArgoRollout restarts the Rollout w by setting its spec.restartAt to now.
+func (rc *rolloutClient) restartArgoRollout(ctx context.Context, w workload) error {
+	patch, err := json.Marshal(map[string]any{
+		"spec": map[string]any{"restartAt": restartTimestamp(rc.clock.Now())},
+	})
+	if err != nil {
+		return err
+	}
+
+	if rc.dryRun == DryRunClient {
+		rc.logger(ctx).WithField("patch", string(patch)).Infof("Dry run, would restart %s", w.Kind)
+		return nil
+	}
+	rc.logger(ctx).Infof("Restarting %s", w.Kind)
+
+	// A patch that has already been scheduled is allowed to finish even if the run is cancelled
+	return rc.patchWorkload(context.WithoutCancel(ctx), w, types.MergePatchType, patch)
+}
+
+// argoRolloutStatus is the part of a Rollout's status the engine reads.
+type argoRolloutStatus struct {
+	Phase             string       `json:"phase"`
+	RestartedAt       *metav1.Time `json:"restartedAt"`
+	Replicas          int32        `json:"replicas"`
+	UpdatedReplicas   int32        `json:"updatedReplicas"`
+	ReadyReplicas     int32        `json:"readyReplicas"`
+	AvailableReplicas int32        `json:"availableReplicas"`
+}
+
+// argoStatus returns the status of the Rollout obj, empty when it can't be read.
+func argoStatus(obj *unstructured.Unstructured) argoRolloutStatus {
+	var status argoRolloutStatus
+	if content, ok := obj.Object["status"].(map[string]any); ok {
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(content, &status)
+	}
+	return status
+}
+
+// argoRolledOut reports whether the Rollout w has finished its restart and is healthy: the controller has
+// replaced every pod for its spec.restartAt, and every desired pod is updated and available.
+func argoRolledOut(w workload, obj *unstructured.Unstructured) bool {
+	status := argoStatus(obj)
+	if value, _, _ := unstructured.NestedString(obj.Object, "spec", "restartAt"); value != "" {
+		restartAt, err := time.Parse(time.RFC3339, value)
+		if err != nil || status.RestartedAt == nil || status.RestartedAt.Time.Before(restartAt) {
+			return false
+		}
+	}
+	return status.Phase == "Healthy" &&
+		status.UpdatedReplicas >= w.Replicas &&
+		status.Replicas <= status.UpdatedReplicas &&
+		status.AvailableReplicas >= status.UpdatedReplicas
+}
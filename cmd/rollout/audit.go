@@ -0,0 +1,86 @@
+package rollout
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// auditedObject is the part of a Deployment, StatefulSet or DaemonSet the audit webhook looks at.
+type auditedObject struct {
+	Spec struct {
+		Template struct {
+			metav1.ObjectMeta `json:"metadata"`
+		} `json:"template"`
+	} `json:"spec"`
+}
+
+// ExternalRestart returns the report of a restart made outside this tool, e.g. with kubectl rollout
+// restart or by another controller, when the admission request req changes the restartedAt pod template
+// annotation of a Deployment, StatefulSet or DaemonSet, nil for any other request. Kept in the run history
+// next to the tool's own runs, the reports give one audit trail of every restart, which the history and undo
+// commands work with the same as with a run's.
+//
+// Updates sent as one of ownFieldManagers are left out, the run making them already recorded them, and so
+// are dry runs.
+func ExternalRestart(req *admissionv1.AdmissionRequest, now time.Time, ownFieldManagers []string) (*Report, error) {
+	if req.Operation != admissionv1.Update || req.Kind.Group != "apps" || (req.DryRun != nil && *req.DryRun) {
+		return nil, nil
+	}
+	switch req.Kind.Kind {
+	case "Deployment", "StatefulSet", "DaemonSet":
+	default:
+		return nil, nil
+	}
+
+	if len(req.Options.Raw) > 0 {
+		var options struct {
+			FieldManager string `json:"fieldManager"`
+		}
+		if err := json.Unmarshal(req.Options.Raw, &options); err != nil {
+			return nil, fmt.Errorf("failed to decode the %s options: %w", strings.ToLower(string(req.Operation)), err)
+		}
+		if slices.Contains(ownFieldManagers, options.FieldManager) {
+			return nil, nil
+		}
+	}
+
+	var object, old auditedObject
+	if err := json.Unmarshal(req.Object.Raw, &object); err != nil {
+		return nil, fmt.Errorf("failed to decode the %s: %w", req.Kind.Kind, err)
+	}
+	if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+		return nil, fmt.Errorf("failed to decode the previous %s: %w", req.Kind.Kind, err)
+	}
+	restartedAt := object.Spec.Template.Annotations[restartedAtAnnotation]
+	if restartedAt == "" || restartedAt == old.Spec.Template.Annotations[restartedAtAnnotation] {
+		return nil, nil
+	}
+
+	set := map[string]string{restartedAtAnnotation: restartedAt}
+	result := ResourceResult{
+		Namespace:           req.Namespace,
+		Kind:                strings.ToLower(req.Kind.Kind),
+		Name:                req.Name,
+		Action:              ActionRestarted,
+		SetAnnotations:      set,
+		PreviousAnnotations: previousValues(old.Spec.Template.Annotations, set),
+	}
+	tally := Tally{Restarted: 1}
+	return &Report{
+		SchemaVersion: ReportSchemaVersion,
+		RunID:         newRunID(),
+		Initiator:     req.UserInfo.Username,
+		StartTime:     now.UTC(),
+		Restarted:     1,
+		Errors:        []string{},
+		Results:       []ResourceResult{result},
+		ByNamespace:   map[string]Tally{result.Namespace: tally},
+		ByKind:        map[string]Tally{result.Kind: tally},
+	}, nil
+}
@@ -0,0 +1,153 @@
+package rollout
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+// auditRequest returns the admission request of an update to the web Deployment changing its restartedAt
+// annotation from old to restartedAt, "" for none, sent as fieldManager.
+func auditRequest(t *testing.T, old, restartedAt, fieldManager string) *admissionv1.AdmissionRequest {
+	t.Helper()
+	raw := func(v any) runtime.RawExtension {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runtime.RawExtension{Raw: data}
+	}
+	deployment := func(restartedAt string) *appsv1.Deployment {
+		template := *testTemplate.DeepCopy()
+		if restartedAt != "" {
+			template.Annotations = map[string]string{restartedAtAnnotation: restartedAt}
+		}
+		return &appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Template: template}}
+	}
+	return &admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Namespace: "default",
+		Name:      "web",
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+		Object:    raw(deployment(restartedAt)),
+		OldObject: raw(deployment(old)),
+		Options:   raw(metav1.PatchOptions{FieldManager: fieldManager}),
+	}
+}
+
+func TestExternalRestart(t *testing.T) {
+	earlier := "2024-01-01T00:00:00Z"
+	restartedAt := restartTimestamp(testNow)
+
+	tests := []struct {
+		name string
+		req  func(t *testing.T) *admissionv1.AdmissionRequest
+		// recorded is whether the request is recorded as a restart, previous the restartedAt it records as
+		// the previous one
+		recorded bool
+		previous *string
+	}{
+		{
+			name: "first restart",
+			req: func(t *testing.T) *admissionv1.AdmissionRequest {
+				return auditRequest(t, "", restartedAt, "kubectl-rollout")
+			},
+			recorded: true,
+		},
+		{
+			name: "restart again",
+			req: func(t *testing.T) *admissionv1.AdmissionRequest {
+				return auditRequest(t, earlier, restartedAt, "kubectl-rollout")
+			},
+			previous: &earlier,
+			recorded: true,
+		},
+		{
+			name: "unchanged annotation",
+			req: func(t *testing.T) *admissionv1.AdmissionRequest {
+				return auditRequest(t, earlier, earlier, "kubectl-edit")
+			},
+		},
+		{
+			name: "annotation removed",
+			req:  func(t *testing.T) *admissionv1.AdmissionRequest { return auditRequest(t, earlier, "", "kubectl-edit") },
+		},
+		{
+			name: "own restart",
+			req: func(t *testing.T) *admissionv1.AdmissionRequest {
+				return auditRequest(t, "", restartedAt, DefaultFieldManager)
+			},
+		},
+		{
+			name: "dry run",
+			req: func(t *testing.T) *admissionv1.AdmissionRequest {
+				req := auditRequest(t, "", restartedAt, "kubectl-rollout")
+				req.DryRun = ptr.To(true)
+				return req
+			},
+		},
+		{
+			name: "create",
+			req: func(t *testing.T) *admissionv1.AdmissionRequest {
+				req := auditRequest(t, "", restartedAt, "kubectl-rollout")
+				req.Operation = admissionv1.Create
+				return req
+			},
+		},
+		{
+			name: "other kind",
+			req: func(t *testing.T) *admissionv1.AdmissionRequest {
+				req := auditRequest(t, "", restartedAt, "kubectl-rollout")
+				req.Kind = metav1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}
+				return req
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ExternalRestart(tt.req(t), testNow, []string{DefaultFieldManager})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.recorded {
+				if report != nil {
+					t.Fatalf("got a report of %v, want none", report.Results)
+				}
+				return
+			}
+			if report == nil {
+				t.Fatal("got no report, want the restart recorded")
+			}
+
+			if report.Initiator != "alice" || !report.StartTime.Equal(testNow) || report.Restarted != 1 {
+				t.Errorf("got initiator %q, start %v and %d restarted, want alice, %v and 1", report.Initiator, report.StartTime, report.Restarted, testNow)
+			}
+			data, err := json.Marshal(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkSchema(ReportSchema, data); err != nil {
+				t.Errorf("report doesn't follow the schema: %v", err)
+			}
+			want := ResourceResult{
+				Namespace:           "default",
+				Kind:                "deployment",
+				Name:                "web",
+				Action:              ActionRestarted,
+				SetAnnotations:      map[string]string{restartedAtAnnotation: restartedAt},
+				PreviousAnnotations: map[string]*string{restartedAtAnnotation: tt.previous},
+			}
+			if len(report.Results) != 1 || !reflect.DeepEqual(report.Results[0], want) {
+				t.Errorf("got results %+v, want %+v", report.Results, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotations configuring a pre-restart backup of a workload, typically a database StatefulSet. The restart
+// is blocked until the backup succeeds, a failed backup fails the workload's restart.
+const (
+	// backupCronJobAnnotation names a CronJob in the workload's namespace, a Job is created from its job
+	// template and has to complete
+	backupCronJobAnnotation = "rollout.tim-codez.io/backup-cronjob"
+	// backupURLAnnotation is a backup API endpoint, it is sent a POST and has to answer with a 2xx status
+	backupURLAnnotation = "rollout.tim-codez.io/backup-url"
+)
+
+// DefaultBackupTimeout bounds how long a restart waits for its pre-restart backup.
+const DefaultBackupTimeout = 30 * time.Minute
+
+// backupPollInterval is how often a backup Job's status is checked.
+const backupPollInterval = 5 * time.Second
+
+// WithBackupTimeout bounds how long a restart waits for the workload's pre-restart backup before failing.
+func WithBackupTimeout(timeout time.Duration) Option {
+	return func(rc *rolloutClient) {
+		rc.backupTimeout = timeout
+	}
+}
+
+// backup runs the pre-restart backups configured by w's annotations and snapshots its volumes when
+// configured, returning once all have succeeded. The names of the volume snapshots taken are returned, even
+// when a later step fails.
+func (rc *rolloutClient) backup(ctx context.Context, w workload) ([]string, error) {
+	annotations := w.object.GetAnnotations()
+	cronJob, url := annotations[backupCronJobAnnotation], annotations[backupURLAnnotation]
+	snapshot := rc.snapshots != nil && w.Kind == "statefulset"
+	if cronJob == "" && url == "" && !snapshot {
+		return nil, nil
+	}
+	if rc.dryRun != DryRunNone {
+		rc.logger(ctx).Infof("Dry run, skipping pre-restart backup of %s", w.Kind)
+		return nil, nil
+	}
+
+	ctx, cancel := rc.withTimeout(ctx, rc.backupTimeout)
+	defer cancel()
+
+	if cronJob != "" {
+		if err := rc.backupWithJob(ctx, w, cronJob); err != nil {
+			return nil, fmt.Errorf("backup job from cronjob %s: %w", cronJob, err)
+		}
+	}
+	if url != "" {
+		if err := rc.backupWithURL(ctx, w, url); err != nil {
+			return nil, fmt.Errorf("backup request to %s: %w", url, err)
+		}
+	}
+	if snapshot {
+		snapshots, err := rc.snapshotVolumes(ctx, w)
+		if err != nil {
+			return snapshots, fmt.Errorf("volume snapshots: %w", err)
+		}
+		return snapshots, nil
+	}
+	return nil, nil
+}
+
+// backupWithJob creates a Job from the named CronJob's job template and waits for it to complete.
+func (rc *rolloutClient) backupWithJob(ctx context.Context, w workload, cronJobName string) error {
+	log := rc.logger(ctx)
+	jobs := rc.cs.BatchV1().Jobs(w.Namespace)
+
+	getCtx, cancel := rc.requestContext(ctx)
+	cronJob, err := rc.cs.BatchV1().CronJobs(w.Namespace).Get(getCtx, cronJobName, metav1.GetOptions{})
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: cronJob.Name + "-prerestart-",
+			Namespace:    w.Namespace,
+			Labels:       cronJob.Spec.JobTemplate.Labels,
+			Annotations:  map[string]string{"cronjob.kubernetes.io/instantiate": "manual"},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+
+	createCtx, cancel := rc.requestContext(ctx)
+	job, err = jobs.Create(createCtx, job, metav1.CreateOptions{})
+	cancel()
+	if err != nil {
+		return err
+	}
+	log.WithField("job", job.Name).Infof("Waiting for %s backup job", w.Kind)
+
+	return rc.poll(ctx, backupPollInterval, false, func(ctx context.Context) (bool, error) {
+		getCtx, cancel := rc.requestContext(ctx)
+		defer cancel()
+		current, err := jobs.Get(getCtx, job.Name, metav1.GetOptions{})
+		if err != nil {
+			// Keep polling through transient errors, the backup timeout bounds the wait
+			log.WithError(err).WithField("job", job.Name).Warn("Failed to get backup job status")
+			return false, nil
+		}
+
+		for _, c := range current.Status.Conditions {
+			if c.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch c.Type {
+			case batchv1.JobComplete:
+				log.WithField("job", job.Name).Info("Backup job completed")
+				return true, nil
+			case batchv1.JobFailed:
+				return false, fmt.Errorf("job %s failed: %s", job.Name, c.Message)
+			}
+		}
+		return false, nil
+	})
+}
+
+// backupWithURL asks a backup API to back up the workload, the request carries its cluster, kind,
+// namespace and name as JSON.
+func (rc *rolloutClient) backupWithURL(ctx context.Context, w workload, url string) error {
+	body, err := json.Marshal(map[string]string{
+		"cluster":   rc.clusterName,
+		"kind":      w.Kind,
+		"namespace": w.Namespace,
+		"name":      w.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rc.logger(ctx).WithField("url", url).Infof("Requesting %s backup", w.Kind)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("backup API returned %s", resp.Status)
+	}
+	return nil
+}
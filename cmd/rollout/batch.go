@@ -0,0 +1,110 @@
+package rollout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// batchSlowdown is how much longer than the previous one a batch may take before the cluster is taken to
+// be struggling with its size, e.g. because the scheduler or image pulls are saturated.
+const batchSlowdown = 1.5
+
+// adaptiveBatches sizes the batches workloads are restarted in by how the previous batches went.
+type adaptiveBatches struct {
+	max  int
+	size int
+	// growing doubles the size after every healthy batch until the first one that isn't, then it grows by one
+	growing  bool
+	previous time.Duration
+}
+
+// WithAdaptiveBatches restarts workloads in batches, together within a batch, when waiting for rollouts
+// (see WithWaitForRollout), instead of one at a time. The first batch is a single workload, and each batch
+// that rolls out without failures and not much slower than the previous one doubles the size of the next,
+// up to maxSize. A batch with failures halves it, one that slowed down shrinks it by one, from then on a
+// healthy batch only grows it by one, so the size converges on the most the cluster handles safely. The
+// order of WithOrder holds between batches. Without waiting for rollouts, or a maxSize <= 1, workloads are
+// restarted one at a time.
+func WithAdaptiveBatches(maxSize int) Option {
+	return func(rc *rolloutClient) {
+		rc.batches = nil
+		if maxSize > 1 {
+			rc.batches = &adaptiveBatches{max: maxSize, size: 1, growing: true}
+		}
+	}
+}
+
+// observe adjusts the size of the next batch to how the last one went: how long it took and how many of
+// its workloads failed.
+func (b *adaptiveBatches) observe(took time.Duration, failed int) {
+	slowedDown := b.previous > 0 && float64(took) > float64(b.previous)*batchSlowdown
+	b.previous = took
+	switch {
+	case failed > 0:
+		b.size = max(b.size/2, 1)
+		b.growing = false
+	case slowedDown:
+		b.size = max(b.size-1, 1)
+		b.growing = false
+	case b.growing:
+		b.size = min(b.size*2, b.max)
+	default:
+		b.size = min(b.size+1, b.max)
+	}
+}
+
+// restartInBatches restarts candidates in adaptively sized batches, see WithAdaptiveBatches, and returns the
+// ones that were restarted.
+func (rc *rolloutClient) restartInBatches(ctx context.Context, candidates []workload) []workload {
+	var restarted []workload
+	for len(candidates) > 0 {
+		// Stop scheduling new restarts once the run has been cancelled
+		if ctx.Err() != nil {
+			break
+		}
+
+		batch := candidates[:min(rc.batches.size, len(candidates))]
+		candidates = candidates[len(batch):]
+		rc.logger(ctx).WithField("size", len(batch)).Info("Restarting batch")
+
+		firstResult := len(rc.metadata.Results)
+		start := rc.clock.Now()
+		ok := make([]bool, len(batch))
+		var wg sync.WaitGroup
+		for i, w := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok[i] = rc.restart(ctx, w)
+			}()
+		}
+		wg.Wait()
+		took := rc.clock.Since(start)
+
+		rc.mu.Lock()
+		for i, w := range batch {
+			if ok[i] {
+				rc.metadata.addRestarted(w.Kind, 1)
+				restarted = append(restarted, w)
+			}
+		}
+		rc.mu.Unlock()
+		// Skipped workloads aren't restarted either, but say nothing about how the cluster copes
+		failed := 0
+		for _, r := range rc.metadata.Results[firstResult:] {
+			if r.Action == ActionFailed {
+				failed++
+			}
+		}
+
+		rc.batches.observe(took, failed)
+		rc.logger(ctx).WithFields(Fields{
+			"size":      len(batch),
+			"failed":    failed,
+			"took":      took.Round(time.Second).String(),
+			"next_size": rc.batches.size,
+		}).Info("Batch completed")
+	}
+	return restarted
+}
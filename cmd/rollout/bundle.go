@@ -0,0 +1,223 @@
+package rollout
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// supportBundleLogLines is how many of the last lines of each container's log a support bundle captures.
+const supportBundleLogLines = 500
+
+// WriteSupportBundle writes a support bundle for the failures of report, a run of this client, to w as a
+// gzipped tarball, so they can be escalated with their full context. For every workload that failed it holds
+// the workload's manifest, its pods, the events of both, and the last lines of the current and previous logs
+// of every container. The report itself is included as report.json. Collection is best effort: whatever
+// couldn't be collected is listed in collection-errors.txt instead of failing the bundle.
+func (rc *rolloutClient) WriteSupportBundle(ctx context.Context, w io.Writer, report *Report) error {
+	b := &supportBundle{
+		root: "support-bundle-" + report.RunID,
+		now:  rc.clock.Now(),
+	}
+	gz := gzip.NewWriter(w)
+	b.tw = tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := b.add("report.json", data); err != nil {
+		return err
+	}
+
+	for _, r := range report.Results {
+		if r.Action != ActionFailed {
+			continue
+		}
+		if err := rc.bundleWorkload(ctx, b, r); err != nil {
+			return err
+		}
+	}
+
+	if len(b.failures) > 0 {
+		if err := b.add("collection-errors.txt", []byte(strings.Join(b.failures, "\n")+"\n")); err != nil {
+			return err
+		}
+	}
+	if err := b.tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// supportBundle is a support bundle being written.
+type supportBundle struct {
+	tw   *tar.Writer
+	root string
+	now  time.Time
+	// failures are what couldn't be collected
+	failures []string
+}
+
+// add adds a file with data at name, relative to the bundle's root directory.
+func (b *supportBundle) add(name string, data []byte) error {
+	if err := b.tw.WriteHeader(&tar.Header{
+		Name:    path.Join(b.root, name),
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: b.now,
+	}); err != nil {
+		return err
+	}
+	_, err := b.tw.Write(data)
+	return err
+}
+
+// addYAML adds obj as YAML at name.
+func (b *supportBundle) addYAML(name string, obj any) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		b.fail(name, err)
+		return nil
+	}
+	return b.add(name, data)
+}
+
+// fail records that what was to be collected at name couldn't be.
+func (b *supportBundle) fail(name string, err error) {
+	b.failures = append(b.failures, name+": "+err.Error())
+}
+
+// bundleWorkload adds the manifest, pods, events and logs of the workload of result r. Only writing the
+// bundle fails it, anything that can't be collected is recorded as a collection failure.
+func (rc *rolloutClient) bundleWorkload(ctx context.Context, b *supportBundle, r ResourceResult) error {
+	dir := path.Join(r.Namespace, r.Kind+"-"+r.Name)
+
+	w, err := rc.refresh(ctx, workload{Kind: r.Kind, Namespace: r.Namespace, Name: r.Name})
+	if err != nil {
+		b.fail(dir, err)
+		return nil
+	}
+	if obj, ok := w.object.(runtime.Object); ok {
+		obj.GetObjectKind().SetGroupVersionKind(w.gvk)
+	}
+	if err := b.addYAML(path.Join(dir, "manifest.yaml"), w.object); err != nil {
+		return err
+	}
+
+	pods, err := rc.listPods(ctx, w)
+	if err != nil {
+		b.fail(path.Join(dir, "pods"), err)
+	}
+	involved := []string{w.Name}
+	for _, pod := range pods {
+		involved = append(involved, pod.Name)
+		pod.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Pod"))
+		if err := b.addYAML(path.Join(dir, "pods", pod.Name+".yaml"), pod); err != nil {
+			return err
+		}
+		if err := rc.bundleLogs(ctx, b, path.Join(dir, "logs", pod.Name), pod); err != nil {
+			return err
+		}
+	}
+
+	var events []corev1.Event
+	for _, name := range involved {
+		listCtx, cancel := rc.requestContext(ctx)
+		list, err := rc.cs.CoreV1().Events(w.Namespace).List(listCtx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("involvedObject.name", name).String(),
+		})
+		cancel()
+		if err != nil {
+			b.fail(path.Join(dir, "events.txt"), err)
+			break
+		}
+		for _, e := range list.Items {
+			if e.InvolvedObject.Name == name {
+				events = append(events, e)
+			}
+		}
+	}
+	return b.add(path.Join(dir, "events.txt"), formatEvents(events))
+}
+
+// bundleLogs adds the last lines of the current log of each container of pod to dir, and of the previous one
+// when the container has restarted.
+func (rc *rolloutClient) bundleLogs(ctx context.Context, b *supportBundle, dir string, pod corev1.Pod) error {
+	restarts := map[string]int32{}
+	for _, status := range slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses) {
+		restarts[status.Name] = status.RestartCount
+	}
+
+	for _, container := range slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers) {
+		for _, previous := range []bool{false, true} {
+			if previous && restarts[container.Name] == 0 {
+				continue
+			}
+			name := path.Join(dir, container.Name+".log")
+			if previous {
+				name = path.Join(dir, container.Name+".previous.log")
+			}
+
+			tail := int64(supportBundleLogLines)
+			logCtx, cancel := rc.requestContext(ctx)
+			data, err := rc.cs.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: container.Name,
+				Previous:  previous,
+				TailLines: &tail,
+			}).DoRaw(logCtx)
+			cancel()
+			if err != nil {
+				b.fail(name, err)
+				continue
+			}
+			if err := b.add(name, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatEvents renders events as a table, oldest first, like kubectl get events.
+func formatEvents(events []corev1.Event) []byte {
+	slices.SortStableFunc(events, func(a, b corev1.Event) int {
+		return eventTime(a).Compare(eventTime(b))
+	})
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAST SEEN\tTYPE\tREASON\tOBJECT\tCOUNT\tMESSAGE")
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s/%s\t%d\t%s\n", eventTime(e).UTC().Format(time.RFC3339), e.Type, e.Reason,
+			strings.ToLower(e.InvolvedObject.Kind), e.InvolvedObject.Name, e.Count, strings.TrimSpace(e.Message))
+	}
+	tw.Flush()
+	return buf.Bytes()
+}
+
+// eventTime returns when event was last seen.
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
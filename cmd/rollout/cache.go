@@ -0,0 +1,172 @@
+package rollout
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// rewatchInterval is how long a WorkloadCache waits before re-establishing a watch that failed.
+const rewatchInterval = 5 * time.Second
+
+// WorkloadCache keeps the workloads listed per kind and namespace between runs, so a long running process
+// triggering frequent small runs doesn't enumerate the whole cluster for each of them. Entries expire after
+// the TTL, and once Watch is running also as soon as a workload of their kind and namespace changes. Share
+// one cache between the clients of such a process with WithWorkloadCache, it is safe for concurrent use.
+//
+// Cached workloads are only used to select and assess what to restart, every write is a patch by name and
+// the rollout waits read the current state, so a stale entry at worst restarts a workload that was just
+// changed or misses one created within the TTL.
+type WorkloadCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+// cacheKey identifies a listing, the label selector is part of it as clients sharing a cache may select
+// different workloads.
+type cacheKey struct {
+	kind, namespace, selector string
+}
+
+type cacheEntry struct {
+	workloads []workload
+	listed    time.Time
+}
+
+// NewWorkloadCache returns an empty cache whose entries expire after ttl.
+func NewWorkloadCache(ttl time.Duration) *WorkloadCache {
+	return &WorkloadCache{ttl: ttl, entries: map[cacheKey]cacheEntry{}}
+}
+
+// WithWorkloadCache lists workloads through cache, reusing what an earlier run listed within its TTL.
+// Lookups by name, e.g. refreshing a workload during a tier gate, always go to the API server.
+func WithWorkloadCache(cache *WorkloadCache) Option {
+	return func(rc *rolloutClient) {
+		rc.cache = cache
+	}
+}
+
+// Watch invalidates entries as soon as a workload of their kind and namespace is added, changed or
+// deleted, until ctx is done. It returns once the watches are started, a watch that fails or is closed by
+// the API server is re-established, dropping the entries of its kind as events may have been missed.
+func (c *WorkloadCache) Watch(ctx context.Context, cs kubernetes.Interface, log Logger) {
+	for _, accessor := range kindAccessors(cs) {
+		go c.watchKind(ctx, accessor, log)
+	}
+}
+
+// watchKind watches every workload of the accessor's kind until ctx is done.
+func (c *WorkloadCache) watchKind(ctx context.Context, accessor kindAccessor, log Logger) {
+	for ctx.Err() == nil {
+		w, err := accessor.watch(ctx, metav1.NamespaceAll, metav1.ListOptions{})
+		if err != nil {
+			log.WithError(err).Warnf("Failed to watch %ss, cached %ss expire after %s", accessor.kind, accessor.kind, c.ttl)
+		} else {
+			// Entries listed before the watch started may already be stale
+			c.invalidateKind(accessor.kind)
+			c.consume(ctx, accessor.kind, w)
+		}
+		c.invalidateKind(accessor.kind)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(rewatchInterval):
+		}
+	}
+}
+
+// consume invalidates the entries of kind for each event of w until it ends or ctx is done.
+func (c *WorkloadCache) consume(ctx context.Context, kind string, w watch.Interface) {
+	defer w.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.ResultChan():
+			if !ok || event.Type == watch.Error {
+				return
+			}
+			if obj, err := meta.Accessor(event.Object); err == nil {
+				c.invalidate(kind, obj.GetNamespace())
+			}
+		}
+	}
+}
+
+// invalidateKind drops every entry of kind.
+func (c *WorkloadCache) invalidateKind(kind string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.kind == kind {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidate drops the entries of kind in namespace.
+func (c *WorkloadCache) invalidate(kind, namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.kind == kind && key.namespace == namespace {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// get returns the workloads cached for key if they were listed less than the TTL before now.
+func (c *WorkloadCache) get(key cacheKey, now time.Time) ([]workload, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || now.Sub(entry.listed) >= c.ttl {
+		return nil, false
+	}
+	return slices.Clone(entry.workloads), true
+}
+
+func (c *WorkloadCache) put(key cacheKey, workloads []workload, listed time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{workloads: slices.Clone(workloads), listed: listed}
+}
+
+// listWorkloads lists the workloads of the accessor's kind in namespace selected by the run's label
+// selector, through the workload cache when one is configured.
+func (rc *rolloutClient) listWorkloads(ctx context.Context, accessor kindAccessor, namespace string) ([]workload, error) {
+	key := cacheKey{kind: accessor.kind, namespace: namespace, selector: rc.labelSelector}
+	if rc.cache != nil {
+		if workloads, ok := rc.cache.get(key, rc.clock.Now()); ok {
+			return workloads, nil
+		}
+	}
+
+	listed := rc.clock.Now()
+	listCtx, cancel := rc.requestContext(ctx)
+	workloads, err := accessor.list(listCtx, namespace, rc.listOptions())
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if rc.cache != nil {
+		rc.cache.put(key, workloads, listed)
+	}
+	return workloads, nil
+}
+
+// forget drops the cached listing w came from once it has been changed, so the next run sees the change
+// even when the cache isn't watching.
+func (rc *rolloutClient) forget(w workload) {
+	if rc.cache != nil {
+		rc.cache.invalidate(w.Kind, w.Namespace)
+	}
+}
@@ -0,0 +1,77 @@
+package rollout
+
+import "context"
+
+// Resource identifies a matched workload.
+type Resource struct {
+	Cluster   string
+	Namespace string
+	Kind      string
+	Name      string
+}
+
+// Callbacks receive progress events while a run executes, so embedding programs can drive their own UI or
+// notifications in real time. Any of them may be nil. They are called synchronously from the goroutine
+// running the rollout, so they should return quickly. All but OnComplete are called while the client is
+// recording the run, they mustn't call Report, Results or Warnings, which wait for the recording.
+type Callbacks struct {
+	// OnMatch is called for every workload matching the filter, before it is restarted or skipped
+	OnMatch func(Resource)
+	// OnRestarted is called after a workload's restart was accepted by the API server
+	OnRestarted func(ResourceResult)
+	// OnFailed is called after a workload failed to restart
+	OnFailed func(ResourceResult)
+	// OnProgress is called after every handled workload with how far along the run is and when it is
+	// estimated to finish
+	OnProgress func(Progress)
+	// OnComplete is called once the run has finished, with every result and the error Run returns
+	OnComplete func(results []ResourceResult, err error)
+}
+
+// RunWithCallbacks performs the same rollout as Run, reporting progress through cb as it happens rather
+// than only in the final summary.
+func (rc *rolloutClient) RunWithCallbacks(ctx context.Context, cb Callbacks) (*Report, error) {
+	rc.callbacks = cb
+	defer func() { rc.callbacks = Callbacks{} }()
+
+	stopUsage := rc.trackUsage()
+	err := rc.run(ctx)
+	usage := stopUsage()
+	if rc.metadata != nil {
+		rc.setEnded(usage)
+	}
+	if cb.OnComplete != nil {
+		cb.OnComplete(rc.Results(), err)
+	}
+
+	report := rc.Report()
+	if rc.metadata != nil {
+		rc.notify(ctx, report)
+	}
+	return report, err
+}
+
+func (rc *rolloutClient) notifyMatch(w workload) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.callbacks.OnMatch != nil {
+		rc.callbacks.OnMatch(Resource{
+			Cluster:   rc.clusterName,
+			Namespace: w.Namespace,
+			Kind:      w.Kind,
+			Name:      w.Name,
+		})
+	}
+}
+
+func (rc *rolloutClient) notifyResult(result ResourceResult) {
+	switch {
+	case result.Action == ActionRestarted && rc.callbacks.OnRestarted != nil:
+		rc.callbacks.OnRestarted(result)
+	case result.Action == ActionFailed && rc.callbacks.OnFailed != nil:
+		rc.callbacks.OnFailed(result)
+	}
+	if rc.callbacks.OnProgress != nil {
+		rc.callbacks.OnProgress(rc.progress())
+	}
+}
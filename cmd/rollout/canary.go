@@ -0,0 +1,80 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultCanaryTimeout bounds how long the canaries of a run have to roll out before the run is aborted.
+const DefaultCanaryTimeout = 10 * time.Minute
+
+// canary selects the workloads restarted ahead of the rest of their namespace, see WithCanary.
+type canary struct {
+	percent int
+	timeout time.Duration
+}
+
+// WithCanary restarts canaries first: the first matching workload of each namespace in the restart order
+// (see WithOrder), or percent of them, rounded up, when percent is above 0. Only once every canary has
+// restarted and rolled out within timeout are the remaining workloads restarted, a canary that fails to do
+// so aborts the run. With tiers every tier has its own canaries. A timeout <= 0 uses DefaultCanaryTimeout.
+func WithCanary(percent int, timeout time.Duration) Option {
+	return func(rc *rolloutClient) {
+		if timeout <= 0 {
+			timeout = DefaultCanaryTimeout
+		}
+		rc.canary = &canary{percent: min(percent, 100), timeout: timeout}
+	}
+}
+
+// split splits the sorted candidates into the canaries and the rest, both keeping their order.
+func (c *canary) split(candidates []workload) (canaries, rest []workload) {
+	total := map[string]int{}
+	for _, w := range candidates {
+		total[w.Namespace]++
+	}
+
+	picked := map[string]int{}
+	for _, w := range candidates {
+		if picked[w.Namespace] < c.count(total[w.Namespace]) {
+			picked[w.Namespace]++
+			canaries = append(canaries, w)
+		} else {
+			rest = append(rest, w)
+		}
+	}
+	return canaries, rest
+}
+
+// count returns how many of a namespace's n workloads are canaries, at least one.
+func (c *canary) count(n int) int {
+	if c.percent <= 0 {
+		return 1
+	}
+	return max((n*c.percent+99)/100, 1)
+}
+
+// gateCanaries fails when any canary failed to restart, and otherwise waits for every restarted canary to
+// finish rolling out.
+func (rc *rolloutClient) gateCanaries(ctx context.Context, results []ResourceResult, restarted []workload) error {
+	for _, r := range results {
+		if r.Action == ActionFailed {
+			return fmt.Errorf("canary %s %s/%s failed to restart", r.Kind, r.Namespace, r.Name)
+		}
+	}
+	// Nothing rolls during a dry run
+	if len(restarted) == 0 || rc.dryRun != DryRunNone {
+		return nil
+	}
+
+	rc.logger(ctx).WithFields(Fields{
+		"canaries": len(restarted),
+		"timeout":  rc.canary.timeout,
+	}).Info("Waiting for canaries to roll out before restarting the rest")
+
+	if err := rc.waitRolledOut(ctx, restarted, rc.canary.timeout); err != nil {
+		return fmt.Errorf("canaries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package rollout
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// CheckpointFS is the filesystem a checkpoint is kept on, see WithCheckpoint.
+type CheckpointFS interface {
+	ReadFile(name string) ([]byte, error)
+	// AppendFile appends data to the file name, creating it with perm if it doesn't exist
+	AppendFile(name string, data []byte, perm fs.FileMode) error
+	Remove(name string) error
+}
+
+// checkpoint records every workload a run has restarted so that a paused (cancelled) run can be
+// resumed later without cycling the same workloads a second time. Entries are appended to the file
+// as "kind/namespace/name" lines as soon as each restart succeeds, so the file is always current
+// even if the process is killed.
+type checkpoint struct {
+	fsys CheckpointFS
+	path string
+
+	// mu guards done and the file, workloads are recorded concurrently with WithConcurrency
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+// loadCheckpoint reads an existing checkpoint file from fsys, a missing file is treated as a fresh run.
+func loadCheckpoint(fsys CheckpointFS, path string) (*checkpoint, error) {
+	cp := &checkpoint{
+		fsys: fsys,
+		path: path,
+		done: make(map[string]bool),
+	}
+
+	data, err := fsys.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			cp.done[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+func checkpointKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// has reports whether the workload was already restarted by the run being resumed.
+func (cp *checkpoint) has(kind, namespace, name string) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.done[checkpointKey(kind, namespace, name)]
+}
+
+// record appends a restarted workload to the checkpoint file.
+func (cp *checkpoint) record(kind, namespace, name string) error {
+	key := checkpointKey(kind, namespace, name)
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if err := cp.fsys.AppendFile(cp.path, []byte(key+"\n"), 0o644); err != nil {
+		return err
+	}
+	cp.done[key] = true
+	return nil
+}
+
+// clear removes the checkpoint once a run has finished, so the next run starts from scratch.
+func (cp *checkpoint) clear() error {
+	err := cp.fsys.Remove(cp.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
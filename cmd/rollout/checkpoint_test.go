@@ -0,0 +1,98 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// memCheckpointFS is an in-memory CheckpointFS.
+type memCheckpointFS struct {
+	fstest.MapFS
+}
+
+func (m memCheckpointFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	if f, ok := m.MapFS[name]; ok {
+		f.Data = append(f.Data, data...)
+		return nil
+	}
+	m.MapFS[name] = &fstest.MapFile{Data: data, Mode: perm}
+	return nil
+}
+
+func (m memCheckpointFS) Remove(name string) error {
+	if _, ok := m.MapFS[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.MapFS, name)
+	return nil
+}
+
+func TestCheckpointPauseAndResume(t *testing.T) {
+	objects := []runtime.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}}
+	for i := range 3 {
+		objects = append(objects, &appsv1.Deployment{
+			ObjectMeta: testMeta(fmt.Sprintf("web-%d", i)),
+			Spec:       appsv1.DeploymentSpec{Selector: testSelector, Template: testTemplate},
+		})
+	}
+	cs := fake.NewSimpleClientset(objects...)
+	fsys := memCheckpointFS{fstest.MapFS{}}
+	newClient := func() *rolloutClient {
+		return newEmbeddedClient(cs, "web", []Option{WithClock(clocktesting.NewFakeClock(testNow)), WithCheckpoint(fsys, "checkpoint")})
+	}
+	// patched returns the deployments patched since the last call
+	patched := func() []string {
+		var names []string
+		for _, action := range cs.Actions() {
+			if patch, ok := action.(k8stesting.PatchAction); ok {
+				names = append(names, patch.GetName())
+			}
+		}
+		cs.ClearActions()
+		return names
+	}
+
+	// The run is paused once the first deployment has been restarted
+	ctx, cancel := context.WithCancel(context.Background())
+	cs.PrependReactor("patch", "deployments", func(k8stesting.Action) (bool, runtime.Object, error) {
+		cancel()
+		return false, nil, nil
+	})
+	if _, err := newClient().Run(ctx); err == nil {
+		t.Fatal("got the paused run completed, want it cancelled")
+	}
+	first := patched()
+	if len(first) != 1 {
+		t.Fatalf("got %v restarted before the pause, want one deployment", first)
+	}
+	if got, want := string(fsys.MapFS["checkpoint"].Data), "deployment/default/"+first[0]+"\n"; got != want {
+		t.Errorf("got checkpoint %q, want %q", got, want)
+	}
+
+	report, err := newClient().Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := patched()
+	if len(resumed) != 2 || slices.Contains(resumed, first[0]) {
+		t.Errorf("got %v restarted when resuming, want the two not restarted before the pause", resumed)
+	}
+	if report.Restarted != 2 || report.Skipped != 1 {
+		t.Errorf("got %d restarted and %d skipped, want 2 and the one restarted before the pause", report.Restarted, report.Skipped)
+	}
+	if _, ok := fsys.MapFS["checkpoint"]; ok {
+		t.Error("got the checkpoint kept, want it removed once the run completed")
+	}
+}
@@ -0,0 +1,67 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/clock"
+)
+
+// WithClock replaces the wall clock the client reads time from: run start times and durations, the
+// restartedAt stamp, pod ages, and the timeouts and poll intervals of the backup, tier gate, rollout and pod
+// diff waits. It makes that logic deterministic under test (see k8s.io/utils/clock/testing.FakeClock) and
+// lets simulations run faster than real time. Per request API timeouts stay on the wall clock, they guard
+// the network rather than the run.
+func WithClock(c clock.WithTicker) Option {
+	return func(rc *rolloutClient) {
+		rc.clock = c
+	}
+}
+
+// withTimeout is context.WithTimeout measured on the client's clock.
+func (rc *rolloutClient) withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := rc.clock.(clock.RealClock); ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := rc.clock.NewTimer(timeout)
+	go func() {
+		select {
+		case <-timer.C():
+			cancel(context.DeadlineExceeded)
+		case <-ctx.Done():
+		}
+		timer.Stop()
+	}()
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+// poll is wait.PollUntilContextCancel ticking on the client's clock: condition runs every interval, and
+// right away when immediate is set, until it is done, fails or ctx ends.
+func (rc *rolloutClient) poll(ctx context.Context, interval time.Duration, immediate bool, condition wait.ConditionWithContextFunc) error {
+	if immediate {
+		if done, err := condition(ctx); err != nil || done {
+			return err
+		}
+	}
+
+	ticker := rc.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// A withTimeout deadline on a fake clock shows up as a cancellation with a DeadlineExceeded cause
+			if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+				return context.DeadlineExceeded
+			}
+			return ctx.Err()
+		case <-ticker.C():
+			if done, err := condition(ctx); err != nil || done {
+				return err
+			}
+		}
+	}
+}
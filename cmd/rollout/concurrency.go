@@ -0,0 +1,70 @@
+package rollout
+
+import (
+	"context"
+	"sync"
+)
+
+// WithConcurrency processes up to n namespaces at once instead of one after another, which speeds up runs
+// on clusters with hundreds of namespaces. Within a namespace workloads are still restarted one at a time
+// in the configured order (see WithOrder), but that order no longer holds across namespaces. Callbacks are
+// never called concurrently. An n <= 1 processes namespaces serially.
+func WithConcurrency(n int) Option {
+	return func(rc *rolloutClient) {
+		rc.concurrency = n
+	}
+}
+
+// parallel calls fn with every index below n on up to the configured concurrency of goroutines, and
+// returns once all calls have. No new calls are started once ctx is done.
+func (rc *rolloutClient) parallel(ctx context.Context, n int, fn func(i int)) {
+	workers := make(chan struct{}, max(rc.concurrency, 1))
+	var wg sync.WaitGroup
+	for i := range n {
+		workers <- struct{}{}
+		if ctx.Err() != nil {
+			<-workers
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// restartConcurrently restarts the sorted candidates namespace by namespace, several namespaces at once,
+// and returns the ones that were restarted.
+func (rc *rolloutClient) restartConcurrently(ctx context.Context, candidates []workload) []workload {
+	var namespaces []string
+	byNamespace := map[string][]workload{}
+	for _, w := range candidates {
+		if _, ok := byNamespace[w.Namespace]; !ok {
+			namespaces = append(namespaces, w.Namespace)
+		}
+		byNamespace[w.Namespace] = append(byNamespace[w.Namespace], w)
+	}
+
+	var restarted []workload
+	rc.parallel(ctx, len(namespaces), func(i int) {
+		for _, w := range byNamespace[namespaces[i]] {
+			// Stop scheduling new restarts once the run has been cancelled
+			if ctx.Err() != nil {
+				return
+			}
+			if rc.restart(ctx, w) {
+				rc.mu.Lock()
+				rc.metadata.addRestarted(w.Kind, 1)
+				restarted = append(restarted, w)
+				rc.mu.Unlock()
+			}
+		}
+	})
+	return restarted
+}
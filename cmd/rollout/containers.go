@@ -0,0 +1,103 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// DefaultContainerRestartCommand signals PID 1 of a container to terminate, the kubelet then restarts the
+// container in place according to the pod's restart policy.
+var DefaultContainerRestartCommand = []string{"/bin/sh", "-c", "kill 1"}
+
+// containerRestarter restarts named containers in place by exec'ing a signal command into them.
+type containerRestarter struct {
+	config     *rest.Config
+	containers []string
+	command    []string
+}
+
+// WithContainerRestart restarts only the named containers of the matched workloads' pods in place, instead of
+// deleting pods, e.g. to cycle a log shipping sidecar without disturbing the main container. Kubernetes has no
+// API to restart a container, so command (DefaultContainerRestartCommand when empty) is exec'd into each
+// container and is expected to make it exit, it should return before the container stops. Images without a
+// shell need a command of their own. Pods lacking a container are left untouched for that container.
+//
+// It can be combined with WithPods or WithCordonedNodesOnly to restart the containers of particular pods only.
+func WithContainerRestart(config *rest.Config, containers []string, command ...string) Option {
+	if len(command) == 0 {
+		command = DefaultContainerRestartCommand
+	}
+	return func(rc *rolloutClient) {
+		rc.containerRestart = &containerRestarter{
+			config:     config,
+			containers: containers,
+			command:    command,
+		}
+	}
+}
+
+// restartContainers execs the restart command into each configured container of the pods and returns how many
+// pods had a container restarted. It stops at the first failure.
+func (rc *rolloutClient) restartContainers(ctx context.Context, pods []corev1.Pod) (int, error) {
+	restarted := 0
+	for _, pod := range pods {
+		var containers []string
+		for _, c := range pod.Spec.Containers {
+			if slices.Contains(rc.containerRestart.containers, c.Name) {
+				containers = append(containers, c.Name)
+			}
+		}
+		if len(containers) == 0 {
+			continue
+		}
+
+		for _, container := range containers {
+			if err := rc.execInContainer(ctx, pod, container, rc.containerRestart.command); err != nil {
+				return restarted, fmt.Errorf("failed to restart container %s of pod %s: %w", container, pod.Name, err)
+			}
+			rc.logger(ctx).WithFields(Fields{"pod": pod.Name, "container": container}).Info("Restarted container")
+		}
+		restarted++
+	}
+	return restarted, nil
+}
+
+// execInContainer runs command in the container, returning its stderr in the error when it fails.
+func (rc *rolloutClient) execInContainer(ctx context.Context, pod corev1.Pod, container string, command []string) error {
+	req := rc.cs.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(rc.containerRestart.config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	execCtx, cancel := rc.requestContext(ctx)
+	defer cancel()
+	var stderr bytes.Buffer
+	if err := executor.StreamWithContext(execCtx, remotecommand.StreamOptions{Stdout: io.Discard, Stderr: &stderr}); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package rollout
+
+import "time"
+
+// CostModel holds the pricing hints used to estimate the compute churn of a run. Every cycled pod is
+// assumed to overlap with its replacement for SurgeWindow, the time a new pod takes to become ready.
+type CostModel struct {
+	CPUCoreHourPrice   float64
+	MemoryGiBHourPrice float64
+	SurgeWindow        time.Duration
+}
+
+// CostEstimate is the estimated compute churn of a run's restarts.
+type CostEstimate struct {
+	PodsCycled     int
+	PodHours       float64
+	CPUCoreHours   float64
+	MemoryGiBHours float64
+	EstimatedCost  float64
+}
+
+// EstimateCost sums the requested resources of every pod cycled by the restarted workloads in results and
+// prices the surge overlap using the model. It is an estimate from pod requests only, extra nodes brought up
+// by a cluster autoscaler during the rollout are not observed.
+func EstimateCost(results []ResourceResult, model CostModel) CostEstimate {
+	var estimate CostEstimate
+	hours := model.SurgeWindow.Hours()
+
+	for _, r := range results {
+		if r.Action != ActionRestarted && r.Action != ActionDryRun {
+			continue
+		}
+		pods := float64(r.Replicas)
+		estimate.PodsCycled += int(r.Replicas)
+		estimate.PodHours += pods * hours
+		estimate.CPUCoreHours += pods * r.CPURequestCores * hours
+		estimate.MemoryGiBHours += pods * r.MemoryRequestGiB * hours
+	}
+
+	estimate.EstimatedCost = estimate.CPUCoreHours*model.CPUCoreHourPrice + estimate.MemoryGiBHours*model.MemoryGiBHourPrice
+	return estimate
+}
+
+// podFootprint returns how many pods a workload runs and the CPU cores and memory GiB each pod requests.
+func podFootprint(w workload) (replicas int32, cpuCores, memoryGiB float64) {
+	for _, c := range w.Template.Spec.Containers {
+		cpuCores += c.Resources.Requests.Cpu().AsApproximateFloat64()
+		memoryGiB += c.Resources.Requests.Memory().AsApproximateFloat64() / (1 << 30)
+	}
+	return w.Replicas, cpuCores, memoryGiB
+}
@@ -0,0 +1,64 @@
+package rollout
+
+import (
+	"cmp"
+	"slices"
+)
+
+// ResultDiff is how the outcome of a workload changed from one run to another. Before or After is empty when
+// the workload was not matched by that run.
+type ResultDiff struct {
+	Cluster   string
+	Kind      string
+	Namespace string
+	Name      string
+	Before    string
+	After     string
+	// Error is the error of the later run, if it failed there
+	Error string
+}
+
+// Fixed reports whether the workload failed in the earlier run and was restarted in the later one.
+func (d ResultDiff) Fixed() bool {
+	return d.Before == ActionFailed && d.After == ActionRestarted
+}
+
+// DiffResults compares the results of two runs, e.g. to confirm that workloads which failed before a fix
+// are restarted after it. Every workload whose action changed is returned, as well as the ones still
+// failing, sorted by cluster, namespace, kind and name.
+func DiffResults(before, after []ResourceResult) []ResultDiff {
+	type key struct{ cluster, kind, namespace, name string }
+	diffs := map[key]*ResultDiff{}
+	entry := func(r ResourceResult) *ResultDiff {
+		k := key{r.Cluster, r.Kind, r.Namespace, r.Name}
+		if diffs[k] == nil {
+			diffs[k] = &ResultDiff{Cluster: r.Cluster, Kind: r.Kind, Namespace: r.Namespace, Name: r.Name}
+		}
+		return diffs[k]
+	}
+
+	for _, r := range before {
+		entry(r).Before = r.Action
+	}
+	for _, r := range after {
+		d := entry(r)
+		d.After = r.Action
+		d.Error = r.Error
+	}
+
+	var changed []ResultDiff
+	for _, d := range diffs {
+		if d.Before != d.After || d.After == ActionFailed {
+			changed = append(changed, *d)
+		}
+	}
+	slices.SortFunc(changed, func(a, b ResultDiff) int {
+		return cmp.Or(
+			cmp.Compare(a.Cluster, b.Cluster),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+	return changed
+}
@@ -0,0 +1,37 @@
+package rollout
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DryRunMode selects whether a run changes anything, with the same modes as kubectl's --dry-run.
+type DryRunMode string
+
+const (
+	// DryRunNone restarts workloads for real.
+	DryRunNone DryRunMode = ""
+	// DryRunClient sends no changes to the API server, every workload the run would restart is only logged
+	// with the annotation patch it would get.
+	DryRunClient DryRunMode = "client"
+	// DryRunServer submits every update, patch and eviction with dryRun=All, so the API server validates and
+	// admits it, webhooks included, without persisting it.
+	DryRunServer DryRunMode = "server"
+)
+
+// WithDryRun makes the run a dry run. Matched workloads are still listed, scored and checked against the
+// policy, quota and risk threshold, and are recorded with ActionDryRun instead of being restarted.
+// Pre-restart backups, volume snapshots, in-place container restarts and new pod diffs have no dry run
+// equivalent and are skipped, and the checkpoint is left untouched.
+func WithDryRun(mode DryRunMode) Option {
+	return func(rc *rolloutClient) {
+		rc.dryRun = mode
+	}
+}
+
+// serverDryRun returns the dryRun value for the options of mutating API calls.
+func (rc *rolloutClient) serverDryRun() []string {
+	if rc.dryRun == DryRunServer {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
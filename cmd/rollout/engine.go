@@ -0,0 +1,256 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// candidates returns every workload of each supported kind in namespace that matches the run. A kind that
+// fails to list is recorded as a run error and logged, without stopping the other kinds.
+func (rc *rolloutClient) candidates(ctx context.Context, namespace string) []workload {
+	var workloads []workload
+	for _, accessor := range rc.accessors() {
+		all, err := rc.listWorkloads(ctx, accessor, namespace)
+		if err != nil {
+			rc.addError(fmt.Errorf("%ss in %s: %w", accessor.kind, namespace, err))
+			rc.logger(ctx).WithField("error", err).Errorf("Failed to list %ss", accessor.kind)
+			continue
+		}
+
+		for _, w := range all {
+			if rc.matches(w) {
+				workloads = append(workloads, w)
+			}
+		}
+	}
+	return workloads
+}
+
+// restart restarts a single matched workload, recording the outcome in the results, and reports whether it
+// was restarted. It is the single restart path for every kind, the accessor only supplies listing and writing
+// back the object. The pods are replaced with the configured RestartStrategy, or the one the policy chooses
+// for the workload.
+//
+// Failures are logged and recorded in the results rather than returned, so they don't stop the remaining
+// workloads.
+func (rc *rolloutClient) restart(ctx context.Context, w workload) bool {
+	ctx = rc.resourceContext(ctx, w)
+	log := rc.logger(ctx)
+	rc.notifyMatch(w)
+
+	if rc.checkpoint != nil && rc.checkpoint.has(w.Kind, w.Namespace, w.Name) {
+		log.Infof("Skipping %s already restarted before the rollout was paused", w.Kind)
+		rc.mu.Lock()
+		rc.metadata.ResumedSkipped++
+		rc.mu.Unlock()
+		rc.recordResult(w, ActionSkipped, 0, nil)
+		return false
+	}
+
+	if rc.riskThreshold > 0 && w.Risk >= rc.riskThreshold {
+		log.WithFields(riskFields(w)).Warnf("Skipping high risk %s, it needs explicit confirmation", w.Kind)
+		rc.addWarning(w, WarningHighRisk, fmt.Sprintf("skipped, risk %d (%s) is at or above the threshold of %d", w.Risk, strings.Join(w.RiskFactors, ", "), rc.riskThreshold))
+		rc.recordResult(w, ActionSkipped, 0, nil)
+		return false
+	}
+
+	if volumes := localDataVolumes(w); len(volumes) > 0 && !rc.localDataConfirmed {
+		log.WithField("volumes", strings.Join(volumes, ", ")).
+			Warnf("Skipping %s keeping data in local volumes that is lost on restart, it needs explicit confirmation", w.Kind)
+		rc.addWarning(w, WarningLocalData, "skipped, data in local volumes "+strings.Join(volumes, ", ")+" would be lost")
+		rc.recordResult(w, ActionSkipped, 0, nil)
+		return false
+	}
+
+	decision, err := rc.decide(ctx, w)
+	if err != nil {
+		log.WithError(err).Errorf("Policy failed to decide on %s", w.Kind)
+		rc.recordResult(w, ActionFailed, 0, err)
+		return false
+	}
+	if !decision.Allow {
+		log.WithField("reason", decision.Reason).Infof("Policy denied restarting %s, skipping", w.Kind)
+		rc.addWarning(w, WarningPolicyDenied, "skipped by policy: "+decision.Reason)
+		rc.recordResult(w, ActionSkipped, 0, nil)
+		return false
+	}
+
+	if err := rc.checkQuotaHeadroom(ctx, w); err != nil {
+		log.WithError(err).Warnf("Skipping %s, its rollout would be blocked by quota", w.Kind)
+		rc.addWarning(w, WarningQuota, "skipped, "+err.Error())
+		rc.recordResult(w, ActionSkipped, 0, err)
+		return false
+	}
+
+	strategy := rc.restartStrategy()
+	if decision.Strategy != "" {
+		// decide has validated the strategy, an unknown one fails the decision
+		strategy, _ = ParseRestartStrategy(decision.Strategy)
+	}
+
+	// Evictions honour PodDisruptionBudgets by themselves, other ways of taking pods down don't
+	if unavailable := strategy.unavailable(w); unavailable > 0 {
+		if err := rc.checkDisruptionBudgets(ctx, w, unavailable); err != nil {
+			if rc.respectPDB {
+				log.WithError(err).Warnf("Skipping %s, its rollout would violate a PodDisruptionBudget", w.Kind)
+				rc.addWarning(w, WarningPDB, "skipped, "+err.Error())
+				rc.recordResult(w, ActionSkipped, 0, err)
+				return false
+			}
+			log.WithError(err).Warnf("Rollout of %s would violate a PodDisruptionBudget, it may cause downtime", w.Kind)
+			rc.addWarning(w, WarningPDB, err.Error()+", the rollout may cause downtime")
+		}
+	}
+	if paused(w) {
+		log.Warnf("%s is paused, the restart only takes effect once it is resumed", w.Kind)
+		rc.addWarning(w, WarningPaused, "paused, the restart only takes effect once it is resumed")
+	}
+
+	w.Snapshots, err = rc.backup(ctx, w)
+	if err != nil {
+		log.WithError(err).Errorf("Pre-restart backup of %s failed, not restarting it", w.Kind)
+		rc.recordResult(w, ActionFailed, 0, err)
+		return false
+	}
+
+	if err := rc.pace(ctx, w); err != nil {
+		log.Warnf("Run cancelled while waiting to restart %s", w.Kind)
+		return false
+	}
+
+	start := rc.clock.Now()
+	replaced, err := strategy.restart(ctx, rc, &w)
+	if err == nil && !replaced {
+		log.Infof("No pods to replace in %s, skipping", w.Kind)
+		rc.recordResult(w, ActionSkipped, 0, nil)
+		return false
+	}
+	if err != nil {
+		log.WithField("error", err).Errorf("Failed to restart %s", w.Kind)
+		rc.recordResult(w, ActionFailed, rc.clock.Since(start), err)
+		return false
+	}
+
+	if rc.dryRun != DryRunNone {
+		rc.recordResult(w, ActionDryRun, rc.clock.Since(start), nil)
+		return true
+	}
+
+	if rc.rolloutTimeout > 0 {
+		if err := rc.waitForRollout(ctx, w); err != nil {
+			// A cancelled run only stops waiting, the restart itself went through
+			if ctx.Err() == nil {
+				log.WithError(err).Errorf("%s did not finish rolling out", w.Kind)
+				rc.recordResult(w, ActionFailed, rc.clock.Since(start), err)
+				return false
+			}
+			log.Warnf("Stopped waiting for %s to roll out, the run was cancelled", w.Kind)
+		}
+	}
+
+	if rc.checkpoint != nil {
+		if err := rc.checkpoint.record(w.Kind, w.Namespace, w.Name); err != nil {
+			log.WithError(err).Warnf("Failed to record %s in checkpoint", w.Kind)
+		}
+	}
+
+	rc.recordResult(w, ActionRestarted, rc.clock.Since(start), nil)
+	return true
+}
+
+// restartWorkload rolls every pod of w by patching the restartedAt annotation onto its pod template, the
+// same way kubectl rollout restart does. The annotations set and their previous values are recorded in w
+// for Undo.
+func (rc *rolloutClient) restartWorkload(ctx context.Context, w *workload) error {
+	restartedAt := rc.restartedAt()
+	annotations := map[string]string{}
+	var labels map[string]string
+	if !rc.kubectlParity {
+		maps.Copy(annotations, rc.stampAnnotations)
+		labels = rc.stampLabels
+	}
+	annotations[restartedAtAnnotation] = restartedAt
+	if w.reloadReason != "" && !rc.kubectlParity {
+		annotations[ReloadReasonAnnotation] = w.reloadReason
+	}
+	if rc.signer != nil && !rc.kubectlParity {
+		rc.signer.annotate(annotations, w.Kind, w.Namespace, w.Name, restartedAt)
+	}
+	w.SetAnnotations, w.PreviousAnnotations = annotations, previousValues(w.Template.Annotations, annotations)
+	if len(labels) > 0 {
+		w.SetLabels, w.PreviousLabels = labels, previousValues(w.Template.Labels, labels)
+	}
+
+	pt, patch, err := rc.restartPatch(*w, annotations, labels)
+	if err != nil {
+		return err
+	}
+
+	if rc.dryRun == DryRunClient {
+		rc.logger(ctx).WithField("patch", string(patch)).Infof("Dry run, would restart %s", w.Kind)
+		return nil
+	}
+	rc.logger(ctx).Infof("Restarting %s", w.Kind)
+
+	// A patch that has already been scheduled is allowed to finish even if the run is cancelled
+	return rc.patchWorkload(context.WithoutCancel(ctx), *w, pt, patch)
+}
+
+// patchWorkload applies a patch to w, or the subresources of it, as the configured field manager. Strategic
+// merge patches are retried if they conflict with a concurrent write, a server-side apply conflict is a
+// field ownership dispute retrying won't resolve.
+func (rc *rolloutClient) patchWorkload(ctx context.Context, w workload, pt types.PatchType, patch []byte, subresources ...string) error {
+	defer rc.forget(w)
+	opts := metav1.PatchOptions{DryRun: rc.serverDryRun(), FieldManager: rc.fieldManager}
+	if pt == types.ApplyPatchType {
+		opts.Force = &rc.forceApply
+		patchCtx, cancel := rc.requestContext(ctx)
+		defer cancel()
+		return applyConflictHint(w.patch(patchCtx, pt, patch, opts, subresources...))
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		patchCtx, cancel := rc.requestContext(ctx)
+		defer cancel()
+		return w.patch(patchCtx, pt, patch, opts, subresources...)
+	})
+}
+
+// restartPods cycles the pods of w chosen by the configured podPicker, all of them when there is none, and
+// returns how many were cycled. Pods are evicted, or have their containers restarted in place when
+// configured with WithContainerRestart.
+func (rc *rolloutClient) restartPods(ctx context.Context, w workload) (int, error) {
+	pods, err := rc.listPods(ctx, w)
+	if err != nil {
+		return 0, err
+	}
+	if rc.pickPods != nil {
+		pods, err = rc.pickPods(ctx, w, pods)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(pods) == 0 {
+		return 0, nil
+	}
+
+	if rc.dryRun == DryRunClient || (rc.dryRun == DryRunServer && rc.containerRestart != nil) {
+		rc.logger(ctx).WithField("pods", podNames(pods)).Infof("Dry run, would cycle selected pods of %s", w.Kind)
+		return len(pods), nil
+	}
+
+	// Pods that have already been scheduled are allowed to finish cycling even if the run is cancelled
+	ctx = context.WithoutCancel(ctx)
+	if rc.containerRestart != nil {
+		rc.logger(ctx).WithField("pods", len(pods)).Infof("Restarting containers in pods of %s", w.Kind)
+		return rc.restartContainers(ctx, pods)
+	}
+	rc.logger(ctx).WithField("pods", len(pods)).Infof("Evicting selected pods of %s", w.Kind)
+	return rc.evictPods(ctx, pods)
+}
@@ -0,0 +1,323 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+)
+
+// testNow is the time of the fake clock restarts are tested with.
+var testNow = time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+// policyFunc is a Policy deciding with a function.
+type policyFunc func(PolicyInput) (PolicyDecision, error)
+
+func (f policyFunc) Decide(_ context.Context, input PolicyInput) (PolicyDecision, error) {
+	return f(input)
+}
+
+// newTestClient returns a client of a fake clientset holding objects, ready to restart workloads outside of a
+// run, and the deployment called name listed through it.
+func newTestClient(t *testing.T, objects []runtime.Object, name string, opts ...Option) (*rolloutClient, *fake.Clientset, workload) {
+	t.Helper()
+	cs := fake.NewSimpleClientset(objects...)
+	rc := newEmbeddedClient(cs, "", append([]Option{WithClock(clocktesting.NewFakeClock(testNow))}, opts...))
+	rc.metadata = &rolloutMetadata{RunID: "test", StartTime: testNow, Errors: []error{}}
+
+	workloads, err := kindAccessors(cs)[0].list(context.Background(), "default", metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for _, w := range workloads {
+		if w.Name == name {
+			cs.ClearActions()
+			return rc, cs, w
+		}
+	}
+	t.Fatalf("no deployment %s", name)
+	return nil, nil, workload{}
+}
+
+// patches returns the patches sent to cs.
+func patches(cs *fake.Clientset) []string {
+	var sent []string
+	for _, action := range cs.Actions() {
+		if patch, ok := action.(k8stesting.PatchAction); ok {
+			sent = append(sent, string(patch.GetPatch()))
+		}
+	}
+	return sent
+}
+
+func TestRestart(t *testing.T) {
+	deployment := func(name string, volumes ...corev1.Volume) *appsv1.Deployment {
+		template := *testTemplate.DeepCopy()
+		template.Spec.Volumes = volumes
+		return &appsv1.Deployment{
+			ObjectMeta: testMeta(name),
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](2), Selector: testSelector, Template: template},
+		}
+	}
+	restartPatch := `{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":"2024-05-06T07:08:09Z"}}}}}`
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		opts    []Option
+		// prepare adjusts the workload or clientset before the restart
+		prepare     func(w *workload, cs *fake.Clientset)
+		wantOK      bool
+		wantAction  string
+		wantPatches []string
+		wantError   bool
+		wantWarning string
+	}{
+		{
+			name:        "patches the restartedAt annotation",
+			objects:     []runtime.Object{deployment("web")},
+			wantOK:      true,
+			wantAction:  ActionRestarted,
+			wantPatches: []string{restartPatch},
+		},
+		{
+			name:       "client dry run sends nothing",
+			objects:    []runtime.Object{deployment("web")},
+			opts:       []Option{WithDryRun(DryRunClient)},
+			wantOK:     true,
+			wantAction: ActionDryRun,
+		},
+		{
+			name:        "high risk is skipped",
+			objects:     []runtime.Object{deployment("web")},
+			opts:        []Option{WithRiskThreshold(40)},
+			prepare:     func(w *workload, _ *fake.Clientset) { w.Risk = 50 },
+			wantAction:  ActionSkipped,
+			wantWarning: WarningHighRisk,
+		},
+		{
+			name:        "local data needs confirmation",
+			objects:     []runtime.Object{deployment("web", corev1.Volume{Name: "data", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/data"}}})},
+			wantAction:  ActionSkipped,
+			wantWarning: WarningLocalData,
+		},
+		{
+			name:        "confirmed local data is restarted",
+			objects:     []runtime.Object{deployment("web", corev1.Volume{Name: "data", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/data"}}})},
+			opts:        []Option{WithLocalDataConfirmed()},
+			wantOK:      true,
+			wantAction:  ActionRestarted,
+			wantPatches: []string{restartPatch},
+		},
+		{
+			name:    "policy denial skips",
+			objects: []runtime.Object{deployment("web")},
+			opts: []Option{WithPolicy(policyFunc(func(PolicyInput) (PolicyDecision, error) {
+				return PolicyDecision{Reason: "business hours"}, nil
+			}))},
+			wantAction:  ActionSkipped,
+			wantWarning: WarningPolicyDenied,
+		},
+		{
+			name:    "policy failure fails",
+			objects: []runtime.Object{deployment("web")},
+			opts: []Option{WithPolicy(policyFunc(func(PolicyInput) (PolicyDecision, error) {
+				return PolicyDecision{}, errors.New("policy unavailable")
+			}))},
+			wantAction: ActionFailed,
+			wantError:  true,
+		},
+		{
+			name:    "policy picks the scale strategy",
+			objects: []runtime.Object{deployment("web")},
+			opts: []Option{WithPolicy(policyFunc(func(PolicyInput) (PolicyDecision, error) {
+				return PolicyDecision{Allow: true, Strategy: StrategyScale}, nil
+			}))},
+			wantOK:      true,
+			wantAction:  ActionRestarted,
+			wantPatches: []string{`{"spec":{"replicas":0}}`, `{"spec":{"replicas":2}}`},
+		},
+		{
+			name:    "policy picking an unknown strategy fails",
+			objects: []runtime.Object{deployment("web")},
+			opts: []Option{WithPolicy(policyFunc(func(PolicyInput) (PolicyDecision, error) {
+				return PolicyDecision{Allow: true, Strategy: "recreate"}, nil
+			}))},
+			wantAction: ActionFailed,
+			wantError:  true,
+		},
+		{
+			name:       "evicting without pods skips",
+			objects:    []runtime.Object{deployment("web")},
+			opts:       []Option{WithRestartStrategy(EvictStrategy)},
+			wantAction: ActionSkipped,
+		},
+		{
+			name:    "failed patch fails",
+			objects: []runtime.Object{deployment("web")},
+			prepare: func(_ *workload, cs *fake.Clientset) {
+				cs.PrependReactor("patch", "deployments", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("webhook denied the request")
+				})
+			},
+			wantAction:  ActionFailed,
+			wantError:   true,
+			wantPatches: []string{restartPatch},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, cs, w := newTestClient(t, tt.objects, "web", tt.opts...)
+			if tt.prepare != nil {
+				tt.prepare(&w, cs)
+			}
+
+			if ok := rc.restart(context.Background(), w); ok != tt.wantOK {
+				t.Errorf("restart reported %t, want %t", ok, tt.wantOK)
+			}
+
+			results := rc.Results()
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			if results[0].Action != tt.wantAction {
+				t.Errorf("got action %q, want %q", results[0].Action, tt.wantAction)
+			}
+			if (results[0].Error != "") != tt.wantError {
+				t.Errorf("got error %q, want one: %t", results[0].Error, tt.wantError)
+			}
+
+			got := patches(cs)
+			if len(got) != len(tt.wantPatches) {
+				t.Fatalf("got patches %q, want %q", got, tt.wantPatches)
+			}
+			for i := range got {
+				if got[i] != tt.wantPatches[i] {
+					t.Errorf("got patch %s, want %s", got[i], tt.wantPatches[i])
+				}
+			}
+
+			warnings := rc.Warnings()
+			if tt.wantWarning == "" && len(warnings) > 0 {
+				t.Errorf("got warnings %v, want none", warnings)
+			}
+			if tt.wantWarning != "" && (len(warnings) != 1 || warnings[0].Reason != tt.wantWarning) {
+				t.Errorf("got warnings %v, want a %s one", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestScaleStrategyScalesThroughSubresource(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: testMeta("web"),
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](2), Selector: testSelector, Template: testTemplate},
+	}
+	rc, cs, w := newTestClient(t, []runtime.Object{deployment}, "web", WithRestartStrategy(ScaleStrategy))
+	if !rc.restart(context.Background(), w) {
+		t.Fatalf("restart failed: %v", rc.Results())
+	}
+
+	var scaled int
+	for _, action := range cs.Actions() {
+		if patch, ok := action.(k8stesting.PatchAction); ok {
+			if patch.GetSubresource() != "scale" {
+				t.Errorf("got patch of %s %q, want the scale subresource patched", patch.GetResource().Resource, patch.GetSubresource())
+			}
+			scaled++
+		}
+	}
+	if scaled != 2 {
+		t.Errorf("got %d patches, want a scale down and up", scaled)
+	}
+}
+
+func TestRestartRecordsAnnotationsForUndo(t *testing.T) {
+	template := *testTemplate.DeepCopy()
+	template.Annotations = map[string]string{restartedAtAnnotation: "2024-01-01T00:00:00Z"}
+	rc, cs, w := newTestClient(t, []runtime.Object{
+		&appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Selector: testSelector, Template: template}},
+	}, "web")
+
+	if !rc.restart(context.Background(), w) {
+		t.Fatal("restart failed")
+	}
+
+	result := rc.Results()[0]
+	if got := result.SetAnnotations[restartedAtAnnotation]; got != "2024-05-06T07:08:09Z" {
+		t.Errorf("got restartedAt %q set, want the clock's time", got)
+	}
+	if got := ptr.Deref(result.PreviousAnnotations[restartedAtAnnotation], ""); got != "2024-01-01T00:00:00Z" {
+		t.Errorf("got previous restartedAt %q, want the template's", got)
+	}
+	updated, err := cs.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := updated.Spec.Template.Annotations[restartedAtAnnotation]; got != "2024-05-06T07:08:09Z" {
+		t.Errorf("got restartedAt %q in the cluster, want the clock's time", got)
+	}
+}
+
+func TestReportDuringRun(t *testing.T) {
+	objects := []runtime.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}}
+	for i := range 20 {
+		objects = append(objects, &appsv1.Deployment{
+			ObjectMeta: testMeta(fmt.Sprintf("web-%d", i)),
+			Spec:       appsv1.DeploymentSpec{Selector: testSelector, Template: testTemplate},
+		})
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "one at a time"},
+		{name: "concurrently", opts: []Option{WithConcurrency(4)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newEmbeddedClient(fake.NewSimpleClientset(objects...), "web", tt.opts)
+
+			// Reports taken while the run records its results see a consistent run so far
+			stop, done := make(chan struct{}), make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					report := rc.Report()
+					if report.Restarted+report.Failed+report.Skipped != len(report.Results) {
+						t.Errorf("got a report of %d results tallying %d", len(report.Results), report.Restarted+report.Failed+report.Skipped)
+						return
+					}
+				}
+			}()
+
+			report, err := rc.Run(context.Background())
+			close(stop)
+			<-done
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Restarted != 20 {
+				t.Errorf("got %d restarted, want 20", report.Restarted)
+			}
+		})
+	}
+}
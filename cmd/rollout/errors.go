@@ -0,0 +1,110 @@
+package rollout
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrorGroup is an error shared by one or more failed resources of a run.
+type ErrorGroup struct {
+	Error string
+	// Resources are the failed resources, as "kind namespace/name"
+	Resources []string
+}
+
+// GroupErrors groups the failed results by their error, so an error hitting dozens of resources, e.g. a
+// webhook denial, reads as a single entry. Each resource's own namespace and name are masked in its error,
+// as they are often the only difference, see maskName. The largest groups come first.
+func GroupErrors(results []ResourceResult) []ErrorGroup {
+	var groups []ErrorGroup
+	index := map[string]int{}
+	for _, r := range results {
+		if r.Action != ActionFailed {
+			continue
+		}
+
+		message := maskName(r.Error, r.Namespace, r.Name)
+		i, ok := index[message]
+		if !ok {
+			i = len(groups)
+			index[message] = i
+			groups = append(groups, ErrorGroup{Error: message})
+		}
+		groups[i].Resources = append(groups[i].Resources, fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name))
+	}
+
+	slices.SortStableFunc(groups, func(a, b ErrorGroup) int {
+		return cmp.Compare(len(b.Resources), len(a.Resources))
+	})
+	return groups
+}
+
+// maskName masks the namespace and name of a resource in its error, in the forms client-go and the API
+// server write them: a namespace/name token and a quoted "name". A name elsewhere in the text is left as
+// is, so a Deployment named web doesn't turn "admission webhook denied" into "admission <name>hook denied".
+func maskName(message, namespace, name string) string {
+	// names are DNS labels or subdomains, a token ends at any other character
+	token := regexp.MustCompile(`(^|[^a-z0-9.-])` + regexp.QuoteMeta(namespace+"/"+name) + `($|[^a-z0-9.-])`)
+	message = token.ReplaceAllString(message, "${1}<namespace>/<name>${2}")
+	return strings.ReplaceAll(message, `"`+name+`"`, `"<name>"`)
+}
+
+// ErrFailures is returned, wrapped together with the errors, by a run configured WithFailOnErrors that had
+// failed resources or run errors.
+var ErrFailures = errors.New("rollout had failures")
+
+// WithFailOnErrors makes Run return an error aggregating the run errors and the errors of failed resources
+// when there are any, rather than only recording them in the report, so that e.g. a CI pipeline fails. The
+// run still handles every workload.
+func WithFailOnErrors() Option {
+	return func(rc *rolloutClient) {
+		rc.failOnErrors = true
+	}
+}
+
+// ErrTooManyFailures is the cause of a run aborted by its circuit breaker, see WithMaxErrors.
+var ErrTooManyFailures = errors.New("too many failed restarts")
+
+// WithMaxErrors aborts the run once n workloads have failed to restart, e.g. when the API server or an
+// admission webhook rejects every update, instead of going through the whole cluster only to fail
+// everywhere. The abort stops the run like a cancellation with ErrTooManyFailures as the cause, the report
+// holds the partial results and a checkpoint (see WithCheckpoint) lets the run resume once the cause is
+// fixed. An n <= 0 never aborts.
+func WithMaxErrors(n int) Option {
+	return func(rc *rolloutClient) {
+		rc.maxErrors = n
+	}
+}
+
+// failures aggregates the run errors and the grouped errors of failed resources, nil when there are none.
+func (rc *rolloutClient) failures() error {
+	errs := slices.Clone(rc.metadata.Errors)
+	failed := 0
+	for _, g := range GroupErrors(rc.metadata.Results) {
+		failed += len(g.Resources)
+		if len(g.Resources) == 1 {
+			errs = append(errs, fmt.Errorf("%s: %s", g.Resources[0], g.Error))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%d resources: %s", len(g.Resources), g.Error))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w, %d resource(s) failed and %d run error(s): %w", ErrFailures, failed, len(rc.metadata.Errors), errors.Join(errs...))
+}
+
+// logErrorGroups logs one line per distinct error of the run, the full detail stays in the results.
+func (rc *rolloutClient) logErrorGroups(log Logger) {
+	for _, g := range GroupErrors(rc.metadata.Results) {
+		if len(g.Resources) == 1 {
+			log.WithField("resource", g.Resources[0]).Error("1 resource failed: " + g.Error)
+			continue
+		}
+		log.WithField("resources", len(g.Resources)).Errorf("%d resources failed: %s", len(g.Resources), g.Error)
+	}
+}
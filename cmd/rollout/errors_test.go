@@ -0,0 +1,76 @@
+package rollout
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGroupErrors(t *testing.T) {
+	failed := func(namespace, name, err string) ResourceResult {
+		return ResourceResult{Kind: "Deployment", Namespace: namespace, Name: name, Action: ActionFailed, Error: err}
+	}
+
+	tests := []struct {
+		name    string
+		results []ResourceResult
+		want    []ErrorGroup
+	}{
+		{
+			name: "masks the quoted name",
+			results: []ResourceResult{
+				failed("default", "web", `admission webhook "policy.example.com" denied the request: deployments.apps "web" is forbidden`),
+				failed("default", "api", `admission webhook "policy.example.com" denied the request: deployments.apps "api" is forbidden`),
+			},
+			want: []ErrorGroup{{
+				Error:     `admission webhook "policy.example.com" denied the request: deployments.apps "<name>" is forbidden`,
+				Resources: []string{"Deployment default/web", "Deployment default/api"},
+			}},
+		},
+		{
+			name: "masks the namespace/name token",
+			results: []ResourceResult{
+				failed("shop", "web", "timed out waiting for shop/web to become ready"),
+				failed("shop", "cart", "timed out waiting for shop/cart to become ready"),
+			},
+			want: []ErrorGroup{{
+				Error:     "timed out waiting for <namespace>/<name> to become ready",
+				Resources: []string{"Deployment shop/web", "Deployment shop/cart"},
+			}},
+		},
+		{
+			name: "keeps names within words",
+			results: []ResourceResult{
+				failed("default", "web", "the webhook rejected the rapid update of default/web-2 via the api"),
+				failed("default", "api", "the webhook rejected the rapid update of default/web-2 via the api"),
+			},
+			want: []ErrorGroup{{
+				Error:     "the webhook rejected the rapid update of default/web-2 via the api",
+				Resources: []string{"Deployment default/web", "Deployment default/api"},
+			}},
+		},
+		{
+			name: "the largest group first",
+			results: []ResourceResult{
+				failed("default", "db", "quota exceeded"),
+				{Kind: "Deployment", Namespace: "default", Name: "cache", Action: ActionRestarted},
+				failed("default", "web", `deployments.apps "web" not found`),
+				failed("default", "api", `deployments.apps "api" not found`),
+			},
+			want: []ErrorGroup{
+				{Error: `deployments.apps "<name>" not found`, Resources: []string{"Deployment default/web", "Deployment default/api"}},
+				{Error: "quota exceeded", Resources: []string{"Deployment default/db"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GroupErrors(tt.results)
+			if !slices.EqualFunc(got, tt.want, func(a, b ErrorGroup) bool {
+				return a.Error == b.Error && slices.Equal(a.Resources, b.Resources)
+			}) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
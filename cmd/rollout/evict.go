@@ -0,0 +1,150 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podPicker chooses which of a workload's pods to evict when only particular pods are cycled rather than the
+// whole workload being rolled.
+type podPicker func(ctx context.Context, w workload, pods []corev1.Pod) ([]corev1.Pod, error)
+
+// WithPods cycles only the named pods of matched workloads, by evicting them, instead of rolling the whole
+// workload, e.g. when a single replica is wedged. For StatefulSets an entry may also be an ordinal ("3") or an
+// inclusive ordinal range ("0-2"). Workloads with none of the pods are skipped.
+func WithPods(names ...string) Option {
+	return func(rc *rolloutClient) {
+		rc.pickPods = func(_ context.Context, w workload, pods []corev1.Pod) ([]corev1.Pod, error) {
+			var picked []corev1.Pod
+			for _, pod := range pods {
+				for _, name := range names {
+					if podSelected(w, pod.Name, name) {
+						picked = append(picked, pod)
+						break
+					}
+				}
+			}
+			return picked, nil
+		}
+	}
+}
+
+// podSelected reports whether entry selects the pod, either by name or, for a StatefulSet, by ordinal.
+func podSelected(w workload, podName, entry string) bool {
+	if podName == entry {
+		return true
+	}
+	if w.Kind != "statefulset" {
+		return false
+	}
+
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(podName, w.Name+"-"))
+	if err != nil || !strings.HasPrefix(podName, w.Name+"-") {
+		return false
+	}
+
+	low, high, found := strings.Cut(entry, "-")
+	if !found {
+		high = low
+	}
+	from, err := strconv.Atoi(low)
+	if err != nil {
+		return false
+	}
+	to, err := strconv.Atoi(high)
+	if err != nil {
+		return false
+	}
+	return ordinal >= from && ordinal <= to
+}
+
+// listPods returns the pods selected by the workload's label selector.
+func (rc *rolloutClient) listPods(ctx context.Context, w workload) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(w.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+	if selector.Empty() {
+		return nil, nil
+	}
+
+	listCtx, cancel := rc.requestContext(ctx)
+	defer cancel()
+	pods, err := rc.cs.CoreV1().Pods(w.Namespace).List(listCtx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for i := range pods.Items {
+		stripManagedFields(&pods.Items[i])
+	}
+	return pods.Items, nil
+}
+
+// evictPods evicts each pod through the Eviction API, so PodDisruptionBudgets are respected, and returns how
+// many were evicted. It stops at the first refused eviction.
+func (rc *rolloutClient) evictPods(ctx context.Context, pods []corev1.Pod) (int, error) {
+	for i, pod := range pods {
+		evictCtx, cancel := rc.requestContext(ctx)
+		err := rc.cs.PolicyV1().Evictions(pod.Namespace).Evict(evictCtx, &policyv1.Eviction{
+			ObjectMeta:    metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+			DeleteOptions: &metav1.DeleteOptions{DryRun: rc.serverDryRun()},
+		})
+		cancel()
+		if err != nil {
+			return i, fmt.Errorf("failed to evict pod %s: %w", pod.Name, err)
+		}
+		rc.logger(ctx).WithField("pod", pod.Name).Info("Evicted pod")
+	}
+	return len(pods), nil
+}
+
+// podNames joins the names of pods for logging.
+func podNames(pods []corev1.Pod) string {
+	names := make([]string, len(pods))
+	for i, pod := range pods {
+		names[i] = pod.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// WithCordonedNodesOnly cycles only the pods of matched workloads that are scheduled on cordoned
+// (unschedulable) nodes, by evicting them, to clear out nodes being prepared for maintenance. Workloads with
+// no pods on cordoned nodes are skipped. It replaces any pod selection made with WithPods.
+func WithCordonedNodesOnly() Option {
+	return func(rc *rolloutClient) {
+		rc.pickPods = rc.podsOnCordonedNodes
+	}
+}
+
+// podsOnCordonedNodes picks the pods scheduled on nodes marked unschedulable, looking each node up once.
+func (rc *rolloutClient) podsOnCordonedNodes(ctx context.Context, _ workload, pods []corev1.Pod) ([]corev1.Pod, error) {
+	cordoned := make(map[string]bool)
+	var picked []corev1.Pod
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+
+		unschedulable, seen := cordoned[pod.Spec.NodeName]
+		if !seen {
+			getCtx, cancel := rc.requestContext(ctx)
+			node, err := rc.cs.CoreV1().Nodes().Get(getCtx, pod.Spec.NodeName, metav1.GetOptions{})
+			cancel()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get node %s: %w", pod.Spec.NodeName, err)
+			}
+			unschedulable = node.Spec.Unschedulable
+			cordoned[pod.Spec.NodeName] = unschedulable
+		}
+		if unschedulable {
+			picked = append(picked, pod)
+		}
+	}
+	return picked, nil
+}
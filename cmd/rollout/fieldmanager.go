@@ -0,0 +1,37 @@
+package rollout
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// DefaultFieldManager is the field manager restarts are sent as, so the restartedAt annotation shows up
+// under its own name in a workload's managedFields.
+const DefaultFieldManager = "rollout-restart"
+
+// WithFieldManager sets the field manager restart patches are sent as, DefaultFieldManager unless set.
+func WithFieldManager(name string) Option {
+	return func(rc *rolloutClient) {
+		rc.fieldManager = name
+	}
+}
+
+// WithServerSideApply sets the restart annotations with server-side apply instead of a strategic merge
+// patch, making the field manager the declared owner of them. If another manager, e.g. a GitOps controller
+// applying the workload's manifest, already owns an annotation the restart fails with the conflict instead
+// of silently taking it over, unless force is set.
+func WithServerSideApply(force bool) Option {
+	return func(rc *rolloutClient) {
+		rc.serverSideApply = true
+		rc.forceApply = force
+	}
+}
+
+// applyConflictHint explains how to resolve a server-side apply ownership conflict.
+func applyConflictHint(err error) error {
+	if apierrors.IsConflict(err) {
+		return fmt.Errorf("%w (another field manager owns the annotation, force the apply to take it over)", err)
+	}
+	return err
+}
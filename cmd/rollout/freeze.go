@@ -0,0 +1,127 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FreezeAnnotation on a namespace freezes restarts in it, e.g. while an incident is handled there. Its
+// value is the reason, shown when the namespace is skipped.
+const FreezeAnnotation = "rollout.tim-codez.io/freeze"
+
+// Keys of the cluster-wide freeze ConfigMap, see WithFreezeConfigMap. Both are optional.
+const (
+	// freezeReasonKey explains the freeze, e.g. the incident it was set for
+	freezeReasonKey = "reason"
+	// freezeUntilKey is an RFC3339 time the freeze lifts by itself at
+	freezeUntilKey = "until"
+)
+
+// freezePollInterval is how often a deferred run checks whether the freeze has been lifted.
+const freezePollInterval = 30 * time.Second
+
+// ErrFrozen is returned by a run refused because restarts are frozen cluster-wide.
+var ErrFrozen = errors.New("restarts are frozen")
+
+// WithFreezeConfigMap makes a run check for a cluster-wide maintenance freeze before restarting anything,
+// e.g. set by incident tooling: while the ConfigMap namespace/name exists, and the time in its "until" key,
+// if any, hasn't passed, the run is refused with ErrFrozen and the ConfigMap's "reason". Namespaces can be
+// frozen on their own with FreezeAnnotation, which is always honoured. Undo ignores freezes, rolling back
+// is a common incident remediation.
+func WithFreezeConfigMap(namespace, name string) Option {
+	return func(rc *rolloutClient) {
+		rc.freezeNamespace, rc.freezeName = namespace, name
+	}
+}
+
+// WithFreezeWait defers a run while restarts are frozen cluster-wide, for at most timeout, instead of
+// refusing it right away.
+func WithFreezeWait(timeout time.Duration) Option {
+	return func(rc *rolloutClient) {
+		rc.freezeWait = timeout
+	}
+}
+
+// checkFreeze returns an ErrFrozen error while restarts are frozen cluster-wide, after waiting for the
+// freeze to lift when configured to.
+func (rc *rolloutClient) checkFreeze(ctx context.Context) error {
+	if rc.freezeName == "" {
+		return nil
+	}
+
+	reason, err := rc.frozen(ctx)
+	if reason == "" || rc.freezeWait <= 0 {
+		return err
+	}
+
+	rc.logger(ctx).WithFields(Fields{
+		"reason":  reason,
+		"timeout": rc.freezeWait,
+	}).Warn("Restarts are frozen, waiting for the freeze to be lifted")
+
+	waitCtx, cancel := rc.withTimeout(ctx, rc.freezeWait)
+	defer cancel()
+	last := err
+	err = rc.poll(waitCtx, freezePollInterval, false, func(ctx context.Context) (bool, error) {
+		_, last = rc.frozen(ctx)
+		return last == nil, nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("stopped waiting for the freeze to be lifted: %w", context.Cause(ctx))
+		}
+		return fmt.Errorf("%w, still after waiting %s", last, rc.freezeWait)
+	}
+	rc.logger(ctx).Info("Freeze lifted, starting the run")
+	return nil
+}
+
+// frozen returns the reason and an ErrFrozen error naming it when the freeze ConfigMap is in effect. A
+// freeze that can't be checked because reading the ConfigMap is forbidden is logged and ignored, so clients
+// without access to it can still run.
+func (rc *rolloutClient) frozen(ctx context.Context) (string, error) {
+	getCtx, cancel := rc.requestContext(ctx)
+	defer cancel()
+	cm, err := rc.cs.CoreV1().ConfigMaps(rc.freezeNamespace).Get(getCtx, rc.freezeName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return "", nil
+	case apierrors.IsForbidden(err):
+		rc.logger(ctx).WithError(err).Warnf("Can't check the freeze ConfigMap %s/%s, assuming restarts aren't frozen", rc.freezeNamespace, rc.freezeName)
+		return "", nil
+	case err != nil:
+		return "", fmt.Errorf("failed to check the freeze ConfigMap %s/%s: %w", rc.freezeNamespace, rc.freezeName, err)
+	}
+
+	message := fmt.Sprintf("by %s/%s", rc.freezeNamespace, rc.freezeName)
+	if until := cm.Data[freezeUntilKey]; until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s in the freeze ConfigMap %s/%s: %w", freezeUntilKey, rc.freezeNamespace, rc.freezeName, err)
+		}
+		if !rc.clock.Now().Before(t) {
+			return "", nil
+		}
+		message += " until " + until
+	}
+	reason := cm.Data[freezeReasonKey]
+	if reason == "" {
+		reason = "no reason given"
+	}
+	return reason, fmt.Errorf("%w %s: %s", ErrFrozen, message, reason)
+}
+
+// namespaceFrozen returns the reason restarts in a namespace with annotations are frozen by its
+// FreezeAnnotation, and whether they are.
+func namespaceFrozen(annotations map[string]string) (string, bool) {
+	reason, ok := annotations[FreezeAnnotation]
+	if ok && reason == "" {
+		reason = "no reason given"
+	}
+	return reason, ok
+}
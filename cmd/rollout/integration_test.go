@@ -0,0 +1,232 @@
+//go:build integration
+
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// The integration tests run against the API server and etcd started by envtest, which has no controllers:
+// workloads never roll out by themselves, the tests write their status. Run them with make test-integration,
+// or point KUBEBUILDER_ASSETS at the binaries installed by setup-envtest and run go test -tags integration.
+
+// integrationClientset is the clientset of the envtest API server.
+var integrationClientset kubernetes.Interface
+
+func TestMain(m *testing.M) {
+	env := &envtest.Environment{}
+	cfg, err := env.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start envtest, is KUBEBUILDER_ASSETS set: %v\n", err)
+		os.Exit(1)
+	}
+	integrationClientset = kubernetes.NewForConfigOrDie(cfg)
+
+	code := m.Run()
+	if err := env.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to stop envtest: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+// newIntegrationDeployment creates a deployment in a namespace of its own and returns it listed as a
+// workload, with a client of the envtest API server.
+func newIntegrationDeployment(t *testing.T, opts ...Option) (*rolloutClient, workload) {
+	t.Helper()
+	ctx := context.Background()
+	cs := integrationClientset
+
+	ns, err := cs.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "rollout-"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("create namespace: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cs.CoreV1().Namespaces().Delete(context.Background(), ns.Name, metav1.DeleteOptions{})
+	})
+
+	template := *testTemplate.DeepCopy()
+	template.Spec.Containers = []corev1.Container{{Name: "web", Image: "nginx"}}
+	_, err = cs.AppsV1().Deployments(ns.Name).Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](2), Selector: testSelector, Template: template},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("create deployment: %v", err)
+	}
+
+	rc := newEmbeddedClient(cs, "", opts)
+	workloads, err := kindAccessors(cs)[0].list(ctx, ns.Name, metav1.ListOptions{})
+	if err != nil || len(workloads) != 1 {
+		t.Fatalf("list: got %d workloads, %v", len(workloads), err)
+	}
+	return rc, workloads[0]
+}
+
+// restartedAt returns the restartedAt annotation of w's pod template as stored by the API server.
+func restartedAt(t *testing.T, w workload) string {
+	t.Helper()
+	d, err := integrationClientset.AppsV1().Deployments(w.Namespace).Get(context.Background(), w.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get deployment: %v", err)
+	}
+	return d.Spec.Template.Annotations[restartedAtAnnotation]
+}
+
+func TestIntegrationRestartPatchesAnnotation(t *testing.T) {
+	rc, w := newIntegrationDeployment(t)
+
+	if err := rc.restartWorkload(context.Background(), &w); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if got := restartedAt(t, w); got == "" || got != w.SetAnnotations[restartedAtAnnotation] {
+		t.Errorf("got restartedAt %q stored, want %q", got, w.SetAnnotations[restartedAtAnnotation])
+	}
+}
+
+func TestIntegrationPatchRetriesConflicts(t *testing.T) {
+	rc, w := newIntegrationDeployment(t)
+	ctx := context.Background()
+
+	// Another client writes the deployment after it was listed, leaving the listed object stale
+	current, err := integrationClientset.AppsV1().Deployments(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current.Labels = map[string]string{"touched": "true"}
+	if _, err := integrationClientset.AppsV1().Deployments(w.Namespace).Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A patch only conflicts when it is made conditional on a resourceVersion, so the first attempt sends the
+	// restart patch with the listed, now stale, one as a precondition, which the API server rejects with a 409
+	stale := w.object.GetResourceVersion()
+	patch := w.patch
+	attempts := 0
+	w.patch = func(ctx context.Context, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) error {
+		attempts++
+		if attempts > 1 {
+			return patch(ctx, pt, data, opts, subresources...)
+		}
+		var conditional map[string]any
+		if err := json.Unmarshal(data, &conditional); err != nil {
+			t.Fatal(err)
+		}
+		conditional["metadata"] = map[string]any{"resourceVersion": stale}
+		data, err := json.Marshal(conditional)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = patch(ctx, pt, data, opts, subresources...)
+		if !apierrors.IsConflict(err) {
+			t.Fatalf("got %v patching the stale resourceVersion, want a conflict", err)
+		}
+		return err
+	}
+
+	if err := rc.restartWorkload(ctx, &w); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("got %d attempts, want the conflict retried once", attempts)
+	}
+	if got := restartedAt(t, w); got != w.SetAnnotations[restartedAtAnnotation] {
+		t.Errorf("got restartedAt %q stored, want %q", got, w.SetAnnotations[restartedAtAnnotation])
+	}
+}
+
+func TestIntegrationApplyConflictIsNotRetried(t *testing.T) {
+	rc, w := newIntegrationDeployment(t, WithServerSideApply(false))
+	ctx := context.Background()
+
+	// Another field manager owns the annotation, so applying it without force conflicts
+	other := fmt.Sprintf(`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":%q},"spec":{"template":{"metadata":{"annotations":{%q:"owned"}}}}}`, w.Name, restartedAtAnnotation)
+	_, err := integrationClientset.AppsV1().Deployments(w.Namespace).Patch(ctx, w.Name, types.ApplyPatchType, []byte(other), metav1.PatchOptions{FieldManager: "gitops"})
+	if err != nil {
+		t.Fatalf("apply as another manager: %v", err)
+	}
+
+	patch := w.patch
+	attempts := 0
+	w.patch = func(ctx context.Context, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) error {
+		attempts++
+		return patch(ctx, pt, data, opts, subresources...)
+	}
+
+	err = rc.restartWorkload(ctx, &w)
+	if err == nil || !strings.Contains(err.Error(), "gitops") {
+		t.Fatalf("got %v, want a conflict with the gitops field manager", err)
+	}
+	if attempts != 1 {
+		t.Errorf("got %d attempts, want the apply conflict not retried", attempts)
+	}
+}
+
+func TestIntegrationWaitForRollout(t *testing.T) {
+	tests := []struct {
+		name string
+		// rollOut is whether the deployment's status is written as rolled out after the restart
+		rollOut bool
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "rolls out once the status catches up", rollOut: true, timeout: time.Minute},
+		{name: "times out without a rollout", timeout: 2 * time.Second, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, w := newIntegrationDeployment(t, WithWaitForRollout(tt.timeout))
+			ctx := context.Background()
+			if err := rc.restartWorkload(ctx, &w); err != nil {
+				t.Fatalf("restart: %v", err)
+			}
+
+			// envtest runs no deployment controller, the status is written as it would once the pods rolled
+			if tt.rollOut {
+				deployments := integrationClientset.AppsV1().Deployments(w.Namespace)
+				d, err := deployments.Get(ctx, w.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if rolledOut(workload{object: d, Replicas: *d.Spec.Replicas}) {
+					t.Fatal("the deployment rolled out before its status was written")
+				}
+				d.Status = appsv1.DeploymentStatus{
+					ObservedGeneration: d.Generation,
+					Replicas:           2,
+					UpdatedReplicas:    2,
+					ReadyReplicas:      2,
+					AvailableReplicas:  2,
+				}
+				if _, err := deployments.UpdateStatus(ctx, d, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("update status: %v", err)
+				}
+			}
+
+			err := rc.waitForRollout(ctx, w)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want an error: %t", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "rollout not complete") {
+				t.Errorf("got %v, want the rollout progress", err)
+			}
+		})
+	}
+}
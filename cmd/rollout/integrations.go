@@ -0,0 +1,70 @@
+package rollout
+
+import (
+	"fmt"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+// Integration is optional software a cluster may have installed that runs can work with, e.g. to snapshot
+// volumes or restart through a database operator. Integrations are only used when configured, see
+// DetectIntegrations to find the ones a cluster has.
+type Integration string
+
+const (
+	// IntegrationVolumeSnapshots is the CSI snapshot controller, see WithVolumeSnapshots
+	IntegrationVolumeSnapshots Integration = "volume-snapshots"
+	// IntegrationArgoRollouts is the Argo Rollouts controller, see WithArgoRollouts
+	IntegrationArgoRollouts Integration = "argo-rollouts"
+	// IntegrationStrimzi and IntegrationCrunchyPGO are database operators, see WithOperatorHandlers
+	IntegrationStrimzi    Integration = "strimzi"
+	IntegrationCrunchyPGO Integration = "crunchy-pgo"
+)
+
+// IsOperator reports whether i is a database operator workloads are restarted through, see
+// WithOperatorHandlers.
+func (i Integration) IsOperator() bool {
+	return slices.ContainsFunc(operatorHandlers, func(h operatorHandler) bool { return h.name == string(i) })
+}
+
+// DetectIntegrations returns the integrations the cluster dc is the discovery client of has installed, told
+// by the APIs it serves: snapshot.storage.k8s.io/v1 for volume snapshots, argoproj.io/v1alpha1 rollouts for
+// Argo Rollouts (the group is shared with the other Argo projects), an operator's group for the operator.
+func DetectIntegrations(dc discovery.DiscoveryInterface) ([]Integration, error) {
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the API groups: %w", err)
+	}
+	// served reports whether the cluster serves group, in version unless it's empty
+	served := func(group, version string) bool {
+		for _, g := range groups.Groups {
+			if g.Name == group && (version == "" || slices.ContainsFunc(g.Versions, func(v metav1.GroupVersionForDiscovery) bool { return v.Version == version })) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var found []Integration
+	if served(volumeSnapshots.Group, volumeSnapshots.Version) {
+		found = append(found, IntegrationVolumeSnapshots)
+	}
+	if served(argoRolloutResource.Group, argoRolloutResource.Version) {
+		list, err := dc.ServerResourcesForGroupVersion(argoRolloutResource.GroupVersion().String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover %s: %w", argoRolloutResource.GroupVersion(), err)
+		}
+		if slices.ContainsFunc(list.APIResources, func(r metav1.APIResource) bool { return r.Name == argoRolloutResource.Resource }) {
+			found = append(found, IntegrationArgoRollouts)
+		}
+	}
+	// Handlers restart through whichever version of the operator's API the workload's owner uses
+	for _, h := range operatorHandlers {
+		if served(h.group, "") {
+			found = append(found, Integration(h.name))
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,179 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Workload kinds of the batch workloads, see WithCronJobs and WithJobRecreation.
+const (
+	cronJobKind = "cronjob"
+	jobKind     = "job"
+)
+
+// jobDeletePollInterval is how often a recreated Job is checked for being gone before it is created again.
+const jobDeletePollInterval = time.Second
+
+// jobDeleteTimeout is how long a recreated Job may take to be deleted.
+const jobDeleteTimeout = 2 * time.Minute
+
+// jobControllerLabels are the pod template labels the API server generates for a Job's selector, dropped
+// when recreating it so the new Job gets its own.
+var jobControllerLabels = []string{
+	"controller-uid", batchv1.ControllerUidLabel,
+	"job-name", batchv1.JobNameLabel,
+}
+
+// WithCronJobs restarts CronJobs alongside the built-in workload kinds, stamping the restartedAt annotation
+// (and any template stamp, see WithTemplateStamp) on their job template, so the next Job they start picks up
+// whatever changed, e.g. a rotated Secret. Jobs already running are left alone, see WithJobRecreation.
+func WithCronJobs() Option {
+	return func(rc *rolloutClient) {
+		rc.cronJobs = true
+	}
+}
+
+// WithJobRecreation restarts running Jobs alongside the built-in workload kinds. A Job's pod template can't
+// be changed, so it is deleted, its pods along with it, and created again from the same spec with the
+// restartedAt annotation on its template, starting over. Finished Jobs and Jobs started by a CronJob, which
+// are the CronJob's to replace (see WithCronJobs), aren't matched.
+func WithJobRecreation() Option {
+	return func(rc *rolloutClient) {
+		rc.recreateJobs = true
+	}
+}
+
+// cronJobsAccessor returns the accessor of CronJobs using cs. A CronJob's workload is its job template,
+// it has no pods of its own to select or count.
+func cronJobsAccessor(cs kubernetes.Interface) kindAccessor {
+	return kindSpec[*batchv1.CronJob, *batchv1.CronJobList]{
+		kind: cronJobKind,
+		gvk:  batchv1.SchemeGroupVersion.WithKind("CronJob"),
+		client: func(namespace string) typedClient[*batchv1.CronJob, *batchv1.CronJobList] {
+			return cs.BatchV1().CronJobs(namespace)
+		},
+		items:    func(list *batchv1.CronJobList) []*batchv1.CronJob { return pointers(list.Items) },
+		template: func(cj *batchv1.CronJob) *corev1.PodTemplateSpec { return &cj.Spec.JobTemplate.Spec.Template },
+		selector: func(*batchv1.CronJob) *metav1.LabelSelector { return nil },
+		replicas: func(*batchv1.CronJob) int32 { return 0 },
+	}.accessor()
+}
+
+// jobsAccessor returns the accessor of the running Jobs not started by a CronJob using cs.
+func jobsAccessor(cs kubernetes.Interface) kindAccessor {
+	return kindSpec[*batchv1.Job, *batchv1.JobList]{
+		kind: jobKind,
+		gvk:  batchv1.SchemeGroupVersion.WithKind("Job"),
+		client: func(namespace string) typedClient[*batchv1.Job, *batchv1.JobList] {
+			return cs.BatchV1().Jobs(namespace)
+		},
+		items: func(list *batchv1.JobList) []*batchv1.Job {
+			var running []*batchv1.Job
+			for _, job := range pointers(list.Items) {
+				owner := metav1.GetControllerOf(job)
+				if job.Status.Active > 0 && job.Status.CompletionTime == nil && (owner == nil || owner.Kind != "CronJob") {
+					running = append(running, job)
+				}
+			}
+			return running
+		},
+		template: func(job *batchv1.Job) *corev1.PodTemplateSpec { return &job.Spec.Template },
+		selector: func(job *batchv1.Job) *metav1.LabelSelector { return job.Spec.Selector },
+		replicas: func(job *batchv1.Job) int32 { return replicasOrDefault(job.Spec.Parallelism) },
+	}.accessor()
+}
+
+// recreateJob restarts the Job w by deleting it and creating it again, see WithJobRecreation.
+func (rc *rolloutClient) recreateJob(ctx context.Context, w workload) error {
+	recreated := newJobFrom(w.object.(*batchv1.Job), restartTimestamp(rc.clock.Now()))
+	jobs := rc.cs.BatchV1().Jobs(w.Namespace)
+
+	if rc.dryRun == DryRunClient {
+		rc.logger(ctx).Infof("Dry run, would delete and recreate %s", w.Kind)
+		return nil
+	}
+	rc.logger(ctx).Infof("Deleting and recreating %s", w.Kind)
+
+	// A recreation that has already been scheduled is allowed to finish even if the run is cancelled, a
+	// deleted Job that isn't created again is lost
+	ctx = context.WithoutCancel(ctx)
+	defer rc.forget(w)
+
+	background := metav1.DeletePropagationBackground
+	deleteCtx, cancel := rc.requestContext(ctx)
+	err := jobs.Delete(deleteCtx, w.Name, metav1.DeleteOptions{
+		DryRun:            rc.serverDryRun(),
+		PropagationPolicy: &background,
+		Preconditions:     metav1.NewUIDPreconditions(string(w.object.GetUID())),
+	})
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to delete the job: %w", err)
+	}
+	// The Job is still there after a server-side dry run, creating it again would conflict
+	if rc.dryRun == DryRunServer {
+		return nil
+	}
+
+	waitCtx, cancel := rc.withTimeout(ctx, jobDeleteTimeout)
+	defer cancel()
+	err = rc.poll(waitCtx, jobDeletePollInterval, true, func(ctx context.Context) (bool, error) {
+		getCtx, cancel := rc.requestContext(ctx)
+		defer cancel()
+		_, err := jobs.Get(getCtx, w.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("the job wasn't deleted within %s, it has to be created again by hand: %w", jobDeleteTimeout, err)
+	}
+
+	createCtx, cancel := rc.requestContext(ctx)
+	defer cancel()
+	if _, err := jobs.Create(createCtx, recreated, metav1.CreateOptions{FieldManager: rc.fieldManager}); err != nil {
+		return fmt.Errorf("failed to create the job again, it has to be created by hand: %w", err)
+	}
+	return nil
+}
+
+// newJobFrom returns a Job to create in place of job, with its spec and metadata but without the server
+// generated fields, the selector and pod labels the API server generates included, and restartedAt on its
+// pod template.
+func newJobFrom(job *batchv1.Job, restartedAt string) *batchv1.Job {
+	recreated := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            job.Name,
+			Namespace:       job.Namespace,
+			Labels:          maps.Clone(job.Labels),
+			Annotations:     maps.Clone(job.Annotations),
+			OwnerReferences: slices.Clone(job.OwnerReferences),
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+
+	template := &recreated.Spec.Template
+	if job.Spec.ManualSelector == nil || !*job.Spec.ManualSelector {
+		recreated.Spec.Selector = nil
+		// A Job without labels of its own is defaulted to its template's, generated ones included
+		for _, key := range jobControllerLabels {
+			delete(recreated.Labels, key)
+			delete(template.Labels, key)
+		}
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[restartedAtAnnotation] = restartedAt
+	return recreated
+}
@@ -0,0 +1,174 @@
+package rollout
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+)
+
+// generatedLabels are the labels the API server generates for the selector of the Job migrate.
+var generatedLabels = map[string]string{
+	"controller-uid":              "uid",
+	batchv1.ControllerUidLabel:    "uid",
+	"job-name":                    "migrate",
+	batchv1.JobNameLabel:          "migrate",
+	"app.kubernetes.io/component": "migration",
+}
+
+// testJob returns the running Job migrate, its selector generated by the API server unless manualSelector.
+func testJob(manualSelector bool) *batchv1.Job {
+	labels := maps.Clone(generatedLabels)
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{batchv1.ControllerUidLabel: "uid"}}
+	if manualSelector {
+		labels = map[string]string{"app": "migrate"}
+		selector = &metav1.LabelSelector{MatchLabels: labels}
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "migrate",
+			Namespace:   "default",
+			UID:         "uid",
+			Labels:      maps.Clone(labels),
+			Annotations: map[string]string{"example.com/owner": "data"},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "argoproj.io/v1alpha1", Kind: "Workflow", Name: "nightly", UID: "workflow-uid", Controller: ptr.To(true),
+			}},
+		},
+		Spec: batchv1.JobSpec{
+			ManualSelector: ptr.To(manualSelector),
+			Selector:       selector,
+			Template:       corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: maps.Clone(labels)}},
+		},
+		Status: batchv1.JobStatus{Active: 1},
+	}
+}
+
+func TestNewJobFrom(t *testing.T) {
+	tests := []struct {
+		name           string
+		manualSelector bool
+		wantSelector   bool
+		wantLabels     map[string]string
+	}{
+		{
+			name:       "drops the generated selector",
+			wantLabels: map[string]string{"app.kubernetes.io/component": "migration"},
+		},
+		{
+			name:           "keeps a manual selector",
+			manualSelector: true,
+			wantSelector:   true,
+			wantLabels:     map[string]string{"app": "migrate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := testJob(tt.manualSelector)
+			original := job.DeepCopy()
+
+			got := newJobFrom(job, "2024-05-06T07:08:09Z")
+			if got.UID != "" || got.ResourceVersion != "" {
+				t.Errorf("got UID %q and resource version %q, want the server's fields dropped", got.UID, got.ResourceVersion)
+			}
+			if (got.Spec.Selector != nil) != tt.wantSelector {
+				t.Errorf("got selector %v, want a selector: %t", got.Spec.Selector, tt.wantSelector)
+			}
+			if !maps.Equal(got.Labels, tt.wantLabels) || !maps.Equal(got.Spec.Template.Labels, tt.wantLabels) {
+				t.Errorf("got labels %v and template labels %v, want %v", got.Labels, got.Spec.Template.Labels, tt.wantLabels)
+			}
+			if !reflect.DeepEqual(got.OwnerReferences, job.OwnerReferences) {
+				t.Errorf("got owner references %v, want the job's", got.OwnerReferences)
+			}
+			if got.Spec.Template.Annotations[restartedAtAnnotation] != "2024-05-06T07:08:09Z" {
+				t.Errorf("got template annotations %v, want restartedAt", got.Spec.Template.Annotations)
+			}
+
+			// The listed job is left as it was
+			got.Annotations["example.com/owner"] = "changed"
+			got.OwnerReferences[0].Name = "changed"
+			if !maps.Equal(job.Annotations, original.Annotations) || !reflect.DeepEqual(job.OwnerReferences, original.OwnerReferences) ||
+				!maps.Equal(job.Labels, original.Labels) || !maps.Equal(job.Spec.Template.Annotations, original.Spec.Template.Annotations) {
+				t.Errorf("got the job changed to %+v, want it as listed", job.ObjectMeta)
+			}
+		})
+	}
+}
+
+func TestRecreateJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		dryRun     DryRunMode
+		wantVerbs  []string
+		wantDryRun bool
+		// wantRecreated is whether the cluster has the recreated job rather than the original
+		wantRecreated bool
+	}{
+		{name: "recreates the job", wantVerbs: []string{"delete", "get", "create"}, wantRecreated: true},
+		{name: "client dry run", dryRun: DryRunClient},
+		{name: "server dry run", dryRun: DryRunServer, wantVerbs: []string{"delete"}, wantDryRun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := fake.NewSimpleClientset(testJob(false))
+			// The fake clientset deletes regardless of the dry run, a server dry run leaves the job alone
+			cs.PrependReactor("delete", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return len(action.(k8stesting.DeleteActionImpl).DeleteOptions.DryRun) > 0, nil, nil
+			})
+			rc := newEmbeddedClient(cs, "", []Option{WithClock(clocktesting.NewFakeClock(testNow)), WithDryRun(tt.dryRun)})
+			workloads, err := jobsAccessor(cs).list(context.Background(), "default", metav1.ListOptions{})
+			if err != nil || len(workloads) != 1 {
+				t.Fatalf("got jobs %v and %v, want migrate listed", workloads, err)
+			}
+			cs.ClearActions()
+
+			if err := rc.recreateJob(context.Background(), workloads[0]); err != nil {
+				t.Fatal(err)
+			}
+
+			var verbs []string
+			for _, action := range cs.Actions() {
+				verbs = append(verbs, action.GetVerb())
+				if del, ok := action.(k8stesting.DeleteActionImpl); ok {
+					if got := len(del.DeleteOptions.DryRun) > 0; got != tt.wantDryRun {
+						t.Errorf("got a delete with dryRun %v, want a dry run: %t", del.DeleteOptions.DryRun, tt.wantDryRun)
+					}
+					if del.DeleteOptions.Preconditions == nil || ptr.Deref(del.DeleteOptions.Preconditions.UID, "") != "uid" {
+						t.Errorf("got preconditions %v, want the listed job's UID", del.DeleteOptions.Preconditions)
+					}
+				}
+			}
+			if !slices.Equal(verbs, tt.wantVerbs) {
+				t.Errorf("got verbs %v, want %v", verbs, tt.wantVerbs)
+			}
+
+			job, err := cs.BatchV1().Jobs("default").Get(context.Background(), "migrate", metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				t.Fatal("got the job deleted and not created again")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			recreated := job.Spec.Template.Annotations[restartedAtAnnotation] == "2024-05-06T07:08:09Z"
+			if recreated != tt.wantRecreated {
+				t.Errorf("got template annotations %v, want the job recreated: %t", job.Spec.Template.Annotations, tt.wantRecreated)
+			}
+			if recreated && (job.Spec.Selector != nil || len(job.OwnerReferences) != 1) {
+				t.Errorf("got selector %v and owner references %v, want a generated selector and the owner kept", job.Spec.Selector, job.OwnerReferences)
+			}
+		})
+	}
+}
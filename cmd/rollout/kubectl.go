@@ -0,0 +1,74 @@
+package rollout
+
+import (
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// KubectlFieldManager is the field manager kubectl rollout restart patches workloads as.
+const KubectlFieldManager = "kubectl-rollout"
+
+// WithKubectlParity makes restarts indistinguishable from kubectl rollout restart: the strategic merge patch
+// is byte for byte what kubectl sends, only the restartedAt annotation stamped in RFC3339 in local time, and
+// it is sent as the kubectl-rollout field manager, so server-side apply ownership of the annotation is shared with
+// restarts done by hand instead of split between two managers. Provenance annotations (see
+// WithProvenanceSigning) are left out and server-side apply (see WithServerSideApply) is not used in this
+// mode.
+func WithKubectlParity() Option {
+	return func(rc *rolloutClient) {
+		rc.kubectlParity = true
+		rc.fieldManager = KubectlFieldManager
+	}
+}
+
+// restartPatch returns the patch setting annotations and labels on w's pod template, a strategic merge patch
+// unless server-side apply is enabled. With only the restartedAt annotation the strategic merge patch is
+// exactly the one kubectl rollout restart produces.
+func (rc *rolloutClient) restartPatch(w workload, annotations, labels map[string]string) (types.PatchType, []byte, error) {
+	// Only the annotations and labels are sent, so the patch can't overwrite changes other writers made since
+	// the workload was listed
+	metadata := map[string]any{"annotations": annotations}
+	if len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	spec := templatePatch(w, metadata)
+	if rc.serverSideApply && !rc.kubectlParity {
+		patch, err := json.Marshal(map[string]any{
+			"apiVersion": w.gvk.GroupVersion().String(),
+			"kind":       w.gvk.Kind,
+			"metadata":   map[string]any{"name": w.Name, "namespace": w.Namespace},
+			"spec":       spec,
+		})
+		return types.ApplyPatchType, patch, err
+	}
+
+	patch, err := json.Marshal(map[string]any{"spec": spec})
+	return types.StrategicMergePatchType, patch, err
+}
+
+// templatePatch returns the spec of a patch setting metadata on w's pod template, which a CronJob has in its
+// job template.
+func templatePatch(w workload, metadata map[string]any) map[string]any {
+	spec := map[string]any{"template": map[string]any{"metadata": metadata}}
+	if w.Kind == cronJobKind {
+		return map[string]any{"jobTemplate": map[string]any{"spec": spec}}
+	}
+	return spec
+}
+
+// restartTimestamp formats t in RFC3339 like kubectl stamps restartedAt, but always in UTC so annotations
+// read the same whatever time zone the run was started in.
+func restartTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// restartedAt returns the restartedAt annotation of a restart done now, in local time as kubectl stamps it
+// with kubectl parity (see WithKubectlParity).
+func (rc *rolloutClient) restartedAt() string {
+	if rc.kubectlParity {
+		return rc.clock.Now().Local().Format(time.RFC3339)
+	}
+	return restartTimestamp(rc.clock.Now())
+}
@@ -0,0 +1,126 @@
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// kubectlRestartPatch returns the patch kubectl rollout restart sends for obj: it stamps restartedAt onto a
+// copy of the pod template and diffs the copy against obj.
+func kubectlRestartPatch(t *testing.T, obj runtime.Object, template func(runtime.Object) *corev1.PodTemplateSpec, now time.Time) []byte {
+	t.Helper()
+	restarted := obj.DeepCopyObject()
+	tmpl := template(restarted)
+	if tmpl.Annotations == nil {
+		tmpl.Annotations = map[string]string{}
+	}
+	tmpl.Annotations[restartedAtAnnotation] = now.Format(time.RFC3339)
+
+	original, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified, err := json.Marshal(restarted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(original, modified, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return patch
+}
+
+func TestKubectlParityPatch(t *testing.T) {
+	annotated := *testTemplate.DeepCopy()
+	annotated.Annotations = map[string]string{"team": "web", restartedAtAnnotation: "2024-01-01T00:00:00Z"}
+
+	tests := []struct {
+		name     string
+		index    int
+		object   runtime.Object
+		template func(runtime.Object) *corev1.PodTemplateSpec
+	}{
+		{
+			name:     "deployment",
+			object:   &appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Selector: testSelector, Template: testTemplate}},
+			template: func(o runtime.Object) *corev1.PodTemplateSpec { return &o.(*appsv1.Deployment).Spec.Template },
+		},
+		{
+			name:     "deployment restarted before",
+			object:   &appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Selector: testSelector, Template: annotated}},
+			template: func(o runtime.Object) *corev1.PodTemplateSpec { return &o.(*appsv1.Deployment).Spec.Template },
+		},
+		{
+			name:     "statefulset",
+			index:    1,
+			object:   &appsv1.StatefulSet{ObjectMeta: testMeta("web"), Spec: appsv1.StatefulSetSpec{Selector: testSelector, Template: annotated}},
+			template: func(o runtime.Object) *corev1.PodTemplateSpec { return &o.(*appsv1.StatefulSet).Spec.Template },
+		},
+		{
+			name:     "daemonset",
+			index:    2,
+			object:   &appsv1.DaemonSet{ObjectMeta: testMeta("web"), Spec: appsv1.DaemonSetSpec{Selector: testSelector, Template: testTemplate}},
+			template: func(o runtime.Object) *corev1.PodTemplateSpec { return &o.(*appsv1.DaemonSet).Spec.Template },
+		},
+	}
+
+	// kubectl stamps the time in the local zone, whatever the zone the clock reads in, so the local zone is
+	// set to one that is neither UTC nor the clock's
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	t.Cleanup(func() { time.Local = local })
+	now := testNow.In(time.FixedZone("UTC+2", 2*60*60))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := fake.NewSimpleClientset(tt.object)
+			rc := newEmbeddedClient(cs, "", []Option{WithClock(clocktesting.NewFakeClock(now)), WithKubectlParity()})
+			workloads, err := kindAccessors(cs)[tt.index].list(context.Background(), "default", metav1.ListOptions{})
+			if err != nil || len(workloads) != 1 {
+				t.Fatalf("list: got %d workloads, %v", len(workloads), err)
+			}
+
+			cs.ClearActions()
+			w := workloads[0]
+			if err := rc.restartWorkload(context.Background(), &w); err != nil {
+				t.Fatalf("restart: %v", err)
+			}
+			actions := cs.Actions()
+			if len(actions) != 1 {
+				t.Fatalf("got actions %v, want a single patch", actions)
+			}
+			patch := actions[0].(k8stesting.PatchAction)
+
+			want := kubectlRestartPatch(t, tt.object, tt.template, now.Local())
+			if !strings.Contains(string(want), `"2024-05-06T02:08:09-05:00"`) {
+				t.Fatalf("kubectl's patch %s isn't stamped in the local zone", want)
+			}
+			if string(patch.GetPatch()) != string(want) {
+				t.Errorf("got patch\n%s\nwant kubectl's\n%s", patch.GetPatch(), want)
+			}
+			if patch.GetPatchType() != types.StrategicMergePatchType {
+				t.Errorf("got a %s patch, want a strategic merge patch", patch.GetPatchType())
+			}
+		})
+	}
+}
+
+func TestRestartTimestampIsUTC(t *testing.T) {
+	rc := newEmbeddedClient(fake.NewSimpleClientset(), "", []Option{WithClock(clocktesting.NewFakeClock(testNow.In(time.FixedZone("UTC+2", 2*60*60))))})
+	if got := rc.restartedAt(); got != "2024-05-06T07:08:09Z" {
+		t.Errorf("got restartedAt %q, want it in UTC", got)
+	}
+}
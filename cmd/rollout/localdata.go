@@ -0,0 +1,37 @@
+package rollout
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// largeEmptyDir is the emptyDir size limit from which a volume is considered to hold data worth keeping,
+// smaller emptyDirs are assumed to be scratch space.
+var largeEmptyDir = resource.MustParse("1Gi")
+
+// WithLocalDataConfirmed restarts workloads whose pods keep data in local volumes, large emptyDirs or
+// hostPaths, which are skipped otherwise since the data is lost (or left behind on the node) when a pod is
+// replaced, e.g. a cache that takes hours to warm up.
+func WithLocalDataConfirmed() Option {
+	return func(rc *rolloutClient) {
+		rc.localDataConfirmed = true
+	}
+}
+
+// localDataVolumes describes the volumes of w's pods that hold data lost on restart: hostPaths, and emptyDirs
+// kept in memory or with a size limit of at least largeEmptyDir.
+func localDataVolumes(w workload) []string {
+	var volumes []string
+	for _, v := range w.Template.Spec.Volumes {
+		switch {
+		case v.HostPath != nil:
+			volumes = append(volumes, fmt.Sprintf("%s (hostPath %s)", v.Name, v.HostPath.Path))
+		case v.EmptyDir != nil && v.EmptyDir.SizeLimit != nil && v.EmptyDir.SizeLimit.Cmp(largeEmptyDir) >= 0:
+			volumes = append(volumes, fmt.Sprintf("%s (emptyDir %s)", v.Name, v.EmptyDir.SizeLimit.String()))
+		case v.EmptyDir != nil && v.EmptyDir.Medium == "Memory":
+			volumes = append(volumes, fmt.Sprintf("%s (in-memory emptyDir)", v.Name))
+		}
+	}
+	return volumes
+}
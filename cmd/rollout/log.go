@@ -0,0 +1,137 @@
+package rollout
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// Fields are the fields a log line is tagged with, by name.
+type Fields map[string]any
+
+// Logger is what the client logs through, leveled and tagged with fields. The With methods return a logger
+// tagging every line it logs with the field, on top of the ones it already tags them with. Use NewSlogLogger
+// to log through log/slog, the CLI adapts its logrus logger.
+type Logger interface {
+	WithField(key string, value any) Logger
+	WithFields(fields Fields) Logger
+	WithError(err error) Logger
+
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+
+	Debug(args ...any)
+	Info(args ...any)
+	Warn(args ...any)
+	Error(args ...any)
+}
+
+// NewSlogLogger returns a Logger logging through l, errors tagged as the error field.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+// slogLogger adapts a slog.Logger to Logger.
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) WithField(key string, value any) Logger {
+	return slogLogger{l: s.l.With(key, value)}
+}
+
+func (s slogLogger) WithFields(fields Fields) Logger {
+	// Sorted, so the fields are in the same order on every line
+	args := make([]any, 0, 2*len(fields))
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		args = append(args, key, fields[key])
+	}
+	return slogLogger{l: s.l.With(args...)}
+}
+
+func (s slogLogger) WithError(err error) Logger {
+	return slogLogger{l: s.l.With("error", err)}
+}
+
+// log logs the message returned by msg at level, only formatting it when the level is enabled.
+func (s slogLogger) log(level slog.Level, msg func() string) {
+	ctx := context.Background()
+	if s.l.Enabled(ctx, level) {
+		s.l.Log(ctx, level, msg())
+	}
+}
+
+func (s slogLogger) Debugf(format string, args ...any) {
+	s.log(slog.LevelDebug, func() string { return fmt.Sprintf(format, args...) })
+}
+
+func (s slogLogger) Infof(format string, args ...any) {
+	s.log(slog.LevelInfo, func() string { return fmt.Sprintf(format, args...) })
+}
+
+func (s slogLogger) Warnf(format string, args ...any) {
+	s.log(slog.LevelWarn, func() string { return fmt.Sprintf(format, args...) })
+}
+
+func (s slogLogger) Errorf(format string, args ...any) {
+	s.log(slog.LevelError, func() string { return fmt.Sprintf(format, args...) })
+}
+
+func (s slogLogger) Debug(args ...any) {
+	s.log(slog.LevelDebug, func() string { return fmt.Sprint(args...) })
+}
+
+func (s slogLogger) Info(args ...any) {
+	s.log(slog.LevelInfo, func() string { return fmt.Sprint(args...) })
+}
+
+func (s slogLogger) Warn(args ...any) {
+	s.log(slog.LevelWarn, func() string { return fmt.Sprint(args...) })
+}
+
+func (s slogLogger) Error(args ...any) {
+	s.log(slog.LevelError, func() string { return fmt.Sprint(args...) })
+}
+
+// discardLogger is the logger of clients that log nothing, e.g. the ones of the embedding API.
+var discardLogger = NewSlogLogger(slog.New(slog.DiscardHandler))
+
+type loggerKey struct{}
+
+// withLogger returns a context carrying log, everything handling that scope (a run, a namespace or a
+// single resource) logs through it so each line is consistently tagged.
+func withLogger(ctx context.Context, log Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// logger returns the logger scoped to ctx, falling back to the client's logger.
+func (rc *rolloutClient) logger(ctx context.Context) Logger {
+	if log, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return log
+	}
+	return rc.log
+}
+
+// resourceContext scopes ctx to a single workload, deriving its logger once with the kind, namespace and
+// name so every line logged while handling the workload carries them.
+func (rc *rolloutClient) resourceContext(ctx context.Context, w workload) context.Context {
+	return withLogger(ctx, rc.logger(ctx).WithFields(Fields{
+		"kind":      w.Kind,
+		"namespace": w.Namespace,
+		"name":      w.Name,
+	}))
+}
+
+// newRunID returns a short random identifier used to correlate the log lines of one run.
+func newRunID() string {
+	b := make([]byte, 6)
+	// crypto/rand.Read never returns an error
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,56 @@
+package rollout
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(Logger)
+		want string
+	}{
+		{
+			name: "fields",
+			log: func(l Logger) {
+				l.WithField("run_id", "abc").WithFields(Fields{"name": "web", "kind": "Deployment"}).Info("Restarted")
+			},
+			want: "level=INFO msg=Restarted run_id=abc kind=Deployment name=web\n",
+		},
+		{
+			name: "error",
+			log:  func(l Logger) { l.WithError(errors.New("boom")).Errorf("Failed to restart %s", "web") },
+			want: "level=ERROR msg=\"Failed to restart web\" error=boom\n",
+		},
+		{
+			name: "warning",
+			log:  func(l Logger) { l.Warn("Slow ", "rollout") },
+			want: "level=WARN msg=\"Slow rollout\"\n",
+		},
+		{
+			name: "below the level",
+			log:  func(l Logger) { l.Debugf("Listing %s", "deployments") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			handler := slog.NewTextHandler(&out, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return a
+				},
+			})
+			tt.log(NewSlogLogger(slog.New(handler)))
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
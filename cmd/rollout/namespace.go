@@ -0,0 +1,119 @@
+package rollout
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// protectedNamespaces are never restarted unless named explicitly in WithNamespaces, restarting the
+// cluster's own components by accident is rarely what a filter meant.
+var protectedNamespaces = []string{metav1.NamespaceSystem}
+
+// WithNamespaces restricts the client to the namespaces matching any of the patterns instead of every
+// namespace in the cluster. Patterns are globs (see path.Match), e.g. "team-*", empty patterns are ignored.
+func WithNamespaces(patterns ...string) Option {
+	return func(rc *rolloutClient) {
+		rc.includeNamespaces = nonEmpty(patterns)
+	}
+}
+
+// WithExcludedNamespaces skips the namespaces matching any of the glob patterns, even when they are
+// included by WithNamespaces.
+func WithExcludedNamespaces(patterns ...string) Option {
+	return func(rc *rolloutClient) {
+		rc.excludeNamespaces = nonEmpty(patterns)
+	}
+}
+
+// namespaces returns the namespaces the client works on sorted by name, or in the order of WithNamespaceOrder:
+// every namespace in the cluster, or the ones matching WithNamespaces, minus the excluded and protected ones.
+func (rc *rolloutClient) namespaces(ctx context.Context) ([]corev1.Namespace, error) {
+	var candidates []corev1.Namespace
+	if names, ok := literalNames(rc.includeNamespaces); ok {
+		// Plain names are fetched one by one, which only needs get access to those namespaces
+		for _, name := range names {
+			getCtx, cancel := rc.requestContext(ctx)
+			ns, err := rc.cs.CoreV1().Namespaces().Get(getCtx, name, metav1.GetOptions{})
+			cancel()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get namespace %s: %w", name, err)
+			}
+			candidates = append(candidates, *ns)
+		}
+	} else {
+		listCtx, cancel := rc.requestContext(ctx)
+		namespaces, err := rc.cs.CoreV1().Namespaces().List(listCtx, metav1.ListOptions{})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		candidates = namespaces.Items
+	}
+
+	var selected []corev1.Namespace
+	for _, ns := range candidates {
+		if rc.namespaceSelected(ns.Name) {
+			selected = append(selected, ns)
+		}
+	}
+	slices.SortFunc(selected, func(a, b corev1.Namespace) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	selected = slices.CompactFunc(selected, func(a, b corev1.Namespace) bool {
+		return a.Name == b.Name
+	})
+	slices.SortStableFunc(selected, rc.compareNamespaces)
+	return selected, nil
+}
+
+// namespaceSelected reports whether the client works on the namespace called name.
+func (rc *rolloutClient) namespaceSelected(name string) bool {
+	if len(rc.includeNamespaces) > 0 && !matchesAny(rc.includeNamespaces, name) {
+		return false
+	}
+	if matchesAny(rc.excludeNamespaces, name) {
+		return false
+	}
+	return !slices.Contains(protectedNamespaces, name) || slices.Contains(rc.includeNamespaces, name)
+}
+
+// literalNames returns patterns when none of them use glob syntax, so they can be looked up directly.
+func literalNames(patterns []string) ([]string, bool) {
+	if len(patterns) == 0 {
+		return nil, false
+	}
+	for _, p := range patterns {
+		if strings.ContainsAny(p, `*?[\`) {
+			return nil, false
+		}
+	}
+	return patterns, true
+}
+
+// matchesAny reports whether name matches any of the glob patterns.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// nonEmpty drops empty strings, so an unset flag doesn't turn into a pattern matching nothing.
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
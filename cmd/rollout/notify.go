@@ -0,0 +1,153 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notifier is told the outcome of every run once it has finished, e.g. to post it to a chat channel. It
+// is the extension point for notification channels, see WebhookNotifier for the built-in one.
+type Notifier interface {
+	Notify(ctx context.Context, report *Report) error
+}
+
+// WithNotifiers notifies each of notifiers of the report of every run once it has finished, including
+// failed and cancelled ones. A notifier that fails is logged and doesn't fail the run.
+func WithNotifiers(notifiers ...Notifier) Option {
+	return func(rc *rolloutClient) {
+		rc.notifiers = append(rc.notifiers, notifiers...)
+	}
+}
+
+// notify notifies the configured notifiers of report.
+func (rc *rolloutClient) notify(ctx context.Context, report *Report) {
+	// The run has finished, its outcome is reported even if it was cancelled
+	ctx = context.WithoutCancel(ctx)
+	for _, n := range rc.notifiers {
+		notifyCtx, cancel := rc.requestContext(ctx)
+		err := n.Notify(notifyCtx, report)
+		cancel()
+		if err != nil {
+			rc.log.WithError(err).WithField("run_id", report.RunID).Error("Failed to send the run summary")
+		}
+	}
+}
+
+// WebhookNotifier posts the summary of a run to a webhook. A Slack incoming webhook
+// (https://hooks.slack.com/...) is sent the summary as its message text, any other URL the summary as
+// JSON, with the message text in its "text" field, so chat tools accepting Slack compatible payloads work
+// as well.
+type WebhookNotifier struct {
+	URL string
+	// Client sends the requests, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// webhookSummary is the JSON payload WebhookNotifier posts to a generic webhook.
+type webhookSummary struct {
+	Text      string   `json:"text"`
+	RunID     string   `json:"run_id"`
+	Cluster   string   `json:"cluster,omitempty"`
+	StartTime string   `json:"start_time"`
+	LocalTime string   `json:"local_start_time,omitempty"`
+	Duration  string   `json:"duration"`
+	Restarted int      `json:"restarted"`
+	Failed    int      `json:"failed"`
+	Skipped   int      `json:"skipped"`
+	DryRun    int      `json:"dry_run,omitempty"`
+	Cancelled bool     `json:"cancelled,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// Notify posts the summary of report.
+func (n WebhookNotifier) Notify(ctx context.Context, report *Report) error {
+	text := summaryText(report)
+	var payload any = map[string]string{"text": text}
+	if u, err := url.Parse(n.URL); err != nil || u.Host != "hooks.slack.com" {
+		summary := webhookSummary{
+			Text:      text,
+			RunID:     report.RunID,
+			Cluster:   report.Cluster,
+			StartTime: report.StartTime.Format(time.RFC3339),
+			Duration:  report.Duration.Round(time.Second).String(),
+			Restarted: report.Restarted,
+			Failed:    report.Failed,
+			Skipped:   report.Skipped,
+			Cancelled: report.Cancelled,
+			DryRun:    dryRunCount(report),
+			Errors:    report.Errors,
+		}
+		// The start time in the time zone of the report, see WithTimeZone
+		if report.LocalStartTime != nil {
+			summary.LocalTime = report.LocalStartTime.Format(time.RFC3339)
+		}
+		payload = summary
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// dryRunCount returns how many workloads a dry run report would have restarted.
+func dryRunCount(report *Report) int {
+	n := 0
+	for _, t := range report.ByKind {
+		n += t.DryRun
+	}
+	return n
+}
+
+// summaryText renders the summary of report as a short message, the counts, duration and errors of the run.
+func summaryText(report *Report) string {
+	var b strings.Builder
+	b.WriteString("Rollout restart")
+	if report.Cluster != "" {
+		fmt.Fprintf(&b, " on %s", report.Cluster)
+	}
+	outcome := "finished"
+	switch {
+	case report.Cancelled:
+		outcome = "was cancelled"
+	case report.Failed > 0 || len(report.Errors) > 0:
+		outcome = "finished with failures"
+	}
+	fmt.Fprintf(&b, " %s after %s: %d restarted, %d failed, %d skipped", outcome, report.Duration.Round(time.Second), report.Restarted, report.Failed, report.Skipped)
+
+	if dryRun := dryRunCount(report); dryRun > 0 {
+		fmt.Fprintf(&b, ", %d would be restarted (dry run)", dryRun)
+	}
+	fmt.Fprintf(&b, " (run %s)", report.RunID)
+
+	for _, err := range report.Errors {
+		b.WriteString("\n- " + err)
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// operatorHandler restarts workloads managed by a database operator through the operator's supported
+// restart mechanism, letting the operator sequence the pods (replicas before the primary, one broker at a
+// time) instead of the workload controller rolling them blindly. A handler applies to workloads with a
+// controller owner of its group and kind.
+type operatorHandler struct {
+	name    string
+	group   string
+	kind    string
+	restart func(ctx context.Context, rc *rolloutClient, w workload, owner metav1.OwnerReference) error
+}
+
+// operatorHandlers are the supported operators.
+var operatorHandlers = []operatorHandler{
+	{
+		// Strimzi rolls a Kafka StatefulSet annotated for a manual rolling update, one broker at a time
+		// while keeping partitions in sync
+		name:  "strimzi",
+		group: "kafka.strimzi.io",
+		kind:  "Kafka",
+		restart: func(ctx context.Context, rc *rolloutClient, w workload, _ metav1.OwnerReference) error {
+			patch, err := json.Marshal(map[string]any{
+				"metadata": map[string]any{
+					"annotations": map[string]string{"strimzi.io/manual-rolling-update": "true"},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			return rc.patchWorkload(ctx, w, types.StrategicMergePatchType, patch)
+		},
+	},
+	{
+		// Crunchy PGO restarts a PostgresCluster when its pod annotations change, replicas first and then
+		// the primary after a switchover
+		name:  "crunchy-pgo",
+		group: "postgres-operator.crunchydata.com",
+		kind:  "PostgresCluster",
+		restart: func(ctx context.Context, rc *rolloutClient, w workload, owner metav1.OwnerReference) error {
+			patch, err := json.Marshal(map[string]any{
+				"spec": map[string]any{
+					"metadata": map[string]any{
+						"annotations": map[string]string{"restarted": restartTimestamp(rc.clock.Now())},
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			gvr := schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind).GroupVersion().WithResource("postgresclusters")
+			patchCtx, cancel := rc.requestContext(ctx)
+			defer cancel()
+			_, err = rc.operators.Resource(gvr).Namespace(w.Namespace).Patch(patchCtx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{DryRun: rc.serverDryRun()})
+			return err
+		},
+	},
+}
+
+// WithOperatorHandlers restarts workloads managed by a supported database operator (Strimzi Kafka, Crunchy
+// PGO PostgresClusters) through the operator's own restart mechanism rather than the restart annotation,
+// detected from the workload's controller owner. client is used to update the operator's resources.
+func WithOperatorHandlers(client dynamic.Interface) Option {
+	return func(rc *rolloutClient) {
+		rc.operators = client
+	}
+}
+
+// operatorFor returns the handler for the operator controlling w and its owner reference, if any.
+func (rc *rolloutClient) operatorFor(w workload) (*operatorHandler, metav1.OwnerReference, bool) {
+	if rc.operators == nil {
+		return nil, metav1.OwnerReference{}, false
+	}
+
+	owner := metav1.GetControllerOf(w.object)
+	if owner == nil {
+		return nil, metav1.OwnerReference{}, false
+	}
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		return nil, metav1.OwnerReference{}, false
+	}
+
+	for i, h := range operatorHandlers {
+		if h.group == gv.Group && h.kind == owner.Kind {
+			return &operatorHandlers[i], *owner, true
+		}
+	}
+	return nil, metav1.OwnerReference{}, false
+}
+
+// restartThroughOperator restarts w with its operator's handler.
+func (rc *rolloutClient) restartThroughOperator(ctx context.Context, w workload, h *operatorHandler, owner metav1.OwnerReference) error {
+	log := rc.logger(ctx).WithFields(Fields{"operator": h.name, "owner": owner.Name})
+	if rc.dryRun == DryRunClient {
+		log.Infof("Dry run, would restart %s through its operator", w.Kind)
+		return nil
+	}
+	log.Infof("Restarting %s through its operator", w.Kind)
+
+	// A restart that has already been scheduled is allowed to finish even if the run is cancelled
+	if err := h.restart(context.WithoutCancel(ctx), rc, w, owner); err != nil {
+		return fmt.Errorf("%s operator restart of %s %s: %w", h.name, owner.Kind, owner.Name, err)
+	}
+	return nil
+}
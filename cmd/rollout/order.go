@@ -0,0 +1,118 @@
+package rollout
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Order is the order matched workloads are restarted in. Either way the order only depends on the
+// workloads themselves, so repeated runs and plans over the same cluster state line up.
+type Order string
+
+const (
+	// OrderRisk restarts the lowest risk workloads first (see WithRiskThreshold), so problems surface on the
+	// workloads best able to absorb them. Workloads of equal risk are restarted in name order.
+	OrderRisk Order = "risk"
+	// OrderName restarts workloads sorted by namespace, kind and name.
+	OrderName Order = "name"
+)
+
+// WithOrder sets the order matched workloads are restarted in, OrderRisk by default.
+func WithOrder(order Order) Option {
+	return func(rc *rolloutClient) {
+		rc.order = order
+	}
+}
+
+// NamespaceOrder is the order namespaces are restarted in, see WithNamespaceOrder.
+type NamespaceOrder string
+
+const (
+	// NamespaceOrderOldest restarts the namespaces created first first, e.g. long standing tenants before
+	// new ones.
+	NamespaceOrderOldest NamespaceOrder = "oldest"
+	// NamespaceOrderNewest restarts the namespaces created last first.
+	NamespaceOrderNewest NamespaceOrder = "newest"
+	// NamespaceOrderPriority restarts namespaces by the integer in their NamespacePriorityLabel, highest
+	// first, namespaces without one (or with one that isn't an integer) last.
+	NamespaceOrderPriority NamespaceOrder = "priority"
+)
+
+// NamespacePriorityLabel is the namespace label NamespaceOrderPriority orders namespaces by.
+const NamespacePriorityLabel = "rollout.tim-codez.io/priority"
+
+// WithNamespaceOrder restarts the workloads of one namespace after the other, in order, instead of ordering
+// the workloads of every namespace together (see WithOrder), which still orders the workloads within each
+// namespace. It lets operators pick which tenants roll first in a long campaign. Namespaces that tie are
+// restarted in name order, and tiers (see WithTiers) still go one after the other.
+func WithNamespaceOrder(order NamespaceOrder) Option {
+	return func(rc *rolloutClient) {
+		rc.namespaceOrder = order
+	}
+}
+
+// compareNamespaces orders namespaces in the configured namespace order, see WithNamespaceOrder.
+func (rc *rolloutClient) compareNamespaces(a, b corev1.Namespace) int {
+	switch rc.namespaceOrder {
+	case NamespaceOrderOldest:
+		return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
+	case NamespaceOrderNewest:
+		return b.CreationTimestamp.Compare(a.CreationTimestamp.Time)
+	case NamespaceOrderPriority:
+		pa, okA := namespacePriority(a)
+		pb, okB := namespacePriority(b)
+		return cmp.Or(compareBools(okB, okA), cmp.Compare(pb, pa))
+	}
+	return 0
+}
+
+// namespacePriority returns the priority ns is labelled with, and whether it has a valid one.
+func namespacePriority(ns corev1.Namespace) (int, bool) {
+	priority, err := strconv.Atoi(ns.Labels[NamespacePriorityLabel])
+	return priority, err == nil
+}
+
+// compareBools orders false before true.
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+// sortWorkloads sorts workloads into the configured order, risk has to be assessed first for OrderRisk.
+// Workloads of earlier tiers (see WithTiers) always come first, then, with WithNamespaceOrder, the workloads
+// of earlier namespaces, which are in namespace order.
+func (rc *rolloutClient) sortWorkloads(workloads []workload, namespaces []corev1.Namespace) {
+	rank := map[string]int{}
+	if rc.namespaceOrder != "" {
+		for i, ns := range namespaces {
+			rank[ns.Name] = i
+		}
+	}
+	slices.SortFunc(workloads, func(a, b workload) int {
+		byTier := cmp.Or(
+			cmp.Compare(rc.tierRank(a.Namespace), rc.tierRank(b.Namespace)),
+			cmp.Compare(rank[a.Namespace], rank[b.Namespace]),
+		)
+		if rc.order == OrderName {
+			return cmp.Or(byTier, compareNames(a, b))
+		}
+		return cmp.Or(byTier, cmp.Compare(a.Risk, b.Risk), compareNames(a, b))
+	})
+}
+
+// compareNames orders workloads by namespace, kind and name.
+func compareNames(a, b workload) int {
+	return cmp.Or(
+		cmp.Compare(a.Namespace, b.Namespace),
+		cmp.Compare(a.Kind, b.Kind),
+		cmp.Compare(a.Name, b.Name),
+	)
+}
@@ -0,0 +1,54 @@
+package rollout
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// WithInterval spaces restarts out by interval, give or take a random jitter of up to jitter, e.g. one
+// workload every 30s ±10s, so that a large run doesn't have every node pulling images and every controller
+// rolling pods at the same moment. The spacing holds across namespaces restarted at once with
+// WithConcurrency. Only workloads that are actually restarted wait their turn, skipped ones don't, and dry
+// runs aren't spaced out. A zero interval restarts workloads as soon as the previous one is done.
+func WithInterval(interval, jitter time.Duration) Option {
+	return func(rc *rolloutClient) {
+		rc.interval = interval
+		rc.jitter = jitter
+	}
+}
+
+// pace waits for w's turn to restart, see WithInterval, and returns an error when ctx ends before it.
+func (rc *rolloutClient) pace(ctx context.Context, w workload) error {
+	if rc.interval <= 0 || rc.dryRun != DryRunNone {
+		return nil
+	}
+
+	// Take the next turn and push the one after it back, so that concurrent restarts queue up
+	rc.mu.Lock()
+	now := rc.clock.Now()
+	turn := rc.nextTurn
+	if turn.Before(now) {
+		turn = now
+	}
+	gap := rc.interval
+	if rc.jitter > 0 {
+		gap += time.Duration(rand.Int64N(int64(2*rc.jitter)+1)) - rc.jitter
+	}
+	rc.nextTurn = turn.Add(max(gap, 0))
+	rc.mu.Unlock()
+
+	delay := turn.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	rc.logger(ctx).WithField("delay", delay).Debugf("Waiting to restart %s", w.Kind)
+	timer := rc.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// defaultMaxUnavailable is the API server's default rolling update maxUnavailable for Deployments.
+var defaultMaxUnavailable = intstr.FromString("25%")
+
+// WithRespectPDB skips workloads whose rollout would take down more pods at once than a PodDisruptionBudget
+// covering them allows, instead of only warning about it. A rollout doesn't go through the eviction API, so
+// nothing else stops it from breaching the budget. Restarts evicting pods (see WithPods) honour budgets
+// anyway.
+func WithRespectPDB() Option {
+	return func(rc *rolloutClient) {
+		rc.respectPDB = true
+	}
+}
+
+// unavailablePods returns how many of w's pods its rollout takes down at once, from its update strategy.
+func unavailablePods(w workload) int {
+	var maxUnavailable *intstr.IntOrString
+	roundUp := true
+	switch obj := w.object.(type) {
+	case *appsv1.Deployment:
+		if obj.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+			return int(w.Replicas)
+		}
+		maxUnavailable, roundUp = &defaultMaxUnavailable, false
+		if obj.Spec.Strategy.RollingUpdate != nil && obj.Spec.Strategy.RollingUpdate.MaxUnavailable != nil {
+			maxUnavailable = obj.Spec.Strategy.RollingUpdate.MaxUnavailable
+		}
+	case *appsv1.StatefulSet:
+		if obj.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+			return 0
+		}
+		// Without maxUnavailable StatefulSets replace one pod at a time
+		if obj.Spec.UpdateStrategy.RollingUpdate == nil || obj.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable == nil {
+			return min(1, int(w.Replicas))
+		}
+		maxUnavailable = obj.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable
+	case *appsv1.DaemonSet:
+		if obj.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+			return 0
+		}
+		if obj.Spec.UpdateStrategy.RollingUpdate == nil || obj.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable == nil {
+			return min(1, int(w.Replicas))
+		}
+		maxUnavailable = obj.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable
+	default:
+		return 0
+	}
+
+	unavailable, err := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, int(w.Replicas), roundUp)
+	if err != nil {
+		return 0
+	}
+	return min(unavailable, int(w.Replicas))
+}
+
+// checkDisruptionBudgets returns an error when restarting w, taking down unavailable of its pods at once,
+// would exceed what a PodDisruptionBudget selecting its pods currently allows. Budgets that can't be read
+// don't block the restart, they are logged and left unchecked.
+func (rc *rolloutClient) checkDisruptionBudgets(ctx context.Context, w workload, unavailable int) error {
+	listCtx, cancel := rc.requestContext(ctx)
+	pdbs, err := rc.cs.PolicyV1().PodDisruptionBudgets(w.Namespace).List(listCtx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		rc.logger(ctx).WithError(err).Warn("Failed to list PodDisruptionBudgets, not checking allowed disruptions")
+		return nil
+	}
+
+	for _, pdb := range pdbs.Items {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || selector.Empty() || !selector.Matches(labels.Set(w.Template.Labels)) {
+			continue
+		}
+		if allowed := int(pdb.Status.DisruptionsAllowed); unavailable > allowed {
+			return fmt.Errorf("taking down %d pod(s) at once exceeds the %d disruption(s) PodDisruptionBudget %s allows",
+				unavailable, allowed, pdb.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// podDiffPollInterval is how often a restarted workload is checked for its first new pod.
+const podDiffPollInterval = 2 * time.Second
+
+// WithPodSpecDiff waits, up to timeout, for the first new pod of each restarted workload to start and
+// records in its result the notable differences between the new pod's effective spec and the running pods
+// it replaces: containers added or removed (e.g. injected sidecars), image changes and environment
+// variables injected or removed. It shows what a supposedly no-op restart actually changed. Not finding a
+// new pod in time is logged, it doesn't fail the restart.
+func WithPodSpecDiff(timeout time.Duration) Option {
+	return func(rc *rolloutClient) {
+		rc.podDiffTimeout = timeout
+	}
+}
+
+// diffNewPod waits for a started pod of w that isn't one of before and returns how its spec differs from
+// the first of before.
+func (rc *rolloutClient) diffNewPod(ctx context.Context, w workload, before []corev1.Pod) ([]string, error) {
+	if len(before) == 0 {
+		return nil, nil
+	}
+	previous := map[types.UID]bool{}
+	for _, pod := range before {
+		previous[pod.UID] = true
+	}
+
+	var changes []string
+	ctx, cancel := rc.withTimeout(ctx, rc.podDiffTimeout)
+	defer cancel()
+	err := rc.poll(ctx, podDiffPollInterval, false, func(ctx context.Context) (bool, error) {
+		pods, err := rc.listPods(ctx, w)
+		if err != nil {
+			// Keep polling through transient errors, the timeout bounds the wait
+			return false, nil
+		}
+		for _, pod := range pods {
+			if previous[pod.UID] || pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			changes = podSpecChanges(before[0].Spec, pod.Spec)
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no new pod started: %w", err)
+	}
+	return changes, nil
+}
+
+// podSpecChanges describes the notable differences between two pod specs.
+func podSpecChanges(before, after corev1.PodSpec) []string {
+	changes := containerChanges("init container", before.InitContainers, after.InitContainers)
+	return append(changes, containerChanges("container", before.Containers, after.Containers)...)
+}
+
+func containerChanges(what string, before, after []corev1.Container) []string {
+	var changes []string
+	find := func(containers []corev1.Container, name string) (corev1.Container, bool) {
+		i := slices.IndexFunc(containers, func(c corev1.Container) bool { return c.Name == name })
+		if i < 0 {
+			return corev1.Container{}, false
+		}
+		return containers[i], true
+	}
+
+	for _, old := range before {
+		if _, ok := find(after, old.Name); !ok {
+			changes = append(changes, fmt.Sprintf("%s %s removed", what, old.Name))
+		}
+	}
+	for _, c := range after {
+		old, ok := find(before, c.Name)
+		if !ok {
+			changes = append(changes, fmt.Sprintf("%s %s added (%s)", what, c.Name, c.Image))
+			continue
+		}
+		if old.Image != c.Image {
+			changes = append(changes, fmt.Sprintf("%s %s image %s -> %s", what, c.Name, old.Image, c.Image))
+		}
+		if !equality.Semantic.DeepEqual(old.Resources, c.Resources) {
+			changes = append(changes, fmt.Sprintf("%s %s resources changed", what, c.Name))
+		}
+		changes = append(changes, envChanges(what, c.Name, old.Env, c.Env)...)
+	}
+	return changes
+}
+
+// envChanges names the environment variables added, removed or changed, values are left out as they may
+// be secret.
+func envChanges(what, container string, before, after []corev1.EnvVar) []string {
+	var changes []string
+	index := func(env []corev1.EnvVar) map[string]corev1.EnvVar {
+		m := make(map[string]corev1.EnvVar, len(env))
+		for _, e := range env {
+			m[e.Name] = e
+		}
+		return m
+	}
+	oldEnv, newEnv := index(before), index(after)
+
+	for _, e := range before {
+		if _, ok := newEnv[e.Name]; !ok {
+			changes = append(changes, fmt.Sprintf("%s %s env %s removed", what, container, e.Name))
+		}
+	}
+	for _, e := range after {
+		old, ok := oldEnv[e.Name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s %s env %s added", what, container, e.Name))
+		case !equality.Semantic.DeepEqual(old, e):
+			changes = append(changes, fmt.Sprintf("%s %s env %s changed", what, container, e.Name))
+		}
+	}
+	return changes
+}
@@ -0,0 +1,41 @@
+package rollout
+
+import "time"
+
+// Progress is how far along a run is, with an estimate of the work remaining, see Callbacks.OnProgress.
+type Progress struct {
+	// Matched is how many workloads the run has matched so far, each tier adds its own once it starts
+	Matched int
+	// Completed is how many workloads have been handled, whatever their outcome
+	Completed int
+	Elapsed   time.Duration
+	// PerResource is the average time a workload has taken, measured on the wall clock so that concurrent
+	// restarts shorten it
+	PerResource time.Duration
+	// Remaining estimates how much longer the run takes, and ETA when it finishes, both zero until the first
+	// workload has completed
+	Remaining time.Duration
+	ETA       time.Time
+}
+
+// progress returns how far along the run is, rc.mu must be held.
+func (rc *rolloutClient) progress() Progress {
+	p := Progress{
+		Matched:   rc.metadata.Matched,
+		Completed: len(rc.metadata.Results),
+		Elapsed:   rc.clock.Since(rc.metadata.StartTime),
+	}
+	if p.Completed > 0 {
+		p.PerResource = p.Elapsed / time.Duration(p.Completed)
+		p.Remaining = p.PerResource * time.Duration(max(p.Matched-p.Completed, 0))
+		p.ETA = rc.clock.Now().Add(p.Remaining)
+	}
+	return p
+}
+
+// addMatched counts the workloads a tier matched towards the run's progress.
+func (rc *rolloutClient) addMatched(n int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.metadata.Matched += n
+}
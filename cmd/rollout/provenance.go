@@ -0,0 +1,72 @@
+package rollout
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const (
+	// provenanceAnnotation holds the JSON payload describing who restarted a workload and why.
+	provenanceAnnotation = "rollout.tim-codez.io/provenance"
+	// provenanceSignatureAnnotation holds the base64 ed25519 signature of the provenance payload,
+	// policy engines can verify it against the public key to tell sanctioned restarts apart from
+	// a copy-pasted kubectl patch.
+	provenanceSignatureAnnotation = "rollout.tim-codez.io/provenance-signature"
+)
+
+// provenance is the signed payload stamped on restarted pod templates.
+type provenance struct {
+	Initiator   string `json:"initiator"`
+	Reason      string `json:"reason,omitempty"`
+	Kind        string `json:"kind"`
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	RestartedAt string `json:"restartedAt"`
+}
+
+type provenanceSigner struct {
+	key       ed25519.PrivateKey
+	initiator string
+	reason    string
+}
+
+// ParseSigningKey parses a PEM encoded PKCS#8 ed25519 private key, as produced by
+// "openssl genpkey -algorithm ed25519".
+func ParseSigningKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key must be ed25519, got %T", key)
+	}
+	return edKey, nil
+}
+
+// annotate adds the provenance payload and its signature to a pod template's annotations.
+func (ps *provenanceSigner) annotate(annotations map[string]string, kind, namespace, name, restartedAt string) {
+	// Marshalling a struct of strings can't fail
+	payload, _ := json.Marshal(provenance{
+		Initiator:   ps.initiator,
+		Reason:      ps.reason,
+		Kind:        kind,
+		Namespace:   namespace,
+		Name:        name,
+		RestartedAt: restartedAt,
+	})
+
+	annotations[provenanceAnnotation] = string(payload)
+	annotations[provenanceSignatureAnnotation] = base64.StdEncoding.EncodeToString(ed25519.Sign(ps.key, payload))
+}
@@ -0,0 +1,112 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// defaultMaxSurge is the API server's default rolling update surge for Deployments.
+var defaultMaxSurge = intstr.FromString("25%")
+
+// surgePods returns how many extra pods w runs at once while rolling, which have to fit in the namespace's
+// quota. StatefulSets replace pods one at a time without surging.
+func surgePods(w workload) int {
+	var maxSurge *intstr.IntOrString
+	switch obj := w.object.(type) {
+	case *appsv1.Deployment:
+		if obj.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+			return 0
+		}
+		maxSurge = &defaultMaxSurge
+		if obj.Spec.Strategy.RollingUpdate != nil && obj.Spec.Strategy.RollingUpdate.MaxSurge != nil {
+			maxSurge = obj.Spec.Strategy.RollingUpdate.MaxSurge
+		}
+	case *appsv1.DaemonSet:
+		if obj.Spec.UpdateStrategy.RollingUpdate == nil || obj.Spec.UpdateStrategy.RollingUpdate.MaxSurge == nil {
+			return 0
+		}
+		maxSurge = obj.Spec.UpdateStrategy.RollingUpdate.MaxSurge
+	default:
+		return 0
+	}
+
+	surge, err := intstr.GetScaledValueFromIntOrPercent(maxSurge, int(w.Replicas), true)
+	if err != nil {
+		return 0
+	}
+	return surge
+}
+
+// podQuotaUsage returns how much of each quota-tracked resource a single pod of w consumes.
+func podQuotaUsage(w workload) corev1.ResourceList {
+	usage := corev1.ResourceList{
+		corev1.ResourcePods:           resource.MustParse("1"),
+		"count/pods":                  resource.MustParse("1"),
+		corev1.ResourceRequestsCPU:    resource.Quantity{},
+		corev1.ResourceRequestsMemory: resource.Quantity{},
+		corev1.ResourceLimitsCPU:      resource.Quantity{},
+		corev1.ResourceLimitsMemory:   resource.Quantity{},
+	}
+	add := func(name corev1.ResourceName, q resource.Quantity) {
+		total := usage[name]
+		total.Add(q)
+		usage[name] = total
+	}
+	for _, c := range w.Template.Spec.Containers {
+		add(corev1.ResourceRequestsCPU, *c.Resources.Requests.Cpu())
+		add(corev1.ResourceRequestsMemory, *c.Resources.Requests.Memory())
+		add(corev1.ResourceLimitsCPU, *c.Resources.Limits.Cpu())
+		add(corev1.ResourceLimitsMemory, *c.Resources.Limits.Memory())
+	}
+	// The short resource names mean requests
+	usage[corev1.ResourceCPU] = usage[corev1.ResourceRequestsCPU]
+	usage[corev1.ResourceMemory] = usage[corev1.ResourceRequestsMemory]
+	return usage
+}
+
+// checkQuotaHeadroom returns an error when the surge pods w needs to roll would not fit in the remaining
+// capacity of a ResourceQuota of its namespace. Such a rollout wedges half-rolled, the new pods are never
+// admitted, so the workload is better left alone. Quota scopes are not evaluated, every quota is assumed
+// to apply.
+func (rc *rolloutClient) checkQuotaHeadroom(ctx context.Context, w workload) error {
+	surge := surgePods(w)
+	if surge == 0 {
+		return nil
+	}
+
+	listCtx, cancel := rc.requestContext(ctx)
+	quotas, err := rc.cs.CoreV1().ResourceQuotas(w.Namespace).List(listCtx, metav1.ListOptions{})
+	cancel()
+	if err != nil {
+		// Not being able to read quotas shouldn't block the restart
+		rc.logger(ctx).WithError(err).Warn("Failed to list resource quotas, not checking headroom")
+		return nil
+	}
+
+	perPod := podQuotaUsage(w)
+	for _, quota := range quotas.Items {
+		for name, hard := range quota.Status.Hard {
+			need, tracked := perPod[name]
+			if !tracked || need.IsZero() {
+				continue
+			}
+
+			required := quota.Status.Used[name]
+			for range surge {
+				required.Add(need)
+			}
+			if required.Cmp(hard) > 0 {
+				used := quota.Status.Used[name]
+				return fmt.Errorf("resource quota %s has no headroom for %d surge pod(s): %s used %s of %s",
+					quota.Name, surge, name, used.String(), hard.String())
+			}
+		}
+	}
+	return nil
+}
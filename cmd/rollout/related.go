@@ -0,0 +1,166 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RelatedObject is an object shaping how a workload behaves while it is cycled, e.g. the autoscaler sizing
+// it or the budget limiting its disruptions, listed next to it in plans made with WithRelatedObjects.
+type RelatedObject struct {
+	Kind string
+	Name string
+}
+
+// String returns the object as kind/name.
+func (o RelatedObject) String() string {
+	return o.Kind + "/" + o.Name
+}
+
+// WithRelatedObjects lists the HorizontalPodAutoscalers scaling, and the PodDisruptionBudgets, Services
+// and NetworkPolicies selecting the pods of, each workload of a plan, so reviewers see the operational
+// context of what is about to be cycled. The objects of a namespace are listed once for all its workloads,
+// the namespaces up to the configured concurrency at once (see WithConcurrency). Only Plan uses it.
+func WithRelatedObjects() Option {
+	return func(rc *rolloutClient) {
+		rc.relatedObjects = true
+	}
+}
+
+// namespaceObjects are the objects of a namespace workloads can be related to.
+type namespaceObjects struct {
+	autoscalers     []autoscalingv2.HorizontalPodAutoscaler
+	budgets         []policyv1.PodDisruptionBudget
+	services        []corev1.Service
+	networkPolicies []networkingv1.NetworkPolicy
+}
+
+// listRelatedObjects lists the objects workloads can be related to in each of namespaces, the kinds of a
+// namespace concurrently.
+func (rc *rolloutClient) listRelatedObjects(ctx context.Context, namespaces []string) (map[string]*namespaceObjects, error) {
+	objects := make([]*namespaceObjects, len(namespaces))
+	errs := make([]error, len(namespaces))
+	rc.parallel(ctx, len(namespaces), func(i int) {
+		objects[i], errs[i] = rc.namespaceObjects(ctx, namespaces[i])
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	byNamespace := make(map[string]*namespaceObjects, len(namespaces))
+	for i, ns := range namespaces {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("namespace %s: %w", ns, errs[i])
+		}
+		byNamespace[ns] = objects[i]
+	}
+	return byNamespace, nil
+}
+
+// namespaceObjects lists the objects workloads can be related to in namespace, every kind at once.
+func (rc *rolloutClient) namespaceObjects(ctx context.Context, namespace string) (*namespaceObjects, error) {
+	objects := &namespaceObjects{}
+	list := func(what string, fn func(ctx context.Context) error) func() error {
+		return func() error {
+			listCtx, cancel := rc.requestContext(ctx)
+			defer cancel()
+			if err := fn(listCtx); err != nil {
+				return fmt.Errorf("failed to list %s: %w", what, err)
+			}
+			return nil
+		}
+	}
+	lists := []func() error{
+		list("horizontal pod autoscalers", func(ctx context.Context) error {
+			l, err := rc.cs.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+			if err == nil {
+				objects.autoscalers = l.Items
+			}
+			return err
+		}),
+		list("pod disruption budgets", func(ctx context.Context) error {
+			l, err := rc.cs.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+			if err == nil {
+				objects.budgets = l.Items
+			}
+			return err
+		}),
+		list("services", func(ctx context.Context) error {
+			l, err := rc.cs.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+			if err == nil {
+				objects.services = l.Items
+			}
+			return err
+		}),
+		list("network policies", func(ctx context.Context) error {
+			l, err := rc.cs.NetworkingV1().NetworkPolicies(namespace).List(ctx, metav1.ListOptions{})
+			if err == nil {
+				objects.networkPolicies = l.Items
+			}
+			return err
+		}),
+	}
+
+	errs := make([]error, len(lists))
+	var wg sync.WaitGroup
+	for i, fn := range lists {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+// relatedTo returns the objects of w's namespace related to w: the autoscalers targeting it, and the
+// budgets, services and network policies selecting its pods. Budgets and services with an empty selector
+// select nothing, a network policy with an empty one every pod of the namespace.
+func (objects *namespaceObjects) relatedTo(w workload) []RelatedObject {
+	var podLabels labels.Set
+	if w.Template != nil {
+		podLabels = w.Template.Labels
+	}
+
+	var related []RelatedObject
+	for _, hpa := range objects.autoscalers {
+		ref := hpa.Spec.ScaleTargetRef
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err == nil && gv.Group == w.gvk.Group && ref.Kind == w.gvk.Kind && ref.Name == w.Name {
+			related = append(related, RelatedObject{Kind: "HorizontalPodAutoscaler", Name: hpa.Name})
+		}
+	}
+	for _, pdb := range objects.budgets {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err == nil && !selector.Empty() && selector.Matches(podLabels) {
+			related = append(related, RelatedObject{Kind: "PodDisruptionBudget", Name: pdb.Name})
+		}
+	}
+	for _, svc := range objects.services {
+		if len(svc.Spec.Selector) > 0 && labels.SelectorFromSet(svc.Spec.Selector).Matches(podLabels) {
+			related = append(related, RelatedObject{Kind: "Service", Name: svc.Name})
+		}
+	}
+	for _, policy := range objects.networkPolicies {
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err == nil && selector.Matches(podLabels) {
+			related = append(related, RelatedObject{Kind: "NetworkPolicy", Name: policy.Name})
+		}
+	}
+	return related
+}
@@ -0,0 +1,460 @@
+package rollout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// configSource is a kind of object workloads read their configuration from, ConfigMaps or Secrets.
+type configSource struct {
+	kind string
+	// list returns the resource version the objects were listed at and the data hash of each, by namespace/name
+	list  func(ctx context.Context) (string, map[string]string, error)
+	watch func(ctx context.Context, resourceVersion string) (watch.Interface, error)
+	// hash returns the namespace, name and data hash of a watched object
+	hash func(obj any) (namespace, name, hash string, ok bool)
+}
+
+// ReloadReasonAnnotation is set on the pod template of a workload restarted by WatchConfig, listing the
+// ConfigMaps and Secrets whose changes it was restarted for.
+const ReloadReasonAnnotation = "rollout.tim-codez.io/reload-reason"
+
+// configChange is a ConfigMap or Secret whose data changed.
+type configChange struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// String returns the change as kind namespace/name.
+func (c configChange) String() string {
+	return fmt.Sprintf("%s %s/%s", c.kind, c.namespace, c.name)
+}
+
+// pendingReload is a workload whose configuration changed, waiting for the quiet period to restart it.
+type pendingReload struct {
+	namespace string
+	// changes are the changes of the objects it uses since it was last restarted, in the order they were seen
+	changes []configChange
+	// due is when the quiet period after the last of them ends
+	due time.Time
+}
+
+// WithReloadQuietPeriod makes WatchConfig wait until none of the ConfigMaps and Secrets a workload uses has
+// changed for period before restarting it, so changes made in quick succession, e.g. to a ConfigMap and a
+// Secret updated by the same deploy, are coalesced into a single restart. Without it, or with a period <= 0,
+// workloads are restarted as soon as one changes.
+func WithReloadQuietPeriod(period time.Duration) Option {
+	return func(rc *rolloutClient) {
+		rc.reloadQuietPeriod = period
+	}
+}
+
+// WatchConfig watches every ConfigMap and Secret until ctx is done and, whenever the data of one changes,
+// restarts the matching workloads that mount it or read it into their environment, so they pick up the new
+// configuration, like Reloader. A workload is restarted once the quiet period has passed (see
+// WithReloadQuietPeriod), for every change of the objects it uses since, which ReloadReasonAnnotation lists.
+// The workloads of a namespace due at the same time are restarted as a run of their own, through the same
+// restart path as Run with its checks and warnings, and onReload, if set, is called with its report.
+// Namespaces are selected like a run's, and changes are left alone while restarts are frozen.
+//
+// Only changes made while watching are acted on. A watch that fails is re-established, catching up on the
+// changes it missed.
+func (rc *rolloutClient) WatchConfig(ctx context.Context, onReload func(*Report)) error {
+	changes := make(chan configChange)
+	for _, source := range rc.configSources() {
+		go rc.watchConfigSource(ctx, source, changes)
+	}
+
+	rc.log.Info("Watching ConfigMaps and Secrets for changes")
+	pending := map[string]*pendingReload{}
+	for {
+		var due <-chan time.Time
+		if len(pending) > 0 {
+			due = rc.clock.After(nextReload(pending).Sub(rc.clock.Now()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case change := <-changes:
+			rc.queueReload(ctx, pending, change)
+		case <-due:
+		}
+
+		for _, report := range rc.reloadDue(ctx, pending) {
+			if onReload != nil {
+				onReload(report)
+			}
+		}
+	}
+}
+
+// nextReload returns when the first of the pending workloads is due.
+func nextReload(pending map[string]*pendingReload) time.Time {
+	var next time.Time
+	for _, p := range pending {
+		if next.IsZero() || p.due.Before(next) {
+			next = p.due
+		}
+	}
+	return next
+}
+
+// queueReload adds change to the pending reloads of the matching workloads using the changed object, and
+// restarts their quiet period.
+func (rc *rolloutClient) queueReload(ctx context.Context, pending map[string]*pendingReload, change configChange) {
+	if !rc.namespaceSelected(change.namespace) {
+		return
+	}
+	log := rc.log.WithField("changed", change.String())
+
+	workloads, err := rc.listMatchingWorkloads(ctx, change.namespace)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to find the workloads using the changed %s", change.kind)
+		return
+	}
+
+	queued := 0
+	for _, w := range workloads {
+		if !usesConfig(w.Template.Spec, change.kind, change.name) {
+			continue
+		}
+		key := checkpointKey(w.Kind, w.Namespace, w.Name)
+		p, ok := pending[key]
+		if !ok {
+			p = &pendingReload{namespace: w.Namespace}
+			pending[key] = p
+		}
+		if !slices.Contains(p.changes, change) {
+			p.changes = append(p.changes, change)
+		}
+		p.due = rc.clock.Now().Add(rc.reloadQuietPeriod)
+		queued++
+	}
+
+	switch {
+	case queued == 0:
+		log.Debugf("%s changed, no matching workload uses it", change.kind)
+	case rc.reloadQuietPeriod > 0:
+		log.WithField("workloads", queued).Infof("%s changed, restarting the workloads using it once their configuration has been quiet for %s", change.kind, rc.reloadQuietPeriod)
+	}
+}
+
+// reloadDue removes the pending workloads whose quiet period has passed and restarts them, a run per
+// namespace, returning the reports of the runs.
+func (rc *rolloutClient) reloadDue(ctx context.Context, pending map[string]*pendingReload) []*Report {
+	now := rc.clock.Now()
+	due := map[string]map[string][]configChange{}
+	for key, p := range pending {
+		if p.due.After(now) {
+			continue
+		}
+		if due[p.namespace] == nil {
+			due[p.namespace] = map[string][]configChange{}
+		}
+		due[p.namespace][key] = p.changes
+		delete(pending, key)
+	}
+
+	var reports []*Report
+	for _, namespace := range slices.Sorted(maps.Keys(due)) {
+		if report := rc.reload(ctx, namespace, due[namespace]); report != nil {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+// watchConfigSource sends a change for every object of source whose data changes, until ctx is done.
+func (rc *rolloutClient) watchConfigSource(ctx context.Context, source configSource, changes chan<- configChange) {
+	log := rc.log.WithField("kind", source.kind)
+	var known map[string]string
+	for ctx.Err() == nil {
+		resourceVersion, hashes, err := source.list(ctx)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to list %ss, retrying in %s", source.kind, rewatchInterval)
+		} else {
+			// Catch up on the changes a failed watch missed
+			for key, hash := range hashes {
+				if previous, ok := known[key]; ok && previous != hash {
+					rc.sendChange(ctx, changes, source.kind, key)
+				}
+			}
+			known = rc.consumeConfig(ctx, source, resourceVersion, hashes, changes)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(rewatchInterval):
+		}
+	}
+}
+
+// consumeConfig watches source from resourceVersion, sending a change for every object whose hash differs
+// from the one known, until the watch ends or ctx is done. The updated hashes are returned.
+func (rc *rolloutClient) consumeConfig(ctx context.Context, source configSource, resourceVersion string, known map[string]string, changes chan<- configChange) map[string]string {
+	w, err := source.watch(ctx, resourceVersion)
+	if err != nil {
+		rc.log.WithError(err).Warnf("Failed to watch %ss, retrying in %s", source.kind, rewatchInterval)
+		return known
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return known
+		case event, ok := <-w.ResultChan():
+			if !ok || event.Type == watch.Error {
+				return known
+			}
+			namespace, name, hash, ok := source.hash(event.Object)
+			if !ok {
+				continue
+			}
+			key := namespace + "/" + name
+			switch event.Type {
+			case watch.Deleted:
+				delete(known, key)
+			case watch.Added:
+				known[key] = hash
+			case watch.Modified:
+				// Changes of labels or annotations alone don't change what the workloads read
+				if known[key] != hash {
+					known[key] = hash
+					rc.sendChange(ctx, changes, source.kind, key)
+				}
+			}
+		}
+	}
+}
+
+// sendChange sends the change of the kind's object namespace/name unless ctx is done first.
+func (rc *rolloutClient) sendChange(ctx context.Context, changes chan<- configChange, kind, key string) {
+	namespace, name, _ := strings.Cut(key, "/")
+	select {
+	case <-ctx.Done():
+	case changes <- configChange{kind: kind, namespace: namespace, name: name}:
+	}
+}
+
+// reload restarts the workloads of namespace with pending changes, keyed like checkpoints, as a run of its
+// own and returns its report, nil when there is nothing to restart.
+func (rc *rolloutClient) reload(ctx context.Context, namespace string, changes map[string][]configChange) *Report {
+	var changed []string
+	for _, key := range slices.Sorted(maps.Keys(changes)) {
+		for _, change := range changes[key] {
+			if !slices.Contains(changed, change.String()) {
+				changed = append(changed, change.String())
+			}
+		}
+	}
+	log := rc.log.WithField("changed", strings.Join(changed, ", "))
+
+	workloads, err := rc.listMatchingWorkloads(ctx, namespace)
+	if err != nil {
+		log.WithError(err).Error("Failed to find the workloads using the changed configuration")
+		return nil
+	}
+	workloads = slices.DeleteFunc(workloads, func(w workload) bool {
+		_, ok := changes[checkpointKey(w.Kind, w.Namespace, w.Name)]
+		return !ok
+	})
+	if len(workloads) == 0 {
+		log.Debug("The workloads using the changed configuration are gone")
+		return nil
+	}
+	for i, w := range workloads {
+		workloads[i].reloadReason = reloadReason(changes[checkpointKey(w.Kind, w.Namespace, w.Name)])
+	}
+
+	rc.startRecording(&rolloutMetadata{
+		RunID:     newRunID(),
+		StartTime: rc.clock.Now(),
+		Matched:   len(workloads),
+		Errors:    []error{},
+	})
+	ctx = withLogger(ctx, log.WithField("run_id", rc.metadata.RunID))
+	log = rc.logger(ctx)
+	log.WithField("workloads", len(workloads)).Info("Configuration changed, restarting the workloads using it")
+
+	stopUsage := rc.trackUsage()
+	if err := rc.reloadAllowed(ctx, namespace); err != nil {
+		log.WithError(err).Error("Refusing to restart")
+		rc.addError(err)
+	} else {
+		rc.assessRisk(ctx, workloads)
+		rc.sortWorkloads(workloads, nil)
+		rc.restartAll(ctx, workloads)
+	}
+	rc.setEnded(stopUsage())
+
+	report := rc.Report()
+	log.WithFields(Fields{
+		"restarted": report.Restarted,
+		"failed":    report.Failed,
+		"skipped":   report.Skipped,
+	}).Info("Reload completed")
+	return report
+}
+
+// reloadReason describes changes for ReloadReasonAnnotation, e.g. "ConfigMap app, Secret db changed".
+func reloadReason(changes []configChange) string {
+	objects := make([]string, len(changes))
+	for i, change := range changes {
+		objects[i] = change.kind + " " + change.name
+	}
+	return strings.Join(objects, ", ") + " changed"
+}
+
+// reloadAllowed returns an error when restarts in namespace are frozen, cluster-wide or in the namespace
+// itself, a dry run is always allowed.
+func (rc *rolloutClient) reloadAllowed(ctx context.Context, namespace string) error {
+	if rc.dryRun != DryRunNone {
+		return nil
+	}
+	if rc.freezeName != "" {
+		if _, err := rc.frozen(ctx); err != nil {
+			return err
+		}
+	}
+
+	getCtx, cancel := rc.requestContext(ctx)
+	defer cancel()
+	ns, err := rc.cs.CoreV1().Namespaces().Get(getCtx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	if reason, frozen := namespaceFrozen(ns.Annotations); frozen {
+		return fmt.Errorf("%w in namespace %s: %s", ErrFrozen, namespace, reason)
+	}
+	return nil
+}
+
+// usesConfig reports whether pods of spec mount the kind's object called name, directly or through a
+// projected volume, or read it into the environment of any of their containers.
+func usesConfig(spec corev1.PodSpec, kind, name string) bool {
+	for _, v := range spec.Volumes {
+		switch {
+		case kind == "ConfigMap" && v.ConfigMap != nil && v.ConfigMap.Name == name:
+			return true
+		case kind == "Secret" && v.Secret != nil && v.Secret.SecretName == name:
+			return true
+		case v.Projected != nil:
+			for _, source := range v.Projected.Sources {
+				if kind == "ConfigMap" && source.ConfigMap != nil && source.ConfigMap.Name == name ||
+					kind == "Secret" && source.Secret != nil && source.Secret.Name == name {
+					return true
+				}
+			}
+		}
+	}
+
+	for _, c := range slices.Concat(spec.InitContainers, spec.Containers) {
+		for _, from := range c.EnvFrom {
+			if kind == "ConfigMap" && from.ConfigMapRef != nil && from.ConfigMapRef.Name == name ||
+				kind == "Secret" && from.SecretRef != nil && from.SecretRef.Name == name {
+				return true
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if kind == "ConfigMap" && env.ValueFrom.ConfigMapKeyRef != nil && env.ValueFrom.ConfigMapKeyRef.Name == name ||
+				kind == "Secret" && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// configSources returns the ConfigMap and Secret sources WatchConfig watches.
+func (rc *rolloutClient) configSources() []configSource {
+	configMaps := rc.cs.CoreV1().ConfigMaps(metav1.NamespaceAll)
+	secrets := rc.cs.CoreV1().Secrets(metav1.NamespaceAll)
+	return []configSource{
+		{
+			kind: "ConfigMap",
+			list: func(ctx context.Context) (string, map[string]string, error) {
+				listCtx, cancel := rc.requestContext(ctx)
+				defer cancel()
+				list, err := configMaps.List(listCtx, metav1.ListOptions{})
+				if err != nil {
+					return "", nil, err
+				}
+				hashes := make(map[string]string, len(list.Items))
+				for _, cm := range list.Items {
+					hashes[cm.Namespace+"/"+cm.Name] = configMapHash(&cm)
+				}
+				return list.ResourceVersion, hashes, nil
+			},
+			watch: func(ctx context.Context, resourceVersion string) (watch.Interface, error) {
+				return configMaps.Watch(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
+			},
+			hash: func(obj any) (string, string, string, bool) {
+				cm, ok := obj.(*corev1.ConfigMap)
+				if !ok {
+					return "", "", "", false
+				}
+				return cm.Namespace, cm.Name, configMapHash(cm), true
+			},
+		},
+		{
+			kind: "Secret",
+			list: func(ctx context.Context) (string, map[string]string, error) {
+				listCtx, cancel := rc.requestContext(ctx)
+				defer cancel()
+				list, err := secrets.List(listCtx, metav1.ListOptions{})
+				if err != nil {
+					return "", nil, err
+				}
+				hashes := make(map[string]string, len(list.Items))
+				for _, s := range list.Items {
+					hashes[s.Namespace+"/"+s.Name] = dataHash(nil, s.Data)
+				}
+				return list.ResourceVersion, hashes, nil
+			},
+			watch: func(ctx context.Context, resourceVersion string) (watch.Interface, error) {
+				return secrets.Watch(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
+			},
+			hash: func(obj any) (string, string, string, bool) {
+				s, ok := obj.(*corev1.Secret)
+				if !ok {
+					return "", "", "", false
+				}
+				return s.Namespace, s.Name, dataHash(nil, s.Data), true
+			},
+		},
+	}
+}
+
+// configMapHash returns the hash of a ConfigMap's data.
+func configMapHash(cm *corev1.ConfigMap) string {
+	return dataHash(cm.Data, cm.BinaryData)
+}
+
+// dataHash returns a hash of the keys and values of data and binaryData, which changes whenever any of
+// them does.
+func dataHash(data map[string]string, binaryData map[string][]byte) string {
+	h := sha256.New()
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		fmt.Fprintf(h, "%q=%q\n", key, data[key])
+	}
+	for _, key := range slices.Sorted(maps.Keys(binaryData)) {
+		fmt.Fprintf(h, "%q=%x\n", key, binaryData[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
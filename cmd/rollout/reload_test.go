@@ -0,0 +1,118 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestReloadCoalescesChanges(t *testing.T) {
+	// web reads the app ConfigMap and the db Secret, worker only the ConfigMap
+	deployment := func(name string, volumes ...corev1.Volume) *appsv1.Deployment {
+		template := *testTemplate.DeepCopy()
+		template.Spec.Volumes = volumes
+		return &appsv1.Deployment{ObjectMeta: testMeta(name), Spec: appsv1.DeploymentSpec{Selector: testSelector, Template: template}}
+	}
+	app := corev1.Volume{Name: "app", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app"}}}}
+	db := corev1.Volume{Name: "db", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "db"}}}
+	appChange := configChange{kind: "ConfigMap", namespace: "default", name: "app"}
+	dbChange := configChange{kind: "Secret", namespace: "default", name: "db"}
+
+	// step is a change seen after the previous one
+	type step struct {
+		after  time.Duration
+		change configChange
+	}
+
+	tests := []struct {
+		name        string
+		quietPeriod time.Duration
+		steps       []step
+		// wantReasons are the reload reasons of the workloads restarted wantAfter the last change, with
+		// nothing restarted before
+		wantReasons map[string]string
+		wantAfter   time.Duration
+	}{
+		{
+			name:        "restarts on every change without a quiet period",
+			steps:       []step{{change: dbChange}},
+			wantReasons: map[string]string{"web": "Secret db changed"},
+		},
+		{
+			name:        "coalesces changes within the quiet period",
+			quietPeriod: 30 * time.Second,
+			steps:       []step{{change: appChange}, {after: 20 * time.Second, change: dbChange}, {after: 20 * time.Second, change: appChange}},
+			wantReasons: map[string]string{"web": "ConfigMap app, Secret db changed", "worker": "ConfigMap app changed"},
+			wantAfter:   30 * time.Second,
+		},
+		{
+			name:        "ignores changes of unused objects",
+			quietPeriod: 30 * time.Second,
+			steps:       []step{{change: configChange{kind: "Secret", namespace: "default", name: "other"}}},
+			wantAfter:   30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := fake.NewSimpleClientset(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+				deployment("web", app, db),
+				deployment("worker", app),
+			)
+			clock := clocktesting.NewFakeClock(testNow)
+			rc := newEmbeddedClient(cs, "", []Option{WithClock(clock), WithReloadQuietPeriod(tt.quietPeriod)})
+			ctx := context.Background()
+
+			pending := map[string]*pendingReload{}
+			var reports []*Report
+			for _, step := range tt.steps {
+				clock.Step(step.after)
+				rc.queueReload(ctx, pending, step.change)
+				reports = append(reports, rc.reloadDue(ctx, pending)...)
+			}
+			if tt.wantAfter > 0 {
+				clock.Step(tt.wantAfter - time.Nanosecond)
+				if reports = append(reports, rc.reloadDue(ctx, pending)...); len(reports) > 0 {
+					t.Fatalf("restarted %v before the quiet period passed", reports[0].Results)
+				}
+				clock.Step(time.Nanosecond)
+				reports = append(reports, rc.reloadDue(ctx, pending)...)
+			}
+
+			if len(tt.wantReasons) == 0 {
+				if len(reports) > 0 || len(pending) > 0 {
+					t.Errorf("got reports %v and pending %v, want nothing restarted", reports, pending)
+				}
+				return
+			}
+			if len(reports) != 1 {
+				t.Fatalf("got %d runs, want the changes coalesced into one", len(reports))
+			}
+			got := map[string]string{}
+			for _, r := range reports[0].Results {
+				if r.Action != ActionRestarted {
+					t.Errorf("%s: got action %q, want restarted", r.Name, r.Action)
+				}
+				got[r.Name] = r.SetAnnotations[ReloadReasonAnnotation]
+			}
+			if len(got) != len(tt.wantReasons) {
+				t.Errorf("got reasons %v, want %v", got, tt.wantReasons)
+			}
+			for name, reason := range tt.wantReasons {
+				if got[name] != reason {
+					t.Errorf("%s: got reason %q, want %q", name, got[name], reason)
+				}
+			}
+			if len(pending) > 0 {
+				t.Errorf("got %d workloads still pending", len(pending))
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package rollout
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Actions recorded against each matched workload in a run's results.
+const (
+	ActionRestarted = "restarted"
+	ActionFailed    = "failed"
+	ActionSkipped   = "skipped"
+	// ActionDryRun is recorded instead of ActionRestarted by dry runs (see WithDryRun)
+	ActionDryRun = "dry-run"
+)
+
+// DefaultOwnerKeys are the workload annotation/label keys checked for a service owner, matching the
+// Backstage software catalog convention.
+var DefaultOwnerKeys = []string{"backstage.io/owner"}
+
+// NotifyAnnotation is the workload annotation naming where its owners want to hear about its restarts, e.g.
+// a Slack channel, an email address or a webhook URL. It is recorded in the results as is.
+const NotifyAnnotation = "rollout.tim-codez.io/notify"
+
+// ResourceResult records what happened to a single matched workload during a run.
+type ResourceResult struct {
+	Cluster   string
+	Namespace string
+	Kind      string
+	Name      string
+	Owner     string
+	Notify    string `json:",omitempty"`
+	Action    string
+	Duration  time.Duration
+	Error     string
+	Risk      int
+	Snapshots []string
+	Changes   []string
+
+	// Pod template annotations and labels the restart set and the values they had before, null when absent,
+	// see Undo
+	SetAnnotations      map[string]string  `json:",omitempty"`
+	PreviousAnnotations map[string]*string `json:",omitempty"`
+	SetLabels           map[string]string  `json:",omitempty"`
+	PreviousLabels      map[string]*string `json:",omitempty"`
+
+	// Pod footprint of the workload, used to estimate the cost of the run
+	Replicas         int32
+	CPURequestCores  float64
+	MemoryRequestGiB float64
+}
+
+func (rc *rolloutClient) recordResult(w workload, action string, duration time.Duration, err error) {
+	result := ResourceResult{
+		Cluster:   rc.clusterName,
+		Namespace: w.Namespace,
+		Kind:      w.Kind,
+		Name:      w.Name,
+		Owner:     rc.ownerOf(w.object),
+		Notify:    w.object.GetAnnotations()[NotifyAnnotation],
+		Action:    action,
+		Duration:  duration,
+		Risk:      w.Risk,
+		Snapshots: w.Snapshots,
+		Changes:   w.PodChanges,
+
+		SetAnnotations:      w.SetAnnotations,
+		PreviousAnnotations: w.PreviousAnnotations,
+		SetLabels:           w.SetLabels,
+		PreviousLabels:      w.PreviousLabels,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Replicas, result.CPURequestCores, result.MemoryRequestGiB = podFootprint(w)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.metadata.Results = append(rc.metadata.Results, result)
+	rc.notifyResult(result)
+
+	if action == ActionFailed && rc.maxErrors > 0 && rc.abort != nil {
+		if failed := rc.metadata.countResults(ActionFailed); failed == rc.maxErrors {
+			rc.abort(fmt.Errorf("%w: %d workload(s) failed to restart", ErrTooManyFailures, failed))
+		}
+	}
+}
+
+// ownerOf resolves a workload's service owner from the first configured key found, annotations
+// taking precedence over labels.
+func (rc *rolloutClient) ownerOf(obj metav1.Object) string {
+	for _, key := range rc.ownerKeys {
+		if owner := obj.GetAnnotations()[key]; owner != "" {
+			return owner
+		}
+		if owner := obj.GetLabels()[key]; owner != "" {
+			return owner
+		}
+	}
+	return ""
+}
+
+// Results returns the per-resource results of the last Run, the ones so far while it is still in progress.
+func (rc *rolloutClient) Results() []ResourceResult {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.results()
+}
+
+// results returns a copy of the run's results, rc.mu must be held.
+func (rc *rolloutClient) results() []ResourceResult {
+	if rc.metadata == nil {
+		return nil
+	}
+	return append([]ResourceResult(nil), rc.metadata.Results...)
+}
+
+// WriteResultsCSV writes one row per resource, with a header row, for loading run results into a spreadsheet.
+func WriteResultsCSV(w io.Writer, results []ResourceResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"cluster", "namespace", "kind", "name", "owner", "action", "duration_seconds", "error", "risk", "snapshots", "changes"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := cw.Write([]string{
+			r.Cluster,
+			r.Namespace,
+			r.Kind,
+			r.Name,
+			r.Owner,
+			r.Action,
+			strconv.FormatFloat(r.Duration.Seconds(), 'f', 3, 64),
+			r.Error,
+			strconv.Itoa(r.Risk),
+			strings.Join(r.Snapshots, " "),
+			strings.Join(r.Changes, "; "),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
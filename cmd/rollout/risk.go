@@ -0,0 +1,120 @@
+package rollout
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// riskWeights weighs each risk factor, a workload's risk score is the sum of the factors that apply to it,
+// from 0 to 100.
+var riskWeights = map[string]int{
+	"single-replica":    30,
+	"two-replicas":      15,
+	"no-pdb-slack":      25,
+	"critical-priority": 20,
+	"priority-class":    10,
+	"stateful":          15,
+	"crash-history":     10,
+}
+
+// WithRiskThreshold skips matched workloads whose risk score (0-100) is at or above threshold, so high risk
+// restarts, e.g. a single replica StatefulSet whose PodDisruptionBudget allows no disruption, need explicit
+// confirmation by running them with a higher threshold. A threshold <= 0 restarts everything.
+func WithRiskThreshold(threshold int) Option {
+	return func(rc *rolloutClient) {
+		rc.riskThreshold = threshold
+	}
+}
+
+// assessRisk scores the restart risk of each workload from its replicas, PodDisruptionBudget slack, priority
+// class, whether it is stateful and the crash history of its pods. Information that can't be read, e.g.
+// for lack of permissions, is logged and treated as no risk.
+func (rc *rolloutClient) assessRisk(ctx context.Context, workloads []workload) {
+	pdbs := map[string][]policyv1.PodDisruptionBudget{}
+	for i := range workloads {
+		w := &workloads[i]
+		log := rc.logger(rc.resourceContext(ctx, *w))
+
+		if _, listed := pdbs[w.Namespace]; !listed {
+			listCtx, cancel := rc.requestContext(ctx)
+			list, err := rc.cs.PolicyV1().PodDisruptionBudgets(w.Namespace).List(listCtx, metav1.ListOptions{})
+			cancel()
+			if err != nil {
+				log.WithError(err).Warn("Failed to list PodDisruptionBudgets, not scoring their slack")
+				list = &policyv1.PodDisruptionBudgetList{}
+			}
+			pdbs[w.Namespace] = list.Items
+		}
+
+		pods, err := rc.listPods(ctx, *w)
+		if err != nil {
+			log.WithError(err).Warn("Failed to list pods, not scoring crash history")
+		}
+
+		w.RiskFactors = riskFactors(*w, pdbs[w.Namespace], pods)
+		w.Risk = 0
+		for _, factor := range w.RiskFactors {
+			w.Risk += riskWeights[factor]
+		}
+		if w.Risk > 0 {
+			log.WithFields(riskFields(*w)).Debugf("Scored %s restart risk", w.Kind)
+		}
+	}
+}
+
+// riskFactors returns the names of the risk factors that apply to w.
+func riskFactors(w workload, pdbs []policyv1.PodDisruptionBudget, pods []corev1.Pod) []string {
+	var factors []string
+
+	if w.Kind != "daemonset" {
+		switch w.Replicas {
+		case 1:
+			factors = append(factors, "single-replica")
+		case 2:
+			factors = append(factors, "two-replicas")
+		}
+	}
+
+	for _, pdb := range pdbs {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		if selector.Matches(labels.Set(w.Template.Labels)) && pdb.Status.DisruptionsAllowed == 0 {
+			factors = append(factors, "no-pdb-slack")
+			break
+		}
+	}
+
+	switch priority := w.Template.Spec.PriorityClassName; {
+	case strings.HasPrefix(priority, "system-"):
+		factors = append(factors, "critical-priority")
+	case priority != "":
+		factors = append(factors, "priority-class")
+	}
+
+	if w.Kind == "statefulset" {
+		factors = append(factors, "stateful")
+	}
+
+	for _, pod := range pods {
+		if slices.ContainsFunc(pod.Status.ContainerStatuses, func(s corev1.ContainerStatus) bool { return s.RestartCount > 0 }) {
+			factors = append(factors, "crash-history")
+			break
+		}
+	}
+	return factors
+}
+
+func riskFields(w workload) Fields {
+	return Fields{
+		"risk":         w.Risk,
+		"risk_factors": strings.Join(w.RiskFactors, ","),
+	}
+}
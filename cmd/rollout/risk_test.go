@@ -0,0 +1,179 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func TestRiskFactors(t *testing.T) {
+	pdb := func(selector *metav1.LabelSelector, allowed int32) policyv1.PodDisruptionBudget {
+		return policyv1.PodDisruptionBudget{
+			ObjectMeta: testMeta("web"),
+			Spec:       policyv1.PodDisruptionBudgetSpec{Selector: selector},
+			Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: allowed},
+		}
+	}
+	pod := func(restarts int32) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "web", RestartCount: restarts}}}}
+	}
+
+	tests := []struct {
+		name     string
+		kind     string
+		replicas int32
+		priority string
+		pdbs     []policyv1.PodDisruptionBudget
+		pods     []corev1.Pod
+		want     []string
+	}{
+		{
+			name:     "no risk",
+			kind:     "deployment",
+			replicas: 3,
+			pdbs:     []policyv1.PodDisruptionBudget{pdb(testSelector, 1)},
+			pods:     []corev1.Pod{pod(0)},
+		},
+		{
+			name:     "single replica",
+			kind:     "deployment",
+			replicas: 1,
+			want:     []string{"single-replica"},
+		},
+		{
+			name:     "two replicas",
+			kind:     "deployment",
+			replicas: 2,
+			want:     []string{"two-replicas"},
+		},
+		{
+			name:     "daemonsets aren't scored by replicas",
+			kind:     "daemonset",
+			replicas: 1,
+		},
+		{
+			name:     "budget without slack",
+			kind:     "deployment",
+			replicas: 3,
+			pdbs:     []policyv1.PodDisruptionBudget{pdb(testSelector, 0), pdb(testSelector, 0)},
+			want:     []string{"no-pdb-slack"},
+		},
+		{
+			name:     "budget of other pods",
+			kind:     "deployment",
+			replicas: 3,
+			pdbs:     []policyv1.PodDisruptionBudget{pdb(&metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}, 0)},
+		},
+		{
+			name:     "empty budget selector",
+			kind:     "deployment",
+			replicas: 3,
+			pdbs:     []policyv1.PodDisruptionBudget{pdb(&metav1.LabelSelector{}, 0)},
+		},
+		{
+			name:     "system priority",
+			kind:     "deployment",
+			replicas: 3,
+			priority: "system-cluster-critical",
+			want:     []string{"critical-priority"},
+		},
+		{
+			name:     "other priority",
+			kind:     "deployment",
+			replicas: 3,
+			priority: "high",
+			want:     []string{"priority-class"},
+		},
+		{
+			name:     "stateful",
+			kind:     "statefulset",
+			replicas: 3,
+			want:     []string{"stateful"},
+		},
+		{
+			name:     "crash history",
+			kind:     "deployment",
+			replicas: 3,
+			pods:     []corev1.Pod{pod(0), pod(2), pod(1)},
+			want:     []string{"crash-history"},
+		},
+		{
+			name:     "every factor",
+			kind:     "statefulset",
+			replicas: 1,
+			priority: "system-node-critical",
+			pdbs:     []policyv1.PodDisruptionBudget{pdb(testSelector, 0)},
+			pods:     []corev1.Pod{pod(5)},
+			want:     []string{"single-replica", "no-pdb-slack", "critical-priority", "stateful", "crash-history"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := testTemplate.DeepCopy()
+			template.Spec.PriorityClassName = tt.priority
+			w := workload{Kind: tt.kind, Namespace: "default", Name: "web", Replicas: tt.replicas, Template: template}
+
+			if got := riskFactors(w, tt.pdbs, tt.pods); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssessRisk(t *testing.T) {
+	template := *testTemplate.DeepCopy()
+	template.Spec.PriorityClassName = "system-cluster-critical"
+	objects := []runtime.Object{
+		&appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](1), Selector: testSelector, Template: template}},
+		&policyv1.PodDisruptionBudget{ObjectMeta: testMeta("web"), Spec: policyv1.PodDisruptionBudgetSpec{Selector: testSelector}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", Labels: testLabels},
+			Status:     corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "web", RestartCount: 3}}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		// forbidden are the resources listing is refused for
+		forbidden   []string
+		wantRisk    int
+		wantFactors []string
+	}{
+		{
+			name:        "sum of the factors",
+			wantRisk:    30 + 25 + 20 + 10,
+			wantFactors: []string{"single-replica", "no-pdb-slack", "critical-priority", "crash-history"},
+		},
+		{
+			name:        "unreadable budgets and pods are no risk",
+			forbidden:   []string{"poddisruptionbudgets", "pods"},
+			wantRisk:    30 + 20,
+			wantFactors: []string{"single-replica", "critical-priority"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, cs, w := newTestClient(t, objects, "web")
+			for _, resource := range tt.forbidden {
+				cs.PrependReactor("list", resource, func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("forbidden")
+				})
+			}
+
+			workloads := []workload{w}
+			rc.assessRisk(context.Background(), workloads)
+			if workloads[0].Risk != tt.wantRisk || !slices.Equal(workloads[0].RiskFactors, tt.wantFactors) {
+				t.Errorf("got risk %d from %v, want %d from %v", workloads[0].Risk, workloads[0].RiskFactors, tt.wantRisk, tt.wantFactors)
+			}
+		})
+	}
+}
@@ -2,17 +2,23 @@ package rollout
 
 import (
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/sirupsen/logrus"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
 )
 
 // Run executes a graceful rolling restart of all Kubernetes workloads (Deployments, StatefulSets, and DaemonSets)
-// that contain the podFilter string in their name across all namespaces in the cluster.
+// that contain the podFilter string in their name across all namespaces in the cluster, optionally only those
+// matching a label selector (see WithLabelSelector) or owned by a team (see WithTeam).
 //
 // The restart is performed by updating the pod template annotation with a timestamp, which triggers
 // Kubernetes to perform a rolling update of the pods - similar to 'kubectl rollout restart'.
@@ -20,227 +26,556 @@ import (
 // The function will:
 //   - List and iterate through all namespaces in the cluster
 //   - For each namespace, identify Deployments, StatefulSets, and DaemonSets matching the podFilter
+//   - Score the restart risk of each matched workload and order them lowest risk first (see WithOrder)
 //   - Apply a restart annotation to trigger a graceful rollout
 //   - Track success/failure metrics for each resource type
 //   - Continue processing even if individual resources fail to restart
+//   - Stop scheduling new restarts once ctx is cancelled, letting any in-flight update finish
 //
 // Errors during restart of individual resources are logged but don't stop the overall process.
 // Only critical errors (like inability to list namespaces) will cause the function to return early.
+// A cancelled run is recorded as such in the summary, with whatever was restarted before the
+// cancellation reported as partial results. The summary and the returned error carry the cancellation
+// cause (see context.Cause), so a user interrupt can be told apart from a timeout.
+//
+// When only particular pods are selected (see WithPods and WithCordonedNodesOnly) those pods are evicted, respecting
+// PodDisruptionBudgets, instead of the whole workload being rolled. With WithContainerRestart only the
+// named containers are restarted in place.
+//
+// Workloads scoring at or above the risk threshold (see WithRiskThreshold) are skipped, so high risk
+// restarts have to be confirmed explicitly by running them with a higher threshold.
+//
+// Workloads whose pods keep data in local volumes (hostPaths, large or in-memory emptyDirs) are skipped
+// unless confirmed with WithLocalDataConfirmed, since that data is lost on restart.
+//
+// With WithOperatorHandlers, workloads managed by a supported database operator are restarted through the
+// operator's own mechanism, so it sequences the pods.
+//
+// With WithTiers namespaces are restarted tier by tier, e.g. dev before staging before prod, and the run
+// stops at the first tier whose restarts fail or don't roll out in time, leaving the later tiers untouched.
+//
+// With WithWaitForRollout each restart waits for the workload to finish rolling out, recording it as failed
+// when it doesn't in time, instead of moving on as soon as the restart annotation is applied.
+//
+// With WithInterval restarts are spaced out, give or take a random jitter, rather than following each other
+// right away.
+//
+// With WithServerCheck the run first checks the cluster runs a supported Kubernetes version serving the APIs
+// it uses, failing right away when it doesn't.
+//
+// With WithAccessCheck the run first checks it has the permissions it needs, failing right away, before
+// anything is changed, when it doesn't.
+//
+// With WithMaxErrors the run is aborted once that many workloads have failed to restart, leaving the rest
+// alone.
+//
+// With WithDryRun nothing is changed, the workloads the run would restart are recorded as ActionDryRun.
+//
+// With WithPodSpecDiff each restart waits for the workload's first new pod and records how its spec differs
+// from the pods it replaces.
+//
+// When a policy is configured (see WithPolicy) it is consulted for every matched workload and can deny
+// its restart or choose how it is restarted.
+//
+// Workloads whose rollout would take down more pods at once than their PodDisruptionBudget allows are warned
+// about, with WithRespectPDB they are skipped.
+//
+// Workloads whose rolling update surge doesn't fit in their namespace's ResourceQuota headroom are skipped,
+// with the reason in their result, instead of being left half-rolled.
+//
+// Workloads annotated with a pre-restart backup (a Job created from a CronJob, or a backup API to call)
+// are only restarted once the backup has succeeded. With WithVolumeSnapshots the volumes of StatefulSets
+// are snapshotted before they are restarted.
+//
+// When a checkpoint is configured (see WithCheckpoint) a cancelled run acts as a pause: every
+// workload restarted so far is recorded, and running again with the same checkpoint resumes the
+// run, skipping those workloads. The checkpoint is removed once a run completes.
 //
 // On completion, a summary is logged showing:
 //   - Total number of resources restarted by type
 //   - Number of namespaces processed
-//   - Any errors encountered
+//   - Any errors encountered, identical errors of several resources aggregated into one line
+//   - How many workloads need attention, e.g. skipped for safety or paused (see Warnings)
 //   - Total execution time
 //
+// The same summary is returned as a Report, with the result of every resource and tallies per namespace
+// and kind, so callers can act on the outcome without parsing logs. It is returned even when the run fails
+// or is cancelled, holding the partial results. Use RunWithCallbacks to be notified of each resource as it
+// is handled.
+//
 // Example usage:
 //
 //	rc := rollout.NewRolloutClient(clientset, "database", logger)
-//	err := rc.Run(context.Background())
-func (rc *rolloutClient) Run(ctx context.Context) error {
-	rc.metadata = &rolloutMetadata{
-		StartTime: time.Now(),
+//	report, err := rc.Run(context.Background())
+func (rc *rolloutClient) Run(ctx context.Context) (*Report, error) {
+	return rc.RunWithCallbacks(ctx, Callbacks{})
+}
+
+func (rc *rolloutClient) run(ctx context.Context) error {
+	rc.startRecording(&rolloutMetadata{
+		RunID:     newRunID(),
+		StartTime: rc.clock.Now(),
 		Errors:    []error{},
+	})
+	ctx = withLogger(ctx, rc.log.WithField("run_id", rc.metadata.RunID))
+	log := rc.logger(ctx)
+
+	if rc.checkpointPath != "" {
+		cp, err := loadCheckpoint(rc.checkpointFS, rc.checkpointPath)
+		if err != nil {
+			return err
+		}
+		if len(cp.done) > 0 {
+			log.WithFields(Fields{
+				"checkpoint": rc.checkpointPath,
+				"completed":  len(cp.done),
+			}).Info("Resuming paused rollout from checkpoint")
+		}
+		rc.checkpoint = cp
+	}
+
+	// A dry run changes nothing, it can show what a run would do while restarts are frozen
+	if rc.dryRun == DryRunNone {
+		if err := rc.checkFreeze(ctx); err != nil {
+			log.WithError(err).Error("Refusing to restart")
+			return err
+		}
+	}
+	if rc.serverCheck {
+		if err := rc.checkServer(ctx); err != nil {
+			log.WithError(err).Error("Refusing to restart")
+			return err
+		}
+	}
+	if rc.accessCheck {
+		if err := rc.checkAccess(ctx); err != nil {
+			log.WithError(err).Error("Refusing to restart")
+			return err
+		}
 	}
 
-	namespaces, err := rc.cs.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	ctx, rc.abort = context.WithCancelCause(ctx)
+	defer rc.abort(nil)
+
+	namespaces, err := rc.namespaces(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to list namespaces: %w", err)
+		return err
 	}
 
-	// Process each namespace
-	for _, ns := range namespaces.Items {
-		rc.metadata.NamespacesProcessed++
-		rc.log.WithField("namespace", ns.Name).Info("Checking namespace")
+	var halted error
+	tiers := rc.groupByTier(namespaces)
+	for i, tier := range tiers {
+		if ctx.Err() != nil {
+			break
+		}
 
-		// Restart deployments with podFilter
-		deploymentCount, err := rc.restartDeployments(ctx, ns.Name)
-		if err != nil {
-			rc.metadata.Errors = append(rc.metadata.Errors, fmt.Errorf("deployments in %s: %w", ns.Name, err))
-			rc.log.WithFields(logrus.Fields{
-				"namespace": ns.Name,
-				"error":     err,
-			}).Error("Failed to restart deployments")
-		} else {
-			rc.metadata.DeploymentsRestarted += deploymentCount
+		tierCtx := ctx
+		if tier.name != "" {
+			tierCtx = withLogger(ctx, log.WithField("tier", tier.name))
+			rc.logger(tierCtx).Info("Starting tier")
 		}
 
-		// Restart statefulsets with podFilter
-		statefulSetCount, err := rc.restartStatefulSets(ctx, ns.Name)
+		firstResult := len(rc.metadata.Results)
+		restarted, err := rc.restartTier(tierCtx, tier.namespaces)
 		if err != nil {
-			rc.metadata.Errors = append(rc.metadata.Errors, fmt.Errorf("statefulsets in %s: %w", ns.Name, err))
-			rc.log.WithFields(logrus.Fields{
-				"namespace": ns.Name,
-				"error":     err,
-			}).Error("Failed to restart statefulsets")
-		} else {
-			rc.metadata.StatefulSetsRestarted += statefulSetCount
+			halted = err
+			rc.addError(err)
+			break
 		}
 
-		// Restart daemonsets with podFilter
-		daemonSetCount, err := rc.restartDaemonSets(ctx, ns.Name)
-		if err != nil {
-			rc.metadata.Errors = append(rc.metadata.Errors, fmt.Errorf("daemonsets in %s: %w", ns.Name, err))
-			rc.log.WithFields(logrus.Fields{
-				"namespace": ns.Name,
-				"error":     err,
-			}).Error("Failed to restart daemonsets")
-		} else {
-			rc.metadata.DaemonSetsRestarted += daemonSetCount
+		// Later tiers only start once this one has verifiably rolled out
+		if i < len(tiers)-1 && ctx.Err() == nil {
+			if err := rc.gateTier(tierCtx, tier.name, rc.metadata.Results[firstResult:], restarted); err != nil {
+				halted = err
+				rc.addError(err)
+				break
+			}
 		}
 	}
 
+	if ctx.Err() != nil {
+		rc.setCancelled(context.Cause(ctx))
+	}
+
 	// Log summary with metadata
-	rc.log.WithFields(logrus.Fields{
+	summary := log.WithFields(Fields{
 		"total_restarted":    rc.metadata.totalRestarted(),
+		"failed":             rc.metadata.countResults(ActionFailed),
 		"deployments":        rc.metadata.DeploymentsRestarted,
 		"statefulsets":       rc.metadata.StatefulSetsRestarted,
 		"daemonsets":         rc.metadata.DaemonSetsRestarted,
+		"rollouts":           rc.metadata.RolloutsRestarted,
+		"cronjobs":           rc.metadata.CronJobsRestarted,
+		"jobs":               rc.metadata.JobsRestarted,
 		"namespaces_checked": rc.metadata.NamespacesProcessed,
 		"errors_count":       len(rc.metadata.Errors),
-		"duration":           rc.metadata.duration().String(),
-	}).Info("Rollout completed")
+		"warnings_count":     len(rc.metadata.Warnings),
+		"duration":           rc.clock.Since(rc.metadata.StartTime).String(),
+		"cancelled":          rc.metadata.Cancelled,
+		"cancel_reason":      rc.metadata.CancelReason,
+		"skipped_resumed":    rc.metadata.ResumedSkipped,
+	})
+	rc.logErrorGroups(log)
+	if rc.metadata.Cancelled {
+		aborted := errors.Is(context.Cause(ctx), ErrTooManyFailures)
+		if aborted {
+			summary.Error("Rollout aborted after too many failed restarts, summary contains partial results")
+		} else {
+			summary.Warn("Rollout cancelled, summary contains partial results")
+		}
+		if rc.checkpoint != nil {
+			log.WithField("checkpoint", rc.checkpointPath).Info("Rollout paused, run again with the same checkpoint to resume")
+		}
+		if aborted {
+			return fmt.Errorf("rollout aborted: %w", context.Cause(ctx))
+		}
+		return fmt.Errorf("rollout cancelled: %w", context.Cause(ctx))
+	}
+	if halted != nil {
+		summary.WithError(halted).Error("Rollout halted at a verification gate, the remaining workloads were not restarted")
+		return fmt.Errorf("rollout halted: %w", halted)
+	}
+
+	var failures error
+	if rc.failOnErrors {
+		failures = rc.failures()
+	}
+	if rc.dryRun != DryRunNone {
+		summary.WithField("dry_run", rc.dryRun).Info("Rollout dry run completed, nothing was changed")
+		return failures
+	}
+
+	if rc.checkpoint != nil {
+		if err := rc.checkpoint.clear(); err != nil {
+			log.WithError(err).Warn("Failed to remove checkpoint")
+		}
+	}
+
+	if failures != nil {
+		summary.Error("Rollout completed with failures")
+		return failures
+	}
+	summary.Info("Rollout completed")
 	return nil
 }
 
+// restartTier restarts the matching workloads of namespaces in the configured order (see WithOrder), and
+// returns the ones that were restarted. With WithCanary the canaries go first, and an error is returned,
+// leaving the other workloads alone, when they fail.
+func (rc *rolloutClient) restartTier(ctx context.Context, namespaces []corev1.Namespace) ([]workload, error) {
+	// Collect the matching workloads of each namespace
+	perNamespace := make([][]workload, len(namespaces))
+	rc.parallel(ctx, len(namespaces), func(i int) {
+		rc.mu.Lock()
+		rc.metadata.NamespacesProcessed++
+		rc.mu.Unlock()
+
+		nsCtx := withLogger(ctx, rc.logger(ctx).WithField("namespace", namespaces[i].Name))
+		if reason, frozen := namespaceFrozen(namespaces[i].Annotations); frozen {
+			rc.logger(nsCtx).WithField("reason", reason).Warn("Restarts in the namespace are frozen, skipping it")
+			rc.addWarning(workload{Namespace: namespaces[i].Name}, WarningFrozen, "namespace frozen: "+reason)
+			return
+		}
+		rc.logger(nsCtx).Info("Checking namespace")
+		perNamespace[i] = rc.candidates(nsCtx, namespaces[i].Name)
+	})
+	candidates := slices.Concat(perNamespace...)
+	rc.addMatched(len(candidates))
+
+	rc.assessRisk(ctx, candidates)
+	rc.sortWorkloads(candidates, namespaces)
+
+	var restarted []workload
+	if rc.canary != nil {
+		var canaries []workload
+		canaries, candidates = rc.canary.split(candidates)
+		firstResult := len(rc.metadata.Results)
+		restarted = rc.restartAll(ctx, canaries)
+		if err := rc.gateCanaries(ctx, rc.metadata.Results[firstResult:], restarted); err != nil {
+			return restarted, err
+		}
+	}
+	return append(restarted, rc.restartAll(ctx, candidates)...), nil
+}
+
+// restartAll restarts candidates in order, several namespaces at once with WithConcurrency or in batches
+// with WithAdaptiveBatches, and returns the ones that were restarted.
+func (rc *rolloutClient) restartAll(ctx context.Context, candidates []workload) []workload {
+	if rc.batches != nil && rc.rolloutTimeout > 0 {
+		return rc.restartInBatches(ctx, candidates)
+	}
+	if rc.concurrency > 1 {
+		return rc.restartConcurrently(ctx, candidates)
+	}
+
+	var restarted []workload
+	for _, w := range candidates {
+		// Stop scheduling new restarts once the run has been cancelled
+		if ctx.Err() != nil {
+			break
+		}
+		if rc.restart(ctx, w) {
+			rc.mu.Lock()
+			rc.metadata.addRestarted(w.Kind, 1)
+			rc.mu.Unlock()
+			restarted = append(restarted, w)
+		}
+	}
+	return restarted
+}
+
 // NewRolloutClient creates a new rolloutClient instance for performing rolling restarts of Kubernetes workloads.
-func NewRolloutClient(clientset *kubernetes.Clientset, podFilter string, logger logrus.FieldLogger) *rolloutClient {
-	return &rolloutClient{
-		podFilter: podFilter,
-		cs:        clientset,
-		log:       logger,
+// Workload names are matched against the podFilter case-insensitively.
+func NewRolloutClient(clientset kubernetes.Interface, podFilter string, logger Logger, opts ...Option) *rolloutClient {
+	rc := &rolloutClient{
+		podFilter:       strings.ToLower(podFilter),
+		ownerKeys:       DefaultOwnerKeys,
+		requestTimeout:  DefaultRequestTimeout,
+		backupTimeout:   DefaultBackupTimeout,
+		tierGateTimeout: DefaultTierGateTimeout,
+		order:           OrderRisk,
+		concurrency:     1,
+		fieldManager:    DefaultFieldManager,
+		clock:           clock.RealClock{},
+		cs:              clientset,
+		log:             logger,
+	}
+	for _, opt := range opts {
+		opt(rc)
+	}
+	return rc
+}
+
+// DefaultRequestTimeout bounds each individual API call made during a run.
+const DefaultRequestTimeout = 30 * time.Second
+
+// Option configures optional rolloutClient behaviour.
+type Option func(*rolloutClient)
+
+// WithRequestTimeout bounds every individual List/Update call, so a single hanging API request can't stall
+// the whole run even when ctx has no deadline. A timeout <= 0 leaves calls bounded only by ctx.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(rc *rolloutClient) {
+		rc.requestTimeout = timeout
+	}
+}
+
+// WithCheckpoint records restarted workloads to the file at path on fsys, allowing a cancelled run to be
+// paused and later resumed from where it stopped.
+func WithCheckpoint(fsys CheckpointFS, path string) Option {
+	return func(rc *rolloutClient) {
+		rc.checkpointFS, rc.checkpointPath = fsys, path
+	}
+}
+
+// WithProvenanceSigning stamps a provenance annotation (initiator, reason and target) on every
+// restarted pod template, signed with key so downstream policy can verify the restart was made by
+// this tool.
+func WithProvenanceSigning(key ed25519.PrivateKey, initiator, reason string) Option {
+	return func(rc *rolloutClient) {
+		rc.signer = &provenanceSigner{
+			key:       key,
+			initiator: initiator,
+			reason:    reason,
+		}
+	}
+}
+
+// WithClusterName sets the cluster name recorded against each resource in the run results.
+func WithClusterName(name string) Option {
+	return func(rc *rolloutClient) {
+		rc.clusterName = name
+	}
+}
+
+// WithTimeZone adds the start time of the run in loc to its Report, for audiences reading local times. The
+// report's StartTime and the restartedAt annotations stay in UTC. A nil loc leaves reports in UTC only.
+func WithTimeZone(loc *time.Location) Option {
+	return func(rc *rolloutClient) {
+		rc.timeZone = loc
+	}
+}
+
+// WithOwnerKeys sets the workload annotation/label keys the service owner is resolved from for
+// the run results, checked in order. Defaults to DefaultOwnerKeys.
+func WithOwnerKeys(keys ...string) Option {
+	return func(rc *rolloutClient) {
+		rc.ownerKeys = keys
+	}
+}
+
+// WithTeam restricts the run to workloads owned by team, as resolved from the owner keys (see WithOwnerKeys),
+// in addition to the podFilter.
+func WithTeam(team string) Option {
+	return func(rc *rolloutClient) {
+		rc.team = team
 	}
 }
 
 type rolloutClient struct {
-	podFilter string
+	podFilter         string
+	includeNamespaces []string
+	excludeNamespaces []string
+	excludeNames      []string
+	labelSelector     string
+	team              string
+	checkpointFS      CheckpointFS
+	checkpointPath    string
+	clusterName       string
+	timeZone          *time.Location
+	ownerKeys         []string
+	requestTimeout    time.Duration
 
-	cs       *kubernetes.Clientset
-	log      logrus.FieldLogger
-	metadata *rolloutMetadata
+	cs         kubernetes.Interface
+	clock      clock.WithTicker
+	log        Logger
+	metadata   *rolloutMetadata
+	checkpoint *checkpoint
+	signer     *provenanceSigner
+	callbacks  Callbacks
+	pickPods   podPicker
+	strategy   RestartStrategy
+	planned    map[string]bool
+	policy     Policy
+
+	riskThreshold      int
+	localDataConfirmed bool
+	respectPDB         bool
+	stampAnnotations   map[string]string
+	stampLabels        map[string]string
+	backupTimeout      time.Duration
+	snapshots          *snapshotter
+	operators          dynamic.Interface
+	argoRollouts       dynamic.Interface
+	cronJobs           bool
+	recreateJobs       bool
+	relatedObjects     bool
+	podDiffTimeout     time.Duration
+	tiers              []Tier
+	tierGateTimeout    time.Duration
+	order              Order
+	namespaceOrder     NamespaceOrder
+	dryRun             DryRunMode
+	rolloutTimeout     time.Duration
+	concurrency        int
+	fieldManager       string
+	kubectlParity      bool
+	serverSideApply    bool
+	forceApply         bool
+	failOnErrors       bool
+	maxErrors          int
+	freezeNamespace    string
+	freezeName         string
+	freezeWait         time.Duration
+	interval           time.Duration
+	jitter             time.Duration
+	reloadQuietPeriod  time.Duration
+	accessCheck        bool
+	serverCheck        bool
+
+	containerRestart *containerRestarter
+	canary           *canary
+	cache            *WorkloadCache
+	usage            *UsageTracker
+	notifiers        []Notifier
+	batches          *adaptiveBatches
+
+	// nextTurn is when the next restart may start, see WithInterval
+	nextTurn time.Time
+	// abort cancels the current run, see WithMaxErrors
+	abort context.CancelCauseFunc
+
+	// mu guards metadata and serializes callbacks, workloads are restarted concurrently with WithConcurrency
+	mu sync.Mutex
 }
 
 type rolloutMetadata struct {
+	RunID                 string
 	StartTime             time.Time
+	EndTime               time.Time
 	DeploymentsRestarted  int
 	StatefulSetsRestarted int
 	DaemonSetsRestarted   int
+	RolloutsRestarted     int
+	CronJobsRestarted     int
+	JobsRestarted         int
 	NamespacesProcessed   int
 	Errors                []error
+	Warnings              []Warning
+	Cancelled             bool
+	CancelReason          string
+	ResumedSkipped        int
+	Matched               int
+	Results               []ResourceResult
+	Usage                 *Usage
 }
 
-func (rm *rolloutMetadata) totalRestarted() int {
-	return rm.DeploymentsRestarted + rm.StatefulSetsRestarted + rm.DaemonSetsRestarted
+// requestContext derives the context for a single API call, bounded by the configured request timeout.
+func (rc *rolloutClient) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if rc.requestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, rc.requestTimeout)
 }
 
-func (rm *rolloutMetadata) duration() time.Duration {
-	return time.Since(rm.StartTime)
+// startRecording starts recording a run in metadata, replacing the previous run's.
+func (rc *rolloutClient) startRecording(metadata *rolloutMetadata) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.metadata = metadata
 }
 
-func (rc *rolloutClient) restartDeployments(ctx context.Context, namespace string) (int, error) {
-	deployments, err := rc.cs.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return 0, err
-	}
+// setCancelled records that the run was cancelled, for cause.
+func (rc *rolloutClient) setCancelled(cause error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.metadata.Cancelled = true
+	rc.metadata.CancelReason = cause.Error()
+}
 
-	count := 0
-	for _, deployment := range deployments.Items {
-		if strings.Contains(strings.ToLower(deployment.Name), rc.podFilter) {
-			rc.log.WithFields(logrus.Fields{
-				"namespace":  namespace,
-				"deployment": deployment.Name,
-			}).Info("Restarting deployment")
-
-			// Update the deployment with a new annotation to trigger rollout
-			if deployment.Spec.Template.ObjectMeta.Annotations == nil {
-				deployment.Spec.Template.ObjectMeta.Annotations = make(map[string]string)
-			}
-			deployment.Spec.Template.ObjectMeta.Annotations["kubectl.kubernetes.io/restartedAt"] = time.Now().Format(time.RFC3339)
-
-			_, err := rc.cs.AppsV1().Deployments(namespace).Update(ctx, &deployment, metav1.UpdateOptions{})
-			if err != nil {
-				rc.log.WithFields(logrus.Fields{
-					"namespace":  namespace,
-					"deployment": deployment.Name,
-					"error":      err,
-				}).Error("Failed to restart deployment")
-				continue
-			}
+// setEnded records that the run has ended, having used usage.
+func (rc *rolloutClient) setEnded(usage *Usage) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.metadata.EndTime = rc.clock.Now()
+	rc.metadata.Usage = usage
+}
 
-			count++
-		}
-	}
-	return count, nil
+// addError records a run error.
+func (rc *rolloutClient) addError(err error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.metadata.Errors = append(rc.metadata.Errors, err)
 }
 
-func (rc *rolloutClient) restartStatefulSets(ctx context.Context, namespace string) (int, error) {
-	statefulSets, err := rc.cs.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return 0, err
+func (rm *rolloutMetadata) addRestarted(kind string, count int) {
+	switch kind {
+	case "deployment":
+		rm.DeploymentsRestarted += count
+	case "statefulset":
+		rm.StatefulSetsRestarted += count
+	case "daemonset":
+		rm.DaemonSetsRestarted += count
+	case argoRolloutKind:
+		rm.RolloutsRestarted += count
+	case cronJobKind:
+		rm.CronJobsRestarted += count
+	case jobKind:
+		rm.JobsRestarted += count
 	}
+}
 
+func (rm *rolloutMetadata) countResults(action string) int {
 	count := 0
-	for _, sts := range statefulSets.Items {
-		if strings.Contains(strings.ToLower(sts.Name), rc.podFilter) {
-			rc.log.WithFields(logrus.Fields{
-				"namespace":   namespace,
-				"statefulset": sts.Name,
-			}).Info("Restarting statefulset")
-
-			// Update the statefulset with a new annotation to trigger rollout
-			if sts.Spec.Template.ObjectMeta.Annotations == nil {
-				sts.Spec.Template.ObjectMeta.Annotations = make(map[string]string)
-			}
-			sts.Spec.Template.ObjectMeta.Annotations["kubectl.kubernetes.io/restartedAt"] = time.Now().Format(time.RFC3339)
-
-			_, err := rc.cs.AppsV1().StatefulSets(namespace).Update(ctx, &sts, metav1.UpdateOptions{})
-			if err != nil {
-				rc.log.WithFields(logrus.Fields{
-					"namespace":   namespace,
-					"statefulset": sts.Name,
-					"error":       err,
-				}).Error("Failed to restart statefulset")
-				continue
-			}
-
+	for _, r := range rm.Results {
+		if r.Action == action {
 			count++
 		}
 	}
-	return count, nil
+	return count
 }
 
-func (rc *rolloutClient) restartDaemonSets(ctx context.Context, namespace string) (int, error) {
-	daemonSets, err := rc.cs.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return 0, err
-	}
-
-	count := 0
-	for _, ds := range daemonSets.Items {
-		if strings.Contains(strings.ToLower(ds.Name), rc.podFilter) {
-			rc.log.WithFields(logrus.Fields{
-				"namespace": namespace,
-				"daemonset": ds.Name,
-			}).Info("Restarting daemonset")
-
-			// Update the daemonset with a new annotation to trigger rollout
-			if ds.Spec.Template.ObjectMeta.Annotations == nil {
-				ds.Spec.Template.ObjectMeta.Annotations = make(map[string]string)
-			}
-			ds.Spec.Template.ObjectMeta.Annotations["kubectl.kubernetes.io/restartedAt"] = time.Now().Format(time.RFC3339)
-
-			_, err := rc.cs.AppsV1().DaemonSets(namespace).Update(ctx, &ds, metav1.UpdateOptions{})
-			if err != nil {
-				rc.log.WithFields(logrus.Fields{
-					"namespace": namespace,
-					"daemonset": ds.Name,
-					"error":     err,
-				}).Error("Failed to restart daemonset")
-				continue
-			}
-
-			count++
-		}
-	}
-	return count, nil
+func (rm *rolloutMetadata) totalRestarted() int {
+	return rm.DeploymentsRestarted + rm.StatefulSetsRestarted + rm.DaemonSetsRestarted + rm.RolloutsRestarted +
+		rm.CronJobsRestarted + rm.JobsRestarted
 }
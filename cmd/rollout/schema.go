@@ -0,0 +1,133 @@
+package rollout
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Plans and reports written as JSON or YAML carry the version of their format in schemaVersion, so tools
+// reading them can tell a format they understand from one they don't. Within a version fields are only ever
+// added to reports, a change to an existing field or to plans, which are read back strictly, gets a new
+// version.
+const (
+	PlanSchemaVersion   = "v1"
+	ReportSchemaVersion = "v1"
+)
+
+// PlanSchema and ReportSchema are the JSON Schemas of the current plan and report formats.
+var (
+	//go:embed schemas/plan.v1.json
+	PlanSchema []byte
+	//go:embed schemas/report.v1.json
+	ReportSchema []byte
+)
+
+// ErrUnsupportedSchema is returned when reading a plan or report of a format this version doesn't know.
+var ErrUnsupportedSchema = errors.New("unsupported schema version")
+
+// plannableKinds are the workload kinds a plan can list.
+var plannableKinds = []string{"deployment", "statefulset", "daemonset", cronJobKind, jobKind, argoRolloutKind}
+
+// relatedKinds are the kinds of the objects a plan can list as related to a workload.
+var relatedKinds = []string{"HorizontalPodAutoscaler", "PodDisruptionBudget", "Service", "NetworkPolicy"}
+
+// ParsePlan reads a plan written as JSON or YAML, e.g. by the plan command, and checks it against the plan
+// schema: its schemaVersion has to be PlanSchemaVersion, fields it doesn't know are rejected rather than
+// ignored, and every workload needs a known kind, a namespace and a name, may only be listed once and only
+// be related to objects of known kinds. Every problem found is returned, not just the first.
+func ParsePlan(data []byte) (*RestartPlan, error) {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	// The version is checked first, a plan of another version failing on its fields would be misleading
+	var header struct {
+		SchemaVersion string `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if err := checkSchemaVersion(header.SchemaVersion, PlanSchemaVersion); err != nil {
+		return nil, fmt.Errorf("plan: %w", err)
+	}
+
+	plan := &RestartPlan{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if plan.Workloads == nil {
+		return nil, errors.New("plan has no Workloads")
+	}
+
+	var errs []error
+	listed := map[string]bool{}
+	for i, w := range plan.Workloads {
+		ref := fmt.Sprintf("workload %d", i+1)
+		if w.Namespace != "" && w.Name != "" {
+			ref = fmt.Sprintf("%s (%s %s/%s)", ref, w.Kind, w.Namespace, w.Name)
+		}
+		if !slices.Contains(plannableKinds, w.Kind) {
+			errs = append(errs, fmt.Errorf("%s: unknown kind %q", ref, w.Kind))
+		}
+		if w.Namespace == "" {
+			errs = append(errs, fmt.Errorf("%s: no namespace", ref))
+		}
+		if w.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: no name", ref))
+		}
+		if w.Replicas < 0 {
+			errs = append(errs, fmt.Errorf("%s: negative replicas", ref))
+		}
+		for _, related := range w.Related {
+			if !slices.Contains(relatedKinds, related.Kind) {
+				errs = append(errs, fmt.Errorf("%s: related object %s of unknown kind", ref, related))
+			}
+		}
+		key := checkpointKey(w.Kind, w.Namespace, w.Name)
+		if listed[key] {
+			errs = append(errs, fmt.Errorf("%s: listed more than once", ref))
+		}
+		listed[key] = true
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid plan: %w", errors.Join(errs...))
+	}
+	return plan, nil
+}
+
+// ParseReport reads a report written as JSON, e.g. to the run history. Reports written before they had a
+// schemaVersion are read as the first version, fields added since are ignored.
+func ParseReport(data []byte) (*Report, error) {
+	report := &Report{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, err
+	}
+	if report.SchemaVersion == "" {
+		report.SchemaVersion = ReportSchemaVersion
+	}
+	if err := checkSchemaVersion(report.SchemaVersion, ReportSchemaVersion); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkSchemaVersion checks that version, read from a file, is the supported one.
+func checkSchemaVersion(version, supported string) error {
+	switch version {
+	case supported:
+		return nil
+	case "":
+		return fmt.Errorf("%w: no schemaVersion, expected %q", ErrUnsupportedSchema, supported)
+	default:
+		return fmt.Errorf("%w %q, expected %q", ErrUnsupportedSchema, version, supported)
+	}
+}
@@ -0,0 +1,309 @@
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with the golden file testdata/name, rewriting it instead with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to write it", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s is out of date, got\n%s\nrun the tests with -update if the change is intended", path, got)
+	}
+}
+
+// validateSchema checks value, decoded from JSON, against schema, a JSON Schema whose $refs point into the
+// $defs of root. Only the keywords the plan and report schemas use are supported: type, const, enum,
+// required, properties, additionalProperties, items, $ref, minLength and minimum.
+func validateSchema(root, schema map[string]any, value any, path string) []error {
+	if ref, ok := schema["$ref"].(string); ok {
+		name, found := strings.CutPrefix(ref, "#/$defs/")
+		def, defined := root["$defs"].(map[string]any)[name].(map[string]any)
+		if !found || !defined {
+			return []error{fmt.Errorf("%s: unresolvable $ref %q", path, ref)}
+		}
+		return validateSchema(root, def, value, path)
+	}
+
+	var errs []error
+	if want, ok := schema["type"]; ok {
+		types, isList := want.([]any)
+		if !isList {
+			types = []any{want}
+		}
+		if !slices.ContainsFunc(types, func(t any) bool { return hasType(value, t.(string)) }) {
+			return []error{fmt.Errorf("%s: %v isn't of type %v", path, value, want)}
+		}
+	}
+	if want, ok := schema["const"]; ok && value != want {
+		errs = append(errs, fmt.Errorf("%s: %v isn't %v", path, value, want))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		errs = append(errs, fmt.Errorf("%s: %v isn't one of %v", path, value, enum))
+	}
+	if minLength, ok := schema["minLength"].(float64); ok {
+		if s, isString := value.(string); isString && float64(len(s)) < minLength {
+			errs = append(errs, fmt.Errorf("%s: %q is shorter than %v", path, s, minLength))
+		}
+	}
+	if minimum, ok := schema["minimum"].(float64); ok {
+		if n, isNumber := value.(float64); isNumber && n < minimum {
+			errs = append(errs, fmt.Errorf("%s: %v is less than %v", path, n, minimum))
+		}
+	}
+
+	if object, ok := value.(map[string]any); ok {
+		required, _ := schema["required"].([]any)
+		for _, field := range required {
+			if _, set := object[field.(string)]; !set {
+				errs = append(errs, fmt.Errorf("%s: %s is required", path, field))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for field, v := range object {
+			if property, known := properties[field]; known {
+				errs = append(errs, validateSchema(root, property.(map[string]any), v, path+"."+field)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, fmt.Errorf("%s: unknown field %s", path, field))
+				}
+			case map[string]any:
+				errs = append(errs, validateSchema(root, additional, v, path+"."+field)...)
+			}
+		}
+	}
+	if array, ok := value.([]any); ok {
+		if items, hasItems := schema["items"].(map[string]any); hasItems {
+			for i, v := range array {
+				errs = append(errs, validateSchema(root, items, v, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+// hasType reports whether value, decoded from JSON, is of the JSON Schema type t.
+func hasType(value any, t string) bool {
+	switch v := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || (t == "integer" && v == math.Trunc(v))
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
+
+// checkSchema checks the JSON document data against schema.
+func checkSchema(schema, data []byte) error {
+	var root map[string]any
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return errors.Join(validateSchema(root, root, value, "$")...)
+}
+
+// goldenPlan returns the plan of a namespace with a deployment noted for a changed LimitRange default and
+// related to an autoscaler, a budget and a service, and a statefulset.
+func goldenPlan(t *testing.T) *RestartPlan {
+	t.Helper()
+	template := *testTemplate.DeepCopy()
+	template.Spec.Containers = []corev1.Container{{Name: "web", Image: "nginx"}}
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](2), Selector: testSelector, Template: template}},
+		&appsv1.StatefulSet{ObjectMeta: testMeta("web-db"), Spec: appsv1.StatefulSetSpec{Replicas: ptr.To[int32](1), Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", Labels: testLabels}, Spec: template.Spec},
+		&corev1.LimitRange{ObjectMeta: testMeta("defaults"), Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+			Type: corev1.LimitTypeContainer, DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+		}}}},
+		&autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: testMeta("web"), Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+		}},
+		&policyv1.PodDisruptionBudget{ObjectMeta: testMeta("web"), Spec: policyv1.PodDisruptionBudgetSpec{Selector: testSelector, MaxUnavailable: ptr.To(intstr.FromInt32(1))}},
+		&corev1.Service{ObjectMeta: testMeta("web"), Spec: corev1.ServiceSpec{Selector: testLabels}},
+	}
+
+	plan, err := Plan(context.Background(), fake.NewSimpleClientset(objects...), "web", WithRelatedObjects())
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	return plan
+}
+
+// goldenReport returns the report of a run restarting one deployment and skipping another for its local
+// data.
+func goldenReport(t *testing.T) *Report {
+	t.Helper()
+	local := *testTemplate.DeepCopy()
+	local.Spec.Volumes = []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/data"}}}}
+	rc, cs, w := newTestClient(t, []runtime.Object{
+		&appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](2), Selector: testSelector, Template: testTemplate}},
+		&appsv1.Deployment{ObjectMeta: testMeta("cache"), Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](1), Selector: testSelector, Template: local}},
+	}, "web", WithClusterName("test"))
+	workloads, err := kindAccessors(cs)[0].list(context.Background(), "default", metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc.restart(context.Background(), w)
+	for _, other := range workloads {
+		if other.Name == "cache" {
+			rc.restart(context.Background(), other)
+		}
+	}
+	rc.metadata.EndTime = testNow
+	return rc.Report()
+}
+
+func TestGoldenFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema []byte
+		// write returns the document written to the golden file
+		write func(t *testing.T) any
+		parse func(data []byte) error
+	}{
+		{
+			name:   "plan.v1.golden",
+			schema: PlanSchema,
+			write:  func(t *testing.T) any { return goldenPlan(t) },
+			parse: func(data []byte) error {
+				_, err := ParsePlan(data)
+				return err
+			},
+		},
+		{
+			name:   "report.v1.golden",
+			schema: ReportSchema,
+			write:  func(t *testing.T) any { return goldenReport(t) },
+			parse: func(data []byte) error {
+				_, err := ParseReport(data)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.MarshalIndent(tt.write(t), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.name, append(data, '\n'))
+
+			if err := checkSchema(tt.schema, data); err != nil {
+				t.Errorf("doesn't match its schema: %v", err)
+			}
+			if err := tt.parse(data); err != nil {
+				t.Errorf("can't be read back: %v", err)
+			}
+		})
+	}
+}
+
+func TestPlanSchemaAgreesWithParsePlan(t *testing.T) {
+	tests := []struct {
+		name string
+		plan string
+		// wantValid is whether both the schema and ParsePlan accept the plan
+		wantValid bool
+	}{
+		{name: "minimal", plan: `{"schemaVersion":"v1","Workloads":[{"Kind":"deployment","Namespace":"default","Name":"web"}]}`, wantValid: true},
+		{name: "no workloads", plan: `{"schemaVersion":"v1","Workloads":[]}`, wantValid: true},
+		{name: "null notes", plan: `{"schemaVersion":"v1","Workloads":[{"Kind":"job","Namespace":"default","Name":"web","Notes":null}]}`, wantValid: true},
+		{name: "other version", plan: `{"schemaVersion":"v2","Workloads":[]}`},
+		{name: "no version", plan: `{"Workloads":[]}`},
+		{name: "no workloads field", plan: `{"schemaVersion":"v1"}`},
+		{name: "unknown field", plan: `{"schemaVersion":"v1","Workloads":[],"Cluster":"prod"}`},
+		{name: "unknown workload field", plan: `{"schemaVersion":"v1","Workloads":[{"Kind":"deployment","Namespace":"default","Name":"web","Risk":3}]}`},
+		{name: "unknown kind", plan: `{"schemaVersion":"v1","Workloads":[{"Kind":"pod","Namespace":"default","Name":"web"}]}`},
+		{name: "empty namespace", plan: `{"schemaVersion":"v1","Workloads":[{"Kind":"deployment","Namespace":"","Name":"web"}]}`},
+		{name: "no name", plan: `{"schemaVersion":"v1","Workloads":[{"Kind":"deployment","Namespace":"default"}]}`},
+		{name: "negative replicas", plan: `{"schemaVersion":"v1","Workloads":[{"Kind":"deployment","Namespace":"default","Name":"web","Replicas":-1}]}`},
+		{name: "unknown related kind", plan: `{"schemaVersion":"v1","Workloads":[{"Kind":"deployment","Namespace":"default","Name":"web","Related":[{"Kind":"Ingress","Name":"web"}]}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schemaErr := checkSchema(PlanSchema, []byte(tt.plan))
+			if (schemaErr == nil) != tt.wantValid {
+				t.Errorf("schema: got %v, want valid: %t", schemaErr, tt.wantValid)
+			}
+			if _, err := ParsePlan([]byte(tt.plan)); (err == nil) != tt.wantValid {
+				t.Errorf("ParsePlan: got %v, want valid: %t", err, tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestParseReportVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  string
+		wantErr error
+	}{
+		{name: "current", report: `{"schemaVersion":"v1","RunID":"abc"}`},
+		{name: "written before versioning", report: `{"RunID":"abc"}`},
+		{name: "unknown fields are ignored", report: `{"schemaVersion":"v1","RunID":"abc","Added":true}`},
+		{name: "other version", report: `{"schemaVersion":"v2","RunID":"abc"}`, wantErr: ErrUnsupportedSchema},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ParseReport([]byte(tt.report))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (report.SchemaVersion != ReportSchemaVersion || report.RunID != "abc") {
+				t.Errorf("got version %q and run %q", report.SchemaVersion, report.RunID)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/discovery"
+)
+
+// ErrUnsupportedCluster is returned by a run refused by its server check, see WithServerCheck.
+var ErrUnsupportedCluster = errors.New("unsupported cluster")
+
+var (
+	// minServerVersion is the oldest Kubernetes version runs support, the first to serve policy/v1
+	// PodDisruptionBudgets
+	minServerVersion = version.MajorMinor(1, 21)
+	// newestServerVersion is the newest Kubernetes version the client-go the module is built with supports,
+	// one minor version newer than client-go's, to be moved along with client-go
+	newestServerVersion = version.MajorMinor(1, 34)
+	// serverSideApplyVersion is the Kubernetes version server-side apply went GA in
+	serverSideApplyVersion = version.MajorMinor(1, 22)
+)
+
+// requiredAPIs are the resources every run uses by group version, with the verbs it needs them to support.
+var requiredAPIs = map[string]map[string][]string{
+	"apps/v1": {
+		"deployments":  {"list", "patch", "watch"},
+		"statefulsets": {"list", "patch", "watch"},
+		"daemonsets":   {"list", "patch", "watch"},
+	},
+	"policy/v1": {
+		"poddisruptionbudgets": {"list"},
+	},
+}
+
+// WithServerCheck makes a run check the cluster it talks to before touching anything: that it runs a
+// supported Kubernetes version and serves the APIs runs use, and that the optional features the client is
+// configured with are available, server-side apply (see WithServerSideApply) and the policy/v1 Eviction API
+// pods are evicted with (see WithPods). An unsupported cluster fails the run right away with
+// ErrUnsupportedCluster and what to do about it, rather than with API errors midway through. A cluster
+// newer than the client is known to work with is only warned about.
+func WithServerCheck() Option {
+	return func(rc *rolloutClient) {
+		rc.serverCheck = true
+	}
+}
+
+// checkServer returns an ErrUnsupportedCluster error when the cluster doesn't support the run, see
+// WithServerCheck.
+func (rc *rolloutClient) checkServer(ctx context.Context) error {
+	log := rc.logger(ctx)
+	dc := rc.cs.Discovery()
+
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return fmt.Errorf("failed to get the cluster version: %w", err)
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return fmt.Errorf("failed to parse the cluster version: %w", err)
+	}
+	log.WithField("version", info.GitVersion).Info("Detected the cluster version")
+
+	if serverVersion.LessThan(minServerVersion) {
+		return fmt.Errorf("%w: it runs Kubernetes %s, runs need v%s or newer, upgrade the cluster or use an older release",
+			ErrUnsupportedCluster, info.GitVersion, minServerVersion)
+	}
+	if serverVersion.WithPatch(0).GreaterThan(newestServerVersion) {
+		log.WithFields(Fields{
+			"version": info.GitVersion,
+			"newest":  "v" + newestServerVersion.String(),
+		}).Warn("The cluster is newer than the Kubernetes versions this release is known to work with, consider upgrading it")
+	}
+
+	for _, groupVersion := range slices.Sorted(maps.Keys(requiredAPIs)) {
+		if err := checkServed(dc, groupVersion, requiredAPIs[groupVersion]); err != nil {
+			return err
+		}
+	}
+
+	if rc.serverSideApply && !rc.kubectlParity && serverVersion.LessThan(serverSideApplyVersion) {
+		return fmt.Errorf("%w: server-side apply needs Kubernetes v%s or newer, it runs %s, restart without it",
+			ErrUnsupportedCluster, serverSideApplyVersion, info.GitVersion)
+	}
+	if rc.restartStrategy() == EvictStrategy && rc.containerRestart == nil {
+		if ok, err := servesEviction(dc); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("%w: it doesn't serve the policy/v1 Eviction API pods are evicted with, restart whole workloads instead",
+				ErrUnsupportedCluster)
+		}
+	}
+	return nil
+}
+
+// checkServed returns an ErrUnsupportedCluster error naming the resources of groupVersion, or the verbs of
+// them, the cluster doesn't serve.
+func checkServed(dc discovery.ServerResourcesInterface, groupVersion string, resources map[string][]string) error {
+	list, err := dc.ServerResourcesForGroupVersion(groupVersion)
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("%w: it doesn't serve %s, upgrade the cluster or use an older release", ErrUnsupportedCluster, groupVersion)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to discover %s: %w", groupVersion, err)
+	}
+
+	var missing []string
+	for _, name := range slices.Sorted(maps.Keys(resources)) {
+		i := slices.IndexFunc(list.APIResources, func(r metav1.APIResource) bool { return r.Name == name })
+		if i < 0 {
+			missing = append(missing, groupVersion+" "+name)
+			continue
+		}
+		for _, verb := range resources[name] {
+			if !slices.Contains(list.APIResources[i].Verbs, verb) {
+				missing = append(missing, verb+" on "+groupVersion+" "+name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: it doesn't serve %s", ErrUnsupportedCluster, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// servesEviction reports whether the cluster serves the policy/v1 Eviction API, the pods/eviction
+// subresource, which is policy/v1beta1 before Kubernetes v1.22.
+func servesEviction(dc discovery.ServerResourcesInterface) (bool, error) {
+	list, err := dc.ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		return false, fmt.Errorf("failed to discover v1: %w", err)
+	}
+	return slices.ContainsFunc(list.APIResources, func(r metav1.APIResource) bool {
+		return r.Name == "pods/eviction" && r.Group == "policy" && r.Version == "v1"
+	}), nil
+}
@@ -0,0 +1,121 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// volumeSnapshots is the CSI VolumeSnapshot resource, accessed through the dynamic client so the module
+// doesn't depend on the external-snapshotter client.
+var volumeSnapshots = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+
+// snapshotter creates VolumeSnapshots of StatefulSet volumes before they are restarted.
+type snapshotter struct {
+	client dynamic.Interface
+	class  string
+}
+
+// WithVolumeSnapshots snapshots the PersistentVolumeClaims of every StatefulSet pod with a CSI VolumeSnapshot
+// before the StatefulSet is restarted, as a safety net for stateful restarts. The restart waits, bounded by
+// the backup timeout (see WithBackupTimeout), until every snapshot is ready to use and fails if one isn't.
+// An empty class uses the cluster's default VolumeSnapshotClass. The snapshots taken are listed in the
+// workload's result.
+func WithVolumeSnapshots(client dynamic.Interface, class string) Option {
+	return func(rc *rolloutClient) {
+		rc.snapshots = &snapshotter{client: client, class: class}
+	}
+}
+
+// snapshotVolumes snapshots the claims of each of the StatefulSet's pods and returns the snapshot names once
+// all are ready. Claims that don't exist, e.g. for pods not created yet, are skipped.
+func (rc *rolloutClient) snapshotVolumes(ctx context.Context, w workload) ([]string, error) {
+	sts, ok := w.object.(*appsv1.StatefulSet)
+	if !ok || len(sts.Spec.VolumeClaimTemplates) == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	for ordinal := range w.Replicas {
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			// StatefulSet claims are named <template>-<statefulset>-<ordinal>
+			claim := fmt.Sprintf("%s-%s-%d", template.Name, sts.Name, ordinal)
+
+			getCtx, cancel := rc.requestContext(ctx)
+			_, err := rc.cs.CoreV1().PersistentVolumeClaims(w.Namespace).Get(getCtx, claim, metav1.GetOptions{})
+			cancel()
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return names, fmt.Errorf("failed to get claim %s: %w", claim, err)
+			}
+
+			name, err := rc.createSnapshot(ctx, w.Namespace, claim)
+			if err != nil {
+				return names, fmt.Errorf("failed to snapshot claim %s: %w", claim, err)
+			}
+			names = append(names, name)
+			rc.logger(ctx).WithFields(Fields{"claim": claim, "snapshot": name}).Info("Created volume snapshot")
+		}
+	}
+
+	for _, name := range names {
+		if err := rc.waitForSnapshot(ctx, w.Namespace, name); err != nil {
+			return names, fmt.Errorf("snapshot %s: %w", name, err)
+		}
+	}
+	return names, nil
+}
+
+func (rc *rolloutClient) createSnapshot(ctx context.Context, namespace, claim string) (string, error) {
+	spec := map[string]any{
+		"source": map[string]any{"persistentVolumeClaimName": claim},
+	}
+	if rc.snapshots.class != "" {
+		spec["volumeSnapshotClassName"] = rc.snapshots.class
+	}
+	snapshot := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": volumeSnapshots.GroupVersion().String(),
+		"kind":       "VolumeSnapshot",
+		"metadata": map[string]any{
+			"generateName": claim + "-prerestart-",
+			"namespace":    namespace,
+		},
+		"spec": spec,
+	}}
+
+	createCtx, cancel := rc.requestContext(ctx)
+	defer cancel()
+	created, err := rc.snapshots.client.Resource(volumeSnapshots).Namespace(namespace).Create(createCtx, snapshot, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return created.GetName(), nil
+}
+
+// waitForSnapshot polls the snapshot until it is ready to use, failing on a snapshot error.
+func (rc *rolloutClient) waitForSnapshot(ctx context.Context, namespace, name string) error {
+	return rc.poll(ctx, backupPollInterval, true, func(ctx context.Context) (bool, error) {
+		getCtx, cancel := rc.requestContext(ctx)
+		defer cancel()
+		snapshot, err := rc.snapshots.client.Resource(volumeSnapshots).Namespace(namespace).Get(getCtx, name, metav1.GetOptions{})
+		if err != nil {
+			// Keep polling through transient errors, the backup timeout bounds the wait
+			rc.logger(ctx).WithError(err).WithField("snapshot", name).Warn("Failed to get volume snapshot status")
+			return false, nil
+		}
+
+		if message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); found {
+			return false, fmt.Errorf("snapshot failed: %s", message)
+		}
+		ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+		return ready, nil
+	})
+}
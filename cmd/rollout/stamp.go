@@ -0,0 +1,12 @@
+package rollout
+
+// WithTemplateStamp additionally sets annotations and labels on the pod template of every workload a run
+// restarts, e.g. the ones an organization's admission policies look for to let a change through during a
+// freeze. They are set in the same patch as the restartedAt annotation, which they can't override, and are
+// reverted with it by Undo. They are left out with WithKubectlParity, and restarts evicting pods, restarting
+// containers or going through an operator don't touch the pod template.
+func WithTemplateStamp(annotations, labels map[string]string) Option {
+	return func(rc *rolloutClient) {
+		rc.stampAnnotations, rc.stampLabels = annotations, labels
+	}
+}
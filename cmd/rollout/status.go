@@ -0,0 +1,110 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// WorkloadStatus is the rollout state of a matched workload.
+type WorkloadStatus struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Desired   int32
+	Ready     int32
+	Updated   int32
+	// LastRestart is when the workload's pods were last cycled, zero when it can't be determined
+	LastRestart time.Time
+}
+
+// Status reports the rollout state of every workload matching the podFilter, e.g. to follow a restart that
+// is still rolling. Nothing is modified.
+func (rc *rolloutClient) Status(ctx context.Context) ([]WorkloadStatus, error) {
+	namespaces, err := rc.namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []WorkloadStatus
+	for _, ns := range namespaces {
+		workloads, err := rc.listMatchingWorkloads(ctx, ns.Name)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", ns.Name, err)
+		}
+
+		for _, w := range workloads {
+			status := WorkloadStatus{
+				Kind:      w.Kind,
+				Namespace: w.Namespace,
+				Name:      w.Name,
+				Desired:   w.Replicas,
+			}
+			status.Ready, status.Updated = rolloutProgress(w)
+			if lastRestart, ok := w.lastRestart(); ok {
+				status.LastRestart = lastRestart
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// rolloutProgress returns how many of w's pods are ready and how many run the current pod template.
+func rolloutProgress(w workload) (ready, updated int32) {
+	switch obj := w.object.(type) {
+	case *appsv1.Deployment:
+		return obj.Status.ReadyReplicas, obj.Status.UpdatedReplicas
+	case *appsv1.StatefulSet:
+		return obj.Status.ReadyReplicas, obj.Status.UpdatedReplicas
+	case *appsv1.DaemonSet:
+		return obj.Status.NumberReady, obj.Status.UpdatedNumberScheduled
+	case *unstructured.Unstructured:
+		if w.Kind == argoRolloutKind {
+			status := argoStatus(obj)
+			return status.ReadyReplicas, status.UpdatedReplicas
+		}
+	}
+	return 0, 0
+}
+
+// paused reports whether w is a paused Deployment or Rollout, which doesn't roll out changes to its pod
+// template.
+func paused(w workload) bool {
+	switch obj := w.object.(type) {
+	case *appsv1.Deployment:
+		return obj.Spec.Paused
+	case *unstructured.Unstructured:
+		paused, _, _ := unstructured.NestedBool(obj.Object, "spec", "paused")
+		return w.Kind == argoRolloutKind && paused
+	}
+	return false
+}
+
+// rolledOut reports whether w's controller has caught up with its latest spec and every desired pod runs
+// the current template and is available, the same conditions kubectl rollout status waits for.
+func rolledOut(w workload) bool {
+	switch obj := w.object.(type) {
+	case *appsv1.Deployment:
+		return obj.Status.ObservedGeneration >= obj.Generation &&
+			obj.Status.UpdatedReplicas >= w.Replicas &&
+			obj.Status.Replicas <= obj.Status.UpdatedReplicas &&
+			obj.Status.AvailableReplicas >= obj.Status.UpdatedReplicas
+	case *appsv1.StatefulSet:
+		return obj.Status.ObservedGeneration >= obj.Generation &&
+			obj.Status.UpdatedReplicas >= w.Replicas &&
+			obj.Status.ReadyReplicas >= w.Replicas
+	case *appsv1.DaemonSet:
+		return obj.Status.ObservedGeneration >= obj.Generation &&
+			obj.Status.UpdatedNumberScheduled >= obj.Status.DesiredNumberScheduled &&
+			obj.Status.NumberAvailable >= obj.Status.DesiredNumberScheduled
+	case *unstructured.Unstructured:
+		if w.Kind == argoRolloutKind {
+			return argoRolledOut(w, obj)
+		}
+	}
+	return true
+}
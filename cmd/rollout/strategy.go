@@ -0,0 +1,200 @@
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Names of the restart strategies, see RestartStrategy.
+const (
+	StrategyRollout = "rollout"
+	StrategyEvict   = "evict"
+	StrategyScale   = "scale"
+)
+
+// scaleDownPollInterval is how often a workload scaled to zero is checked for having no pods left.
+const scaleDownPollInterval = 2 * time.Second
+
+// scaleDownTimeout is how long the pods of a workload scaled to zero may take to terminate.
+const scaleDownTimeout = 5 * time.Minute
+
+// RestartStrategy is how a restart replaces a workload's pods. The strategies are RolloutStrategy,
+// EvictStrategy and ScaleStrategy, pick one with WithRestartStrategy or ParseRestartStrategy, a Policy can
+// pick another for a workload by its name.
+type RestartStrategy interface {
+	// Name identifies the strategy in configuration and policy decisions
+	Name() string
+
+	// restart replaces the pods of w, recording in w what it changed, and reports false when w had none
+	// for it to replace
+	restart(ctx context.Context, rc *rolloutClient, w *workload) (bool, error)
+	// unavailable returns how many of w's pods the strategy takes down at once outside of the eviction API,
+	// which honours PodDisruptionBudgets by itself
+	unavailable(w workload) int
+}
+
+var (
+	// RolloutStrategy rolls the whole workload through its pod template, by patching the restartedAt
+	// annotation onto it as kubectl rollout restart does, its own way for Argo Rollouts, Jobs and workloads
+	// managed by a database operator. It is the default.
+	RolloutStrategy RestartStrategy = rolloutStrategy{}
+	// EvictStrategy evicts the workload's pods, those picked by WithPods or WithCordonedNodesOnly if
+	// configured, or restarts their containers in place with WithContainerRestart. Evictions respect
+	// PodDisruptionBudgets, and leave the workload itself untouched. It is the default when pods are picked.
+	EvictStrategy RestartStrategy = evictStrategy{}
+	// ScaleStrategy scales the workload to zero replicas, waits for its pods to terminate and scales it back,
+	// for workloads that can't run an old and a new pod side by side, e.g. ones holding an exclusive lock or
+	// a ReadWriteOnce volume. Every pod is down until the new ones are ready, only Deployments, StatefulSets
+	// and Argo Rollouts can be scaled.
+	ScaleStrategy RestartStrategy = scaleStrategy{}
+)
+
+// restartStrategies are the strategies ParseRestartStrategy knows.
+var restartStrategies = []RestartStrategy{RolloutStrategy, EvictStrategy, ScaleStrategy}
+
+// ParseRestartStrategy returns the strategy called name, StrategyRollout, StrategyEvict or StrategyScale.
+func ParseRestartStrategy(name string) (RestartStrategy, error) {
+	i := slices.IndexFunc(restartStrategies, func(s RestartStrategy) bool { return s.Name() == name })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown restart strategy %q, expected %s, %s or %s", name, StrategyRollout, StrategyEvict, StrategyScale)
+	}
+	return restartStrategies[i], nil
+}
+
+// WithRestartStrategy replaces the pods of workloads with strategy, instead of rolling them or, when pods
+// are picked (see WithPods), evicting them. A Policy can still pick another strategy per workload.
+func WithRestartStrategy(strategy RestartStrategy) Option {
+	return func(rc *rolloutClient) {
+		rc.strategy = strategy
+	}
+}
+
+// restartStrategy returns the strategy workloads are restarted with unless a policy picks another.
+func (rc *rolloutClient) restartStrategy() RestartStrategy {
+	switch {
+	case rc.strategy != nil:
+		return rc.strategy
+	case rc.pickPods != nil || rc.containerRestart != nil:
+		return EvictStrategy
+	default:
+		return RolloutStrategy
+	}
+}
+
+type rolloutStrategy struct{}
+
+func (rolloutStrategy) Name() string { return StrategyRollout }
+
+func (rolloutStrategy) restart(ctx context.Context, rc *rolloutClient, w *workload) (bool, error) {
+	var before []corev1.Pod
+	if rc.podDiffTimeout > 0 && rc.dryRun == DryRunNone {
+		var err error
+		if before, err = rc.listPods(ctx, *w); err != nil {
+			rc.logger(ctx).WithError(err).Warn("Failed to list pods, not diffing the new pod spec")
+		}
+	}
+
+	var err error
+	if h, owner, ok := rc.operatorFor(*w); ok {
+		err = rc.restartThroughOperator(ctx, *w, h, owner)
+	} else if w.Kind == argoRolloutKind {
+		err = rc.restartArgoRollout(ctx, *w)
+	} else if w.Kind == jobKind {
+		err = rc.recreateJob(ctx, *w)
+	} else {
+		err = rc.restartWorkload(ctx, w)
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if len(before) > 0 {
+		changes, diffErr := rc.diffNewPod(ctx, *w, before)
+		if diffErr != nil {
+			rc.logger(ctx).WithError(diffErr).Warnf("Failed to diff the new pod spec of %s", w.Kind)
+		}
+		w.PodChanges = changes
+	}
+	return true, nil
+}
+
+func (rolloutStrategy) unavailable(w workload) int { return unavailablePods(w) }
+
+type evictStrategy struct{}
+
+func (evictStrategy) Name() string { return StrategyEvict }
+
+func (evictStrategy) restart(ctx context.Context, rc *rolloutClient, w *workload) (bool, error) {
+	cycled, err := rc.restartPods(ctx, *w)
+	if err != nil {
+		return true, err
+	}
+	// Only the cycled pods are disrupted, the cost estimate is based on those
+	w.Replicas = int32(cycled)
+	return cycled > 0, nil
+}
+
+func (evictStrategy) unavailable(workload) int { return 0 }
+
+type scaleStrategy struct{}
+
+func (scaleStrategy) Name() string { return StrategyScale }
+
+func (scaleStrategy) restart(ctx context.Context, rc *rolloutClient, w *workload) (bool, error) {
+	if !slices.Contains([]string{"deployment", "statefulset", argoRolloutKind}, w.Kind) {
+		return true, fmt.Errorf("a %s can't be scaled, restart it with the %s strategy", w.Kind, StrategyRollout)
+	}
+	if _, _, ok := rc.operatorFor(*w); ok {
+		return true, fmt.Errorf("the %s is managed by its operator, which would scale it back, restart it with the %s strategy", w.Kind, StrategyRollout)
+	}
+	if w.Replicas == 0 {
+		return false, nil
+	}
+
+	if rc.dryRun == DryRunClient {
+		rc.logger(ctx).Infof("Dry run, would scale %s to 0 and back to %d", w.Kind, w.Replicas)
+		return true, nil
+	}
+	rc.logger(ctx).WithField("replicas", w.Replicas).Infof("Scaling %s to 0 and back", w.Kind)
+
+	// A workload that has been scaled down is scaled back even if the run is cancelled
+	ctx = context.WithoutCancel(ctx)
+	if err := rc.scaleWorkload(ctx, *w, 0); err != nil {
+		return true, fmt.Errorf("failed to scale to 0: %w", err)
+	}
+	if rc.dryRun == DryRunServer {
+		return true, nil
+	}
+
+	waitCtx, cancel := rc.withTimeout(ctx, scaleDownTimeout)
+	defer cancel()
+	waitErr := rc.poll(waitCtx, scaleDownPollInterval, true, func(ctx context.Context) (bool, error) {
+		pods, err := rc.listPods(ctx, *w)
+		return err == nil && len(pods) == 0, nil
+	})
+	if waitErr != nil {
+		rc.logger(ctx).WithError(waitErr).Warnf("Pods of %s didn't terminate within %s, scaling it back anyway", w.Kind, scaleDownTimeout)
+	}
+
+	if err := rc.scaleWorkload(ctx, *w, w.Replicas); err != nil {
+		return true, fmt.Errorf("failed to scale back to %d, it has to be scaled by hand: %w", w.Replicas, err)
+	}
+	return true, nil
+}
+
+func (scaleStrategy) unavailable(w workload) int { return int(w.Replicas) }
+
+// scaleWorkload sets the replicas of w through its scale subresource.
+func (rc *rolloutClient) scaleWorkload(ctx context.Context, w workload, replicas int32) error {
+	patch, err := json.Marshal(map[string]any{"spec": map[string]any{"replicas": replicas}})
+	if err != nil {
+		return err
+	}
+	return rc.patchWorkload(ctx, w, types.MergePatchType, patch, "scale")
+}
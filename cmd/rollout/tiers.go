@@ -0,0 +1,164 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// Tier is a group of namespaces restarted together, e.g. every dev namespace. Namespaces are glob patterns
+// (see path.Match), so "prod-*" covers every namespace starting with "prod-".
+type Tier struct {
+	Name       string   `json:"name"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// DefaultTierGateTimeout bounds how long the verification gate after a tier waits for its restarts to roll out.
+const DefaultTierGateTimeout = 15 * time.Minute
+
+// WithTiers restarts namespaces tier by tier in the given order, e.g. dev, then staging, then prod, instead
+// of all at once. Before moving on to the next tier every workload restarted in the previous one has to
+// have rolled out, and none may have failed, otherwise the run stops. Namespaces not covered by any tier
+// are restarted last.
+func WithTiers(tiers ...Tier) Option {
+	return func(rc *rolloutClient) {
+		rc.tiers = tiers
+	}
+}
+
+// WithTierGateTimeout bounds how long the verification gate between tiers waits for the restarted
+// workloads to roll out before stopping the run.
+func WithTierGateTimeout(timeout time.Duration) Option {
+	return func(rc *rolloutClient) {
+		rc.tierGateTimeout = timeout
+	}
+}
+
+// namespaceTier is a tier resolved to the namespaces it covers.
+type namespaceTier struct {
+	name       string
+	namespaces []corev1.Namespace
+}
+
+// groupByTier splits namespaces into the configured tiers, in tier order. A namespace belongs to the first
+// tier with a matching pattern, the ones matching none are grouped into a trailing unnamed tier. Without
+// configured tiers everything is a single unnamed tier.
+func (rc *rolloutClient) groupByTier(namespaces []corev1.Namespace) []namespaceTier {
+	tiers := make([]namespaceTier, len(rc.tiers))
+	for i, t := range rc.tiers {
+		tiers[i].name = t.Name
+	}
+
+	var untiered []corev1.Namespace
+	for _, ns := range namespaces {
+		if i := rc.tierOf(ns.Name); i >= 0 {
+			tiers[i].namespaces = append(tiers[i].namespaces, ns)
+		} else {
+			untiered = append(untiered, ns)
+		}
+	}
+	if len(untiered) > 0 {
+		tiers = append(tiers, namespaceTier{namespaces: untiered})
+	}
+	return tiers
+}
+
+// tierOf returns the index of the first tier with a pattern matching namespace, -1 when there is none.
+func (rc *rolloutClient) tierOf(namespace string) int {
+	for i, t := range rc.tiers {
+		if matchesAny(t.Namespaces, namespace) {
+			return i
+		}
+	}
+	return -1
+}
+
+// tierRank returns the position of namespace's tier in the restart order, namespaces outside every tier
+// come last.
+func (rc *rolloutClient) tierRank(namespace string) int {
+	if i := rc.tierOf(namespace); i >= 0 {
+		return i
+	}
+	return len(rc.tiers)
+}
+
+// gateTier is the verification gate after a tier. It fails when any restart in the tier failed, and
+// otherwise waits for every workload restarted in it to finish rolling out.
+func (rc *rolloutClient) gateTier(ctx context.Context, tier string, results []ResourceResult, restarted []workload) error {
+	for _, r := range results {
+		if r.Action == ActionFailed {
+			return fmt.Errorf("tier %s: %s %s/%s failed to restart", tier, r.Kind, r.Namespace, r.Name)
+		}
+	}
+	// Nothing rolls during a dry run
+	if len(restarted) == 0 || rc.dryRun != DryRunNone {
+		return nil
+	}
+
+	rc.logger(ctx).WithFields(Fields{
+		"tier":      tier,
+		"workloads": len(restarted),
+	}).Info("Waiting for tier to roll out before moving on")
+
+	if err := rc.waitRolledOut(ctx, restarted, rc.tierGateTimeout); err != nil {
+		return fmt.Errorf("tier %s: %w", tier, err)
+	}
+	return nil
+}
+
+// waitRolledOut waits for every workload in restarted to finish rolling out, for at most timeout.
+func (rc *rolloutClient) waitRolledOut(ctx context.Context, restarted []workload, timeout time.Duration) error {
+	ctx, cancel := rc.withTimeout(ctx, timeout)
+	defer cancel()
+
+	pending := restarted
+	err := rc.poll(ctx, rolloutPollInterval, true, func(ctx context.Context) (bool, error) {
+		var notDone []workload
+		for _, w := range pending {
+			current, err := rc.refresh(ctx, w)
+			if err != nil {
+				rc.logger(ctx).WithError(err).Warnf("Failed to check rollout of %s %s/%s, retrying", w.Kind, w.Namespace, w.Name)
+				notDone = append(notDone, w)
+				continue
+			}
+			if !rolledOut(current) {
+				notDone = append(notDone, w)
+			}
+		}
+		pending = notDone
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		w := pending[0]
+		return fmt.Errorf("%d workload(s) not rolled out, including %s %s/%s: %w", len(pending), w.Kind, w.Namespace, w.Name, err)
+	}
+	return nil
+}
+
+// refresh fetches the current state of w.
+func (rc *rolloutClient) refresh(ctx context.Context, w workload) (workload, error) {
+	for _, accessor := range rc.accessors() {
+		if accessor.kind != w.Kind {
+			continue
+		}
+
+		listCtx, cancel := rc.requestContext(ctx)
+		all, err := accessor.list(listCtx, w.Namespace, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", w.Name).String(),
+		})
+		cancel()
+		if err != nil {
+			return workload{}, err
+		}
+		for _, current := range all {
+			if current.Name == w.Name {
+				return current, nil
+			}
+		}
+	}
+	return workload{}, fmt.Errorf("%s %s/%s not found", w.Kind, w.Namespace, w.Name)
+}
@@ -0,0 +1,152 @@
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+// previousValues returns the values the keys of set have in values, nil for the ones not present, so an
+// empty value is told apart from an absent one.
+func previousValues(values, set map[string]string) map[string]*string {
+	previous := make(map[string]*string, len(set))
+	for key := range set {
+		if value, ok := values[key]; ok {
+			previous[key] = &value
+			continue
+		}
+		previous[key] = nil
+	}
+	return previous
+}
+
+// revertedValues returns the values reverting to previous sets, "" for the ones it removes.
+func revertedValues(previous map[string]*string) map[string]string {
+	values := make(map[string]string, len(previous))
+	for key, value := range previous {
+		values[key] = ptr.Deref(value, "")
+	}
+	return values
+}
+
+// Undo reverts the restarts of a previous run, e.g. the last one kept in the CLI's history: the pod
+// template annotations and labels each restarted workload was patched with are set back to their previous
+// values, or removed when they were absent. The template then matches the one before the restart again, so a
+// Deployment scales its previous ReplicaSet back up, the same as kubectl rollout undo, and StatefulSets and
+// DaemonSets roll back to their previous revision.
+//
+// Only workloads restarted by a plain restart can be undone, ones restarted by evicting pods, restarting
+// containers, through an operator or, for Argo Rollouts, through spec.restartAt are left alone. A workload restarted again since the run is skipped
+// with a warning, as undoing would also revert the later restart. Undo honours the client's dry run mode
+// and rollout wait, and reports like a run.
+func (rc *rolloutClient) Undo(ctx context.Context, previous *Report) (*Report, error) {
+	rc.startRecording(&rolloutMetadata{
+		RunID:     newRunID(),
+		StartTime: rc.clock.Now(),
+		Errors:    []error{},
+	})
+	ctx = withLogger(ctx, rc.log.WithField("run_id", rc.metadata.RunID))
+	rc.logger(ctx).WithField("undoing", previous.RunID).Info("Undoing the restarts of a previous run")
+	stopUsage := rc.trackUsage()
+
+	for _, r := range previous.Results {
+		if r.Action != ActionRestarted || len(r.SetAnnotations) == 0 {
+			continue
+		}
+		// Stop scheduling new restarts once the run has been cancelled
+		if ctx.Err() != nil {
+			break
+		}
+		rc.undo(ctx, r)
+	}
+
+	var err error
+	if ctx.Err() != nil {
+		rc.setCancelled(context.Cause(ctx))
+		err = fmt.Errorf("undo cancelled: %w", context.Cause(ctx))
+	} else if rc.failOnErrors {
+		err = rc.failures()
+	}
+	rc.setEnded(stopUsage())
+
+	report := rc.Report()
+	rc.logger(ctx).WithFields(Fields{
+		"reverted": report.Restarted,
+		"failed":   report.Failed,
+		"skipped":  report.Skipped,
+	}).Info("Undo completed")
+	return report, err
+}
+
+// undo reverts the restart of the workload r is the result of.
+func (rc *rolloutClient) undo(ctx context.Context, r ResourceResult) {
+	current, err := rc.refresh(ctx, workload{Kind: r.Kind, Namespace: r.Namespace, Name: r.Name})
+	if err != nil {
+		rc.addError(fmt.Errorf("%s %s/%s: %w", r.Kind, r.Namespace, r.Name, err))
+		rc.logger(ctx).WithError(err).Errorf("Failed to get %s %s/%s to undo its restart", r.Kind, r.Namespace, r.Name)
+		return
+	}
+
+	ctx = rc.resourceContext(ctx, current)
+	log := rc.logger(ctx)
+
+	if restartedAt := current.Template.Annotations[restartedAtAnnotation]; restartedAt != r.SetAnnotations[restartedAtAnnotation] {
+		log.WithField("restarted_at", restartedAt).Warnf("Not undoing %s, it was restarted again since", current.Kind)
+		rc.addWarning(current, WarningRestartedSince, "not undone, restarted again at "+restartedAt)
+		rc.recordResult(current, ActionSkipped, 0, nil)
+		return
+	}
+
+	// The reverted annotations and labels are recorded like a restart's, so the undo itself can be undone
+	current.SetAnnotations = revertedValues(r.PreviousAnnotations)
+	current.PreviousAnnotations = previousValues(current.Template.Annotations, current.SetAnnotations)
+	if len(r.PreviousLabels) > 0 {
+		current.SetLabels = revertedValues(r.PreviousLabels)
+		current.PreviousLabels = previousValues(current.Template.Labels, current.SetLabels)
+	}
+
+	start := rc.clock.Now()
+	if err := rc.revert(ctx, current, r.PreviousAnnotations, r.PreviousLabels); err != nil {
+		log.WithError(err).Errorf("Failed to undo the restart of %s", current.Kind)
+		rc.recordResult(current, ActionFailed, rc.clock.Since(start), err)
+		return
+	}
+	if rc.dryRun != DryRunNone {
+		rc.recordResult(current, ActionDryRun, rc.clock.Since(start), nil)
+		return
+	}
+
+	if rc.rolloutTimeout > 0 {
+		if err := rc.waitForRollout(ctx, current); err != nil && ctx.Err() == nil {
+			log.WithError(err).Errorf("%s did not finish rolling back", current.Kind)
+			rc.recordResult(current, ActionFailed, rc.clock.Since(start), err)
+			return
+		}
+	}
+	rc.recordResult(current, ActionRestarted, rc.clock.Since(start), nil)
+}
+
+// revert patches w's pod template annotations and labels back to previous and previousLabels, removing the
+// ones that were absent: their nil values are sent as null.
+func (rc *rolloutClient) revert(ctx context.Context, w workload, previous, previousLabels map[string]*string) error {
+	metadata := map[string]any{"annotations": previous}
+	if len(previousLabels) > 0 {
+		metadata["labels"] = previousLabels
+	}
+	patch, err := json.Marshal(map[string]any{"spec": templatePatch(w, metadata)})
+	if err != nil {
+		return err
+	}
+
+	if rc.dryRun == DryRunClient {
+		rc.logger(ctx).WithField("patch", string(patch)).Infof("Dry run, would undo the restart of %s", w.Kind)
+		return nil
+	}
+	rc.logger(ctx).Infof("Undoing the restart of %s", w.Kind)
+
+	// A patch that has already been scheduled is allowed to finish even if the run is cancelled
+	return rc.patchWorkload(context.WithoutCancel(ctx), w, types.StrategicMergePatchType, patch)
+}
@@ -0,0 +1,164 @@
+package rollout
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+)
+
+func TestUndo(t *testing.T) {
+	restartedAt := "2024-05-06T07:08:09Z"
+	// The undone run set restartedAt, absent before, and an owner annotation that was empty
+	undone := ResourceResult{
+		Kind:                "deployment",
+		Namespace:           "default",
+		Name:                "web",
+		Action:              ActionRestarted,
+		SetAnnotations:      map[string]string{restartedAtAnnotation: restartedAt, "example.com/owner": "ops"},
+		PreviousAnnotations: map[string]*string{restartedAtAnnotation: nil, "example.com/owner": ptr.To("")},
+	}
+	revertPatch := `{"spec":{"template":{"metadata":{"annotations":{"example.com/owner":"","kubectl.kubernetes.io/restartedAt":null}}}}}`
+
+	tests := []struct {
+		name string
+		opts []Option
+		// annotations are the deployment's pod template annotations when undoing
+		annotations     map[string]string
+		result          ResourceResult
+		wantPatches     []string
+		wantDryRun      bool
+		wantAction      string
+		wantAnnotations map[string]string
+		wantWarning     string
+	}{
+		{
+			name:            "reverts the restart",
+			annotations:     map[string]string{restartedAtAnnotation: restartedAt, "example.com/owner": "ops"},
+			result:          undone,
+			wantPatches:     []string{revertPatch},
+			wantAction:      ActionRestarted,
+			wantAnnotations: map[string]string{"example.com/owner": ""},
+		},
+		{
+			name:        "restores an earlier restart",
+			annotations: map[string]string{restartedAtAnnotation: restartedAt},
+			result: ResourceResult{
+				Kind:                "deployment",
+				Namespace:           "default",
+				Name:                "web",
+				Action:              ActionRestarted,
+				SetAnnotations:      map[string]string{restartedAtAnnotation: restartedAt},
+				PreviousAnnotations: map[string]*string{restartedAtAnnotation: ptr.To("2024-01-01T00:00:00Z")},
+			},
+			wantPatches:     []string{`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":"2024-01-01T00:00:00Z"}}}}}`},
+			wantAction:      ActionRestarted,
+			wantAnnotations: map[string]string{restartedAtAnnotation: "2024-01-01T00:00:00Z"},
+		},
+		{
+			name:            "skips a workload restarted since",
+			annotations:     map[string]string{restartedAtAnnotation: "2024-05-07T00:00:00Z", "example.com/owner": "ops"},
+			result:          undone,
+			wantAction:      ActionSkipped,
+			wantAnnotations: map[string]string{restartedAtAnnotation: "2024-05-07T00:00:00Z", "example.com/owner": "ops"},
+			wantWarning:     WarningRestartedSince,
+		},
+		{
+			name:            "client dry run",
+			opts:            []Option{WithDryRun(DryRunClient)},
+			annotations:     map[string]string{restartedAtAnnotation: restartedAt, "example.com/owner": "ops"},
+			result:          undone,
+			wantAction:      ActionDryRun,
+			wantAnnotations: map[string]string{restartedAtAnnotation: restartedAt, "example.com/owner": "ops"},
+		},
+		{
+			name:        "server dry run",
+			opts:        []Option{WithDryRun(DryRunServer)},
+			annotations: map[string]string{restartedAtAnnotation: restartedAt, "example.com/owner": "ops"},
+			result:      undone,
+			wantPatches: []string{revertPatch},
+			wantDryRun:  true,
+			wantAction:  ActionDryRun,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := *testTemplate.DeepCopy()
+			template.Annotations = tt.annotations
+			cs := fake.NewSimpleClientset(&appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Selector: testSelector, Template: template}})
+			rc := newEmbeddedClient(cs, "", append([]Option{WithClock(clocktesting.NewFakeClock(testNow))}, tt.opts...))
+
+			report, err := rc.Undo(context.Background(), &Report{RunID: "undone", Results: []ResourceResult{tt.result}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := patches(cs); !slices.Equal(got, tt.wantPatches) {
+				t.Errorf("got patches %q, want %q", got, tt.wantPatches)
+			}
+			for _, action := range cs.Actions() {
+				if patch, ok := action.(k8stesting.PatchActionImpl); ok {
+					if got := len(patch.GetPatchOptions().DryRun) > 0; got != tt.wantDryRun {
+						t.Errorf("got a patch with dryRun %v, want a dry run: %t", patch.GetPatchOptions().DryRun, tt.wantDryRun)
+					}
+				}
+			}
+			if len(report.Results) != 1 || report.Results[0].Action != tt.wantAction {
+				t.Fatalf("got results %+v, want the workload %s", report.Results, tt.wantAction)
+			}
+			if tt.wantAnnotations != nil {
+				d, err := cs.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := d.Spec.Template.Annotations; !maps.Equal(got, tt.wantAnnotations) {
+					t.Errorf("got annotations %v, want %v", got, tt.wantAnnotations)
+				}
+			}
+			if tt.wantWarning == "" && len(report.Warnings) > 0 {
+				t.Errorf("got warnings %v, want none", report.Warnings)
+			}
+			if tt.wantWarning != "" && (len(report.Warnings) != 1 || report.Warnings[0].Reason != tt.wantWarning) {
+				t.Errorf("got warnings %v, want a %s one", report.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestUndoCanBeUndone(t *testing.T) {
+	template := *testTemplate.DeepCopy()
+	template.Annotations = map[string]string{restartedAtAnnotation: "2024-05-06T07:08:09Z"}
+	cs := fake.NewSimpleClientset(&appsv1.Deployment{ObjectMeta: testMeta("web"), Spec: appsv1.DeploymentSpec{Selector: testSelector, Template: template}})
+	rc := newEmbeddedClient(cs, "", []Option{WithClock(clocktesting.NewFakeClock(testNow))})
+
+	undo, err := rc.Undo(context.Background(), &Report{RunID: "undone", Results: []ResourceResult{{
+		Kind:                "deployment",
+		Namespace:           "default",
+		Name:                "web",
+		Action:              ActionRestarted,
+		SetAnnotations:      map[string]string{restartedAtAnnotation: "2024-05-06T07:08:09Z"},
+		PreviousAnnotations: map[string]*string{restartedAtAnnotation: nil},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.Undo(context.Background(), undo); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := cs.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Spec.Template.Annotations[restartedAtAnnotation]; got != "2024-05-06T07:08:09Z" {
+		t.Errorf("got restartedAt %q, want the undone restart's back", got)
+	}
+}
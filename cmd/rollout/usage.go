@@ -0,0 +1,156 @@
+package rollout
+
+import (
+	"io"
+	"net/http"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// usageSampleInterval is how often the memory in use is sampled during a run, for its peak.
+const usageSampleInterval = 250 * time.Millisecond
+
+// Usage is the client's own footprint during a run: the API requests it made, the bytes it sent and
+// received with them, and the peak memory the process had in use.
+type Usage struct {
+	APIRequests     int64
+	BytesSent       int64
+	BytesReceived   int64
+	PeakMemoryBytes uint64
+}
+
+// UsageTracker counts the requests made through the transports it wraps, see WrapTransport. It is shared
+// by every client of a connection and safe for concurrent use.
+type UsageTracker struct {
+	requests atomic.Int64
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// NewUsageTracker returns a tracker that hasn't counted anything yet.
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{}
+}
+
+// WrapTransport returns rt counting its requests and bytes in the tracker, to be installed with
+// rest.Config.Wrap before the clients are created from the config.
+func (t *UsageTracker) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &countingTransport{next: rt, tracker: t}
+}
+
+// Requests returns how many requests the tracker has counted.
+func (t *UsageTracker) Requests() int64 {
+	return t.requests.Load()
+}
+
+// BytesSent returns how many request body bytes the tracker has counted.
+func (t *UsageTracker) BytesSent() int64 {
+	return t.sent.Load()
+}
+
+// BytesReceived returns how many response body bytes the tracker has counted.
+func (t *UsageTracker) BytesReceived() int64 {
+	return t.received.Load()
+}
+
+// WithUsageTracker reports the API requests counted by tracker during each run in its Report, along with
+// the peak memory in use, see Usage. tracker must wrap the transport of the client's clientset.
+func WithUsageTracker(tracker *UsageTracker) Option {
+	return func(rc *rolloutClient) {
+		rc.usage = tracker
+	}
+}
+
+// countingTransport counts the requests it sends and the bytes of their bodies.
+type countingTransport struct {
+	next    http.RoundTripper
+	tracker *UsageTracker
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.tracker.requests.Add(1)
+	if req.ContentLength > 0 {
+		c.tracker.sent.Add(req.ContentLength)
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	// The connection of an upgraded response, e.g. an exec stream, is taken over by its caller
+	if err != nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		return resp, err
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, received: &c.tracker.received}
+	return resp, nil
+}
+
+// countingBody counts the bytes read from a response body.
+type countingBody struct {
+	io.ReadCloser
+	received *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.received.Add(int64(n))
+	return n, err
+}
+
+// trackUsage starts measuring the run's usage, returning a function that stops and returns it, nil
+// without a tracker.
+func (rc *rolloutClient) trackUsage() func() *Usage {
+	if rc.usage == nil {
+		return func() *Usage { return nil }
+	}
+
+	requests, sent, received := rc.usage.Requests(), rc.usage.BytesSent(), rc.usage.BytesReceived()
+	var peak atomic.Uint64
+	sample := func() {
+		if used := memoryInUse(); used > peak.Load() {
+			peak.Store(used)
+		}
+	}
+	sample()
+
+	ticker := rc.clock.NewTicker(usageSampleInterval)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C():
+				sample()
+			}
+		}
+	}()
+
+	return func() *Usage {
+		close(done)
+		ticker.Stop()
+		wg.Wait()
+		sample()
+		return &Usage{
+			APIRequests:     rc.usage.Requests() - requests,
+			BytesSent:       rc.usage.BytesSent() - sent,
+			BytesReceived:   rc.usage.BytesReceived() - received,
+			PeakMemoryBytes: peak.Load(),
+		}
+	}
+}
+
+// memoryInUse returns the memory the Go runtime has mapped and not released back to the OS.
+func memoryInUse() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
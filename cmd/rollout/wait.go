@@ -0,0 +1,43 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// rolloutPollInterval is how often a restarted workload's status is checked while waiting for it to roll out.
+const rolloutPollInterval = 5 * time.Second
+
+// WithWaitForRollout waits, after restarting each workload, until its controller has observed the change and
+// every desired pod is updated and ready, for at most timeout. A workload that doesn't finish rolling out
+// in time is recorded as failed instead of restarted. A timeout <= 0 doesn't wait.
+func WithWaitForRollout(timeout time.Duration) Option {
+	return func(rc *rolloutClient) {
+		rc.rolloutTimeout = timeout
+	}
+}
+
+// waitForRollout polls w until it has rolled out (see rolledOut) or the rollout timeout expires.
+func (rc *rolloutClient) waitForRollout(ctx context.Context, w workload) error {
+	rc.logger(ctx).Infof("Waiting for %s to roll out", w.Kind)
+
+	ctx, cancel := rc.withTimeout(ctx, rc.rolloutTimeout)
+	defer cancel()
+
+	var last workload
+	err := rc.poll(ctx, rolloutPollInterval, false, func(ctx context.Context) (bool, error) {
+		current, err := rc.refresh(ctx, w)
+		if err != nil {
+			rc.logger(ctx).WithError(err).Warnf("Failed to check rollout of %s, retrying", w.Kind)
+			return false, nil
+		}
+		last = current
+		return rolledOut(current), nil
+	})
+	if err != nil {
+		ready, updated := rolloutProgress(last)
+		return fmt.Errorf("rollout not complete after %s, %d updated and %d ready of %d: %w", rc.rolloutTimeout, updated, ready, w.Replicas, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package rollout
+
+// Reasons a workload is reported as needing attention. Unlike errors, warnings don't mean something failed,
+// they point at workloads an operator should look at, e.g. ones skipped for safety.
+const (
+	WarningHighRisk     = "high-risk"
+	WarningLocalData    = "local-data"
+	WarningPolicyDenied = "policy-denied"
+	WarningQuota        = "quota"
+	WarningPDB          = "pdb"
+	WarningPaused       = "paused"
+	// WarningFrozen is a namespace skipped because of its FreezeAnnotation, the warning has no kind or name
+	WarningFrozen = "frozen"
+	// WarningRestartedSince is an Undo skipping a workload restarted again after the undone run
+	WarningRestartedSince = "restarted-since"
+)
+
+// Warning is a workload that needs attention after a run.
+type Warning struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Reason    string
+	Message   string
+}
+
+// Warnings returns the warnings of the last Run, the ones so far while it is still in progress.
+func (rc *rolloutClient) Warnings() []Warning {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.warnings()
+}
+
+// warnings returns a copy of the run's warnings, rc.mu must be held.
+func (rc *rolloutClient) warnings() []Warning {
+	if rc.metadata == nil {
+		return nil
+	}
+	return append([]Warning(nil), rc.metadata.Warnings...)
+}
+
+// addWarning records a warning about w in the run's metadata, it is logged by the caller.
+func (rc *rolloutClient) addWarning(w workload, reason, message string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.metadata.Warnings = append(rc.metadata.Warnings, Warning{
+		Kind:      w.Kind,
+		Namespace: w.Namespace,
+		Name:      w.Name,
+		Reason:    reason,
+		Message:   message,
+	})
+}
@@ -0,0 +1,122 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// restartedAtAnnotation is the pod template annotation kubectl rollout restart uses to trigger a rollout.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// workload is a kind agnostic view of a Deployment, StatefulSet or DaemonSet, produced by the kind's
+// accessor. Template points into the underlying object, so changes made to it are sent to the cluster
+// by update.
+type workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Created   time.Time
+	Selector  *metav1.LabelSelector
+	Template  *corev1.PodTemplateSpec
+	Replicas  int32
+
+	// Restart risk score and the factors contributing to it, see assessRisk
+	Risk        int
+	RiskFactors []string
+
+	// Names of the VolumeSnapshots taken before the restart
+	Snapshots []string
+	// Notable differences of the first new pod's spec from the replaced pods, see WithPodSpecDiff
+	PodChanges []string
+	// Pod template annotations and labels the restart set and the values they had before, see Undo
+	SetAnnotations      map[string]string
+	PreviousAnnotations map[string]*string
+	SetLabels           map[string]string
+	PreviousLabels      map[string]*string
+
+	// reloadReason names the changed ConfigMaps and Secrets a reload restarts the workload for
+	reloadReason string
+
+	object metav1.Object
+	gvk    schema.GroupVersionKind
+	patch  func(ctx context.Context, pt types.PatchType, patch []byte, opts metav1.PatchOptions, subresources ...string) error
+}
+
+// lastRestart returns when the workload's pods were last cycled, the restartedAt annotation if set,
+// otherwise the workload's creation time. ok is false if the annotation is present but unparseable.
+func (w workload) lastRestart() (t time.Time, ok bool) {
+	value, found := w.Template.Annotations[restartedAtAnnotation]
+	if !found {
+		return w.Created, true
+	}
+
+	restartedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if restartedAt.Before(w.Created) {
+		return w.Created, true
+	}
+	return restartedAt, true
+}
+
+// WithLabelSelector restricts the client to workloads whose labels match selector, e.g.
+// "app.kubernetes.io/part-of=payments", in addition to the podFilter. The selector is evaluated by the API
+// server when listing, pass an empty podFilter to select by labels alone.
+func WithLabelSelector(selector string) Option {
+	return func(rc *rolloutClient) {
+		rc.labelSelector = selector
+	}
+}
+
+// WithExcludedNames skips workloads whose name matches any of the glob patterns (see path.Match), e.g.
+// "nginx-canary" or "*-canary", even though they match the podFilter. It applies to every workload kind.
+func WithExcludedNames(patterns ...string) Option {
+	return func(rc *rolloutClient) {
+		rc.excludeNames = nonEmpty(patterns)
+	}
+}
+
+// listOptions returns the options workloads are listed with.
+func (rc *rolloutClient) listOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: rc.labelSelector}
+}
+
+// matches reports whether w is in scope of the run, its name containing the podFilter but no exclude
+// pattern and, when a team is set, it being owned by that team. The label selector is already applied when
+// listing. A run applying a plan is further limited to the planned workloads.
+func (rc *rolloutClient) matches(w workload) bool {
+	if !strings.Contains(strings.ToLower(w.Name), rc.podFilter) || matchesAny(rc.excludeNames, w.Name) {
+		return false
+	}
+	if rc.planned != nil && !rc.planned[checkpointKey(w.Kind, w.Namespace, w.Name)] {
+		return false
+	}
+	return rc.team == "" || rc.ownerOf(w.object) == rc.team
+}
+
+// listMatchingWorkloads returns every workload of each supported kind in namespace that matches the
+// podFilter, label selector and team.
+func (rc *rolloutClient) listMatchingWorkloads(ctx context.Context, namespace string) ([]workload, error) {
+	var workloads []workload
+	for _, accessor := range rc.accessors() {
+		all, err := rc.listWorkloads(ctx, accessor, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %ss: %w", accessor.kind, err)
+		}
+
+		for _, w := range all {
+			if rc.matches(w) {
+				workloads = append(workloads, w)
+			}
+		}
+	}
+	return workloads, nil
+}
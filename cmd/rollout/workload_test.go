@@ -0,0 +1,33 @@
+package rollout
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		exclude []string
+		want    bool
+	}{
+		{name: "web-api", want: true},
+		{name: "web-api", filter: "web", want: true},
+		{name: "Web-API", filter: "api", want: true},
+		{name: "web-api", filter: "API", want: true},
+		{name: "WEB-API", filter: "Web", want: true},
+		{name: "web-api", filter: "db"},
+		{name: "web-canary", filter: "web", exclude: []string{"*-canary"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.filter, func(t *testing.T) {
+			rc := newEmbeddedClient(fake.NewSimpleClientset(), tt.filter, []Option{WithExcludedNames(tt.exclude...)})
+			if got := rc.matches(workload{Name: tt.name}); got != tt.want {
+				t.Errorf("got match %t, want %t", got, tt.want)
+			}
+		})
+	}
+}